@@ -6,29 +6,53 @@ package main
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
 
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagecustomizerapi"
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/exe"
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/logger"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/sliceutils"
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/timestamp"
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/pkg/imagecustomizerlib"
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/pkg/profile"
 	"gopkg.in/alecthomas/kingpin.v2"
 )
 
+var (
+	supportedOutputImageFormats = []string{"vhd", "vhdx", "qcow2", "raw", "raw-sparse"}
+)
+
 var (
 	app = kingpin.New("imagecustomizer", "Customizes a pre-built CBL-Mariner image")
 
-	buildDir                    = app.Flag("build-dir", "Directory to run build out of.").Required().String()
-	imageFile                   = app.Flag("image-file", "Path of the base CBL-Mariner image which the customization will be applied to.").Required().String()
-	outputImageFile             = app.Flag("output-image-file", "Path to write the customized image to.").Required().String()
-	outputImageFormat           = app.Flag("output-image-format", "Format of output image. Supported: vhd, vhdx, qcow2, raw.").Enum("vhd", "vhdx", "qcow2", "raw")
+	buildDir                    = app.Flag("build-dir", "Directory to run build out of.").String()
+	imageFile                   = app.Flag("image-file", "Path of the base CBL-Mariner image which the customization will be applied to. If not specified, a new image is created from scratch using the config's Disks.").String()
+	outputImageFile             = app.Flag("output-image-file", "Path to write the customized image to.").String()
+	outputImageFormat           = app.Flag("output-image-format", "Format of output image. Supported: vhd, vhdx, qcow2, raw, raw-sparse (case-insensitive).").String()
+	compressOutput              = app.Flag("compress-output", "Compress the raw output image. The compression type's file extension is added to the output image file's name.").Bool()
+	compressionType             = app.Flag("compression-type", "Compression type used by --compress-output. Supported: zstd, gzip.").Default("zstd").Enum("zstd", "gzip")
+	outputImagePreallocation    = app.Flag("output-image-preallocation", "Preallocation mode of the output image. Supported: off (the only mode for raw-sparse), metadata (qcow2 only), falloc (raw and qcow2 only), full.").Enum("off", "metadata", "falloc", "full")
 	outputSplitPartitionsFormat = app.Flag("output-split-partitions-format", "Format of partition files. Supported: raw, raw-zstd").Enum("raw", "raw-zstd")
-	configFile                  = app.Flag("config-file", "Path of the image customization config file.").Required().String()
-	rpmSources                  = app.Flag("rpm-source", "Path to a RPM repo config file or a directory containing RPMs.").Strings()
+	configFiles                 = app.Flag("config-file", "Path of the image customization config file. May be specified multiple times, in which case the configs are merged in order.").Required().Strings()
+	mergeLists                  = app.Flag("merge-lists", "How the lists of later --config-file files are merged. Supported: append, replace.").Default("append").Enum("append", "replace")
+	expandEnvVars               = app.Flag("expand-env-vars", "Substitute ${VAR} environment variable references in the --config-file files.").Bool()
+	configProfile               = app.Flag("profile", "Name of the config's profile to merge into the config.").String()
+	rpmSources                  = app.Flag("rpm-source", "Path to a RPM repo config file, a directory containing RPMs, a tarball of RPMs, an RPM file, or the URL of a remote RPM repo. A path may be suffixed with =PRIORITY (e.g. /rpms=10), where higher priorities take precedence.").Strings()
 	disableBaseImageRpmRepos    = app.Flag("disable-base-image-rpm-repos", "Disable the base image's RPM repos as an RPM source").Bool()
+	preserveRpmSourceDirs       = app.Flag("preserve-rpm-source-dirs", "Create the RPM repo metadata for --rpm-source directories in the build directory, instead of within the directories themselves.").Bool()
+	repoSigningKey              = app.Flag("repo-signing-key", "Path to a GPG secret key file used to sign the metadata of the RPM repos created from --rpm-source directories and tarballs.").String()
+	force                       = app.Flag("force", "Overwrite the output image file if it already exists.").Bool()
+	checkFilesystems            = app.Flag("check-filesystems", "Run a read-only filesystem check (e.g. fsck) on each of the image's partitions after customization.").Bool()
+	trimFilesystems             = app.Flag("trim-filesystems", "Discard the unused blocks of the image's filesystems before writing the output image, so that the output image is smaller.").Bool()
+	verifyRootfs                = app.Flag("verify-rootfs", "After customization, mount the image's filesystems read-only and check that the OS's init and kernel files exist.").Bool()
+	packageManifest             = app.Flag("package-manifest", "Path to write the sorted list of the image's installed packages (name-version-release) to.").String()
+	extractedRpmsCacheMaxSize   = app.Flag("extracted-rpms-cache-max-size", "Maximum size (in MiB) of the build directory's cache of extracted RPM tarballs. 0 means no limit.").Default(strconv.Itoa(imagecustomizerlib.DefaultExtractedRpmsCacheMaxSize)).Uint64()
 	logFlags                    = exe.SetupLogFlags(app)
 	profFlags                   = exe.SetupProfileFlags(app)
 	timestampFile               = app.Flag("timestamp-file", "File that stores timestamps for this program.").String()
+	validateOnly                = app.Flag("validate-only", "Validate the config (including the files it references) and exit, without customizing the image.").Bool()
+	dumpConfig                  = app.Flag("dump-config", "Print the merged and validated config as YAML to stdout and exit, without customizing the image.").Bool()
 )
 
 func main() {
@@ -36,12 +60,53 @@ func main() {
 
 	app.Version(imagecustomizerlib.ToolVersion)
 	kingpin.MustParse(app.Parse(os.Args[1:]))
-	if *outputSplitPartitionsFormat == "" && *outputImageFormat == "" {
-		kingpin.Fatalf("Either --output-image-format or --output-split-partitions-format must be specified.")
+
+	// Accept the output image format case-insensitively (e.g. VHD).
+	*outputImageFormat = strings.ToLower(*outputImageFormat)
+	if *outputImageFormat != "" && !sliceutils.Contains(supportedOutputImageFormats, *outputImageFormat,
+		sliceutils.StringMatch) {
+		kingpin.Fatalf("--output-image-format must be one of: %s (got '%s').",
+			strings.Join(supportedOutputImageFormats, ", "), *outputImageFormat)
+	}
+
+	if *validateOnly && *dumpConfig {
+		kingpin.Fatalf("--validate-only and --dump-config must not both be specified.")
+	}
+	if !*validateOnly && !*dumpConfig {
+		if *buildDir == "" {
+			kingpin.Fatalf("--build-dir must be specified.")
+		}
+		if *outputImageFile == "" {
+			kingpin.Fatalf("--output-image-file must be specified.")
+		}
+		if *outputSplitPartitionsFormat == "" && *outputImageFormat == "" {
+			kingpin.Fatalf("Either --output-image-format or --output-split-partitions-format must be specified.")
+		}
 	}
 
 	logger.InitBestEffort(logFlags)
 
+	if *dumpConfig {
+		err = imagecustomizerlib.DumpConfigWithConfigFiles(*configFiles,
+			imagecustomizerapi.ListMergeStrategy(*mergeLists), *expandEnvVars, *configProfile, os.Stdout)
+		if err != nil {
+			log.Fatalf("failed to dump config: %v", err)
+		}
+		return
+	}
+
+	if *validateOnly {
+		err = imagecustomizerlib.ValidateConfigWithConfigFiles(*configFiles,
+			imagecustomizerapi.ListMergeStrategy(*mergeLists), *expandEnvVars, *configProfile, *imageFile,
+			*rpmSources, !*disableBaseImageRpmRepos)
+		if err != nil {
+			log.Fatalf("config validation failed: %v", err)
+		}
+
+		logger.Log.Infof("Config is valid")
+		return
+	}
+
 	prof, err := profile.StartProfiling(profFlags)
 	if err != nil {
 		logger.Log.Warnf("Could not start profiling: %s", err)
@@ -60,8 +125,27 @@ func main() {
 func customizeImage() error {
 	var err error
 
-	err = imagecustomizerlib.CustomizeImageWithConfigFile(*buildDir, *configFile, *imageFile,
-		*rpmSources, *outputImageFile, *outputImageFormat, *outputSplitPartitionsFormat, !*disableBaseImageRpmRepos)
+	outputImageCompression := ""
+	if *compressOutput {
+		outputImageCompression = *compressionType
+	}
+
+	options := imagecustomizerlib.CustomizeImageOptions{
+		PreserveRpmSourceDirs:     *preserveRpmSourceDirs,
+		RepoSigningKeyFile:        *repoSigningKey,
+		OverwriteOutput:           *force,
+		CheckFilesystems:          *checkFilesystems,
+		TrimFilesystems:           *trimFilesystems,
+		OutputImagePreallocation:  *outputImagePreallocation,
+		VerifyRootfs:              *verifyRootfs,
+		OutputImageCompression:    outputImageCompression,
+		PackageManifestFile:       *packageManifest,
+		ExtractedRpmsCacheMaxSize: *extractedRpmsCacheMaxSize,
+	}
+
+	err = imagecustomizerlib.CustomizeImageWithConfigFiles(*buildDir, *configFiles,
+		imagecustomizerapi.ListMergeStrategy(*mergeLists), *expandEnvVars, *configProfile, *imageFile, *rpmSources,
+		*outputImageFile, *outputImageFormat, *outputSplitPartitionsFormat, !*disableBaseImageRpmRepos, options)
 	if err != nil {
 		return err
 	}
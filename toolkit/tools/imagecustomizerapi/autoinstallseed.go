@@ -0,0 +1,61 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// AutoinstallSeedType is the format of an autoinstall seed.
+type AutoinstallSeedType string
+
+const (
+	// AutoinstallSeedTypeKickstart is a kickstart file used by anaconda-style installers.
+	AutoinstallSeedTypeKickstart AutoinstallSeedType = "kickstart"
+	// AutoinstallSeedTypeNoCloud is a cloud-init NoCloud seed directory.
+	AutoinstallSeedTypeNoCloud AutoinstallSeedType = "nocloud"
+)
+
+var (
+	autoinstallSeedTypeValues = []AutoinstallSeedType{AutoinstallSeedTypeKickstart, AutoinstallSeedTypeNoCloud}
+)
+
+func (t AutoinstallSeedType) IsValid() error {
+	switch t {
+	case AutoinstallSeedTypeKickstart, AutoinstallSeedTypeNoCloud:
+		// All good.
+		return nil
+
+	default:
+		return fmt.Errorf("invalid Type value (%s); must be one of: %s", t,
+			enumValuesString(autoinstallSeedTypeValues))
+	}
+}
+
+// AutoinstallSeed is an answer file that is baked into the image for installation flows that run at first boot.
+type AutoinstallSeed struct {
+	// The format of the seed.
+	Type AutoinstallSeedType `yaml:"Type"`
+	// The seed's source path, relative to the config file.
+	// For kickstart, this is a file. For nocloud, this is a directory containing the user-data and meta-data files.
+	Path string `yaml:"Path"`
+}
+
+func (s *AutoinstallSeed) IsValid() error {
+	err := s.Type.IsValid()
+	if err != nil {
+		return err
+	}
+
+	if s.Path == "" {
+		return fmt.Errorf("Path must be specified")
+	}
+
+	if !filepath.IsLocal(s.Path) {
+		return fmt.Errorf("invalid Path value (%s): must be a relative path under the config file's directory", s.Path)
+	}
+
+	return nil
+}
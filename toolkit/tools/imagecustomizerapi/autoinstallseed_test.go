@@ -0,0 +1,49 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAutoinstallSeedIsValidKickstart(t *testing.T) {
+	seed := AutoinstallSeed{
+		Type: AutoinstallSeedTypeKickstart,
+		Path: "files/ks.cfg",
+	}
+
+	err := seed.IsValid()
+	assert.NoError(t, err)
+}
+
+func TestAutoinstallSeedIsValidBadType(t *testing.T) {
+	seed := AutoinstallSeed{
+		Type: "preseed",
+		Path: "files/preseed.cfg",
+	}
+
+	err := seed.IsValid()
+	assert.ErrorContains(t, err, "invalid Type value (preseed)")
+}
+
+func TestAutoinstallSeedIsValidMissingPath(t *testing.T) {
+	seed := AutoinstallSeed{
+		Type: AutoinstallSeedTypeNoCloud,
+	}
+
+	err := seed.IsValid()
+	assert.ErrorContains(t, err, "Path must be specified")
+}
+
+func TestAutoinstallSeedIsValidPathOutsideConfigDir(t *testing.T) {
+	seed := AutoinstallSeed{
+		Type: AutoinstallSeedTypeNoCloud,
+		Path: "../seed",
+	}
+
+	err := seed.IsValid()
+	assert.ErrorContains(t, err, "must be a relative path under the config file's directory")
+}
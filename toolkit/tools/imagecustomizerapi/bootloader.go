@@ -0,0 +1,45 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Bootloader contains the grub menu settings.
+type Bootloader struct {
+	// The number of seconds the grub menu is shown for before the default entry is booted.
+	Timeout *int `yaml:"Timeout"`
+
+	// The grub menu entry to boot by default, specified by either its index (e.g. "0") or its title.
+	DefaultEntry string `yaml:"DefaultEntry"`
+
+	// The serial console to use for both grub and the kernel (e.g. "ttyS0,115200").
+	SerialConsole SerialConsole `yaml:"SerialConsole"`
+}
+
+func (b *Bootloader) IsValid() error {
+	if b.Timeout != nil && *b.Timeout < 0 {
+		return fmt.Errorf("invalid Timeout value (%d): must be a non-negative integer", *b.Timeout)
+	}
+
+	// Disallow special characters to avoid breaking the grub.cfg file.
+	if strings.ContainsAny(b.DefaultEntry, "\n'\"\\$`") {
+		return fmt.Errorf("the DefaultEntry value (%s) contains invalid characters", b.DefaultEntry)
+	}
+
+	if b.DefaultEntry != "" && strings.TrimSpace(b.DefaultEntry) != b.DefaultEntry {
+		return fmt.Errorf("the DefaultEntry value (%s) must not have leading or trailing whitespace", b.DefaultEntry)
+	}
+
+	if b.SerialConsole != "" {
+		err := b.SerialConsole.IsValid()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
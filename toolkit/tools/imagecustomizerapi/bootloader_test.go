@@ -0,0 +1,53 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"testing"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/ptrutils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBootloaderIsValid(t *testing.T) {
+	bootloader := Bootloader{
+		Timeout:      ptrutils.PtrTo(5),
+		DefaultEntry: "CBL-Mariner",
+	}
+
+	err := bootloader.IsValid()
+	assert.NoError(t, err)
+}
+
+func TestBootloaderIsValidZeroTimeout(t *testing.T) {
+	bootloader := Bootloader{
+		Timeout:      ptrutils.PtrTo(0),
+		DefaultEntry: "1",
+	}
+
+	err := bootloader.IsValid()
+	assert.NoError(t, err)
+}
+
+func TestBootloaderIsValidNegativeTimeout(t *testing.T) {
+	bootloader := Bootloader{
+		Timeout: ptrutils.PtrTo(-1),
+	}
+
+	err := bootloader.IsValid()
+	assert.ErrorContains(t, err, "must be a non-negative integer")
+}
+
+func TestBootloaderIsValidBadDefaultEntry(t *testing.T) {
+	bootloader := Bootloader{
+		DefaultEntry: "CBL-Mariner\"",
+	}
+
+	err := bootloader.IsValid()
+	assert.ErrorContains(t, err, "contains invalid characters")
+}
+
+func TestParseBootloaderInvalidTimeout(t *testing.T) {
+	testInvalidYamlValue[*Bootloader](t, "{ \"Timeout\": \"abc\" }")
+}
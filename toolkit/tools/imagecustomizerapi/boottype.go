@@ -12,16 +12,25 @@ type BootType string
 const (
 	BootTypeEfi    BootType = "efi"
 	BootTypeLegacy BootType = "legacy"
-	BootTypeUnset  BootType = ""
+
+	// BootTypeNone indicates that no bootloader is installed.
+	// This is useful for images that are used as data disks or that are booted by an external bootloader.
+	BootTypeNone BootType = "none"
+
+	BootTypeUnset BootType = ""
+)
+
+var (
+	bootTypeValues = []BootType{BootTypeEfi, BootTypeLegacy, BootTypeNone}
 )
 
 func (t BootType) IsValid() error {
 	switch t {
-	case BootTypeEfi, BootTypeLegacy, BootTypeUnset:
+	case BootTypeEfi, BootTypeLegacy, BootTypeNone, BootTypeUnset:
 		// All good.
 		return nil
 
 	default:
-		return fmt.Errorf("invalid BootType value (%v)", t)
+		return fmt.Errorf("invalid BootType value (%v); must be one of: %s", t, enumValuesString(bootTypeValues))
 	}
 }
@@ -4,86 +4,175 @@
 package imagecustomizerapi
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/sliceutils"
 )
 
 type Config struct {
-	Disks        *[]Disk      `yaml:"Disks"`
-	SystemConfig SystemConfig `yaml:"SystemConfig"`
+	Include      []string          `yaml:"Include"`
+	Profiles     map[string]Config `yaml:"Profiles"`
+	Disks        *[]Disk           `yaml:"Disks"`
+	SystemConfig SystemConfig      `yaml:"SystemConfig"`
 }
 
+// IsValid checks the config and returns all of the errors found, instead of just the first one.
 func (c *Config) IsValid() error {
+	var errs []error
+
+	// The includes are merged into the config when the config file is read. See UnmarshalAndMergeYamlFiles.
+	if len(c.Include) > 0 {
+		errs = append(errs, fmt.Errorf("Include is only supported in config files that are read using UnmarshalAndMergeYamlFiles"))
+	}
+
+	// The selected profile is merged into the config when the config file is read. See UnmarshalAndMergeYamlFiles.
+	if len(c.Profiles) > 0 {
+		errs = append(errs, fmt.Errorf("Profiles is only supported in config files that are read using UnmarshalAndMergeYamlFiles"))
+	}
+
 	if c.Disks != nil {
 		disks := *c.Disks
 		if len(disks) < 1 {
-			return fmt.Errorf("at least 1 disk must be specified (or the Disks field should be ommited)")
-		}
-		if len(disks) > 1 {
-			return fmt.Errorf("multiple disks is not currently supported")
+			errs = append(errs, fmt.Errorf("at least 1 disk must be specified (or the Disks field should be ommited)"))
 		}
 
+		// Partition IDs must be unique across all the disks, since PartitionSettings reference them by ID alone.
+		partitionIDDisks := make(map[string]int)
 		for i, disk := range disks {
 			err := disk.IsValid()
 			if err != nil {
-				return fmt.Errorf("invalid disk at index %d:\n%w", i, err)
+				errs = append(errs, fmt.Errorf("invalid disk at index %d:\n%w", i, err))
+			}
+
+			for _, partition := range disk.Partitions {
+				if existingDiskIndex, ok := partitionIDDisks[partition.ID]; ok && existingDiskIndex != i {
+					errs = append(errs, fmt.Errorf("duplicate partition ID (%s) used on disks at index %d and %d",
+						partition.ID, existingDiskIndex, i))
+					continue
+				}
+
+				partitionIDDisks[partition.ID] = i
 			}
 		}
 	}
 
 	err := c.SystemConfig.IsValid()
 	if err != nil {
-		return err
+		errs = append(errs, err)
 	}
 
 	hasDisks := c.Disks != nil
 	hasBootType := c.SystemConfig.BootType != BootTypeUnset
 	hasPartitionSettings := len(c.SystemConfig.PartitionSettings) > 0
+	hasDefaultMountIdentifier := c.SystemConfig.DefaultMountIdentifier != MountIdentifierTypeDefault
 
 	if hasDisks != hasBootType {
-		return fmt.Errorf("SystemConfig.BootType and Disks must be specified together")
+		errs = append(errs, fmt.Errorf("SystemConfig.BootType and Disks must be specified together"))
 	}
 
 	if hasPartitionSettings && !hasDisks {
-		return fmt.Errorf("the Disks and SystemConfig.BootType values must also be specified if SystemConfig.PartitionSettings is specified")
+		errs = append(errs, fmt.Errorf("the Disks and SystemConfig.BootType values must also be specified if SystemConfig.PartitionSettings is specified"))
 	}
 
-	// Ensure the correct partitions exist to support the specified the boot type.
+	if hasDefaultMountIdentifier && !hasPartitionSettings {
+		errs = append(errs, fmt.Errorf("SystemConfig.PartitionSettings must also be specified if SystemConfig.DefaultMountIdentifier is specified"))
+	}
+
+	if c.SystemConfig.BootType == BootTypeNone {
+		// Both the kernel command-line and the verity settings are applied through the bootloader's config.
+		if c.SystemConfig.KernelCommandLine.ExtraCommandLine != "" {
+			errs = append(errs, fmt.Errorf("SystemConfig.KernelCommandLine.ExtraCommandLine must not be specified when SystemConfig.BootType is 'none'"))
+		}
+
+		if c.SystemConfig.KernelCommandLine.LegacyNetworkInterfaceNames {
+			errs = append(errs, fmt.Errorf("SystemConfig.KernelCommandLine.LegacyNetworkInterfaceNames must not be enabled when SystemConfig.BootType is 'none'"))
+		}
+
+		if c.SystemConfig.Verity != nil {
+			errs = append(errs, fmt.Errorf("SystemConfig.Verity must not be specified when SystemConfig.BootType is 'none'"))
+		}
+
+		if c.SystemConfig.Bootloader != nil {
+			errs = append(errs, fmt.Errorf("SystemConfig.Bootloader must not be specified when SystemConfig.BootType is 'none'"))
+		}
+	}
+
+	// The remaining checks cross-reference the disks.
+	if !hasDisks {
+		return errors.Join(errs...)
+	}
+
+	if len(*c.Disks) < 1 {
+		return errors.Join(errs...)
+	}
+
+	// Ensure the correct partitions exist on the boot disk (i.e. the first disk) to support the specified the boot
+	// type.
+	// Note: BootTypeNone doesn't require any bootloader partitions.
+	bootDisk := &(*c.Disks)[0]
 	switch c.SystemConfig.BootType {
 	case BootTypeEfi:
-		hasEsp := sliceutils.ContainsFunc(*c.Disks, func(disk Disk) bool {
-			return sliceutils.ContainsFunc(disk.Partitions, func(partition Partition) bool {
-				return sliceutils.ContainsValue(partition.Flags, PartitionFlagESP)
-			})
+		hasEsp := sliceutils.ContainsFunc(bootDisk.Partitions, func(partition Partition) bool {
+			return sliceutils.ContainsValue(partition.Flags, PartitionFlagESP)
 		})
 		if !hasEsp {
-			return fmt.Errorf("'esp' partition must be provided for 'efi' boot type")
+			errs = append(errs, fmt.Errorf("'esp' partition must be provided on the first disk for 'efi' boot type"))
 		}
 
 	case BootTypeLegacy:
-		hasBiosBoot := sliceutils.ContainsFunc(*c.Disks, func(disk Disk) bool {
-			return sliceutils.ContainsFunc(disk.Partitions, func(partition Partition) bool {
-				return sliceutils.ContainsValue(partition.Flags, PartitionFlagBiosGrub)
-			})
+		// On MBR disks, grub is embedded in the gap after the MBR. So, a BIOS boot partition is only required on GPT
+		// disks.
+		hasBiosBoot := sliceutils.ContainsFunc(bootDisk.Partitions, func(partition Partition) bool {
+			return sliceutils.ContainsValue(partition.Flags, PartitionFlagBiosGrub)
 		})
-		if !hasBiosBoot {
-			return fmt.Errorf("'bios_grub' partition must be provided for 'legacy' boot type")
+		if bootDisk.PartitionTableType == PartitionTableTypeGpt && !hasBiosBoot {
+			errs = append(errs, fmt.Errorf("'bios_grub' partition must be provided on the first disk for 'legacy' boot type"))
 		}
 	}
 
 	// Ensure all the partition settings object have an equivalent partition object.
-	for i, partitionSetting := range c.SystemConfig.PartitionSettings {
-		diskExists := sliceutils.ContainsFunc(*c.Disks, func(disk Disk) bool {
-			return sliceutils.ContainsFunc(disk.Partitions, func(partition Partition) bool {
+	for i, partitionSetting := range c.SystemConfig.PartitionSettingsWithDefaults() {
+		var partitionDisk *Disk
+		for j := range *c.Disks {
+			disk := &(*c.Disks)[j]
+			if sliceutils.ContainsFunc(disk.Partitions, func(partition Partition) bool {
 				return partition.ID == partitionSetting.ID
-			})
-		})
-		if !diskExists {
-			return fmt.Errorf("invalid PartitionSetting at index %d:\nno partition with matching ID (%s)", i,
-				partitionSetting.ID)
+			}) {
+				partitionDisk = disk
+				break
+			}
+		}
+		if partitionDisk == nil {
+			errs = append(errs, fmt.Errorf("invalid PartitionSetting at index %d:\nno partition with matching ID (%s)", i,
+				partitionSetting.ID))
+			continue
+		}
+
+		// Unformatted partitions can't be mounted.
+		if sliceutils.ContainsFunc(partitionDisk.Partitions, func(partition Partition) bool {
+			return partition.ID == partitionSetting.ID && partition.FsType == FileSystemTypeNone
+		}) {
+			errs = append(errs, fmt.Errorf("invalid PartitionSetting at index %d:\npartition (%s) has 'none' FsType and so can't be mounted",
+				i, partitionSetting.ID))
+		}
+
+		// Unlocking encrypted partitions from the initramfs or the bootloader is not supported.
+		if (partitionSetting.MountPoint == "/" || partitionSetting.MountPoint == "/boot") &&
+			sliceutils.ContainsFunc(partitionDisk.Partitions, func(partition Partition) bool {
+				return partition.ID == partitionSetting.ID && partition.Luks != nil
+			}) {
+			errs = append(errs, fmt.Errorf("invalid PartitionSetting at index %d:\npartition (%s) is encrypted and so can't be mounted at (%s)",
+				i, partitionSetting.ID, partitionSetting.MountPoint))
+		}
+
+		// MBR partitions don't have labels.
+		if partitionDisk.PartitionTableType == PartitionTableTypeMbr &&
+			partitionSetting.MountIdentifier == MountIdentifierTypePartLabel {
+			errs = append(errs, fmt.Errorf("invalid PartitionSetting at index %d:\n'partlabel' MountIdentifier is not supported on MBR disks (%s)",
+				i, partitionSetting.ID))
 		}
 	}
 
-	return nil
+	return errors.Join(errs...)
 }
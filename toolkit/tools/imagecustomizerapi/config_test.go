@@ -6,6 +6,7 @@ package imagecustomizerapi
 import (
 	"testing"
 
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/ptrutils"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -97,21 +98,129 @@ func TestConfigIsValidMultipleDisks(t *testing.T) {
 		Disks: &[]Disk{
 			{
 				PartitionTableType: "gpt",
-				MaxSize:            1,
+				MaxSize:            2,
+				Partitions: []Partition{
+					{
+						ID:     "esp",
+						FsType: "fat32",
+						Start:  1,
+						Flags: []PartitionFlag{
+							"esp",
+							"boot",
+						},
+					},
+				},
 			},
 			{
 				PartitionTableType: "gpt",
-				MaxSize:            1,
+				MaxSize:            2,
+				Partitions: []Partition{
+					{
+						ID:     "data",
+						FsType: "ext4",
+						Start:  1,
+					},
+				},
 			},
 		},
 		SystemConfig: SystemConfig{
+			BootType: "efi",
 			Hostname: "test",
+			PartitionSettings: []PartitionSetting{
+				{
+					ID:         "esp",
+					MountPoint: "/boot/efi",
+				},
+				{
+					ID:         "data",
+					MountPoint: "/data",
+				},
+			},
 		},
 	}
 
 	err := config.IsValid()
-	assert.Error(t, err)
-	assert.ErrorContains(t, err, "multiple disks")
+	assert.NoError(t, err)
+}
+
+func TestConfigIsValidMultipleDisksDuplicatePartitionId(t *testing.T) {
+	config := &Config{
+		Disks: &[]Disk{
+			{
+				PartitionTableType: "gpt",
+				MaxSize:            2,
+				Partitions: []Partition{
+					{
+						ID:     "esp",
+						FsType: "fat32",
+						Start:  1,
+						Flags: []PartitionFlag{
+							"esp",
+							"boot",
+						},
+					},
+				},
+			},
+			{
+				PartitionTableType: "gpt",
+				MaxSize:            2,
+				Partitions: []Partition{
+					{
+						ID:     "esp",
+						FsType: "ext4",
+						Start:  1,
+					},
+				},
+			},
+		},
+		SystemConfig: SystemConfig{
+			BootType: "efi",
+			Hostname: "test",
+		},
+	}
+
+	err := config.IsValid()
+	assert.ErrorContains(t, err, "duplicate partition ID (esp) used on disks at index 0 and 1")
+}
+
+func TestConfigIsValidMultipleDisksEspNotOnFirstDisk(t *testing.T) {
+	config := &Config{
+		Disks: &[]Disk{
+			{
+				PartitionTableType: "gpt",
+				MaxSize:            2,
+				Partitions: []Partition{
+					{
+						ID:     "data",
+						FsType: "ext4",
+						Start:  1,
+					},
+				},
+			},
+			{
+				PartitionTableType: "gpt",
+				MaxSize:            2,
+				Partitions: []Partition{
+					{
+						ID:     "esp",
+						FsType: "fat32",
+						Start:  1,
+						Flags: []PartitionFlag{
+							"esp",
+							"boot",
+						},
+					},
+				},
+			},
+		},
+		SystemConfig: SystemConfig{
+			BootType: "efi",
+			Hostname: "test",
+		},
+	}
+
+	err := config.IsValid()
+	assert.ErrorContains(t, err, "'esp' partition must be provided on the first disk for 'efi' boot type")
 }
 
 func TestConfigIsValidZeroDisks(t *testing.T) {
@@ -331,3 +440,317 @@ func TestConfigIsValidKernelCLI(t *testing.T) {
 	err := config.IsValid()
 	assert.NoError(t, err)
 }
+
+func TestConfigIsValidBootTypeNone(t *testing.T) {
+	config := &Config{
+		Disks: &[]Disk{{
+			PartitionTableType: "gpt",
+			MaxSize:            2,
+			Partitions: []Partition{
+				{
+					ID:     "data",
+					FsType: "ext4",
+					Start:  1,
+				},
+			},
+		}},
+		SystemConfig: SystemConfig{
+			BootType: "none",
+			PartitionSettings: []PartitionSetting{
+				{
+					ID:         "data",
+					MountPoint: "/",
+				},
+			},
+		},
+	}
+
+	err := config.IsValid()
+	assert.NoError(t, err)
+}
+
+func TestConfigIsValidBootTypeNoneExtraCommandLine(t *testing.T) {
+	config := &Config{
+		Disks: &[]Disk{{
+			PartitionTableType: "gpt",
+			MaxSize:            2,
+			Partitions: []Partition{
+				{
+					ID:     "data",
+					FsType: "ext4",
+					Start:  1,
+				},
+			},
+		}},
+		SystemConfig: SystemConfig{
+			BootType: "none",
+			KernelCommandLine: KernelCommandLine{
+				ExtraCommandLine: "console=ttyS0",
+			},
+		},
+	}
+
+	err := config.IsValid()
+	assert.ErrorContains(t, err, "ExtraCommandLine must not be specified")
+}
+
+func TestConfigIsValidLegacyMbr(t *testing.T) {
+	config := &Config{
+		Disks: &[]Disk{{
+			PartitionTableType: "mbr",
+			MaxSize:            2,
+			Partitions: []Partition{
+				{
+					ID:     "rootfs",
+					FsType: "ext4",
+					Start:  1,
+					Flags: []PartitionFlag{
+						"boot",
+					},
+				},
+			},
+		}},
+		SystemConfig: SystemConfig{
+			BootType: "legacy",
+			PartitionSettings: []PartitionSetting{
+				{
+					ID:         "rootfs",
+					MountPoint: "/",
+				},
+			},
+		},
+	}
+
+	err := config.IsValid()
+	assert.NoError(t, err)
+}
+
+func TestConfigIsValidMbrPartLabel(t *testing.T) {
+	config := &Config{
+		Disks: &[]Disk{{
+			PartitionTableType: "mbr",
+			MaxSize:            2,
+			Partitions: []Partition{
+				{
+					ID:     "rootfs",
+					FsType: "ext4",
+					Start:  1,
+				},
+			},
+		}},
+		SystemConfig: SystemConfig{
+			BootType: "legacy",
+			PartitionSettings: []PartitionSetting{
+				{
+					ID:              "rootfs",
+					MountIdentifier: "partlabel",
+					MountPoint:      "/",
+				},
+			},
+		},
+	}
+
+	err := config.IsValid()
+	assert.ErrorContains(t, err, "'partlabel' MountIdentifier is not supported on MBR disks")
+}
+
+func TestConfigIsValidUnformattedPartition(t *testing.T) {
+	config := &Config{
+		Disks: &[]Disk{{
+			PartitionTableType: "gpt",
+			MaxSize:            4,
+			Partitions: []Partition{
+				{
+					ID:     "esp",
+					FsType: "fat32",
+					Start:  1,
+					End:    ptrutils.PtrTo(uint64(2)),
+					Flags: []PartitionFlag{
+						"esp",
+						"boot",
+					},
+				},
+				{
+					ID:     "rootfs",
+					FsType: "ext4",
+					Start:  2,
+					End:    ptrutils.PtrTo(uint64(3)),
+				},
+				{
+					ID:     "appdata",
+					FsType: "none",
+					Start:  3,
+				},
+			},
+		}},
+		SystemConfig: SystemConfig{
+			BootType: "efi",
+			PartitionSettings: []PartitionSetting{
+				{
+					ID:         "esp",
+					MountPoint: "/boot/efi",
+				},
+				{
+					ID:         "rootfs",
+					MountPoint: "/",
+				},
+			},
+		},
+	}
+
+	err := config.IsValid()
+	assert.NoError(t, err)
+}
+
+func TestConfigIsValidUnformattedPartitionMounted(t *testing.T) {
+	config := &Config{
+		Disks: &[]Disk{{
+			PartitionTableType: "gpt",
+			MaxSize:            4,
+			Partitions: []Partition{
+				{
+					ID:     "esp",
+					FsType: "fat32",
+					Start:  1,
+					End:    ptrutils.PtrTo(uint64(2)),
+					Flags: []PartitionFlag{
+						"esp",
+						"boot",
+					},
+				},
+				{
+					ID:     "appdata",
+					FsType: "none",
+					Start:  2,
+				},
+			},
+		}},
+		SystemConfig: SystemConfig{
+			BootType: "efi",
+			PartitionSettings: []PartitionSetting{
+				{
+					ID:         "appdata",
+					MountPoint: "/var/lib/app",
+				},
+			},
+		},
+	}
+
+	err := config.IsValid()
+	assert.ErrorContains(t, err, "partition (appdata) has 'none' FsType and so can't be mounted")
+}
+
+func TestConfigIsValidMbrDefaultMountIdentifierPartLabel(t *testing.T) {
+	config := &Config{
+		Disks: &[]Disk{{
+			PartitionTableType: "mbr",
+			MaxSize:            2,
+			Partitions: []Partition{
+				{
+					ID:     "rootfs",
+					FsType: "ext4",
+					Start:  1,
+				},
+			},
+		}},
+		SystemConfig: SystemConfig{
+			BootType:               "legacy",
+			DefaultMountIdentifier: "partlabel",
+			PartitionSettings: []PartitionSetting{
+				{
+					ID:         "rootfs",
+					MountPoint: "/",
+				},
+			},
+		},
+	}
+
+	err := config.IsValid()
+	assert.ErrorContains(t, err, "'partlabel' MountIdentifier is not supported on MBR disks")
+
+	// An explicit MountIdentifier overrides the default.
+	config.SystemConfig.PartitionSettings[0].MountIdentifier = "uuid"
+
+	err = config.IsValid()
+	assert.NoError(t, err)
+}
+
+func TestConfigIsValidDefaultMountIdentifierMissingPartitionSettings(t *testing.T) {
+	config := &Config{
+		SystemConfig: SystemConfig{
+			DefaultMountIdentifier: "uuid",
+		},
+	}
+
+	err := config.IsValid()
+	assert.ErrorContains(t, err, "SystemConfig.PartitionSettings must also be specified")
+}
+
+func TestConfigIsValidEncryptedRootfs(t *testing.T) {
+	config := &Config{
+		Disks: &[]Disk{{
+			PartitionTableType: "gpt",
+			MaxSize:            3,
+			Partitions: []Partition{
+				{
+					ID:     "esp",
+					FsType: "fat32",
+					Start:  1,
+					End:    ptrutils.PtrTo(uint64(2)),
+					Flags: []PartitionFlag{
+						"esp",
+						"boot",
+					},
+				},
+				{
+					ID:     "rootfs",
+					FsType: "ext4",
+					Start:  2,
+					Luks: &Luks{
+						PassphraseFile: "files/passphrase.txt",
+					},
+				},
+			},
+		}},
+		SystemConfig: SystemConfig{
+			BootType: "efi",
+			PartitionSettings: []PartitionSetting{
+				{
+					ID:         "esp",
+					MountPoint: "/boot/efi",
+				},
+				{
+					ID:         "rootfs",
+					MountPoint: "/",
+				},
+			},
+		},
+	}
+
+	err := config.IsValid()
+	assert.ErrorContains(t, err, "partition (rootfs) is encrypted and so can't be mounted at (/)")
+}
+
+func TestConfigIsValidMultipleErrors(t *testing.T) {
+	config := &Config{
+		SystemConfig: SystemConfig{
+			Hostname: "bad_hostname",
+			Users: []User{
+				{
+					Name: "",
+				},
+			},
+			PartitionSettings: []PartitionSetting{
+				{
+					ID:         "rootfs",
+					MountPoint: "/",
+				},
+			},
+		},
+	}
+
+	err := config.IsValid()
+	assert.ErrorContains(t, err, "invalid hostname: bad_hostname")
+	assert.ErrorContains(t, err, "invalid Users item at index 0")
+	assert.ErrorContains(t, err, "Disks and SystemConfig.BootType values must also be specified")
+}
@@ -0,0 +1,261 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/sliceutils"
+	"gopkg.in/yaml.v3"
+)
+
+// ListMergeStrategy controls how the lists of an overlay config are merged into the base config.
+type ListMergeStrategy string
+
+const (
+	// ListMergeStrategyAppend appends the overlay's items to the base's items.
+	ListMergeStrategyAppend ListMergeStrategy = "append"
+
+	// ListMergeStrategyReplace replaces the base's items with the overlay's items, if the overlay specifies the list.
+	// An empty list (i.e. `[]`) clears the base's items.
+	ListMergeStrategyReplace ListMergeStrategy = "replace"
+
+	ListMergeStrategyUnset ListMergeStrategy = ""
+)
+
+var (
+	listMergeStrategyValues = []ListMergeStrategy{ListMergeStrategyAppend, ListMergeStrategyReplace}
+)
+
+func (s ListMergeStrategy) IsValid() error {
+	switch s {
+	case ListMergeStrategyAppend, ListMergeStrategyReplace, ListMergeStrategyUnset:
+		// All good.
+		return nil
+
+	default:
+		return fmt.Errorf("invalid ListMergeStrategy value (%v); must be one of: %s", s,
+			enumValuesString(listMergeStrategyValues))
+	}
+}
+
+// UnmarshalAndMergeYamlFiles reads a list of config files and deep-merges them, in order, into a single config.
+// Each file only needs to be valid once merged with the files before it. So, only the merged config is validated.
+//
+// If expandEnvVars is true, then the environment variable references in the files are substituted before the files
+// are parsed. See SubstituteEnvVars.
+//
+// The files listed in a file's Include field are merged in first, in order, followed by the file itself. So, the
+// file's own values take precedence over the values of the files it includes.
+//
+// If profile is not empty, then the merged config's profile with that name is merged in last. So, the profile's values
+// take precedence over the values of all the files.
+func UnmarshalAndMergeYamlFiles(yamlFilePaths []string, listMergeStrategy ListMergeStrategy, expandEnvVars bool,
+	profile string, config *Config,
+) error {
+	err := listMergeStrategy.IsValid()
+	if err != nil {
+		return err
+	}
+
+	for _, yamlFilePath := range yamlFilePaths {
+		overlay, err := unmarshalYamlFileWithIncludes(yamlFilePath, listMergeStrategy, expandEnvVars, nil)
+		if err != nil {
+			return err
+		}
+
+		MergeConfig(config, overlay, listMergeStrategy)
+	}
+
+	err = applyProfile(config, profile, listMergeStrategy)
+	if err != nil {
+		return err
+	}
+
+	err = config.IsValid()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// applyProfile merges the named profile into the config and then removes the config's profiles.
+func applyProfile(config *Config, profile string, listMergeStrategy ListMergeStrategy) error {
+	profiles := config.Profiles
+	config.Profiles = nil
+
+	if profile == "" {
+		return nil
+	}
+
+	profileConfig, found := profiles[profile]
+	if !found {
+		profileNames := sliceutils.MapToSlice(profiles)
+		sort.Strings(profileNames)
+		return fmt.Errorf("profile (%s) not found (available profiles: %s)", profile, strings.Join(profileNames, ", "))
+	}
+
+	if len(profileConfig.Include) > 0 {
+		return fmt.Errorf("invalid profile (%s): Include is not supported within a profile", profile)
+	}
+
+	if len(profileConfig.Profiles) > 0 {
+		return fmt.Errorf("invalid profile (%s): Profiles is not supported within a profile", profile)
+	}
+
+	MergeConfig(config, &profileConfig, listMergeStrategy)
+	return nil
+}
+
+// unmarshalYamlFileWithIncludes reads a config file and merges in the config files that it includes.
+// includeStack is the list of the files that are currently being read, which is used to detect include cycles.
+func unmarshalYamlFileWithIncludes(yamlFilePath string, listMergeStrategy ListMergeStrategy, expandEnvVars bool,
+	includeStack []string,
+) (*Config, error) {
+	yamlFilePath, err := filepath.Abs(yamlFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, includingFilePath := range includeStack {
+		if includingFilePath == yamlFilePath {
+			cycle := append(append([]string(nil), includeStack[i:]...), yamlFilePath)
+			return nil, fmt.Errorf("config file include cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	yamlFile, err := os.ReadFile(yamlFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if expandEnvVars {
+		yamlFile, err = SubstituteEnvVars(yamlFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to substitute environment variables in config file (%s):\n%w",
+				yamlFilePath, err)
+		}
+	}
+
+	var fileConfig Config
+
+	decoder := yaml.NewDecoder(bytes.NewReader(yamlFile))
+
+	// Ensure unknown fields result in an error.
+	decoder.KnownFields(true)
+
+	err = decoder.Decode(&fileConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file (%s):\n%w", yamlFilePath, err)
+	}
+
+	includeStack = append(includeStack, yamlFilePath)
+
+	// Included files are resolved relative to the including file.
+	var config Config
+	for _, includePath := range fileConfig.Include {
+		if !filepath.IsAbs(includePath) {
+			includePath = filepath.Join(filepath.Dir(yamlFilePath), includePath)
+		}
+
+		includedConfig, err := unmarshalYamlFileWithIncludes(includePath, listMergeStrategy, expandEnvVars,
+			includeStack)
+		if err != nil {
+			return nil, fmt.Errorf("failed to include config file (%s) from (%s):\n%w", includePath, yamlFilePath,
+				err)
+		}
+
+		MergeConfig(&config, includedConfig, listMergeStrategy)
+	}
+
+	fileConfig.Include = nil
+	MergeConfig(&config, &fileConfig, listMergeStrategy)
+
+	return &config, nil
+}
+
+// MergeConfig deep-merges the overlay config into the base config.
+//
+// Merge rules:
+//
+// - Scalar values: The overlay's value wins, unless it is the zero value (e.g. "", 0, or false).
+// - Lists: Depends on listMergeStrategy. By default, the overlay's items are appended to the base's items.
+// - Maps: The maps are merged, with the values of keys that are in both maps merged using these rules.
+// - Objects: Each field is merged using these rules.
+// - Disks: The overlay's disks replace the base's disks.
+func MergeConfig(base *Config, overlay *Config, listMergeStrategy ListMergeStrategy) {
+	mergeValue(reflect.ValueOf(base).Elem(), reflect.ValueOf(overlay).Elem(), listMergeStrategy)
+}
+
+func mergeValue(base reflect.Value, overlay reflect.Value, listMergeStrategy ListMergeStrategy) {
+	switch base.Kind() {
+	case reflect.Struct:
+		for i := 0; i < base.NumField(); i++ {
+			mergeValue(base.Field(i), overlay.Field(i), listMergeStrategy)
+		}
+
+	case reflect.Pointer:
+		switch {
+		case overlay.IsNil():
+			// Nothing to merge.
+
+		case base.IsNil() || base.Elem().Kind() != reflect.Struct:
+			// Pointers to non-objects (e.g. optional values and the Disks list) are replaced as a whole.
+			base.Set(overlay)
+
+		default:
+			mergeValue(base.Elem(), overlay.Elem(), listMergeStrategy)
+		}
+
+	case reflect.Slice:
+		switch {
+		case listMergeStrategy == ListMergeStrategyReplace:
+			// Note: An omitted list is nil but an empty list (`[]`) isn't.
+			if !overlay.IsNil() {
+				base.Set(overlay)
+			}
+
+		case overlay.Len() > 0:
+			merged := reflect.MakeSlice(base.Type(), 0, base.Len()+overlay.Len())
+			merged = reflect.AppendSlice(merged, base)
+			merged = reflect.AppendSlice(merged, overlay)
+			base.Set(merged)
+		}
+
+	case reflect.Map:
+		if overlay.Len() <= 0 {
+			break
+		}
+
+		if base.IsNil() {
+			base.Set(reflect.MakeMapWithSize(base.Type(), overlay.Len()))
+		}
+
+		iter := overlay.MapRange()
+		for iter.Next() {
+			// Map values aren't addressable. So, merge into a copy of the value.
+			merged := reflect.New(base.Type().Elem()).Elem()
+
+			baseValue := base.MapIndex(iter.Key())
+			if baseValue.IsValid() {
+				merged.Set(baseValue)
+			}
+
+			mergeValue(merged, iter.Value(), listMergeStrategy)
+			base.SetMapIndex(iter.Key(), merged)
+		}
+
+	default:
+		if !overlay.IsZero() {
+			base.Set(overlay)
+		}
+	}
+}
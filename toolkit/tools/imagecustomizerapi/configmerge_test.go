@@ -0,0 +1,293 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/ptrutils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeConfig(t *testing.T) {
+	base := &Config{
+		SystemConfig: SystemConfig{
+			Hostname:              "base",
+			SkipInstalledPackages: true,
+			PackagesInstall:       []string{"jq"},
+			AdditionalFiles: map[string]FileConfigList{
+				"a.txt": {{Path: "/a.txt"}},
+			},
+			Users: []User{{Name: "test", UID: ptrutils.PtrTo(1000)}},
+			Verity: &Verity{
+				DataPartition: VerityPartition{IdType: "part-uuid", Id: "1"},
+			},
+		},
+	}
+
+	overlay := &Config{
+		SystemConfig: SystemConfig{
+			Hostname:        "overlay",
+			PackagesInstall: []string{"curl"},
+			AdditionalFiles: map[string]FileConfigList{
+				"a.txt": {{Path: "/b.txt"}},
+				"c.txt": {{Path: "/c.txt"}},
+			},
+			Verity: &Verity{
+				HashPartition: VerityPartition{IdType: "part-uuid", Id: "2"},
+			},
+		},
+	}
+
+	MergeConfig(base, overlay, ListMergeStrategyAppend)
+
+	assert.Equal(t, "overlay", base.SystemConfig.Hostname)
+	assert.True(t, base.SystemConfig.SkipInstalledPackages)
+	assert.Equal(t, []string{"jq", "curl"}, base.SystemConfig.PackagesInstall)
+	assert.Equal(t, map[string]FileConfigList{
+		"a.txt": {{Path: "/a.txt"}, {Path: "/b.txt"}},
+		"c.txt": {{Path: "/c.txt"}},
+	}, base.SystemConfig.AdditionalFiles)
+	assert.Equal(t, []User{{Name: "test", UID: ptrutils.PtrTo(1000)}}, base.SystemConfig.Users)
+	assert.Equal(t, &Verity{
+		DataPartition: VerityPartition{IdType: "part-uuid", Id: "1"},
+		HashPartition: VerityPartition{IdType: "part-uuid", Id: "2"},
+	}, base.SystemConfig.Verity)
+}
+
+func TestMergeConfigDisksReplaced(t *testing.T) {
+	base := &Config{
+		Disks: &[]Disk{{PartitionTableType: "gpt", MaxSize: 2}},
+	}
+
+	overlay := &Config{
+		Disks: &[]Disk{{PartitionTableType: "gpt", MaxSize: 4}},
+	}
+
+	MergeConfig(base, overlay, ListMergeStrategyReplace)
+
+	assert.Equal(t, &[]Disk{{PartitionTableType: "gpt", MaxSize: 4}}, base.Disks)
+}
+
+func TestUnmarshalAndMergeYamlFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	baseFile := filepath.Join(tmpDir, "base.yaml")
+	err := os.WriteFile(baseFile, []byte("SystemConfig:\n  Hostname: base\n  PackagesInstall: [jq]\n"), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	overlayFile := filepath.Join(tmpDir, "overlay.yaml")
+	err = os.WriteFile(overlayFile, []byte("SystemConfig:\n  PackagesInstall: [curl]\n"), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var config Config
+	err = UnmarshalAndMergeYamlFiles([]string{baseFile, overlayFile}, ListMergeStrategyUnset, false, "", &config)
+	assert.NoError(t, err)
+	assert.Equal(t, "base", config.SystemConfig.Hostname)
+	assert.Equal(t, []string{"jq", "curl"}, config.SystemConfig.PackagesInstall)
+}
+
+func TestUnmarshalAndMergeYamlFilesInvalidMerged(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	baseFile := filepath.Join(tmpDir, "base.yaml")
+	err := os.WriteFile(baseFile, []byte("SystemConfig:\n  Hostname: base\n"), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	overlayFile := filepath.Join(tmpDir, "overlay.yaml")
+	err = os.WriteFile(overlayFile, []byte("SystemConfig:\n  Hostname: bad_hostname\n"), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var config Config
+	err = UnmarshalAndMergeYamlFiles([]string{baseFile, overlayFile}, ListMergeStrategyUnset, false, "", &config)
+	assert.ErrorContains(t, err, "invalid hostname: bad_hostname")
+}
+
+func TestMergeConfigListsReplace(t *testing.T) {
+	base := &Config{
+		SystemConfig: SystemConfig{
+			PackagesInstall: []string{"jq"},
+			PackagesRemove:  []string{"vim"},
+			PackagesUpdate:  []string{"openssl"},
+		},
+	}
+
+	overlay := &Config{
+		SystemConfig: SystemConfig{
+			PackagesInstall: []string{"curl"},
+			PackagesRemove:  []string{},
+		},
+	}
+
+	MergeConfig(base, overlay, ListMergeStrategyReplace)
+
+	assert.Equal(t, []string{"curl"}, base.SystemConfig.PackagesInstall)
+	assert.Equal(t, []string{}, base.SystemConfig.PackagesRemove)
+	assert.Equal(t, []string{"openssl"}, base.SystemConfig.PackagesUpdate)
+}
+
+func TestUnmarshalAndMergeYamlFilesInvalidListMergeStrategy(t *testing.T) {
+	var config Config
+	err := UnmarshalAndMergeYamlFiles(nil, "prepend", false, "", &config)
+	assert.ErrorContains(t, err, "invalid ListMergeStrategy value (prepend)")
+}
+
+func TestUnmarshalAndMergeYamlFilesInclude(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	err := os.Mkdir(filepath.Join(tmpDir, "shared"), os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	sharedFile := filepath.Join(tmpDir, "shared", "packages.yaml")
+	err = os.WriteFile(sharedFile, []byte("SystemConfig:\n  Hostname: shared\n  PackagesInstall: [jq]\n"), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	configFile := filepath.Join(tmpDir, "config.yaml")
+	err = os.WriteFile(configFile,
+		[]byte("Include: [shared/packages.yaml]\nSystemConfig:\n  Hostname: local\n  PackagesInstall: [curl]\n"), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var config Config
+	err = UnmarshalAndMergeYamlFiles([]string{configFile}, ListMergeStrategyUnset, false, "", &config)
+	assert.NoError(t, err)
+	assert.Nil(t, config.Include)
+	assert.Equal(t, "local", config.SystemConfig.Hostname)
+	assert.Equal(t, []string{"jq", "curl"}, config.SystemConfig.PackagesInstall)
+}
+
+func TestUnmarshalAndMergeYamlFilesIncludeCycle(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	aFile := filepath.Join(tmpDir, "a.yaml")
+	err := os.WriteFile(aFile, []byte("Include: [b.yaml]\n"), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	bFile := filepath.Join(tmpDir, "b.yaml")
+	err = os.WriteFile(bFile, []byte("Include: [a.yaml]\n"), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var config Config
+	err = UnmarshalAndMergeYamlFiles([]string{aFile}, ListMergeStrategyUnset, false, "", &config)
+	assert.ErrorContains(t, err, "config file include cycle: "+aFile+" -> "+bFile+" -> "+aFile)
+}
+
+func TestConfigIsValidUnresolvedInclude(t *testing.T) {
+	config := &Config{
+		Include: []string{"other.yaml"},
+	}
+
+	err := config.IsValid()
+	assert.ErrorContains(t, err, "Include is only supported in config files")
+}
+
+func TestConfigIsValidUnresolvedProfiles(t *testing.T) {
+	config := &Config{
+		Profiles: map[string]Config{
+			"edge": {},
+		},
+	}
+
+	err := config.IsValid()
+	assert.ErrorContains(t, err, "Profiles is only supported in config files")
+}
+
+func TestUnmarshalAndMergeYamlFilesProfile(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	configFile := filepath.Join(tmpDir, "config.yaml")
+	err := os.WriteFile(configFile, []byte(`
+SystemConfig:
+  Hostname: base
+  PackagesInstall: [jq]
+Profiles:
+  edge:
+    SystemConfig:
+      Hostname: edge
+      PackagesInstall: [curl]
+  cloud:
+    SystemConfig:
+      Hostname: cloud
+`), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var config Config
+	err = UnmarshalAndMergeYamlFiles([]string{configFile}, ListMergeStrategyUnset, false, "edge", &config)
+	assert.NoError(t, err)
+	assert.Nil(t, config.Profiles)
+	assert.Equal(t, "edge", config.SystemConfig.Hostname)
+	assert.Equal(t, []string{"jq", "curl"}, config.SystemConfig.PackagesInstall)
+
+	// Without a profile, the profiles are ignored.
+	config = Config{}
+	err = UnmarshalAndMergeYamlFiles([]string{configFile}, ListMergeStrategyUnset, false, "", &config)
+	assert.NoError(t, err)
+	assert.Nil(t, config.Profiles)
+	assert.Equal(t, "base", config.SystemConfig.Hostname)
+
+	config = Config{}
+	err = UnmarshalAndMergeYamlFiles([]string{configFile}, ListMergeStrategyUnset, false, "missing", &config)
+	assert.ErrorContains(t, err, "profile (missing) not found (available profiles: cloud, edge)")
+}
+
+func TestUnmarshalAndMergeYamlFilesProfileInvalidResult(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	configFile := filepath.Join(tmpDir, "config.yaml")
+	err := os.WriteFile(configFile, []byte(`
+Profiles:
+  bad:
+    SystemConfig:
+      Hostname: bad_hostname
+`), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var config Config
+	err = UnmarshalAndMergeYamlFiles([]string{configFile}, ListMergeStrategyUnset, false, "bad", &config)
+	assert.ErrorContains(t, err, "invalid hostname: bad_hostname")
+}
+
+func TestUnmarshalAndMergeYamlFilesProfileNested(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	configFile := filepath.Join(tmpDir, "config.yaml")
+	err := os.WriteFile(configFile, []byte(`
+Profiles:
+  a:
+    Profiles:
+      b:
+        SystemConfig:
+          Hostname: b
+`), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var config Config
+	err = UnmarshalAndMergeYamlFiles([]string{configFile}, ListMergeStrategyUnset, false, "a", &config)
+	assert.ErrorContains(t, err, "invalid profile (a): Profiles is not supported within a profile")
+}
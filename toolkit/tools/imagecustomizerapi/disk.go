@@ -7,10 +7,21 @@ import (
 	"fmt"
 	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/sliceutils"
 )
 
+const (
+	// The maximum number of partitions on an MBR disk.
+	// Extended and logical partitions aren't supported. So, this is limited to the number of primary partitions.
+	mbrMaxPartitions = 4
+
+	// The maximum size of an MBR disk, in MiBs.
+	// MBR uses 32-bit sector addresses. Assuming 512 byte sectors, that is 2 TiB.
+	mbrMaxDiskSize = 2 * 1024 * 1024
+)
+
 type Disk struct {
 	// The type of partition table to use (e.g. mbr, gpt)
 	PartitionTableType PartitionTableType `yaml:"PartitionTableType"`
@@ -20,6 +31,9 @@ type Disk struct {
 
 	// The partitions to allocate on the disk.
 	Partitions []Partition `yaml:"Partitions"`
+
+	// Reapply the base image's partition and filesystem UUIDs to the recreated partitions, matched by mount point.
+	PreservePartitionUuids bool `yaml:"PreservePartitionUuids"`
 }
 
 func (d *Disk) IsValid() error {
@@ -32,6 +46,19 @@ func (d *Disk) IsValid() error {
 		return fmt.Errorf("a disk's MaxSize value (%d) must be a positive non-zero number", d.MaxSize)
 	}
 
+	if d.PartitionTableType == PartitionTableTypeMbr {
+		if d.MaxSize > mbrMaxDiskSize {
+			return fmt.Errorf("a disk's MaxSize value (%d) must not be larger than %d MiB when using an MBR partition table",
+				d.MaxSize, mbrMaxDiskSize)
+		}
+
+		if len(d.Partitions) > mbrMaxPartitions {
+			return fmt.Errorf("MBR disks support at most %d partitions but %d were specified", mbrMaxPartitions,
+				len(d.Partitions))
+		}
+	}
+
+	growPartitionID := ""
 	partitionIDSet := make(map[string]bool)
 	for i, partition := range d.Partitions {
 		err := partition.IsValid()
@@ -45,7 +72,17 @@ func (d *Disk) IsValid() error {
 
 		partitionIDSet[partition.ID] = false // dummy value
 
-		if d.PartitionTableType == PartitionTableTypeGpt {
+		if partition.Grow {
+			if growPartitionID != "" {
+				return fmt.Errorf("only one partition may have Grow set but both partition (%s) and partition (%s) do",
+					growPartitionID, partition.ID)
+			}
+
+			growPartitionID = partition.ID
+		}
+
+		switch d.PartitionTableType {
+		case PartitionTableTypeGpt:
 			isESP := sliceutils.ContainsValue(partition.Flags, PartitionFlagESP)
 			isBoot := sliceutils.ContainsValue(partition.Flags, PartitionFlagBoot)
 
@@ -53,13 +90,47 @@ func (d *Disk) IsValid() error {
 				return fmt.Errorf(
 					"invalid partition at index %d:\n'esp' and 'boot' flags must be specified together on GPT disks", i)
 			}
+
+		case PartitionTableTypeMbr:
+			if partition.Name != "" {
+				return fmt.Errorf("invalid partition at index %d:\npartition names are not supported on MBR disks", i)
+			}
+
+			if sliceutils.ContainsValue(partition.Flags, PartitionFlagBiosGrub) {
+				return fmt.Errorf("invalid partition at index %d:\n'bios_grub' flag is not supported on MBR disks", i)
+			}
+
+			if len(partition.GptAttributes) > 0 {
+				return fmt.Errorf("invalid partition at index %d:\nGptAttributes are not supported on MBR disks", i)
+			}
+		}
+
+		// While the new partitions are created, the base image's filesystems are still mounted. And XFS refuses to
+		// mount a filesystem with the same UUID as an already mounted one.
+		if d.PreservePartitionUuids && partition.FsType == FileSystemTypeXfs && partition.Luks == nil {
+			return fmt.Errorf("invalid partition at index %d:\nPreservePartitionUuids is not supported for xfs "+
+				"partitions", i)
+		}
+	}
+
+	// Check that at most one partition fills the remainder of the disk.
+	var expandingPartitionIDs []string
+	for _, partition := range d.Partitions {
+		_, hasEnd := partition.GetEnd()
+		if !hasEnd && !partition.Grow {
+			expandingPartitionIDs = append(expandingPartitionIDs, partition.ID)
 		}
 	}
 
+	if len(expandingPartitionIDs) > 1 {
+		return fmt.Errorf("partitions (%s) all omit both End and Size but only the last partition may fill the remainder of the disk",
+			strings.Join(expandingPartitionIDs, ", "))
+	}
+
 	// Check for overlapping partitions.
 	// First, sort partitions by start index.
 	sortedPartitions := append([]Partition(nil), d.Partitions...)
-	sort.Slice(sortedPartitions, func(i, j int) bool {
+	sort.SliceStable(sortedPartitions, func(i, j int) bool {
 		return sortedPartitions[i].Start < sortedPartitions[j].Start
 	})
 
@@ -70,7 +141,18 @@ func (d *Disk) IsValid() error {
 
 		aEnd, aHasEnd := a.GetEnd()
 		if !aHasEnd {
-			return fmt.Errorf("partition (%s) is not last partition but ommitted End value", a.ID)
+			if !a.Grow {
+				return fmt.Errorf("partition (%s) is not the last partition but omits both End and Size (set End, Size, or Grow)",
+					a.ID)
+			}
+
+			// The partition grows to fill the space up to the next partition.
+			if a.Start >= b.Start {
+				return fmt.Errorf("partition (%s) has Grow set but there is no space between it and partition (%s)",
+					a.ID, b.ID)
+			}
+
+			continue
 		}
 		if aEnd > b.Start {
 			bEnd, bHasEnd := b.GetEnd()
@@ -109,3 +191,28 @@ func (d *Disk) IsValid() error {
 
 	return nil
 }
+
+// GetPartitionEnd returns the end of the partition at the specified index.
+// For a partition that has Grow set, this is the start of the next partition.
+// Returns false if the partition fills the remainder of the disk.
+func (d *Disk) GetPartitionEnd(index int) (uint64, bool) {
+	partition := &d.Partitions[index]
+
+	end, hasEnd := partition.GetEnd()
+	if hasEnd {
+		return end, true
+	}
+
+	// Find the next partition.
+	hasNextPartition := false
+	nextPartitionStart := uint64(0)
+	for i := range d.Partitions {
+		start := d.Partitions[i].Start
+		if start > partition.Start && (!hasNextPartition || start < nextPartitionStart) {
+			hasNextPartition = true
+			nextPartitionStart = start
+		}
+	}
+
+	return nextPartitionStart, hasNextPartition
+}
@@ -139,7 +139,7 @@ func TestDiskIsValidTwoExpanding(t *testing.T) {
 
 	err := disk.IsValid()
 	assert.Error(t, err)
-	assert.ErrorContains(t, err, "is not last partition")
+	assert.ErrorContains(t, err, "partitions (a, b) all omit both End and Size")
 }
 
 func TestDiskIsValidOverlaps(t *testing.T) {
@@ -321,3 +321,366 @@ func TestDiskIsValidDuplicatePartitionId(t *testing.T) {
 	assert.Error(t, err)
 	assert.ErrorContains(t, err, "duplicate partition ID")
 }
+
+func TestDiskIsValidMbr(t *testing.T) {
+	disk := &Disk{
+		PartitionTableType: PartitionTableTypeMbr,
+		MaxSize:            5,
+		Partitions: []Partition{
+			{
+				ID:     "a",
+				FsType: "fat32",
+				Start:  1,
+				End:    ptrutils.PtrTo(uint64(2)),
+				Flags:  []PartitionFlag{"boot"},
+			},
+			{
+				ID:     "b",
+				FsType: "ext4",
+				Start:  2,
+				End:    ptrutils.PtrTo(uint64(3)),
+			},
+			{
+				ID:     "c",
+				FsType: "ext4",
+				Start:  3,
+				End:    ptrutils.PtrTo(uint64(4)),
+			},
+			{
+				ID:     "d",
+				FsType: "ext4",
+				Start:  4,
+			},
+		},
+	}
+
+	err := disk.IsValid()
+	assert.NoError(t, err)
+}
+
+func TestDiskIsValidMbrTooManyPartitions(t *testing.T) {
+	disk := &Disk{
+		PartitionTableType: PartitionTableTypeMbr,
+		MaxSize:            6,
+		Partitions: []Partition{
+			{
+				ID:     "a",
+				FsType: "ext4",
+				Start:  1,
+				End:    ptrutils.PtrTo(uint64(2)),
+			},
+			{
+				ID:     "b",
+				FsType: "ext4",
+				Start:  2,
+				End:    ptrutils.PtrTo(uint64(3)),
+			},
+			{
+				ID:     "c",
+				FsType: "ext4",
+				Start:  3,
+				End:    ptrutils.PtrTo(uint64(4)),
+			},
+			{
+				ID:     "d",
+				FsType: "ext4",
+				Start:  4,
+				End:    ptrutils.PtrTo(uint64(5)),
+			},
+			{
+				ID:     "e",
+				FsType: "ext4",
+				Start:  5,
+			},
+		},
+	}
+
+	err := disk.IsValid()
+	assert.Error(t, err)
+	assert.ErrorContains(t, err, "MBR disks support at most 4 partitions but 5 were specified")
+}
+
+func TestDiskIsValidMbrPartitionName(t *testing.T) {
+	disk := &Disk{
+		PartitionTableType: PartitionTableTypeMbr,
+		MaxSize:            2,
+		Partitions: []Partition{
+			{
+				ID:     "a",
+				FsType: "ext4",
+				Name:   "rootfs",
+				Start:  1,
+			},
+		},
+	}
+
+	err := disk.IsValid()
+	assert.Error(t, err)
+	assert.ErrorContains(t, err, "partition names are not supported on MBR disks")
+}
+
+func TestDiskIsValidMbrBiosGrub(t *testing.T) {
+	disk := &Disk{
+		PartitionTableType: PartitionTableTypeMbr,
+		MaxSize:            2,
+		Partitions: []Partition{
+			{
+				ID:     "a",
+				FsType: "fat32",
+				Start:  1,
+				Flags:  []PartitionFlag{"bios_grub"},
+			},
+		},
+	}
+
+	err := disk.IsValid()
+	assert.Error(t, err)
+	assert.ErrorContains(t, err, "'bios_grub' flag is not supported on MBR disks")
+}
+
+func TestDiskIsValidMbrTooLarge(t *testing.T) {
+	disk := &Disk{
+		PartitionTableType: PartitionTableTypeMbr,
+		MaxSize:            3 * 1024 * 1024,
+		Partitions: []Partition{
+			{
+				ID:     "a",
+				FsType: "ext4",
+				Start:  1,
+			},
+		},
+	}
+
+	err := disk.IsValid()
+	assert.Error(t, err)
+	assert.ErrorContains(t, err, "must not be larger than 2097152 MiB")
+}
+
+func TestDiskIsValidGrowMiddle(t *testing.T) {
+	disk := &Disk{
+		PartitionTableType: PartitionTableTypeGpt,
+		MaxSize:            4,
+		Partitions: []Partition{
+			{
+				ID:     "a",
+				FsType: "ext4",
+				Start:  1,
+				Grow:   true,
+			},
+			{
+				ID:     "b",
+				FsType: "ext4",
+				Start:  3,
+			},
+		},
+	}
+
+	err := disk.IsValid()
+	assert.NoError(t, err)
+
+	end, hasEnd := disk.GetPartitionEnd(0)
+	assert.True(t, hasEnd)
+	assert.Equal(t, uint64(3), end)
+
+	_, hasEnd = disk.GetPartitionEnd(1)
+	assert.False(t, hasEnd)
+}
+
+func TestDiskIsValidGrowLast(t *testing.T) {
+	disk := &Disk{
+		PartitionTableType: PartitionTableTypeGpt,
+		MaxSize:            4,
+		Partitions: []Partition{
+			{
+				ID:     "a",
+				FsType: "ext4",
+				Start:  1,
+				Size:   ptrutils.PtrTo(uint64(1)),
+			},
+			{
+				ID:     "b",
+				FsType: "ext4",
+				Start:  2,
+				Grow:   true,
+			},
+		},
+	}
+
+	err := disk.IsValid()
+	assert.NoError(t, err)
+
+	end, hasEnd := disk.GetPartitionEnd(0)
+	assert.True(t, hasEnd)
+	assert.Equal(t, uint64(2), end)
+
+	_, hasEnd = disk.GetPartitionEnd(1)
+	assert.False(t, hasEnd)
+}
+
+func TestDiskIsValidTwoGrow(t *testing.T) {
+	disk := &Disk{
+		PartitionTableType: PartitionTableTypeGpt,
+		MaxSize:            4,
+		Partitions: []Partition{
+			{
+				ID:     "a",
+				FsType: "ext4",
+				Start:  1,
+				Grow:   true,
+			},
+			{
+				ID:     "b",
+				FsType: "ext4",
+				Start:  3,
+				Grow:   true,
+			},
+		},
+	}
+
+	err := disk.IsValid()
+	assert.Error(t, err)
+	assert.ErrorContains(t, err, "only one partition may have Grow set")
+}
+
+func TestDiskIsValidGrowNoSpace(t *testing.T) {
+	disk := &Disk{
+		PartitionTableType: PartitionTableTypeGpt,
+		MaxSize:            4,
+		Partitions: []Partition{
+			{
+				ID:     "a",
+				FsType: "ext4",
+				Start:  2,
+				Grow:   true,
+			},
+			{
+				ID:     "b",
+				FsType: "ext4",
+				Start:  2,
+			},
+		},
+	}
+
+	err := disk.IsValid()
+	assert.Error(t, err)
+	assert.ErrorContains(t, err, "no space between it and partition (b)")
+}
+
+func TestDiskIsValidExpandingNotLast(t *testing.T) {
+	disk := &Disk{
+		PartitionTableType: PartitionTableTypeGpt,
+		MaxSize:            4,
+		Partitions: []Partition{
+			{
+				ID:     "a",
+				FsType: "ext4",
+				Start:  1,
+			},
+			{
+				ID:     "b",
+				FsType: "ext4",
+				Start:  2,
+				End:    ptrutils.PtrTo(uint64(3)),
+			},
+		},
+	}
+
+	err := disk.IsValid()
+	assert.Error(t, err)
+	assert.ErrorContains(t, err, "partition (a) is not the last partition but omits both End and Size")
+}
+
+func TestDiskIsValidThreeExpanding(t *testing.T) {
+	disk := &Disk{
+		PartitionTableType: PartitionTableTypeGpt,
+		MaxSize:            8,
+		Partitions: []Partition{
+			{
+				ID:     "a",
+				FsType: "ext4",
+				Start:  1,
+			},
+			{
+				ID:     "b",
+				FsType: "ext4",
+				Start:  2,
+				Size:   ptrutils.PtrTo(uint64(1)),
+			},
+			{
+				ID:     "c",
+				FsType: "ext4",
+				Start:  3,
+			},
+			{
+				ID:     "d",
+				FsType: "ext4",
+				Start:  4,
+			},
+		},
+	}
+
+	err := disk.IsValid()
+	assert.Error(t, err)
+	assert.ErrorContains(t, err, "partitions (a, c, d) all omit both End and Size")
+}
+
+func TestDiskIsValidMbrGptAttributes(t *testing.T) {
+	disk := &Disk{
+		PartitionTableType: PartitionTableTypeMbr,
+		MaxSize:            2,
+		Partitions: []Partition{
+			{
+				ID:            "a",
+				FsType:        "ext4",
+				Start:         1,
+				GptAttributes: []uint{60},
+			},
+		},
+	}
+
+	err := disk.IsValid()
+	assert.ErrorContains(t, err, "GptAttributes are not supported on MBR disks")
+}
+
+func TestDiskIsValidPreservePartitionUuids(t *testing.T) {
+	disk := &Disk{
+		PartitionTableType: PartitionTableTypeGpt,
+		MaxSize:            3,
+		Partitions: []Partition{
+			{
+				ID:     "a",
+				FsType: "fat32",
+				Start:  1,
+				End:    ptrutils.PtrTo(uint64(2)),
+				Flags:  []PartitionFlag{PartitionFlagESP, PartitionFlagBoot},
+			},
+			{
+				ID:     "b",
+				FsType: "ext4",
+				Start:  2,
+			},
+		},
+		PreservePartitionUuids: true,
+	}
+
+	err := disk.IsValid()
+	assert.NoError(t, err)
+}
+
+func TestDiskIsValidPreservePartitionUuidsXfs(t *testing.T) {
+	disk := &Disk{
+		PartitionTableType: PartitionTableTypeGpt,
+		MaxSize:            2,
+		Partitions: []Partition{
+			{
+				ID:     "a",
+				FsType: "xfs",
+				Start:  1,
+			},
+		},
+		PreservePartitionUuids: true,
+	}
+
+	err := disk.IsValid()
+	assert.ErrorContains(t, err, "PreservePartitionUuids is not supported for xfs partitions")
+}
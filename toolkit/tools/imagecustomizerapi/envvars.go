@@ -0,0 +1,57 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+)
+
+var (
+	// Matches an escaped '$' (i.e. `$$`) or an environment variable reference (e.g. `${VAR}` or `${VAR:-default}`).
+	envVarRegex = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+)
+
+// SubstituteEnvVars replaces the `${VAR}` references in a config file's contents with the values of the process's
+// environment variables.
+//
+// Supported syntax:
+//
+// - `${VAR}`: The value of VAR. It is an error if VAR is not defined.
+// - `${VAR:-default}`: The value of VAR, or `default` if VAR is not defined or is empty.
+// - `$$`: A literal `$`.
+func SubstituteEnvVars(data []byte) ([]byte, error) {
+	var err error
+
+	result := envVarRegex.ReplaceAllFunc(data, func(match []byte) []byte {
+		if string(match) == "$$" {
+			return []byte("$")
+		}
+
+		submatchIndexes := envVarRegex.FindSubmatchIndex(match)
+		name := string(match[submatchIndexes[2]:submatchIndexes[3]])
+		hasDefault := submatchIndexes[4] >= 0
+
+		value, found := os.LookupEnv(name)
+		switch {
+		case found && (value != "" || !hasDefault):
+			return []byte(value)
+
+		case hasDefault:
+			return match[submatchIndexes[4]:submatchIndexes[5]]
+
+		default:
+			if err == nil {
+				err = fmt.Errorf("environment variable (%s) is not defined and has no default value", name)
+			}
+			return match
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
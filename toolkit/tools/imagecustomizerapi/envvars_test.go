@@ -0,0 +1,41 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubstituteEnvVars(t *testing.T) {
+	t.Setenv("IC_TEST_VERSION", "1.2.3")
+	t.Setenv("IC_TEST_EMPTY", "")
+
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"Hostname: host-${IC_TEST_VERSION}", "Hostname: host-1.2.3"},
+		{"Path: ${IC_TEST_MISSING:-/default}", "Path: /default"},
+		{"Path: ${IC_TEST_VERSION:-/default}", "Path: 1.2.3"},
+		{"Path: ${IC_TEST_EMPTY:-/default}", "Path: /default"},
+		{"Path: ${IC_TEST_MISSING:-}", "Path: "},
+		{"Path: '${IC_TEST_EMPTY}'", "Path: ''"},
+		{"Script: echo $$HOME", "Script: echo $HOME"},
+		{"Script: echo $${IC_TEST_MISSING}", "Script: echo ${IC_TEST_MISSING}"},
+		{"Script: echo $HOME", "Script: echo $HOME"},
+	}
+
+	for _, testCase := range testCases {
+		result, err := SubstituteEnvVars([]byte(testCase.input))
+		assert.NoError(t, err, testCase.input)
+		assert.Equal(t, testCase.expected, string(result), testCase.input)
+	}
+}
+
+func TestSubstituteEnvVarsUndefined(t *testing.T) {
+	_, err := SubstituteEnvVars([]byte("Hostname: ${IC_TEST_MISSING}"))
+	assert.ErrorContains(t, err, "environment variable (IC_TEST_MISSING) is not defined and has no default value")
+}
@@ -0,0 +1,47 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"fmt"
+)
+
+// FileAttribute is a file system attribute of a file (see chattr(1)).
+type FileAttribute string
+
+const (
+	// FileAttributeImmutable prevents the file from being modified, deleted, or renamed (chattr +i).
+	FileAttributeImmutable FileAttribute = "immutable"
+
+	// FileAttributeAppendOnly only allows the file to be opened for appending (chattr +a).
+	FileAttributeAppendOnly FileAttribute = "append-only"
+
+	// FileAttributeNoAtime disables updating the file's access time (chattr +A).
+	FileAttributeNoAtime FileAttribute = "no-atime"
+
+	// FileAttributeNoDump excludes the file from backups made by dump (chattr +d).
+	FileAttributeNoDump FileAttribute = "no-dump"
+
+	// FileAttributeSync writes changes to the file synchronously to disk (chattr +S).
+	FileAttributeSync FileAttribute = "sync"
+)
+
+var (
+	fileAttributeValues = []FileAttribute{
+		FileAttributeImmutable, FileAttributeAppendOnly, FileAttributeNoAtime, FileAttributeNoDump, FileAttributeSync,
+	}
+)
+
+func (a FileAttribute) IsValid() error {
+	switch a {
+	case FileAttributeImmutable, FileAttributeAppendOnly, FileAttributeNoAtime, FileAttributeNoDump,
+		FileAttributeSync:
+		// All good.
+		return nil
+
+	default:
+		return fmt.Errorf("invalid FileAttribute value (%s); must be one of: %s", a,
+			enumValuesString(fileAttributeValues))
+	}
+}
@@ -7,10 +7,21 @@ package imagecustomizerapi
 
 import (
 	"fmt"
+	"regexp"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
 
+var (
+	// A clause of the capability text format used by setcap (see cap_from_text(3)).
+	// For example: "cap_net_bind_service,cap_net_raw+ep".
+	fileCapabilitiesClauseRegex = regexp.MustCompile(`^(?:all|cap_[a-z0-9_]+)(?:,cap_[a-z0-9_]+)*(?:[=+-][eip]*)+$`)
+
+	// A user or group name (see useradd(8)) or a numeric ID.
+	fileOwnerRegex = regexp.MustCompile(`^(?:[0-9]+|[a-z_][a-z0-9_-]*\$?)$`)
+)
+
 // DestinationFileConfigList is a list of destination files where the source file will be copied to in the final image.
 // This type exists to allow a custom marshaller to be attached to it.
 type FileConfigList []FileConfig
@@ -22,12 +33,30 @@ type FileConfig struct {
 
 	// The file permissions to set on the file.
 	Permissions *FilePermissions `yaml:"Permissions"`
+
+	// The user (name or numeric ID) that will own the file.
+	// Defaults to root.
+	Owner string `yaml:"Owner"`
+
+	// The group (name or numeric ID) that will own the file.
+	// Defaults to root.
+	Group string `yaml:"Group"`
+
+	// Convert Windows (CRLF) line endings to Unix (LF) line endings when copying the file.
+	NormalizeLineEndings bool `yaml:"NormalizeLineEndings"`
+
+	// The Linux capabilities to set on the file using setcap (e.g. "cap_net_bind_service+ep").
+	Capabilities string `yaml:"Capabilities"`
+
+	// The file system attributes to set on the file using chattr (e.g. immutable).
+	Attributes []FileAttribute `yaml:"Attributes"`
 }
 
 var (
 	DefaultFileConfig = FileConfig{
-		Path:        "",
-		Permissions: nil,
+		Path:                 "",
+		Permissions:          nil,
+		NormalizeLineEndings: false,
 	}
 )
 
@@ -81,6 +110,43 @@ func (f *FileConfig) IsValid() (err error) {
 		}
 	}
 
+	// Owner
+	if f.Owner != "" && !fileOwnerRegex.MatchString(f.Owner) {
+		return fmt.Errorf("invalid Owner value (%s): must be a user name or a numeric ID", f.Owner)
+	}
+
+	// Group
+	if f.Group != "" && !fileOwnerRegex.MatchString(f.Group) {
+		return fmt.Errorf("invalid Group value (%s): must be a group name or a numeric ID", f.Group)
+	}
+
+	// Capabilities
+	if f.Capabilities != "" {
+		err = fileCapabilitiesIsValid(f.Capabilities)
+		if err != nil {
+			return fmt.Errorf("invalid Capabilities value:\n%w", err)
+		}
+	}
+
+	// Attributes
+	for i, attribute := range f.Attributes {
+		err = attribute.IsValid()
+		if err != nil {
+			return fmt.Errorf("invalid Attributes item at index %d:\n%w", i, err)
+		}
+	}
+
+	return nil
+}
+
+func fileCapabilitiesIsValid(capabilities string) error {
+	for _, clause := range strings.Fields(capabilities) {
+		if !fileCapabilitiesClauseRegex.MatchString(clause) {
+			return fmt.Errorf("capability clause (%s) must be a comma separated list of capability names (e.g. cap_net_raw) followed by operators and flags (e.g. +ep)",
+				clause)
+		}
+	}
+
 	return nil
 }
 
@@ -90,8 +156,9 @@ func (f *FileConfig) UnmarshalYAML(value *yaml.Node) error {
 	if value.Kind == yaml.ScalarNode {
 		// Parse as a string.
 		*f = FileConfig{
-			Path:        value.Value,
-			Permissions: nil,
+			Path:                 value.Value,
+			Permissions:          nil,
+			NormalizeLineEndings: false,
 		}
 		return nil
 	}
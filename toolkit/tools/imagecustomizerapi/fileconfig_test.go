@@ -27,6 +27,18 @@ func TestParseFileConfigValidFullStruct(t *testing.T) {
 	)
 }
 
+func TestParseFileConfigValidNormalizeLineEndings(t *testing.T) {
+	testValidYamlValue(t, "{ \"Path\": \"/b.sh\", \"NormalizeLineEndings\": true }",
+		&FileConfigList{{Path: "/b.sh", NormalizeLineEndings: true}},
+	)
+}
+
+func TestParseFileConfigValidCapabilities(t *testing.T) {
+	testValidYamlValue(t, "{ \"Path\": \"/usr/bin/b\", \"Capabilities\": \"cap_net_bind_service,cap_net_raw+ep cap_sys_nice=i\" }",
+		&FileConfigList{{Path: "/usr/bin/b", Capabilities: "cap_net_bind_service,cap_net_raw+ep cap_sys_nice=i"}},
+	)
+}
+
 func TestParseFileConfigValidMixedArray(t *testing.T) {
 	testValidYamlValue(t, "[ { \"Path\": \"/b.txt\" }, \"/c.txt\" ]",
 		&FileConfigList{
@@ -55,3 +67,46 @@ func TestParseFileConfigInvalidFilePermissions(t *testing.T) {
 	// Empty string.
 	testInvalidYamlValue[*FileConfigList](t, "{ \"Path\": \"/b.txt\", \"Permissions\": \"7777\" }")
 }
+
+func TestParseFileConfigInvalidCapabilities(t *testing.T) {
+	// Missing operator.
+	testInvalidYamlValue[*FileConfigList](t, "{ \"Path\": \"/usr/bin/b\", \"Capabilities\": \"cap_net_bind_service\" }")
+
+	// Not a capability name.
+	testInvalidYamlValue[*FileConfigList](t, "{ \"Path\": \"/usr/bin/b\", \"Capabilities\": \"net_bind_service+ep\" }")
+
+	// Unknown flag.
+	testInvalidYamlValue[*FileConfigList](t, "{ \"Path\": \"/usr/bin/b\", \"Capabilities\": \"cap_net_raw+x\" }")
+}
+
+func TestParseFileConfigValidAttributes(t *testing.T) {
+	testValidYamlValue(t, "{ \"Path\": \"/etc/audit/auditd.conf\", \"Attributes\": [ \"immutable\", \"no-dump\" ] }",
+		&FileConfigList{{
+			Path:       "/etc/audit/auditd.conf",
+			Attributes: []FileAttribute{FileAttributeImmutable, FileAttributeNoDump},
+		}},
+	)
+}
+
+func TestParseFileConfigInvalidAttributes(t *testing.T) {
+	testInvalidYamlValue[*FileConfigList](t, "{ \"Path\": \"/etc/audit/auditd.conf\", \"Attributes\": [ \"i\" ] }")
+}
+
+func TestParseFileConfigValidOwnerAndGroup(t *testing.T) {
+	testValidYamlValue(t, "{ \"Path\": \"/etc/app.conf\", \"Owner\": \"app-user\", \"Group\": \"1000\" }",
+		&FileConfigList{{Path: "/etc/app.conf", Owner: "app-user", Group: "1000"}},
+	)
+}
+
+func TestParseFileConfigInvalidOwner(t *testing.T) {
+	// Upper case.
+	testInvalidYamlValue[*FileConfigList](t, "{ \"Path\": \"/etc/app.conf\", \"Owner\": \"Admin\" }")
+
+	// Contains a colon.
+	testInvalidYamlValue[*FileConfigList](t, "{ \"Path\": \"/etc/app.conf\", \"Owner\": \"root:root\" }")
+}
+
+func TestParseFileConfigInvalidGroup(t *testing.T) {
+	// Negative number.
+	testInvalidYamlValue[*FileConfigList](t, "{ \"Path\": \"/etc/app.conf\", \"Group\": \"-1\" }")
+}
@@ -46,3 +46,7 @@ func (p *FilePermissions) UnmarshalYAML(value *yaml.Node) error {
 	*p = (FilePermissions)(fileModeUint)
 	return nil
 }
+
+func (p FilePermissions) MarshalYAML() (interface{}, error) {
+	return fmt.Sprintf("%o", uint32(p)), nil
+}
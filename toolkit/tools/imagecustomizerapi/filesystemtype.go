@@ -12,15 +12,25 @@ const (
 	FileSystemTypeExt4  FileSystemType = "ext4"
 	FileSystemTypeXfs   FileSystemType = "xfs"
 	FileSystemTypeFat32 FileSystemType = "fat32"
+
+	// FileSystemTypeNone indicates the partition is left unformatted.
+	FileSystemTypeNone FileSystemType = "none"
+)
+
+var (
+	fileSystemTypeValues = []FileSystemType{
+		FileSystemTypeExt4, FileSystemTypeXfs, FileSystemTypeFat32, FileSystemTypeNone,
+	}
 )
 
 func (t FileSystemType) IsValid() error {
 	switch t {
-	case FileSystemTypeExt4, FileSystemTypeXfs, FileSystemTypeFat32:
+	case FileSystemTypeExt4, FileSystemTypeXfs, FileSystemTypeFat32, FileSystemTypeNone:
 		// All good.
 		return nil
 
 	default:
-		return fmt.Errorf("invalid FileSystemType value (%s)", t)
+		return fmt.Errorf("invalid FileSystemType value (%s); must be one of: %s", t,
+			enumValuesString(fileSystemTypeValues))
 	}
 }
@@ -0,0 +1,119 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Firewall configures the image's firewall.
+// Exactly one of Nftables and Firewalld must be specified.
+type Firewall struct {
+	Nftables  *NftablesConfig  `yaml:"Nftables"`
+	Firewalld *FirewalldConfig `yaml:"Firewalld"`
+}
+
+// NftablesConfig specifies the nftables ruleset that is loaded on boot by nftables.service.
+type NftablesConfig struct {
+	Ruleset string `yaml:"Ruleset"`
+}
+
+// FirewalldConfig specifies the firewalld zones to add to the image.
+type FirewalldConfig struct {
+	// The name of the zone to use as the default zone.
+	DefaultZone string `yaml:"DefaultZone"`
+
+	// Zone definition files to add to the /etc/firewalld/zones directory.
+	Zones []FirewalldZone `yaml:"Zones"`
+}
+
+// FirewalldZone is a firewalld zone definition.
+type FirewalldZone struct {
+	// The name of the zone.
+	Name string `yaml:"Name"`
+
+	// The zone's XML definition.
+	Content string `yaml:"Content"`
+}
+
+func (f *Firewall) IsValid() error {
+	if (f.Nftables == nil) == (f.Firewalld == nil) {
+		return fmt.Errorf("exactly one of Nftables and Firewalld must be specified")
+	}
+
+	if f.Nftables != nil {
+		err := f.Nftables.IsValid()
+		if err != nil {
+			return fmt.Errorf("invalid Nftables:\n%w", err)
+		}
+	}
+
+	if f.Firewalld != nil {
+		err := f.Firewalld.IsValid()
+		if err != nil {
+			return fmt.Errorf("invalid Firewalld:\n%w", err)
+		}
+	}
+
+	return nil
+}
+
+func (n *NftablesConfig) IsValid() error {
+	if strings.TrimSpace(n.Ruleset) == "" {
+		return fmt.Errorf("invalid Ruleset value: empty string")
+	}
+
+	return nil
+}
+
+func (f *FirewalldConfig) IsValid() error {
+	if f.DefaultZone != "" {
+		err := firewalldZoneNameIsValid(f.DefaultZone)
+		if err != nil {
+			return fmt.Errorf("invalid DefaultZone value:\n%w", err)
+		}
+	}
+
+	zoneNameSet := make(map[string]bool)
+	for i, zone := range f.Zones {
+		err := zone.IsValid()
+		if err != nil {
+			return fmt.Errorf("invalid Zones item at index %d:\n%w", i, err)
+		}
+
+		if _, existingName := zoneNameSet[zone.Name]; existingName {
+			return fmt.Errorf("duplicate Zones Name used (%s) at index %d", zone.Name, i)
+		}
+
+		zoneNameSet[zone.Name] = false // dummy value
+	}
+
+	return nil
+}
+
+func (z *FirewalldZone) IsValid() error {
+	err := firewalldZoneNameIsValid(z.Name)
+	if err != nil {
+		return fmt.Errorf("invalid Name value:\n%w", err)
+	}
+
+	if strings.TrimSpace(z.Content) == "" {
+		return fmt.Errorf("invalid Content value for zone (%s): empty string", z.Name)
+	}
+
+	return nil
+}
+
+func firewalldZoneNameIsValid(name string) error {
+	if name == "" {
+		return fmt.Errorf("zone name must not be empty")
+	}
+
+	if strings.ContainsAny(name, "/. \t\n") {
+		return fmt.Errorf("zone name (%s) must not contain '/', '.', or whitespace characters", name)
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFirewallIsValidNftables(t *testing.T) {
+	value := Firewall{
+		Nftables: &NftablesConfig{
+			Ruleset: "table inet filter {\n}\n",
+		},
+	}
+
+	err := value.IsValid()
+	assert.NoError(t, err)
+}
+
+func TestFirewallIsValidFirewalld(t *testing.T) {
+	value := Firewall{
+		Firewalld: &FirewalldConfig{
+			DefaultZone: "appliance",
+			Zones: []FirewalldZone{
+				{
+					Name:    "appliance",
+					Content: "<zone><service name=\"ssh\"/></zone>",
+				},
+			},
+		},
+	}
+
+	err := value.IsValid()
+	assert.NoError(t, err)
+}
+
+func TestFirewallIsValidNeither(t *testing.T) {
+	value := Firewall{}
+
+	err := value.IsValid()
+	assert.ErrorContains(t, err, "exactly one of Nftables and Firewalld")
+}
+
+func TestFirewallIsValidBoth(t *testing.T) {
+	value := Firewall{
+		Nftables: &NftablesConfig{
+			Ruleset: "table inet filter {\n}\n",
+		},
+		Firewalld: &FirewalldConfig{},
+	}
+
+	err := value.IsValid()
+	assert.ErrorContains(t, err, "exactly one of Nftables and Firewalld")
+}
+
+func TestFirewallIsValidEmptyRuleset(t *testing.T) {
+	value := Firewall{
+		Nftables: &NftablesConfig{},
+	}
+
+	err := value.IsValid()
+	assert.ErrorContains(t, err, "invalid Ruleset value")
+}
+
+func TestFirewallIsValidBadZoneName(t *testing.T) {
+	value := Firewall{
+		Firewalld: &FirewalldConfig{
+			Zones: []FirewalldZone{
+				{
+					Name:    "../public",
+					Content: "<zone/>",
+				},
+			},
+		},
+	}
+
+	err := value.IsValid()
+	assert.ErrorContains(t, err, "invalid Name value")
+}
+
+func TestFirewallIsValidDuplicateZone(t *testing.T) {
+	value := Firewall{
+		Firewalld: &FirewalldConfig{
+			Zones: []FirewalldZone{
+				{
+					Name:    "appliance",
+					Content: "<zone/>",
+				},
+				{
+					Name:    "appliance",
+					Content: "<zone/>",
+				},
+			},
+		},
+	}
+
+	err := value.IsValid()
+	assert.ErrorContains(t, err, "duplicate Zones Name")
+}
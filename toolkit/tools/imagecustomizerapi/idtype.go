@@ -15,6 +15,10 @@ const (
 	IdTypePartUuid  IdType = "PartUuid"
 )
 
+var (
+	idTypeValues = []IdType{IdTypePartLabel, IdTypeUuid, IdTypePartUuid}
+)
+
 func (i IdType) IsValid() error {
 	switch i {
 	case IdTypePartLabel, IdTypeUuid, IdTypePartUuid:
@@ -22,6 +26,6 @@ func (i IdType) IsValid() error {
 		return nil
 
 	default:
-		return fmt.Errorf("invalid IdType value (%v)", i)
+		return fmt.Errorf("invalid IdType value (%v); must be one of: %s", i, enumValuesString(idTypeValues))
 	}
 }
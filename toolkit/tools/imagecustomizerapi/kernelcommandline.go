@@ -8,9 +8,18 @@ import (
 	"strings"
 )
 
+const (
+	// Disables the systemd/udev predictable network interface names (e.g. "enp0s3") and the biosdevname names (e.g.
+	// "em1"), so that the kernel's names (e.g. "eth0") are used instead.
+	legacyNetworkInterfaceNamesCommandLine = "net.ifnames=0 biosdevname=0"
+)
+
 type KernelCommandLine struct {
 	// Extra kernel command line args.
 	ExtraCommandLine string `yaml:"ExtraCommandLine"`
+
+	// Use the kernel's network interface names (e.g. "eth0") instead of the predictable names (e.g. "enp0s3").
+	LegacyNetworkInterfaceNames bool `yaml:"LegacyNetworkInterfaceNames"`
 }
 
 func (s *KernelCommandLine) IsValid() error {
@@ -19,9 +28,32 @@ func (s *KernelCommandLine) IsValid() error {
 		return err
 	}
 
+	if s.LegacyNetworkInterfaceNames {
+		for _, arg := range strings.Fields(s.ExtraCommandLine) {
+			if strings.HasPrefix(arg, "net.ifnames=") || strings.HasPrefix(arg, "biosdevname=") {
+				return fmt.Errorf("ExtraCommandLine must not contain the %s arg when LegacyNetworkInterfaceNames "+
+					"is enabled", arg)
+			}
+		}
+	}
+
 	return nil
 }
 
+// CommandLine returns all the extra args to add to the kernel command line.
+func (s *KernelCommandLine) CommandLine() string {
+	args := []string(nil)
+	if s.ExtraCommandLine != "" {
+		args = append(args, s.ExtraCommandLine)
+	}
+
+	if s.LegacyNetworkInterfaceNames {
+		args = append(args, legacyNetworkInterfaceNamesCommandLine)
+	}
+
+	return strings.Join(args, " ")
+}
+
 func commandLineIsValid(commandLine string, fieldName string) error {
 	// Disallow special characters to avoid breaking the grub.cfg file.
 	// In addition, disallow the "`" character, since it is used as the sed escape character by
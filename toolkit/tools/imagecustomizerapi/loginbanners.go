@@ -0,0 +1,83 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"fmt"
+	"text/template"
+	"unicode/utf8"
+)
+
+// LoginBanners contains the messages that are displayed to users when they log in.
+//
+// Each value is a Go text/template. See LoginBannerTemplateData for the values that are available to the templates.
+type LoginBanners struct {
+	// The contents of the /etc/motd file.
+	Motd *string `yaml:"Motd"`
+
+	// The contents of the /etc/issue file.
+	Issue *string `yaml:"Issue"`
+
+	// The contents of the /etc/issue.net file.
+	IssueNet *string `yaml:"IssueNet"`
+}
+
+// LoginBannerTemplateData contains the values that can be referenced by the login banner templates.
+type LoginBannerTemplateData struct {
+	// The version of the image customizer tool.
+	ToolVersion string
+
+	// The time the image was customized.
+	BuildTime string
+}
+
+func (b *LoginBanners) IsValid() error {
+	err := loginBannerIsValid(b.Motd, "Motd")
+	if err != nil {
+		return err
+	}
+
+	err = loginBannerIsValid(b.Issue, "Issue")
+	if err != nil {
+		return err
+	}
+
+	err = loginBannerIsValid(b.IssueNet, "IssueNet")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func loginBannerIsValid(content *string, fieldName string) error {
+	if content == nil {
+		return nil
+	}
+
+	if !utf8.ValidString(*content) {
+		return fmt.Errorf("invalid %s value: must be valid UTF-8", fieldName)
+	}
+
+	// Allow tabs, newlines, and escape sequences (e.g. for colors). But disallow other control characters, which can
+	// corrupt the user's terminal.
+	for _, c := range *content {
+		if (c < 0x20 && c != '\t' && c != '\n' && c != '\x1b') || c == 0x7f {
+			return fmt.Errorf("invalid %s value: contains control character (0x%02x)", fieldName, c)
+		}
+	}
+
+	_, err := ParseLoginBannerTemplate(*content)
+	if err != nil {
+		return fmt.Errorf("invalid %s value:\n%w", fieldName, err)
+	}
+
+	return nil
+}
+
+// ParseLoginBannerTemplate parses a login banner's template.
+func ParseLoginBannerTemplate(content string) (*template.Template, error) {
+	// Fail on references to values that don't exist.
+	return template.New("").Option("missingkey=error").Parse(content)
+}
@@ -0,0 +1,49 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"testing"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/ptrutils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoginBannersIsValid(t *testing.T) {
+	value := LoginBanners{
+		Motd:     ptrutils.PtrTo("\x1b[1mWelcome\x1b[0m\n\tBuilt: {{.BuildTime}}\n"),
+		Issue:    ptrutils.PtrTo("Authorized use only. \\n \\l\n"),
+		IssueNet: ptrutils.PtrTo(""),
+	}
+
+	err := value.IsValid()
+	assert.NoError(t, err)
+}
+
+func TestLoginBannersIsValidControlCharacter(t *testing.T) {
+	value := LoginBanners{
+		Issue: ptrutils.PtrTo("Authorized use only.\x00\n"),
+	}
+
+	err := value.IsValid()
+	assert.ErrorContains(t, err, "invalid Issue value: contains control character (0x00)")
+}
+
+func TestLoginBannersIsValidInvalidUtf8(t *testing.T) {
+	value := LoginBanners{
+		Motd: ptrutils.PtrTo("Welcome\xff\n"),
+	}
+
+	err := value.IsValid()
+	assert.ErrorContains(t, err, "invalid Motd value: must be valid UTF-8")
+}
+
+func TestLoginBannersIsValidBadTemplate(t *testing.T) {
+	value := LoginBanners{
+		IssueNet: ptrutils.PtrTo("Version: {{.ToolVersion\n"),
+	}
+
+	err := value.IsValid()
+	assert.ErrorContains(t, err, "invalid IssueNet value")
+}
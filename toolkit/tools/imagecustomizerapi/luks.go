@@ -0,0 +1,25 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"fmt"
+)
+
+// Luks specifies that a partition is encrypted using LUKS.
+type Luks struct {
+	// PassphraseFile is the path of a file containing the passphrase used to encrypt the partition.
+	// The path is relative to the config file's directory.
+	PassphraseFile string `yaml:"PassphraseFile"`
+	// Tpm2Unlock specifies that the partition should be unlocked at boot using the system's TPM 2.0 device.
+	Tpm2Unlock bool `yaml:"Tpm2Unlock"`
+}
+
+func (l *Luks) IsValid() error {
+	if l.PassphraseFile == "" {
+		return fmt.Errorf("PassphraseFile must be specified")
+	}
+
+	return nil
+}
@@ -5,10 +5,22 @@ package imagecustomizerapi
 
 import (
 	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// Matches a kernel module name (e.g. "br_netfilter" or "nf-conntrack").
+	moduleNameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+	// Matches a kernel module parameter name.
+	moduleOptionNameRegex = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
 )
 
 type Module struct {
 	Name string `yaml:"Name"`
+	// The module's parameters (e.g. `nf_conntrack_helper: 1`), which are passed to the module when it is loaded.
+	Options map[string]string `yaml:"Options"`
 }
 
 func (m *Module) IsValid() error {
@@ -16,6 +28,20 @@ func (m *Module) IsValid() error {
 		return fmt.Errorf("name of module may not be empty")
 	}
 
+	if !moduleNameRegex.MatchString(m.Name) {
+		return fmt.Errorf("module name (%s) may only contain letters, digits, '_' and '-'", m.Name)
+	}
+
+	for name, value := range m.Options {
+		if !moduleOptionNameRegex.MatchString(name) {
+			return fmt.Errorf("invalid option name (%s): may only contain letters, digits, '_', '.' and '-'", name)
+		}
+
+		if strings.ContainsAny(value, "\"\n\r") {
+			return fmt.Errorf("invalid value for option (%s): may not contain '\"' or line breaks", name)
+		}
+	}
+
 	return nil
 }
 
@@ -31,11 +57,31 @@ func (m *Modules) IsValid() error {
 		}
 	}
 
+	loadModuleNames := make(map[string]bool)
+	for _, module := range m.Load {
+		loadModuleNames[normalizeModuleName(module.Name)] = true
+	}
+
 	for i, module := range m.Disable {
 		if err := module.IsValid(); err != nil {
 			return fmt.Errorf("invalid module '%s' in Modules.Disable at index %d: %w", module.Name, i, err)
 		}
+
+		if len(module.Options) > 0 {
+			return fmt.Errorf("invalid module '%s' in Modules.Disable at index %d: a disabled module may not have "+
+				"Options", module.Name, i)
+		}
+
+		if loadModuleNames[normalizeModuleName(module.Name)] {
+			return fmt.Errorf("module '%s' is in both Modules.Load and Modules.Disable", module.Name)
+		}
 	}
 
 	return nil
 }
+
+// normalizeModuleName returns the canonical form of a kernel module name.
+// Like modprobe, '-' and '_' are treated as the same character.
+func normalizeModuleName(name string) string {
+	return strings.ReplaceAll(name, "-", "_")
+}
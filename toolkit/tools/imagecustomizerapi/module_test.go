@@ -0,0 +1,80 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModulesIsValid(t *testing.T) {
+	value := Modules{
+		Load: []Module{
+			{Name: "br_netfilter"},
+			{Name: "nf_conntrack", Options: map[string]string{"nf_conntrack_helper": "1"}},
+		},
+		Disable: []Module{{Name: "mousedev"}},
+	}
+
+	err := value.IsValid()
+	assert.NoError(t, err)
+}
+
+func TestModulesIsValidEmptyName(t *testing.T) {
+	value := Modules{
+		Load: []Module{{Name: ""}},
+	}
+
+	err := value.IsValid()
+	assert.ErrorContains(t, err, "name of module may not be empty")
+}
+
+func TestModulesIsValidBadName(t *testing.T) {
+	value := Modules{
+		Disable: []Module{{Name: "../mousedev"}},
+	}
+
+	err := value.IsValid()
+	assert.ErrorContains(t, err, "module name (../mousedev) may only contain letters, digits, '_' and '-'")
+}
+
+func TestModulesIsValidLoadAndDisable(t *testing.T) {
+	value := Modules{
+		Load:    []Module{{Name: "nf-conntrack"}},
+		Disable: []Module{{Name: "nf_conntrack"}},
+	}
+
+	err := value.IsValid()
+	assert.ErrorContains(t, err, "module 'nf_conntrack' is in both Modules.Load and Modules.Disable")
+}
+
+func TestModulesIsValidDisableWithOptions(t *testing.T) {
+	value := Modules{
+		Disable: []Module{{Name: "mousedev", Options: map[string]string{"xres": "1024"}}},
+	}
+
+	err := value.IsValid()
+	assert.ErrorContains(t, err, "a disabled module may not have Options")
+}
+
+func TestModuleIsValidBadOptionName(t *testing.T) {
+	value := Module{
+		Name:    "nf_conntrack",
+		Options: map[string]string{"bad name": "1"},
+	}
+
+	err := value.IsValid()
+	assert.ErrorContains(t, err, "invalid option name (bad name)")
+}
+
+func TestModuleIsValidBadOptionValue(t *testing.T) {
+	value := Module{
+		Name:    "nf_conntrack",
+		Options: map[string]string{"helper": "1\ninstall evil /bin/sh"},
+	}
+
+	err := value.IsValid()
+	assert.ErrorContains(t, err, "invalid value for option (helper)")
+}
@@ -22,6 +22,12 @@ const (
 	MountIdentifierTypeDefault MountIdentifierType = ""
 )
 
+var (
+	mountIdentifierTypeValues = []MountIdentifierType{
+		MountIdentifierTypeUuid, MountIdentifierTypePartUuid, MountIdentifierTypePartLabel,
+	}
+)
+
 func (m MountIdentifierType) IsValid() error {
 	switch m {
 	case MountIdentifierTypeUuid, MountIdentifierTypePartUuid, MountIdentifierTypePartLabel, MountIdentifierTypeDefault:
@@ -29,6 +35,7 @@ func (m MountIdentifierType) IsValid() error {
 		return nil
 
 	default:
-		return fmt.Errorf("invalid MountIdentifierType value (%v)", m)
+		return fmt.Errorf("invalid MountIdentifierType value (%v); must be one of: %s", m,
+			enumValuesString(mountIdentifierTypeValues))
 	}
 }
@@ -0,0 +1,69 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// NetworkStack is a network management service that network config files can target.
+type NetworkStack string
+
+const (
+	NetworkStackNetworkd       NetworkStack = "systemd-networkd"
+	NetworkStackNetworkManager NetworkStack = "NetworkManager"
+)
+
+// NetworkConfigFile is a network configuration file to write into the image.
+//
+// The network stack that the file targets is determined by the file's extension:
+//
+// - systemd-networkd: .network, .link, .netdev
+//
+// - NetworkManager: .nmconnection
+type NetworkConfigFile struct {
+	// The name of the file.
+	Name string `yaml:"Name"`
+
+	// The contents of the file.
+	Content string `yaml:"Content"`
+}
+
+func (n *NetworkConfigFile) IsValid() error {
+	if n.Name == "" {
+		return fmt.Errorf("invalid Name value: empty string")
+	}
+
+	if strings.Contains(n.Name, "/") {
+		return fmt.Errorf("invalid Name value (%s): must not contain '/' character", n.Name)
+	}
+
+	_, err := n.NetworkStack()
+	if err != nil {
+		return err
+	}
+
+	if n.Content == "" {
+		return fmt.Errorf("invalid Content value for network config file (%s): empty string", n.Name)
+	}
+
+	return nil
+}
+
+// NetworkStack returns the network stack that the file targets, based on the file's extension.
+func (n *NetworkConfigFile) NetworkStack() (NetworkStack, error) {
+	switch path.Ext(n.Name) {
+	case ".network", ".link", ".netdev":
+		return NetworkStackNetworkd, nil
+
+	case ".nmconnection":
+		return NetworkStackNetworkManager, nil
+
+	default:
+		return "", fmt.Errorf("invalid Name value (%s): unknown network config file extension (supported: .network, .link, .netdev, .nmconnection)",
+			n.Name)
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNetworkConfigFileIsValidNetworkd(t *testing.T) {
+	value := NetworkConfigFile{
+		Name:    "10-eth0.network",
+		Content: "[Match]\nName=eth0\n",
+	}
+
+	err := value.IsValid()
+	assert.NoError(t, err)
+
+	stack, err := value.NetworkStack()
+	assert.NoError(t, err)
+	assert.Equal(t, NetworkStackNetworkd, stack)
+}
+
+func TestNetworkConfigFileIsValidNetworkManager(t *testing.T) {
+	value := NetworkConfigFile{
+		Name:    "eth0.nmconnection",
+		Content: "[connection]\nid=eth0\n",
+	}
+
+	err := value.IsValid()
+	assert.NoError(t, err)
+
+	stack, err := value.NetworkStack()
+	assert.NoError(t, err)
+	assert.Equal(t, NetworkStackNetworkManager, stack)
+}
+
+func TestNetworkConfigFileIsValidUnknownExtension(t *testing.T) {
+	value := NetworkConfigFile{
+		Name:    "eth0.conf",
+		Content: "[Match]\nName=eth0\n",
+	}
+
+	err := value.IsValid()
+	assert.ErrorContains(t, err, "unknown network config file extension")
+}
+
+func TestNetworkConfigFileIsValidSlash(t *testing.T) {
+	value := NetworkConfigFile{
+		Name:    "../eth0.network",
+		Content: "[Match]\nName=eth0\n",
+	}
+
+	err := value.IsValid()
+	assert.ErrorContains(t, err, "must not contain '/'")
+}
+
+func TestSystemConfigIsValidMixedNetworkStacks(t *testing.T) {
+	value := SystemConfig{
+		NetworkConfigFiles: []NetworkConfigFile{
+			{
+				Name:    "10-eth0.network",
+				Content: "[Match]\nName=eth0\n",
+			},
+			{
+				Name:    "eth1.nmconnection",
+				Content: "[connection]\nid=eth1\n",
+			},
+		},
+	}
+
+	err := value.IsValid()
+	assert.ErrorContains(t, err, "same network stack")
+}
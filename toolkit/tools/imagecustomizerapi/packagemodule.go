@@ -0,0 +1,62 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"fmt"
+	"regexp"
+)
+
+var (
+	// Matches a module stream (e.g. "nodejs:18").
+	packageModuleStreamRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.+-]*:[A-Za-z0-9][A-Za-z0-9_.+-]*$`)
+
+	// Matches a module name, optionally with a stream (e.g. "nodejs" or "nodejs:18").
+	packageModuleNameRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.+-]*(?::[A-Za-z0-9][A-Za-z0-9_.+-]*)?$`)
+
+	// Matches a module stream, optionally with a profile (e.g. "nodejs:18" or "nodejs:18/development").
+	packageModuleProfileRegex = regexp.MustCompile(
+		`^[A-Za-z0-9][A-Za-z0-9_.+-]*:[A-Za-z0-9][A-Za-z0-9_.+-]*(?:/[A-Za-z0-9][A-Za-z0-9_.+-]*)?$`)
+)
+
+// PackageModules configures the package module streams (i.e. application streams) of the image.
+type PackageModules struct {
+	// The module streams to enable, in the form `name:stream`.
+	Enable []string `yaml:"Enable"`
+	// The modules to disable, in the form `name` or `name:stream`.
+	Disable []string `yaml:"Disable"`
+	// The module profiles to install, in the form `name:stream/profile` or `name:stream` (for the stream's default
+	// profile).
+	Install []string `yaml:"Install"`
+}
+
+func (m *PackageModules) IsValid() error {
+	for i, stream := range m.Enable {
+		if !packageModuleStreamRegex.MatchString(stream) {
+			return fmt.Errorf("invalid module stream (%s) in PackageModules.Enable at index %d: must be of the form name:stream",
+				stream, i)
+		}
+	}
+
+	for i, module := range m.Disable {
+		if !packageModuleNameRegex.MatchString(module) {
+			return fmt.Errorf("invalid module (%s) in PackageModules.Disable at index %d: must be of the form name or name:stream",
+				module, i)
+		}
+	}
+
+	for i, profile := range m.Install {
+		if !packageModuleProfileRegex.MatchString(profile) {
+			return fmt.Errorf("invalid module profile (%s) in PackageModules.Install at index %d: must be of the form name:stream/profile or name:stream",
+				profile, i)
+		}
+	}
+
+	return nil
+}
+
+// HasOperations returns true if there are any module operations to perform.
+func (m *PackageModules) HasOperations() bool {
+	return len(m.Enable) > 0 || len(m.Disable) > 0 || len(m.Install) > 0
+}
@@ -0,0 +1,66 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPackageModulesIsValid(t *testing.T) {
+	value := PackageModules{
+		Enable:  []string{"nodejs:18", "postgresql:15"},
+		Disable: []string{"python36", "perl:5.26"},
+		Install: []string{"nodejs:18/development", "postgresql:15"},
+	}
+
+	err := value.IsValid()
+	assert.NoError(t, err)
+	assert.True(t, value.HasOperations())
+}
+
+func TestPackageModulesIsValidEmpty(t *testing.T) {
+	value := PackageModules{}
+
+	err := value.IsValid()
+	assert.NoError(t, err)
+	assert.False(t, value.HasOperations())
+}
+
+func TestPackageModulesIsValidEnableMissingStream(t *testing.T) {
+	value := PackageModules{
+		Enable: []string{"nodejs"},
+	}
+
+	err := value.IsValid()
+	assert.ErrorContains(t, err, "invalid module stream (nodejs) in PackageModules.Enable at index 0")
+}
+
+func TestPackageModulesIsValidDisableInvalid(t *testing.T) {
+	value := PackageModules{
+		Disable: []string{"nodejs:18/development"},
+	}
+
+	err := value.IsValid()
+	assert.ErrorContains(t, err, "invalid module (nodejs:18/development) in PackageModules.Disable at index 0")
+}
+
+func TestPackageModulesIsValidInstallInvalid(t *testing.T) {
+	for _, profile := range []string{"nodejs", "nodejs:", "nodejs:18/", ":18", "nodejs 18"} {
+		value := PackageModules{
+			Install: []string{profile},
+		}
+
+		err := value.IsValid()
+		assert.ErrorContains(t, err, "PackageModules.Install at index 0", profile)
+	}
+}
+
+func TestParsePackageModules(t *testing.T) {
+	testValidYamlValue(t, "Enable: [\"nodejs:18\"]\nInstall: [\"nodejs:18/common\"]\n", &PackageModules{
+		Enable:  []string{"nodejs:18"},
+		Install: []string{"nodejs:18/common"},
+	})
+}
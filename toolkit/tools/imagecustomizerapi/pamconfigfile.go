@@ -0,0 +1,59 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/sliceutils"
+)
+
+const (
+	PamServiceConfigDir = "/etc/pam.d"
+)
+
+var (
+	// The directories that PAM configuration files may be written to.
+	PamConfigDirs = []string{PamServiceConfigDir, "/etc/security"}
+)
+
+// PamConfigFile is a PAM configuration file to write into the image.
+type PamConfigFile struct {
+	// The path of the file in the target OS.
+	// Must be under either the /etc/pam.d or /etc/security directories.
+	Path string `yaml:"Path"`
+
+	// The contents of the file.
+	Content string `yaml:"Content"`
+}
+
+func (p *PamConfigFile) IsValid() error {
+	if p.Path == "" {
+		return fmt.Errorf("invalid Path value: empty string")
+	}
+
+	if !path.IsAbs(p.Path) || path.Clean(p.Path) != p.Path {
+		return fmt.Errorf("invalid Path value (%s): must be a clean, absolute path", p.Path)
+	}
+
+	inPamConfigDir := sliceutils.ContainsFunc(PamConfigDirs, func(dir string) bool {
+		return isUnderDir(p.Path, dir)
+	})
+	if !inPamConfigDir {
+		return fmt.Errorf("invalid Path value (%s): must be under one of: %s", p.Path, strings.Join(PamConfigDirs, ", "))
+	}
+
+	return nil
+}
+
+// IsInPamDir returns true if the file is a PAM service configuration file (i.e. it is under /etc/pam.d).
+func (p *PamConfigFile) IsInPamDir() bool {
+	return isUnderDir(p.Path, PamServiceConfigDir)
+}
+
+func isUnderDir(filePath string, dir string) bool {
+	return strings.HasPrefix(filePath, dir+"/") && len(filePath) > len(dir)+1
+}
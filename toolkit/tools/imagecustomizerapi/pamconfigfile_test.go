@@ -0,0 +1,68 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPamConfigFileIsValidPamDir(t *testing.T) {
+	value := PamConfigFile{
+		Path:    "/etc/pam.d/sshd",
+		Content: "auth include system-auth\n",
+	}
+
+	err := value.IsValid()
+	assert.NoError(t, err)
+	assert.True(t, value.IsInPamDir())
+}
+
+func TestPamConfigFileIsValidSecurityDir(t *testing.T) {
+	value := PamConfigFile{
+		Path:    "/etc/security/limits.d/10-nofile.conf",
+		Content: "* soft nofile 4096\n",
+	}
+
+	err := value.IsValid()
+	assert.NoError(t, err)
+	assert.False(t, value.IsInPamDir())
+}
+
+func TestPamConfigFileIsValidOutsideDirs(t *testing.T) {
+	value := PamConfigFile{
+		Path: "/etc/passwd",
+	}
+
+	err := value.IsValid()
+	assert.ErrorContains(t, err, "must be under one of")
+}
+
+func TestPamConfigFileIsValidDirItself(t *testing.T) {
+	value := PamConfigFile{
+		Path: "/etc/pam.d",
+	}
+
+	err := value.IsValid()
+	assert.ErrorContains(t, err, "must be under one of")
+}
+
+func TestPamConfigFileIsValidPathEscape(t *testing.T) {
+	value := PamConfigFile{
+		Path: "/etc/pam.d/../shadow",
+	}
+
+	err := value.IsValid()
+	assert.ErrorContains(t, err, "clean, absolute path")
+}
+
+func TestPamConfigFileIsValidRelative(t *testing.T) {
+	value := PamConfigFile{
+		Path: "etc/pam.d/sshd",
+	}
+
+	err := value.IsValid()
+	assert.ErrorContains(t, err, "clean, absolute path")
+}
@@ -10,6 +10,11 @@ import (
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/sliceutils"
 )
 
+const (
+	// GPT partition attributes are a 64-bit field.
+	maxGptAttributeBit = 63
+)
+
 type Partition struct {
 	// ID is used to correlate `Partition` objects with `PartitionSetting` objects.
 	ID string `yaml:"ID"`
@@ -25,6 +30,13 @@ type Partition struct {
 	Size *uint64 `yaml:"Size"`
 	// Flags assigns features to the partition.
 	Flags []PartitionFlag `yaml:"Flags"`
+	// Grow specifies that the partition expands to fill the space up to the next partition (or the end of the disk,
+	// if it is the last partition).
+	Grow bool `yaml:"Grow"`
+	// GptAttributes is the list of GPT partition attribute bits to set (e.g. 60 for read-only).
+	GptAttributes []uint `yaml:"GptAttributes"`
+	// Luks specifies that the partition is encrypted using LUKS.
+	Luks *Luks `yaml:"Luks"`
 }
 
 func (p *Partition) IsValid() error {
@@ -42,6 +54,10 @@ func (p *Partition) IsValid() error {
 		return fmt.Errorf("cannot specify both End and Size on partition (%s)", p.ID)
 	}
 
+	if p.Grow && (p.End != nil || p.Size != nil) {
+		return fmt.Errorf("cannot specify End or Size on partition (%s) that has Grow set", p.ID)
+	}
+
 	if (p.End != nil && p.Start >= *p.End) || (p.Size != nil && *p.Size <= 0) {
 		return fmt.Errorf("partition's (%s) size can't be 0 or negative", p.ID)
 	}
@@ -53,6 +69,13 @@ func (p *Partition) IsValid() error {
 		}
 	}
 
+	for _, attribute := range p.GptAttributes {
+		if attribute > maxGptAttributeBit {
+			return fmt.Errorf("partition's (%s) GptAttributes value (%d) must be between 0 and %d", p.ID, attribute,
+				maxGptAttributeBit)
+		}
+	}
+
 	isESP := sliceutils.ContainsValue(p.Flags, PartitionFlagESP)
 	if isESP {
 		if p.FsType != FileSystemTypeFat32 {
@@ -61,6 +84,22 @@ func (p *Partition) IsValid() error {
 	}
 
 	isBiosBoot := sliceutils.ContainsValue(p.Flags, PartitionFlagBiosGrub)
+
+	if p.Luks != nil {
+		err := p.Luks.IsValid()
+		if err != nil {
+			return fmt.Errorf("invalid partition (%s) Luks value:\n%w", p.ID, err)
+		}
+
+		// The firmware and the bootloader must be able to read these partitions.
+		if isESP || isBiosBoot {
+			return fmt.Errorf("partition (%s) is a boot partition and so can't be encrypted", p.ID)
+		}
+
+		if p.FsType == FileSystemTypeNone {
+			return fmt.Errorf("encrypted partition (%s) must have a filesystem type", p.ID)
+		}
+	}
 	if isBiosBoot {
 		if p.Start != 1 {
 			return fmt.Errorf("BIOS boot partition must start at block 1")
@@ -157,7 +157,7 @@ func TestPartitionIsValidBadFlag(t *testing.T) {
 
 	err := partition.IsValid()
 	assert.Error(t, err)
-	assert.ErrorContains(t, err, "PartitionFlag")
+	assert.ErrorContains(t, err, "unknown PartitionFlag value (a); must be one of: esp, bios_grub, boot")
 }
 
 func TestPartitionIsValidUnsupportedFileSystem(t *testing.T) {
@@ -171,7 +171,7 @@ func TestPartitionIsValidUnsupportedFileSystem(t *testing.T) {
 
 	err := partition.IsValid()
 	assert.Error(t, err)
-	assert.ErrorContains(t, err, "FileSystemType")
+	assert.ErrorContains(t, err, "invalid FileSystemType value (ntfs); must be one of: ext4, xfs, fat32, none")
 }
 
 func TestPartitionIsValidBadEspFsType(t *testing.T) {
@@ -218,3 +218,97 @@ func TestPartitionIsValidBadBiosBootStart(t *testing.T) {
 	assert.ErrorContains(t, err, "BIOS boot")
 	assert.ErrorContains(t, err, "start")
 }
+
+func TestPartitionIsValidGrowWithEnd(t *testing.T) {
+	partition := Partition{
+		ID:     "a",
+		FsType: "ext4",
+		Start:  1,
+		End:    ptrutils.PtrTo(uint64(2)),
+		Grow:   true,
+	}
+
+	err := partition.IsValid()
+	assert.Error(t, err)
+	assert.ErrorContains(t, err, "cannot specify End or Size on partition (a) that has Grow set")
+}
+
+func TestPartitionIsValidGptAttributes(t *testing.T) {
+	partition := Partition{
+		ID:            "a",
+		FsType:        "ext4",
+		Start:         0,
+		GptAttributes: []uint{60, 63},
+	}
+
+	err := partition.IsValid()
+	assert.NoError(t, err)
+}
+
+func TestPartitionIsValidGptAttributesOutOfRange(t *testing.T) {
+	partition := Partition{
+		ID:            "a",
+		FsType:        "ext4",
+		Start:         0,
+		GptAttributes: []uint{64},
+	}
+
+	err := partition.IsValid()
+	assert.ErrorContains(t, err, "GptAttributes value (64) must be between 0 and 63")
+}
+
+func TestPartitionIsValidLuks(t *testing.T) {
+	partition := Partition{
+		ID:     "a",
+		FsType: "ext4",
+		Start:  0,
+		Luks: &Luks{
+			PassphraseFile: "files/passphrase.txt",
+		},
+	}
+
+	err := partition.IsValid()
+	assert.NoError(t, err)
+}
+
+func TestPartitionIsValidLuksMissingPassphraseFile(t *testing.T) {
+	partition := Partition{
+		ID:     "a",
+		FsType: "ext4",
+		Start:  0,
+		Luks:   &Luks{},
+	}
+
+	err := partition.IsValid()
+	assert.ErrorContains(t, err, "invalid partition (a) Luks value")
+	assert.ErrorContains(t, err, "PassphraseFile must be specified")
+}
+
+func TestPartitionIsValidLuksEsp(t *testing.T) {
+	partition := Partition{
+		ID:     "a",
+		FsType: "fat32",
+		Start:  0,
+		Flags:  []PartitionFlag{"esp"},
+		Luks: &Luks{
+			PassphraseFile: "files/passphrase.txt",
+		},
+	}
+
+	err := partition.IsValid()
+	assert.ErrorContains(t, err, "partition (a) is a boot partition and so can't be encrypted")
+}
+
+func TestPartitionIsValidLuksUnformatted(t *testing.T) {
+	partition := Partition{
+		ID:     "a",
+		FsType: "none",
+		Start:  0,
+		Luks: &Luks{
+			PassphraseFile: "files/passphrase.txt",
+		},
+	}
+
+	err := partition.IsValid()
+	assert.ErrorContains(t, err, "encrypted partition (a) must have a filesystem type")
+}
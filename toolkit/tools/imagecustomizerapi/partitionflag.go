@@ -29,6 +29,10 @@ const (
 	PartitionFlagBoot PartitionFlag = "boot"
 )
 
+var (
+	partitionFlagValues = []PartitionFlag{PartitionFlagESP, PartitionFlagBiosGrub, PartitionFlagBoot}
+)
+
 func (p PartitionFlag) IsValid() (err error) {
 	switch p {
 	case PartitionFlagBoot, PartitionFlagBiosGrub, PartitionFlagESP:
@@ -36,6 +40,6 @@ func (p PartitionFlag) IsValid() (err error) {
 		return nil
 
 	default:
-		return fmt.Errorf("unknown PartitionFlag value (%s)", p)
+		return fmt.Errorf("unknown PartitionFlag value (%s); must be one of: %s", p, enumValuesString(partitionFlagValues))
 	}
 }
@@ -19,4 +19,5 @@ func TestPartitionIsValidInvalidMountIdentifier(t *testing.T) {
 	assert.Error(t, err)
 	assert.ErrorContains(t, err, "invalid")
 	assert.ErrorContains(t, err, "MountIdentifierType")
+	assert.ErrorContains(t, err, "must be one of: uuid, partuuid, partlabel")
 }
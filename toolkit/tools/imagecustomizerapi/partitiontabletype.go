@@ -12,15 +12,21 @@ type PartitionTableType string
 
 const (
 	PartitionTableTypeGpt PartitionTableType = "gpt"
+	PartitionTableTypeMbr PartitionTableType = "mbr"
+)
+
+var (
+	partitionTableTypeValues = []PartitionTableType{PartitionTableTypeGpt, PartitionTableTypeMbr}
 )
 
 func (t PartitionTableType) IsValid() error {
 	switch t {
-	case PartitionTableTypeGpt:
+	case PartitionTableTypeGpt, PartitionTableTypeMbr:
 		// All good.
 		return nil
 
 	default:
-		return fmt.Errorf("invalid PartitionTableType value (%s)", t)
+		return fmt.Errorf("invalid PartitionTableType value (%s); must be one of: %s", t,
+			enumValuesString(partitionTableTypeValues))
 	}
 }
@@ -0,0 +1,34 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+const (
+	// The value that a password is replaced with when it is formatted or serialized.
+	RedactedPassword = "<redacted>"
+)
+
+// A user's password.
+//
+// To avoid leaking the password into logs or config dumps, the value is masked when it is formatted (e.g. using
+// fmt's %v verb) or serialized as YAML.
+// Use string(password) to get the actual value.
+type Password string
+
+// String returns the masked password.
+func (p Password) String() string {
+	if p == "" {
+		return ""
+	}
+
+	return RedactedPassword
+}
+
+// GoString returns the masked password, so that it isn't leaked by fmt's %#v verb.
+func (p Password) GoString() string {
+	return "\"" + p.String() + "\""
+}
+
+func (p Password) MarshalYAML() (interface{}, error) {
+	return p.String(), nil
+}
@@ -0,0 +1,60 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestPasswordString(t *testing.T) {
+	assert.Equal(t, RedactedPassword, Password("secret").String())
+	assert.Equal(t, "", Password("").String())
+}
+
+func TestPasswordFormatDoesNotLeak(t *testing.T) {
+	user := User{
+		Name:     "test",
+		Password: "supersecretpassword",
+	}
+
+	for _, format := range []string{"%v", "%+v", "%#v", "%s"} {
+		formatted := fmt.Sprintf(format, user)
+		assert.NotContains(t, formatted, "supersecretpassword", format)
+		assert.Contains(t, formatted, RedactedPassword, format)
+	}
+}
+
+func TestPasswordMarshalYamlDoesNotLeak(t *testing.T) {
+	config := Config{
+		SystemConfig: SystemConfig{
+			Users: []User{
+				{
+					Name:     "test",
+					Password: "supersecretpassword",
+				},
+			},
+		},
+	}
+
+	yamlData, err := MarshalYaml(&config)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(yamlData), "supersecretpassword")
+	assert.Contains(t, string(yamlData), "Password: <redacted>")
+
+	yamlData, err = yaml.Marshal(&config)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(yamlData), "supersecretpassword")
+}
+
+func TestPasswordUnmarshal(t *testing.T) {
+	var user User
+	err := UnmarshalYaml([]byte("Name: test\nPassword: supersecretpassword\n"), &user)
+	assert.NoError(t, err)
+	assert.Equal(t, Password("supersecretpassword"), user.Password)
+	assert.Equal(t, "supersecretpassword", string(user.Password))
+}
@@ -0,0 +1,198 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+//go:generate go run ./schemagen ../imagecustomizer/docs/config.schema.json
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+const (
+	jsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+)
+
+// jsonSchema is a subset of a JSON Schema object.
+type jsonSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Ref                  string                 `json:"$ref,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Enum                 []string               `json:"enum,omitempty"`
+	Pattern              string                 `json:"pattern,omitempty"`
+	Minimum              *int                   `json:"minimum,omitempty"`
+	Items                *jsonSchema            `json:"items,omitempty"`
+	Properties           map[string]*jsonSchema `json:"properties,omitempty"`
+	AdditionalProperties interface{}            `json:"additionalProperties,omitempty"`
+	OneOf                []*jsonSchema          `json:"oneOf,omitempty"`
+	Defs                 map[string]*jsonSchema `json:"$defs,omitempty"`
+}
+
+var (
+	// The valid values of each of the string enum types.
+	jsonSchemaEnums = map[reflect.Type][]string{
+		reflect.TypeOf(AutoinstallSeedType("")): enumValueStrings(autoinstallSeedTypeValues),
+		reflect.TypeOf(BootType("")):            enumValueStrings(bootTypeValues),
+		reflect.TypeOf(FileAttribute("")):       enumValueStrings(fileAttributeValues),
+		reflect.TypeOf(FileSystemType("")):      enumValueStrings(fileSystemTypeValues),
+		reflect.TypeOf(IdType("")):              enumValueStrings(idTypeValues),
+		reflect.TypeOf(MountIdentifierType("")): enumValueStrings(mountIdentifierTypeValues),
+		reflect.TypeOf(PartitionFlag("")):       enumValueStrings(partitionFlagValues),
+		reflect.TypeOf(PartitionTableType("")):  enumValueStrings(partitionTableTypeValues),
+	}
+)
+
+type jsonSchemaGenerator struct {
+	defs map[string]*jsonSchema
+}
+
+// JsonSchema generates a JSON Schema for the Config type.
+//
+// The schema only describes the structure of the config. So, a config that matches the schema might still fail the
+// IsValid() checks.
+func JsonSchema() ([]byte, error) {
+	generator := jsonSchemaGenerator{
+		defs: make(map[string]*jsonSchema),
+	}
+
+	schema := generator.structSchema(reflect.TypeOf(Config{}))
+	schema.Schema = jsonSchemaDialect
+	schema.Title = "Mariner Image Customizer config"
+	schema.Defs = generator.defs
+
+	schemaBytes, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize JSON schema:\n%w", err)
+	}
+
+	schemaBytes = append(schemaBytes, '\n')
+	return schemaBytes, nil
+}
+
+// typeSchema returns the schema for a type.
+// Named types are added to the schema's definitions and referenced, so that they only appear once.
+func (g *jsonSchemaGenerator) typeSchema(t reflect.Type) *jsonSchema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	isNamed := t.PkgPath() != "" && t.Name() != ""
+	if !isNamed {
+		return g.unnamedTypeSchema(t)
+	}
+
+	ref := &jsonSchema{Ref: "#/$defs/" + t.Name()}
+
+	if _, exists := g.defs[t.Name()]; exists {
+		return ref
+	}
+
+	// Add a placeholder to handle recursive types.
+	g.defs[t.Name()] = nil
+
+	var schema *jsonSchema
+	if override := g.overrideSchema(t); override != nil {
+		schema = override
+	} else if enumValues, isEnum := jsonSchemaEnums[t]; isEnum {
+		schema = &jsonSchema{Type: "string", Enum: enumValues}
+	} else {
+		schema = g.unnamedTypeSchema(t)
+	}
+
+	g.defs[t.Name()] = schema
+	return ref
+}
+
+// overrideSchema returns the schema for types that have a custom YAML unmarshaller and therefore don't match their Go
+// type's structure.
+// Returns nil if the type doesn't have a custom unmarshaller.
+func (g *jsonSchemaGenerator) overrideSchema(t reflect.Type) *jsonSchema {
+	switch t {
+	case reflect.TypeOf(FilePermissions(0)), reflect.TypeOf(Umask(0)):
+		// Octal string.
+		return &jsonSchema{Type: "string", Pattern: "^[0-7]{1,4}$"}
+
+	case reflect.TypeOf(FileConfig{}):
+		// A FileConfig may be specified as just the destination path.
+		return &jsonSchema{
+			OneOf: []*jsonSchema{
+				{Type: "string"},
+				g.structSchema(t),
+			},
+		}
+
+	case reflect.TypeOf(FileConfigList{}):
+		// A FileConfigList may be specified as a single FileConfig.
+		fileConfigSchema := g.typeSchema(reflect.TypeOf(FileConfig{}))
+		return &jsonSchema{
+			OneOf: []*jsonSchema{
+				fileConfigSchema,
+				{Type: "array", Items: fileConfigSchema},
+			},
+		}
+
+	default:
+		return nil
+	}
+}
+
+func (g *jsonSchemaGenerator) unnamedTypeSchema(t reflect.Type) *jsonSchema {
+	switch t.Kind() {
+	case reflect.Struct:
+		return g.structSchema(t)
+
+	case reflect.Slice, reflect.Array:
+		return &jsonSchema{Type: "array", Items: g.typeSchema(t.Elem())}
+
+	case reflect.Map:
+		return &jsonSchema{Type: "object", AdditionalProperties: g.typeSchema(t.Elem())}
+
+	case reflect.String:
+		return &jsonSchema{Type: "string"}
+
+	case reflect.Bool:
+		return &jsonSchema{Type: "boolean"}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &jsonSchema{Type: "integer"}
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		minimum := 0
+		return &jsonSchema{Type: "integer", Minimum: &minimum}
+
+	default:
+		panic(fmt.Sprintf("unsupported type in JSON schema (%s)", t))
+	}
+}
+
+func (g *jsonSchemaGenerator) structSchema(t reflect.Type) *jsonSchema {
+	schema := &jsonSchema{
+		Type:                 "object",
+		Properties:           make(map[string]*jsonSchema),
+		AdditionalProperties: false,
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			// Match the yaml package's default field naming.
+			name = strings.ToLower(field.Name)
+		}
+
+		schema.Properties[name] = g.typeSchema(field.Type)
+	}
+
+	return schema
+}
@@ -0,0 +1,53 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	jsonSchemaFilePath = "../imagecustomizer/docs/config.schema.json"
+)
+
+func TestJsonSchemaUpToDate(t *testing.T) {
+	schema, err := JsonSchema()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	expectedSchema, err := os.ReadFile(jsonSchemaFilePath)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equalf(t, string(expectedSchema), string(schema),
+		"%s is out of date. Run 'go generate ./imagecustomizerapi' to update it.", jsonSchemaFilePath)
+}
+
+func TestJsonSchemaEnumsValid(t *testing.T) {
+	for enumType, values := range jsonSchemaEnums {
+		for _, value := range values {
+			enumValue := reflect.New(enumType).Elem()
+			enumValue.SetString(value)
+
+			err := enumValue.Interface().(HasIsValid).IsValid()
+			assert.NoErrorf(t, err, "type (%s) value (%s)", enumType, value)
+		}
+	}
+}
+
+func TestJsonSchemaEnumsInvalid(t *testing.T) {
+	for enumType := range jsonSchemaEnums {
+		enumValue := reflect.New(enumType).Elem()
+		enumValue.SetString("not-a-valid-value")
+
+		err := enumValue.Interface().(HasIsValid).IsValid()
+		assert.Errorf(t, err, "type (%s)", enumType)
+	}
+}
@@ -0,0 +1,31 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+// schemagen writes the JSON Schema of the image customizer config to a file.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagecustomizerapi"
+)
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprintf(os.Stderr, "usage: %s <output-file>\n", os.Args[0])
+		os.Exit(1)
+	}
+
+	schema, err := imagecustomizerapi.JsonSchema()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	err = os.WriteFile(os.Args[1], schema, 0o644)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write JSON schema file:\n%v\n", err)
+		os.Exit(1)
+	}
+}
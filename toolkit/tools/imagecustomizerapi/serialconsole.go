@@ -0,0 +1,79 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+const (
+	// The kernel's default serial console baud rate.
+	defaultSerialConsoleBaudRate = 9600
+)
+
+var (
+	// Matches the kernel's serial console format: ttyS<n>[,<baud>[<parity>[<bits>]]].
+	serialConsoleRegex = regexp.MustCompile(`^ttyS(\d+)(?:,(\d+)(?:([noe])([5-8])?)?)?$`)
+)
+
+// SerialConsole is a serial console spec, in the same format as the kernel's `console=` arg (e.g. "ttyS0,115200n8").
+type SerialConsole string
+
+// SerialConsoleSettings contains the parsed values of a SerialConsole spec.
+type SerialConsoleSettings struct {
+	Unit     int
+	BaudRate int
+	// One of: 'n' (none), 'o' (odd), or 'e' (even).
+	Parity   byte
+	WordBits int
+}
+
+func (c SerialConsole) IsValid() error {
+	_, err := c.Settings()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Settings parses the serial console spec.
+func (c SerialConsole) Settings() (SerialConsoleSettings, error) {
+	match := serialConsoleRegex.FindStringSubmatch(string(c))
+	if match == nil {
+		return SerialConsoleSettings{}, fmt.Errorf("invalid serial console (%s): must be in the format "+
+			"ttyS<n>[,<baud>[<parity>[<bits>]]] (e.g. ttyS0,115200n8)", c)
+	}
+
+	settings := SerialConsoleSettings{
+		BaudRate: defaultSerialConsoleBaudRate,
+		Parity:   'n',
+		WordBits: 8,
+	}
+
+	var err error
+	settings.Unit, err = strconv.Atoi(match[1])
+	if err != nil {
+		return SerialConsoleSettings{}, fmt.Errorf("invalid serial console (%s) unit:\n%w", c, err)
+	}
+
+	if match[2] != "" {
+		settings.BaudRate, err = strconv.Atoi(match[2])
+		if err != nil || settings.BaudRate <= 0 {
+			return SerialConsoleSettings{}, fmt.Errorf("invalid serial console (%s) baud rate", c)
+		}
+	}
+
+	if match[3] != "" {
+		settings.Parity = match[3][0]
+	}
+
+	if match[4] != "" {
+		settings.WordBits, _ = strconv.Atoi(match[4])
+	}
+
+	return settings, nil
+}
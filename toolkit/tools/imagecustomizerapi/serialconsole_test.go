@@ -0,0 +1,43 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSerialConsoleSettingsFull(t *testing.T) {
+	settings, err := SerialConsole("ttyS1,115200e7").Settings()
+	assert.NoError(t, err)
+	assert.Equal(t, SerialConsoleSettings{Unit: 1, BaudRate: 115200, Parity: 'e', WordBits: 7}, settings)
+}
+
+func TestSerialConsoleSettingsBaudOnly(t *testing.T) {
+	settings, err := SerialConsole("ttyS0,115200").Settings()
+	assert.NoError(t, err)
+	assert.Equal(t, SerialConsoleSettings{Unit: 0, BaudRate: 115200, Parity: 'n', WordBits: 8}, settings)
+}
+
+func TestSerialConsoleSettingsDeviceOnly(t *testing.T) {
+	settings, err := SerialConsole("ttyS0").Settings()
+	assert.NoError(t, err)
+	assert.Equal(t, SerialConsoleSettings{Unit: 0, BaudRate: 9600, Parity: 'n', WordBits: 8}, settings)
+}
+
+func TestSerialConsoleIsValidBadDevice(t *testing.T) {
+	err := SerialConsole("tty0").IsValid()
+	assert.ErrorContains(t, err, "invalid serial console (tty0)")
+}
+
+func TestSerialConsoleIsValidBadBaudRate(t *testing.T) {
+	err := SerialConsole("ttyS0,fast").IsValid()
+	assert.ErrorContains(t, err, "invalid serial console (ttyS0,fast)")
+}
+
+func TestSerialConsoleIsValidBadWordBits(t *testing.T) {
+	err := SerialConsole("ttyS0,115200n9").IsValid()
+	assert.ErrorContains(t, err, "invalid serial console (ttyS0,115200n9)")
+}
@@ -5,6 +5,14 @@ package imagecustomizerapi
 
 import (
 	"fmt"
+	"strings"
+)
+
+var (
+	// The systemd unit types.
+	systemdUnitTypes = []string{
+		"service", "socket", "device", "mount", "automount", "swap", "target", "path", "timer", "slice", "scope",
+	}
 )
 
 type Service struct {
@@ -37,5 +45,32 @@ func (s *Services) IsValid() error {
 		}
 	}
 
+	// Check that a service isn't both enabled and disabled.
+	enableUnitNames := make(map[string]bool)
+	for _, service := range s.Enable {
+		enableUnitNames[serviceUnitName(service.Name)] = true
+	}
+
+	for _, service := range s.Disable {
+		if enableUnitNames[serviceUnitName(service.Name)] {
+			return fmt.Errorf("service '%s' is in both Services.Enable and Services.Disable", service.Name)
+		}
+	}
+
 	return nil
 }
+
+// serviceUnitName returns the full unit name of a service.
+// Like systemctl, a name without a unit type suffix (e.g. "sshd") is treated as a service (e.g. "sshd.service").
+func serviceUnitName(name string) string {
+	index := strings.LastIndex(name, ".")
+	if index >= 0 {
+		for _, unitType := range systemdUnitTypes {
+			if name[index+1:] == unitType {
+				return name
+			}
+		}
+	}
+
+	return name + ".service"
+}
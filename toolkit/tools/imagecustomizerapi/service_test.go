@@ -0,0 +1,67 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServicesIsValid(t *testing.T) {
+	value := Services{
+		Enable:  []Service{{Name: "sshd"}, {Name: "systemd-networkd.socket"}},
+		Disable: []Service{{Name: "bluetooth"}},
+	}
+
+	err := value.IsValid()
+	assert.NoError(t, err)
+}
+
+func TestServicesIsValidEmptyName(t *testing.T) {
+	value := Services{
+		Enable: []Service{{Name: ""}},
+	}
+
+	err := value.IsValid()
+	assert.ErrorContains(t, err, "name of service may not be empty")
+}
+
+func TestServicesIsValidEnableAndDisable(t *testing.T) {
+	value := Services{
+		Enable:  []Service{{Name: "sshd"}},
+		Disable: []Service{{Name: "sshd"}},
+	}
+
+	err := value.IsValid()
+	assert.ErrorContains(t, err, "service 'sshd' is in both Services.Enable and Services.Disable")
+}
+
+func TestServicesIsValidEnableAndDisableUnitSuffix(t *testing.T) {
+	value := Services{
+		Enable:  []Service{{Name: "sshd"}},
+		Disable: []Service{{Name: "sshd.service"}},
+	}
+
+	err := value.IsValid()
+	assert.ErrorContains(t, err, "service 'sshd.service' is in both Services.Enable and Services.Disable")
+}
+
+func TestServicesIsValidDifferentUnitTypes(t *testing.T) {
+	value := Services{
+		Enable:  []Service{{Name: "sshd.socket"}},
+		Disable: []Service{{Name: "sshd"}},
+	}
+
+	err := value.IsValid()
+	assert.NoError(t, err)
+}
+
+func TestServiceUnitName(t *testing.T) {
+	assert.Equal(t, "sshd.service", serviceUnitName("sshd"))
+	assert.Equal(t, "sshd.service", serviceUnitName("sshd.service"))
+	assert.Equal(t, "sshd.socket", serviceUnitName("sshd.socket"))
+	assert.Equal(t, "getty@tty1.service", serviceUnitName("getty@tty1"))
+	assert.Equal(t, "foo.bar.service", serviceUnitName("foo.bar"))
+}
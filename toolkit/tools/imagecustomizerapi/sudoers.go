@@ -0,0 +1,35 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SudoersFile is a drop-in file to place in the /etc/sudoers.d directory.
+type SudoersFile struct {
+	// The name of the file within the /etc/sudoers.d directory.
+	Name string `yaml:"Name"`
+
+	// The contents of the file.
+	Content string `yaml:"Content"`
+}
+
+func (s *SudoersFile) IsValid() error {
+	if s.Name == "" {
+		return fmt.Errorf("invalid Name value: empty string")
+	}
+
+	// sudo's @includedir directive silently skips files whose names end with '~' or contain a '.'.
+	if strings.ContainsAny(s.Name, "/.") || strings.HasSuffix(s.Name, "~") {
+		return fmt.Errorf("invalid Name value (%s): must not contain '/' or '.' characters or end with '~'", s.Name)
+	}
+
+	if s.Content == "" {
+		return fmt.Errorf("invalid Content value for sudoers file (%s): empty string", s.Name)
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSudoersFileIsValid(t *testing.T) {
+	value := SudoersFile{
+		Name:    "wheel",
+		Content: "%wheel ALL=(ALL) ALL\n",
+	}
+
+	err := value.IsValid()
+	assert.NoError(t, err)
+}
+
+func TestSudoersFileIsValidEmptyName(t *testing.T) {
+	value := SudoersFile{
+		Content: "%wheel ALL=(ALL) ALL\n",
+	}
+
+	err := value.IsValid()
+	assert.ErrorContains(t, err, "invalid Name value")
+}
+
+func TestSudoersFileIsValidNameWithDot(t *testing.T) {
+	value := SudoersFile{
+		Name:    "wheel.conf",
+		Content: "%wheel ALL=(ALL) ALL\n",
+	}
+
+	err := value.IsValid()
+	assert.ErrorContains(t, err, "invalid Name value")
+}
+
+func TestSudoersFileIsValidNameWithSlash(t *testing.T) {
+	value := SudoersFile{
+		Name:    "../sudoers",
+		Content: "%wheel ALL=(ALL) ALL\n",
+	}
+
+	err := value.IsValid()
+	assert.ErrorContains(t, err, "invalid Name value")
+}
+
+func TestSystemConfigIsValidDuplicateSudoersName(t *testing.T) {
+	value := SystemConfig{
+		Sudoers: []SudoersFile{
+			{
+				Name:    "wheel",
+				Content: "%wheel ALL=(ALL) ALL\n",
+			},
+			{
+				Name:    "wheel",
+				Content: "%wheel ALL=(ALL) NOPASSWD: ALL\n",
+			},
+		},
+	}
+
+	err := value.IsValid()
+	assert.ErrorContains(t, err, "duplicate Sudoers Name")
+}
@@ -0,0 +1,88 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+// symlinksAreValid checks the Symlinks entries, which map a link's path to its target.
+func symlinksAreValid(symlinks map[string]string) error {
+	linkPaths := make([]string, 0, len(symlinks))
+	for linkPath := range symlinks {
+		linkPaths = append(linkPaths, linkPath)
+	}
+	sort.Strings(linkPaths)
+
+	cleanLinkPaths := make(map[string]string)
+	for _, linkPath := range linkPaths {
+		err := imageFilePathIsValid(linkPath)
+		if err != nil {
+			return fmt.Errorf("invalid link path:\n%w", err)
+		}
+
+		cleanLinkPath := filepath.Clean(linkPath)
+		if otherLinkPath, duplicate := cleanLinkPaths[cleanLinkPath]; duplicate {
+			return fmt.Errorf("link paths (%s) and (%s) refer to the same file", otherLinkPath, linkPath)
+		}
+		cleanLinkPaths[cleanLinkPath] = linkPath
+
+		if symlinks[linkPath] == "" {
+			return fmt.Errorf("target of symlink (%s) may not be empty", linkPath)
+		}
+	}
+
+	for _, linkPath := range linkPaths {
+		err := checkSymlinkCycle(symlinks, linkPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkSymlinkCycle follows a chain of symlinks through the Symlinks entries and checks that it doesn't loop back on
+// itself.
+// Note: Only the Symlinks entries are checked. Cycles formed with symlinks that already exist in the image are
+// detected when the symlinks are resolved by the OS.
+func checkSymlinkCycle(symlinks map[string]string, linkPath string) error {
+	visited := make(map[string]bool)
+
+	current := filepath.Clean(linkPath)
+	for {
+		if visited[current] {
+			return fmt.Errorf("symlink (%s) forms a cycle", linkPath)
+		}
+		visited[current] = true
+
+		target, found := lookupSymlink(symlinks, current)
+		if !found {
+			return nil
+		}
+
+		current = resolveSymlinkTarget(current, target)
+	}
+}
+
+func lookupSymlink(symlinks map[string]string, path string) (string, bool) {
+	for linkPath, target := range symlinks {
+		if filepath.Clean(linkPath) == path {
+			return target, true
+		}
+	}
+
+	return "", false
+}
+
+// resolveSymlinkTarget returns the absolute path that a symlink's target refers to.
+func resolveSymlinkTarget(linkPath string, target string) string {
+	if filepath.IsAbs(target) {
+		return filepath.Clean(target)
+	}
+
+	return filepath.Join(filepath.Dir(linkPath), target)
+}
@@ -0,0 +1,72 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSymlinksAreValid(t *testing.T) {
+	err := symlinksAreValid(map[string]string{
+		"/usr/bin/python":       "python3",
+		"/usr/bin/python3":      "python3.9",
+		"/etc/localtime":        "/usr/share/zoneinfo/UTC",
+		"/usr/lib/libfoo.so":    "../lib64/libfoo.so.1",
+		"/usr/lib64/libfoo.so1": "libfoo.so.1",
+	})
+	assert.NoError(t, err)
+}
+
+func TestSymlinksAreValidRelativeLinkPath(t *testing.T) {
+	err := symlinksAreValid(map[string]string{
+		"usr/bin/python": "python3",
+	})
+	assert.ErrorContains(t, err, "path (usr/bin/python) must be an absolute path")
+}
+
+func TestSymlinksAreValidLinkPathDotDot(t *testing.T) {
+	err := symlinksAreValid(map[string]string{
+		"/usr/../../python": "python3",
+	})
+	assert.ErrorContains(t, err, "must not contain '..'")
+}
+
+func TestSymlinksAreValidEmptyTarget(t *testing.T) {
+	err := symlinksAreValid(map[string]string{
+		"/usr/bin/python": "",
+	})
+	assert.ErrorContains(t, err, "target of symlink (/usr/bin/python) may not be empty")
+}
+
+func TestSymlinksAreValidDuplicate(t *testing.T) {
+	err := symlinksAreValid(map[string]string{
+		"/usr/bin/python":   "python3",
+		"/usr/bin//python/": "python2",
+	})
+	assert.ErrorContains(t, err, "refer to the same file")
+}
+
+func TestSymlinksAreValidSelfCycle(t *testing.T) {
+	err := symlinksAreValid(map[string]string{
+		"/usr/bin/python": "./python",
+	})
+	assert.ErrorContains(t, err, "symlink (/usr/bin/python) forms a cycle")
+}
+
+func TestSymlinksAreValidCycle(t *testing.T) {
+	err := symlinksAreValid(map[string]string{
+		"/usr/bin/a":       "b",
+		"/usr/bin/b":       "/usr/local/bin/c",
+		"/usr/local/bin/c": "../../bin/a",
+	})
+	assert.ErrorContains(t, err, "forms a cycle")
+}
+
+func TestResolveSymlinkTarget(t *testing.T) {
+	assert.Equal(t, "/usr/bin/python3", resolveSymlinkTarget("/usr/bin/python", "python3"))
+	assert.Equal(t, "/usr/lib64/libfoo.so.1", resolveSymlinkTarget("/usr/lib/libfoo.so", "../lib64/libfoo.so.1"))
+	assert.Equal(t, "/usr/share/zoneinfo/UTC", resolveSymlinkTarget("/etc/localtime", "/usr/share/zoneinfo//UTC"))
+}
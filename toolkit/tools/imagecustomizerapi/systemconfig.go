@@ -4,69 +4,194 @@
 package imagecustomizerapi
 
 import (
+	"errors"
 	"fmt"
+	"path/filepath"
+	"regexp"
 	"strings"
+	"time"
+
+	// Embed the timezone database, so that the Timezone values can be validated on hosts without tzdata.
+	_ "time/tzdata"
 
 	"github.com/asaskevich/govalidator"
 )
 
+var (
+	// Matches the tdnf metadata_expire values: a number of seconds (optionally with a s, m, h or d unit suffix),
+	// 'never', or -1 (i.e. never).
+	packagesMetadataExpireRegex = regexp.MustCompile(`^(?:[0-9]+[smhd]?|never|-1)$`)
+
+	// Matches a locale name: language[_territory][.codeset][@modifier] (e.g. "en_US.UTF-8"), "C" or "POSIX".
+	localeRegex = regexp.MustCompile(
+		`^(?:(?:[a-z]{2,3}(?:_[A-Z]{2})?|C)(?:\.[A-Za-z0-9-]+)?(?:@[A-Za-z0-9]+)?|POSIX)$`)
+)
+
 // SystemConfig defines how each system present on the image is supposed to be configured.
 type SystemConfig struct {
-	BootType                BootType                  `yaml:"BootType"`
-	Hostname                string                    `yaml:"Hostname"`
-	UpdateBaseImagePackages bool                      `yaml:"UpdateBaseImagePackages"`
-	PackageListsInstall     []string                  `yaml:"PackageListsInstall"`
-	PackagesInstall         []string                  `yaml:"PackagesInstall"`
-	PackageListsRemove      []string                  `yaml:"PackageListsRemove"`
-	PackagesRemove          []string                  `yaml:"PackagesRemove"`
-	PackageListsUpdate      []string                  `yaml:"PackageListsUpdate"`
-	PackagesUpdate          []string                  `yaml:"PackagesUpdate"`
-	KernelCommandLine       KernelCommandLine         `yaml:"KernelCommandLine"`
-	AdditionalFiles         map[string]FileConfigList `yaml:"AdditionalFiles"`
-	PartitionSettings       []PartitionSetting        `yaml:"PartitionSettings"`
-	PostInstallScripts      []Script                  `yaml:"PostInstallScripts"`
-	FinalizeImageScripts    []Script                  `yaml:"FinalizeImageScripts"`
-	Users                   []User                    `yaml:"Users"`
-	Services                Services                  `yaml:"Services"`
-	Modules                 Modules                   `yaml:"Modules"`
-	Verity                  *Verity                   `yaml:"Verity"`
+	BootType                 BootType                  `yaml:"BootType"`
+	Hostname                 string                    `yaml:"Hostname"`
+	Timezone                 string                    `yaml:"Timezone"`
+	Locale                   string                    `yaml:"Locale"`
+	UpdateBaseImagePackages  bool                      `yaml:"UpdateBaseImagePackages"`
+	PackageListsInstall      []string                  `yaml:"PackageListsInstall"`
+	PackagesInstall          []string                  `yaml:"PackagesInstall"`
+	SkipInstalledPackages    bool                      `yaml:"SkipInstalledPackages"`
+	PackagesInstallDebuginfo bool                      `yaml:"PackagesInstallDebuginfo"`
+	PreflightPackages        bool                      `yaml:"PreflightPackages"`
+	PackageListsRemove       []string                  `yaml:"PackageListsRemove"`
+	PackagesRemove           []string                  `yaml:"PackagesRemove"`
+	PackagesAutoremove       bool                      `yaml:"PackagesAutoremove"`
+	PackageListsUpdate       []string                  `yaml:"PackageListsUpdate"`
+	PackagesUpdate           []string                  `yaml:"PackagesUpdate"`
+	PackageListsDowngrade    []string                  `yaml:"PackageListsDowngrade"`
+	PackagesDowngrade        []string                  `yaml:"PackagesDowngrade"`
+	PackageListsKeep         []string                  `yaml:"PackageListsKeep"`
+	PackagesKeep             []string                  `yaml:"PackagesKeep"`
+	TdnfCommands             [][]string                `yaml:"TdnfCommands"`
+	PackagesGpgCheck         bool                      `yaml:"PackagesGpgCheck"`
+	PackagesGpgKeys          []string                  `yaml:"PackagesGpgKeys"`
+	PackagesMakeCache        bool                      `yaml:"PackagesMakeCache"`
+	PackagesMetadataExpire   string                    `yaml:"PackagesMetadataExpire"`
+	PackageModules           PackageModules            `yaml:"PackageModules"`
+	ReleaseVersion           string                    `yaml:"ReleaseVersion"`
+	KernelCommandLine        KernelCommandLine         `yaml:"KernelCommandLine"`
+	Bootloader               *Bootloader               `yaml:"Bootloader"`
+	RemoveFiles              []string                  `yaml:"RemoveFiles"`
+	AdditionalFiles          map[string]FileConfigList `yaml:"AdditionalFiles"`
+	Symlinks                 map[string]string         `yaml:"Symlinks"`
+	SymlinksOverwrite        bool                      `yaml:"SymlinksOverwrite"`
+	DefaultMountIdentifier   MountIdentifierType       `yaml:"DefaultMountIdentifier"`
+	PartitionSettings        []PartitionSetting        `yaml:"PartitionSettings"`
+	PostInstallScripts       []Script                  `yaml:"PostInstallScripts"`
+	FinalizeImageScripts     []Script                  `yaml:"FinalizeImageScripts"`
+	Users                    []User                    `yaml:"Users"`
+	Services                 Services                  `yaml:"Services"`
+	Modules                  Modules                   `yaml:"Modules"`
+	Verity                   *Verity                   `yaml:"Verity"`
+	Umask                    *Umask                    `yaml:"Umask"`
+	Sudoers                  []SudoersFile             `yaml:"Sudoers"`
+	PamConfigFiles           []PamConfigFile           `yaml:"PamConfigFiles"`
+	NetworkConfigFiles       []NetworkConfigFile       `yaml:"NetworkConfigFiles"`
+	Firewall                 *Firewall                 `yaml:"Firewall"`
+	LoginBanners             *LoginBanners             `yaml:"LoginBanners"`
+	Zram                     *Zram                     `yaml:"Zram"`
+	AutoinstallSeed          *AutoinstallSeed          `yaml:"AutoinstallSeed"`
 }
 
+// IsValid checks the config's fields and returns all of the errors found, instead of just the first one.
 func (s *SystemConfig) IsValid() error {
-	var err error
+	var errs []error
 
-	err = s.BootType.IsValid()
+	err := s.BootType.IsValid()
 	if err != nil {
-		return err
+		errs = append(errs, err)
 	}
 
 	if s.Hostname != "" {
 		if !govalidator.IsDNSName(s.Hostname) || strings.Contains(s.Hostname, "_") {
-			return fmt.Errorf("invalid hostname: %s", s.Hostname)
+			errs = append(errs, fmt.Errorf("invalid hostname: %s", s.Hostname))
+		}
+	}
+
+	if s.Timezone != "" {
+		err = timezoneIsValid(s.Timezone)
+		if err != nil {
+			errs = append(errs, err)
 		}
 	}
 
+	if s.Locale != "" && !localeRegex.MatchString(s.Locale) {
+		errs = append(errs, fmt.Errorf(
+			"invalid Locale value (%s): must be of the form language[_territory][.codeset][@modifier] (e.g. en_US.UTF-8)",
+			s.Locale))
+	}
+
+	if s.ReleaseVersion != "" {
+		if strings.ContainsAny(s.ReleaseVersion, " \t\n/$") {
+			errs = append(errs, fmt.Errorf("invalid ReleaseVersion (%s): must not contain whitespace, '/', or '$'",
+				s.ReleaseVersion))
+		}
+	}
+
+	if s.PackagesGpgCheck && len(s.PackagesGpgKeys) <= 0 {
+		errs = append(errs, fmt.Errorf("PackagesGpgCheck requires at least one PackagesGpgKeys file"))
+	}
+
+	if !s.PackagesGpgCheck && len(s.PackagesGpgKeys) > 0 {
+		errs = append(errs, fmt.Errorf("PackagesGpgKeys requires PackagesGpgCheck to be enabled"))
+	}
+
+	for i, gpgKey := range s.PackagesGpgKeys {
+		if !filepath.IsLocal(gpgKey) {
+			errs = append(errs, fmt.Errorf(
+				"invalid PackagesGpgKeys item at index %d: path (%s) must be under the config directory", i, gpgKey))
+		}
+	}
+
+	if s.PackagesMetadataExpire != "" && !packagesMetadataExpireRegex.MatchString(s.PackagesMetadataExpire) {
+		errs = append(errs, fmt.Errorf(
+			"invalid PackagesMetadataExpire value (%s): must be a duration (e.g. 3600 or 1h), 'never' or -1",
+			s.PackagesMetadataExpire))
+	}
+
+	for i, command := range s.TdnfCommands {
+		err = tdnfCommandIsValid(command)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid TdnfCommands item at index %d: %w", i, err))
+		}
+	}
+
+	err = s.PackageModules.IsValid()
+	if err != nil {
+		errs = append(errs, err)
+	}
+
 	err = s.KernelCommandLine.IsValid()
 	if err != nil {
-		return fmt.Errorf("invalid KernelCommandLine: %w", err)
+		errs = append(errs, fmt.Errorf("invalid KernelCommandLine: %w", err))
+	}
+
+	if s.Bootloader != nil {
+		err = s.Bootloader.IsValid()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid Bootloader:\n%w", err))
+		}
+	}
+
+	for i, removeFile := range s.RemoveFiles {
+		err = imageFilePathIsValid(removeFile)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid RemoveFiles item at index %d: %w", i, err))
+		}
 	}
 
 	for sourcePath, fileConfigList := range s.AdditionalFiles {
 		err = fileConfigList.IsValid()
 		if err != nil {
-			return fmt.Errorf("invalid file configs for (%s):\n%w", sourcePath, err)
+			errs = append(errs, fmt.Errorf("invalid file configs for (%s):\n%w", sourcePath, err))
 		}
 	}
 
+	err = symlinksAreValid(s.Symlinks)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid Symlinks:\n%w", err))
+	}
+
+	err = s.DefaultMountIdentifier.IsValid()
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid DefaultMountIdentifier:\n%w", err))
+	}
+
 	partitionIDSet := make(map[string]bool)
 	for i, partition := range s.PartitionSettings {
 		err = partition.IsValid()
 		if err != nil {
-			return fmt.Errorf("invalid PartitionSettings item at index %d: %w", i, err)
+			errs = append(errs, fmt.Errorf("invalid PartitionSettings item at index %d: %w", i, err))
 		}
 
 		if _, existingName := partitionIDSet[partition.ID]; existingName {
-			return fmt.Errorf("duplicate PartitionSettings ID used (%s) at index %d", partition.ID, i)
+			errs = append(errs, fmt.Errorf("duplicate PartitionSettings ID used (%s) at index %d", partition.ID, i))
 		}
 
 		partitionIDSet[partition.ID] = false // dummy value
@@ -75,38 +200,201 @@ func (s *SystemConfig) IsValid() error {
 	for i, script := range s.PostInstallScripts {
 		err = script.IsValid()
 		if err != nil {
-			return fmt.Errorf("invalid PostInstallScripts item at index %d: %w", i, err)
+			errs = append(errs, fmt.Errorf("invalid PostInstallScripts item at index %d: %w", i, err))
 		}
 	}
 
 	for i, script := range s.FinalizeImageScripts {
 		err = script.IsValid()
 		if err != nil {
-			return fmt.Errorf("invalid FinalizeImageScripts item at index %d: %w", i, err)
+			errs = append(errs, fmt.Errorf("invalid FinalizeImageScripts item at index %d: %w", i, err))
 		}
 	}
 
 	for i, user := range s.Users {
 		err = user.IsValid()
 		if err != nil {
-			return fmt.Errorf("invalid Users item at index %d: %w", i, err)
+			errs = append(errs, fmt.Errorf("invalid Users item at index %d: %w", i, err))
 		}
 	}
 
-	if err := s.Services.IsValid(); err != nil {
-		return err
+	err = s.Services.IsValid()
+	if err != nil {
+		errs = append(errs, err)
 	}
 
-	if err := s.Modules.IsValid(); err != nil {
-		return err
+	err = s.Modules.IsValid()
+	if err != nil {
+		errs = append(errs, err)
 	}
 
 	if s.Verity != nil {
 		err = s.Verity.IsValid()
 		if err != nil {
-			return fmt.Errorf("invalid Verity: %w", err)
+			errs = append(errs, fmt.Errorf("invalid Verity: %w", err))
+		}
+	}
+
+	if s.Umask != nil {
+		err = s.Umask.IsValid()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid Umask value:\n%w", err))
+		}
+	}
+
+	sudoersNameSet := make(map[string]bool)
+	for i, sudoersFile := range s.Sudoers {
+		err = sudoersFile.IsValid()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid Sudoers item at index %d: %w", i, err))
+		}
+
+		if _, existingName := sudoersNameSet[sudoersFile.Name]; existingName {
+			errs = append(errs, fmt.Errorf("duplicate Sudoers Name used (%s) at index %d", sudoersFile.Name, i))
+		}
+
+		sudoersNameSet[sudoersFile.Name] = false // dummy value
+	}
+
+	pamConfigPathSet := make(map[string]bool)
+	for i, pamConfigFile := range s.PamConfigFiles {
+		err = pamConfigFile.IsValid()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid PamConfigFiles item at index %d: %w", i, err))
+		}
+
+		if _, existingPath := pamConfigPathSet[pamConfigFile.Path]; existingPath {
+			errs = append(errs, fmt.Errorf("duplicate PamConfigFiles Path used (%s) at index %d", pamConfigFile.Path,
+				i))
+		}
+
+		pamConfigPathSet[pamConfigFile.Path] = false // dummy value
+	}
+
+	networkConfigNameSet := make(map[string]bool)
+	networkConfigFilesValid := true
+	for i, networkConfigFile := range s.NetworkConfigFiles {
+		err = networkConfigFile.IsValid()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid NetworkConfigFiles item at index %d: %w", i, err))
+			networkConfigFilesValid = false
+		}
+
+		if _, existingName := networkConfigNameSet[networkConfigFile.Name]; existingName {
+			errs = append(errs, fmt.Errorf("duplicate NetworkConfigFiles Name used (%s) at index %d",
+				networkConfigFile.Name, i))
+		}
+
+		networkConfigNameSet[networkConfigFile.Name] = false // dummy value
+	}
+
+	// The network stack can only be determined from valid network config files.
+	if networkConfigFilesValid {
+		_, err = s.NetworkStack()
+		if err != nil {
+			errs = append(errs, err)
 		}
 	}
 
+	if s.Firewall != nil {
+		err = s.Firewall.IsValid()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid Firewall:\n%w", err))
+		}
+	}
+
+	if s.LoginBanners != nil {
+		err = s.LoginBanners.IsValid()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid LoginBanners:\n%w", err))
+		}
+	}
+
+	if s.Zram != nil {
+		err = s.Zram.IsValid()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid Zram:\n%w", err))
+		}
+	}
+
+	if s.AutoinstallSeed != nil {
+		err = s.AutoinstallSeed.IsValid()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid AutoinstallSeed:\n%w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// PartitionSettingsWithDefaults returns the PartitionSettings with the DefaultMountIdentifier value applied to the
+// partitions that don't specify a MountIdentifier.
+func (s *SystemConfig) PartitionSettingsWithDefaults() []PartitionSetting {
+	partitionSettings := []PartitionSetting(nil)
+	for _, partitionSetting := range s.PartitionSettings {
+		if partitionSetting.MountIdentifier == MountIdentifierTypeDefault {
+			partitionSetting.MountIdentifier = s.DefaultMountIdentifier
+		}
+
+		partitionSettings = append(partitionSettings, partitionSetting)
+	}
+
+	return partitionSettings
+}
+
+// NetworkStack returns the network stack targeted by the NetworkConfigFiles or an empty string if there are no
+// network config files.
+func (s *SystemConfig) NetworkStack() (NetworkStack, error) {
+	var networkStack NetworkStack
+	for _, networkConfigFile := range s.NetworkConfigFiles {
+		fileNetworkStack, err := networkConfigFile.NetworkStack()
+		if err != nil {
+			return "", err
+		}
+
+		if networkStack != "" && networkStack != fileNetworkStack {
+			return "", fmt.Errorf("all NetworkConfigFiles must target the same network stack (found both %s and %s)",
+				networkStack, fileNetworkStack)
+		}
+
+		networkStack = fileNetworkStack
+	}
+
+	return networkStack, nil
+}
+
+// timezoneIsValid checks that a timezone names an entry in the zoneinfo database (e.g. "America/Los_Angeles").
+func timezoneIsValid(timezone string) error {
+	// "Local" is a special value in Go. It isn't in the zoneinfo database.
+	if timezone == "Local" || strings.HasPrefix(timezone, "/") {
+		return fmt.Errorf("invalid Timezone value (%s): must be a zoneinfo name (e.g. America/Los_Angeles or UTC)",
+			timezone)
+	}
+
+	_, err := time.LoadLocation(timezone)
+	if err != nil {
+		return fmt.Errorf("invalid Timezone value (%s): must be a zoneinfo name (e.g. America/Los_Angeles or UTC):\n%w",
+			timezone, err)
+	}
+
+	return nil
+}
+
+// imageFilePathIsValid checks that a path is an absolute path within the image's rootfs.
+func imageFilePathIsValid(path string) error {
+	if !filepath.IsAbs(path) {
+		return fmt.Errorf("path (%s) must be an absolute path", path)
+	}
+
+	for _, element := range strings.Split(path, "/") {
+		if element == ".." {
+			return fmt.Errorf("path (%s) must not contain '..'", path)
+		}
+	}
+
+	if filepath.Clean(path) == "/" {
+		return fmt.Errorf("path (%s) must not be the root directory", path)
+	}
+
 	return nil
 }
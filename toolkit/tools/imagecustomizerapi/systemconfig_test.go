@@ -53,3 +53,269 @@ func TestSystemConfigIsValidKernelCommandLineInvalidChars(t *testing.T) {
 	assert.Error(t, err)
 	assert.ErrorContains(t, err, "ExtraCommandLine")
 }
+
+func TestSystemConfigIsValidReleaseVersion(t *testing.T) {
+	value := SystemConfig{
+		ReleaseVersion: "2.0",
+	}
+
+	err := value.IsValid()
+	assert.NoError(t, err)
+}
+
+func TestSystemConfigIsValidReleaseVersionInvalidChars(t *testing.T) {
+	value := SystemConfig{
+		ReleaseVersion: "2.0/x86_64",
+	}
+
+	err := value.IsValid()
+	assert.Error(t, err)
+	assert.ErrorContains(t, err, "invalid ReleaseVersion")
+}
+
+func TestSystemConfigIsValidLegacyNetworkInterfaceNamesConflict(t *testing.T) {
+	value := SystemConfig{
+		KernelCommandLine: KernelCommandLine{
+			ExtraCommandLine:            "console=ttyS0 net.ifnames=1",
+			LegacyNetworkInterfaceNames: true,
+		},
+	}
+
+	err := value.IsValid()
+	assert.ErrorContains(t, err, "must not contain the net.ifnames=1 arg")
+}
+
+func TestKernelCommandLineCommandLine(t *testing.T) {
+	value := KernelCommandLine{
+		ExtraCommandLine:            "console=ttyS0",
+		LegacyNetworkInterfaceNames: true,
+	}
+	assert.Equal(t, "console=ttyS0 net.ifnames=0 biosdevname=0", value.CommandLine())
+
+	value = KernelCommandLine{
+		LegacyNetworkInterfaceNames: true,
+	}
+	assert.Equal(t, "net.ifnames=0 biosdevname=0", value.CommandLine())
+
+	value = KernelCommandLine{
+		ExtraCommandLine: "console=ttyS0",
+	}
+	assert.Equal(t, "console=ttyS0", value.CommandLine())
+}
+
+func TestSystemConfigPartitionSettingsWithDefaults(t *testing.T) {
+	value := SystemConfig{
+		DefaultMountIdentifier: MountIdentifierTypeUuid,
+		PartitionSettings: []PartitionSetting{
+			{
+				ID:         "esp",
+				MountPoint: "/boot/efi",
+			},
+			{
+				ID:              "rootfs",
+				MountIdentifier: MountIdentifierTypePartLabel,
+				MountPoint:      "/",
+			},
+		},
+	}
+
+	partitionSettings := value.PartitionSettingsWithDefaults()
+	assert.Equal(t, []PartitionSetting{
+		{
+			ID:              "esp",
+			MountIdentifier: MountIdentifierTypeUuid,
+			MountPoint:      "/boot/efi",
+		},
+		{
+			ID:              "rootfs",
+			MountIdentifier: MountIdentifierTypePartLabel,
+			MountPoint:      "/",
+		},
+	}, partitionSettings)
+
+	// The original values are unchanged.
+	assert.Equal(t, MountIdentifierTypeDefault, value.PartitionSettings[0].MountIdentifier)
+}
+
+func TestSystemConfigIsValidBadDefaultMountIdentifier(t *testing.T) {
+	value := SystemConfig{
+		DefaultMountIdentifier: "label",
+	}
+
+	err := value.IsValid()
+	assert.ErrorContains(t, err, "invalid DefaultMountIdentifier")
+}
+
+func TestSystemConfigIsValidPackagesGpgCheck(t *testing.T) {
+	value := SystemConfig{
+		PackagesGpgCheck: true,
+		PackagesGpgKeys:  []string{"keys/RPM-GPG-KEY"},
+	}
+
+	err := value.IsValid()
+	assert.NoError(t, err)
+}
+
+func TestSystemConfigIsValidPackagesGpgCheckNoKeys(t *testing.T) {
+	value := SystemConfig{
+		PackagesGpgCheck: true,
+	}
+
+	err := value.IsValid()
+	assert.ErrorContains(t, err, "PackagesGpgCheck requires at least one PackagesGpgKeys file")
+}
+
+func TestSystemConfigIsValidPackagesGpgKeysWithoutGpgCheck(t *testing.T) {
+	value := SystemConfig{
+		PackagesGpgKeys: []string{"keys/RPM-GPG-KEY"},
+	}
+
+	err := value.IsValid()
+	assert.ErrorContains(t, err, "PackagesGpgKeys requires PackagesGpgCheck to be enabled")
+}
+
+func TestSystemConfigIsValidPackagesGpgKeysNotLocal(t *testing.T) {
+	value := SystemConfig{
+		PackagesGpgCheck: true,
+		PackagesGpgKeys:  []string{"../RPM-GPG-KEY"},
+	}
+
+	err := value.IsValid()
+	assert.ErrorContains(t, err, "invalid PackagesGpgKeys item at index 0: path (../RPM-GPG-KEY) must be under the config directory")
+}
+
+func TestSystemConfigIsValidPackagesMetadataExpire(t *testing.T) {
+	for _, metadataExpire := range []string{"0", "3600", "90m", "1h", "2d", "never", "-1"} {
+		value := SystemConfig{
+			PackagesMetadataExpire: metadataExpire,
+		}
+
+		err := value.IsValid()
+		assert.NoError(t, err, metadataExpire)
+	}
+}
+
+func TestSystemConfigIsValidPackagesMetadataExpireInvalid(t *testing.T) {
+	for _, metadataExpire := range []string{"1y", "-2", "forever", " 1h"} {
+		value := SystemConfig{
+			PackagesMetadataExpire: metadataExpire,
+		}
+
+		err := value.IsValid()
+		assert.ErrorContains(t, err, "invalid PackagesMetadataExpire value", metadataExpire)
+	}
+}
+
+func TestSystemConfigIsValidTimezone(t *testing.T) {
+	for _, timezone := range []string{"UTC", "America/Los_Angeles", "Europe/London", "Etc/GMT+8"} {
+		value := SystemConfig{
+			Timezone: timezone,
+		}
+
+		err := value.IsValid()
+		assert.NoError(t, err, timezone)
+	}
+}
+
+func TestSystemConfigIsValidTimezoneInvalid(t *testing.T) {
+	for _, timezone := range []string{"America/Atlantis", "utc", "Local", "/usr/share/zoneinfo/UTC", "../etc/passwd"} {
+		value := SystemConfig{
+			Timezone: timezone,
+		}
+
+		err := value.IsValid()
+		assert.ErrorContains(t, err, "invalid Timezone value ("+timezone+")", timezone)
+	}
+}
+
+func TestSystemConfigIsValidLocale(t *testing.T) {
+	locales := []string{"en_US.UTF-8", "en_US", "de_DE.utf8", "sr_RS@latin", "ast_ES.UTF-8", "C", "C.UTF-8", "POSIX"}
+	for _, locale := range locales {
+		value := SystemConfig{
+			Locale: locale,
+		}
+
+		err := value.IsValid()
+		assert.NoError(t, err, locale)
+	}
+}
+
+func TestSystemConfigIsValidLocaleInvalid(t *testing.T) {
+	for _, locale := range []string{"english", "en-US", "EN_us", "en_US.", "en_US.UTF-8 ", "LANG=en_US.UTF-8"} {
+		value := SystemConfig{
+			Locale: locale,
+		}
+
+		err := value.IsValid()
+		assert.ErrorContains(t, err, "invalid Locale value ("+locale+")", locale)
+	}
+}
+
+func TestSystemConfigIsValidRemoveFiles(t *testing.T) {
+	value := SystemConfig{
+		RemoveFiles: []string{"/etc/motd", "/usr/share/doc/", "/var/log/./old.log"},
+	}
+
+	err := value.IsValid()
+	assert.NoError(t, err)
+}
+
+func TestSystemConfigIsValidRemoveFilesInvalid(t *testing.T) {
+	testCases := []struct {
+		path          string
+		expectedError string
+	}{
+		{"etc/motd", "must be an absolute path"},
+		{"/etc/../../motd", "must not contain '..'"},
+		{"/..", "must not contain '..'"},
+		{"/", "must not be the root directory"},
+		{"//", "must not be the root directory"},
+	}
+
+	for _, testCase := range testCases {
+		value := SystemConfig{
+			RemoveFiles: []string{testCase.path},
+		}
+
+		err := value.IsValid()
+		assert.ErrorContains(t, err, "invalid RemoveFiles item at index 0", testCase.path)
+		assert.ErrorContains(t, err, testCase.expectedError, testCase.path)
+	}
+}
+
+func TestSystemConfigIsValidTdnfCommands(t *testing.T) {
+	value := SystemConfig{
+		TdnfCommands: [][]string{
+			{"mark", "install", "jq"},
+			{"autoremove"},
+			{"install", "--enablerepo=extras", "golang"},
+		},
+	}
+
+	err := value.IsValid()
+	assert.NoError(t, err)
+}
+
+func TestSystemConfigIsValidTdnfCommandsInvalid(t *testing.T) {
+	testCases := []struct {
+		command       []string
+		expectedError string
+	}{
+		{[]string{}, "command may not be empty"},
+		{[]string{"sh", "-c", "reboot"}, "unsupported tdnf subcommand (sh)"},
+		{[]string{"--installroot=/", "install", "jq"}, "unsupported tdnf subcommand (--installroot=/)"},
+		{[]string{"install", "--installroot=/", "jq"}, "tdnf option (--installroot) may not be used"},
+		{[]string{"install", "--setopt", "reposdir=/etc/yum.repos.d", "jq"}, "tdnf option (--setopt) may not be used"},
+		{[]string{"install", "-c", "/tmp/tdnf.conf", "jq"}, "tdnf option (-c) may not be used"},
+	}
+
+	for _, testCase := range testCases {
+		value := SystemConfig{
+			TdnfCommands: [][]string{testCase.command},
+		}
+
+		err := value.IsValid()
+		assert.ErrorContains(t, err, "invalid TdnfCommands item at index 0", testCase.command)
+		assert.ErrorContains(t, err, testCase.expectedError, testCase.command)
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/sliceutils"
+)
+
+var (
+	// The tdnf subcommands that may be used in TdnfCommands.
+	tdnfSubcommands = []string{
+		"autoremove", "check", "check-update", "clean", "distro-sync", "downgrade", "erase", "info", "install", "list",
+		"makecache", "mark", "provides", "reinstall", "remove", "repolist", "repoquery", "search", "update",
+		"updateinfo", "upgrade", "whatprovides",
+	}
+
+	// The tdnf options that would change which system or repos tdnf operates on.
+	tdnfDisallowedOptions = []string{
+		"--installroot", "--config", "-c", "--setopt", "--repofromdir", "--repofrompath",
+	}
+)
+
+// tdnfCommandIsValid checks that a TdnfCommands item is a known tdnf subcommand followed by its args.
+func tdnfCommandIsValid(command []string) error {
+	if len(command) <= 0 {
+		return fmt.Errorf("command may not be empty")
+	}
+
+	subcommand := command[0]
+	if !sliceutils.ContainsValue(tdnfSubcommands, subcommand) {
+		return fmt.Errorf("unsupported tdnf subcommand (%s); must be one of: %s", subcommand,
+			strings.Join(tdnfSubcommands, ", "))
+	}
+
+	for _, arg := range command[1:] {
+		option, _, _ := strings.Cut(arg, "=")
+		if sliceutils.ContainsValue(tdnfDisallowedOptions, option) {
+			return fmt.Errorf("tdnf option (%s) may not be used, since the RPM sources and the image are set by the "+
+				"image customizer", option)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// The default file mode creation mask used by login shells.
+//
+// Accepted formats:
+//
+// - Octal string (e.g. "027")
+type Umask os.FileMode
+
+func (u *Umask) IsValid() error {
+	// Check if there are set bits outside of the permissions bits.
+	if *u & ^Umask(os.ModePerm) != 0 {
+		return fmt.Errorf("0o%o contains non-permission bits", *u)
+	}
+
+	return nil
+}
+
+func (u *Umask) UnmarshalYAML(value *yaml.Node) error {
+	var err error
+
+	// Try to parse as a string.
+	var strValue string
+	err = value.Decode(&strValue)
+	if err != nil {
+		return fmt.Errorf("failed to parse Umask:\n%w", err)
+	}
+
+	// Try to parse the string as an octal number.
+	umaskUint, err := strconv.ParseUint(strValue, 8, 32)
+	if err != nil {
+		return fmt.Errorf("failed to parse Umask:\n%w", err)
+	}
+
+	*u = (Umask)(umaskUint)
+	return nil
+}
+
+func (u Umask) MarshalYAML() (interface{}, error) {
+	return u.String(), nil
+}
+
+// String returns the umask formatted as a 3 digit octal number (e.g. "027").
+func (u Umask) String() string {
+	return fmt.Sprintf("%03o", uint32(u))
+}
@@ -0,0 +1,32 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"testing"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/ptrutils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseUmaskValid(t *testing.T) {
+	testValidYamlValue(t, "\"027\"", ptrutils.PtrTo(Umask(0o027)))
+}
+
+func TestParseUmaskValidZero(t *testing.T) {
+	testValidYamlValue(t, "\"0\"", ptrutils.PtrTo(Umask(0)))
+}
+
+func TestParseUmaskInvalidOutOfRange(t *testing.T) {
+	testInvalidYamlValue[*Umask](t, "\"1000\"")
+}
+
+func TestParseUmaskInvalidNotOctal(t *testing.T) {
+	testInvalidYamlValue[*Umask](t, "\"089\"")
+}
+
+func TestUmaskString(t *testing.T) {
+	assert.Equal(t, "027", Umask(0o027).String())
+	assert.Equal(t, "000", Umask(0).String())
+}
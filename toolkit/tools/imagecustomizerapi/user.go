@@ -13,7 +13,7 @@ type User struct {
 	Name                string   `yaml:"Name"`
 	UID                 *int     `yaml:"UID"`
 	PasswordHashed      bool     `yaml:"PasswordHashed"`
-	Password            string   `yaml:"Password"`
+	Password            Password `yaml:"Password"`
 	PasswordPath        string   `yaml:"PasswordPath"`
 	PasswordExpiresDays *int64   `yaml:"PasswordExpiresDays"`
 	SSHPubKeyPaths      []string `yaml:"SSHPubKeyPaths"`
@@ -5,7 +5,9 @@ package imagecustomizerapi
 
 import (
 	"bytes"
+	"fmt"
 	"os"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
@@ -51,3 +53,93 @@ func UnmarshalYaml[ValueType HasIsValid](yamlData []byte, value ValueType) error
 
 	return nil
 }
+
+// MarshalYaml serializes a value as YAML.
+// Fields that are left unset (i.e. null, empty or false) are omitted from the output.
+func MarshalYaml(value interface{}) ([]byte, error) {
+	var node yaml.Node
+	err := node.Encode(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode YAML:\n%w", err)
+	}
+
+	pruneUnsetYamlFields(&node)
+
+	var buffer bytes.Buffer
+	encoder := yaml.NewEncoder(&buffer)
+	encoder.SetIndent(2)
+
+	err = encoder.Encode(&node)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write YAML:\n%w", err)
+	}
+
+	err = encoder.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to write YAML:\n%w", err)
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// pruneUnsetYamlFields removes the fields of mappings that don't have a value.
+// Returns true if the node itself doesn't have a value.
+func pruneUnsetYamlFields(node *yaml.Node) bool {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			pruneUnsetYamlFields(child)
+		}
+		return false
+
+	case yaml.MappingNode:
+		content := []*yaml.Node(nil)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			value := node.Content[i+1]
+			if !pruneUnsetYamlFields(value) {
+				content = append(content, key, value)
+			}
+		}
+		node.Content = content
+		return len(node.Content) <= 0
+
+	case yaml.SequenceNode:
+		for _, child := range node.Content {
+			pruneUnsetYamlFields(child)
+		}
+		return len(node.Content) <= 0
+
+	case yaml.ScalarNode:
+		switch node.Tag {
+		case "!!null":
+			return true
+
+		case "!!str":
+			return node.Value == ""
+
+		case "!!bool":
+			return node.Value == "false"
+
+		default:
+			return false
+		}
+
+	default:
+		return false
+	}
+}
+
+// enumValueStrings converts a list of string enum values into a list of strings.
+func enumValueStrings[EnumType ~string](values []EnumType) []string {
+	strs := make([]string, len(values))
+	for i, value := range values {
+		strs[i] = string(value)
+	}
+	return strs
+}
+
+// enumValuesString formats a list of string enum values for use in error messages.
+func enumValuesString[EnumType ~string](values []EnumType) string {
+	return strings.Join(enumValueStrings(values), ", ")
+}
@@ -30,3 +30,42 @@ func makeValue[DataType any]() DataType {
 	var placeholder DataType
 	return reflect.New(reflect.TypeOf(placeholder).Elem()).Interface().(DataType)
 }
+
+func TestMarshalYamlRoundTrip(t *testing.T) {
+	timeout := 0
+	permissions := FilePermissions(0o640)
+	umask := Umask(0o027)
+
+	config := &Config{
+		SystemConfig: SystemConfig{
+			Hostname: "testname",
+			Umask:    &umask,
+			Bootloader: &Bootloader{
+				Timeout: &timeout,
+			},
+			AdditionalFiles: map[string]FileConfigList{
+				"a.txt": {
+					{
+						Path:        "/a.txt",
+						Permissions: &permissions,
+					},
+				},
+			},
+		},
+	}
+
+	yamlData, err := MarshalYaml(config)
+	assert.NoError(t, err)
+
+	yamlString := string(yamlData)
+	assert.Contains(t, yamlString, "Hostname: testname")
+	assert.Contains(t, yamlString, "Umask: \"027\"")
+	assert.Contains(t, yamlString, "Timeout: 0")
+	assert.NotContains(t, yamlString, "Disks")
+	assert.NotContains(t, yamlString, "PackagesGpgCheck")
+
+	var parsedConfig Config
+	err = UnmarshalYaml(yamlData, &parsedConfig)
+	assert.NoError(t, err)
+	assert.Equal(t, config, &parsedConfig)
+}
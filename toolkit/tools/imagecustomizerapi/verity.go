@@ -7,7 +7,15 @@ import (
 	"fmt"
 )
 
+const (
+	VerityMountPointRoot = "/"
+	VerityMountPointUsr  = "/usr"
+)
+
 type Verity struct {
+	// The mount point of the file system that the data partition holds (i.e. "/" or "/usr").
+	// Defaults to "/".
+	MountPoint    string          `yaml:"MountPoint"`
 	DataPartition VerityPartition `yaml:"DataPartition"`
 	HashPartition VerityPartition `yaml:"HashPartition"`
 }
@@ -21,5 +29,21 @@ func (v *Verity) IsValid() error {
 		return fmt.Errorf("invalid HashPartition: %v", err)
 	}
 
+	switch v.MountPoint {
+	case "", VerityMountPointRoot, VerityMountPointUsr:
+
+	default:
+		return fmt.Errorf("invalid MountPoint value (%s); must be one of: %s, %s", v.MountPoint, VerityMountPointRoot,
+			VerityMountPointUsr)
+	}
+
 	return nil
 }
+
+// GetMountPoint returns the mount point of the data partition, with the default applied.
+func (v *Verity) GetMountPoint() string {
+	if v.MountPoint == "" {
+		return VerityMountPointRoot
+	}
+	return v.MountPoint
+}
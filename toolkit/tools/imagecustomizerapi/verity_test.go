@@ -0,0 +1,44 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerityIsValidUsr(t *testing.T) {
+	verity := Verity{
+		MountPoint:    "/usr",
+		DataPartition: VerityPartition{IdType: "PartLabel", Id: "usr"},
+		HashPartition: VerityPartition{IdType: "PartLabel", Id: "usr-hash"},
+	}
+
+	err := verity.IsValid()
+	assert.NoError(t, err)
+	assert.Equal(t, "/usr", verity.GetMountPoint())
+}
+
+func TestVerityIsValidDefaultMountPoint(t *testing.T) {
+	verity := Verity{
+		DataPartition: VerityPartition{IdType: "PartLabel", Id: "root"},
+		HashPartition: VerityPartition{IdType: "PartLabel", Id: "root-hash"},
+	}
+
+	err := verity.IsValid()
+	assert.NoError(t, err)
+	assert.Equal(t, "/", verity.GetMountPoint())
+}
+
+func TestVerityIsValidBadMountPoint(t *testing.T) {
+	verity := Verity{
+		MountPoint:    "/var",
+		DataPartition: VerityPartition{IdType: "PartLabel", Id: "var"},
+		HashPartition: VerityPartition{IdType: "PartLabel", Id: "var-hash"},
+	}
+
+	err := verity.IsValid()
+	assert.ErrorContains(t, err, "invalid MountPoint value (/var); must be one of: /, /usr")
+}
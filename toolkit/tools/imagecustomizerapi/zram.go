@@ -0,0 +1,71 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/sliceutils"
+)
+
+var (
+	// Accepted formats:
+	//
+	// - Fixed size in MiBs (e.g. "512").
+	// - Fraction of the system's RAM (e.g. "ram", "ram / 2", "ram * 0.5").
+	zramSizeRegex = regexp.MustCompile(`^(?:(\d+)|ram(?:\s*([*/])\s*(\d+(?:\.\d+)?))?)$`)
+
+	zramCompressionAlgorithms = []string{"lzo", "lzo-rle", "lz4", "lz4hc", "zstd", "842", "deflate"}
+)
+
+// Zram configures a compressed swap device in RAM using zram-generator.
+type Zram struct {
+	// Size is the size of the zram device. Either a size in MiBs or an expression of the system's RAM.
+	Size string `yaml:"Size"`
+	// CompressionAlgorithm is the algorithm used to compress the zram device's pages.
+	CompressionAlgorithm string `yaml:"CompressionAlgorithm"`
+}
+
+func (z *Zram) IsValid() error {
+	if z.Size != "" {
+		err := zramSizeIsValid(z.Size)
+		if err != nil {
+			return fmt.Errorf("invalid Size value:\n%w", err)
+		}
+	}
+
+	if z.CompressionAlgorithm != "" && !sliceutils.ContainsValue(zramCompressionAlgorithms, z.CompressionAlgorithm) {
+		return fmt.Errorf("invalid CompressionAlgorithm value (%s); must be one of: %s", z.CompressionAlgorithm,
+			enumValuesString(zramCompressionAlgorithms))
+	}
+
+	return nil
+}
+
+func zramSizeIsValid(size string) error {
+	match := zramSizeRegex.FindStringSubmatch(size)
+	if match == nil {
+		return fmt.Errorf("zram size (%s) must be a size in MiBs (e.g. '512') or an expression of the system's RAM (e.g. 'ram / 2')",
+			size)
+	}
+
+	fixedSize, operand := match[1], match[3]
+	switch {
+	case fixedSize != "":
+		sizeValue, err := strconv.ParseUint(fixedSize, 10, 64)
+		if err != nil || sizeValue == 0 {
+			return fmt.Errorf("zram size (%s) must be a positive number", size)
+		}
+
+	case operand != "":
+		operandValue, err := strconv.ParseFloat(operand, 64)
+		if err != nil || operandValue == 0 {
+			return fmt.Errorf("zram size (%s) must not use a zero operand", size)
+		}
+	}
+
+	return nil
+}
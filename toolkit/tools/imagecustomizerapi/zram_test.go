@@ -0,0 +1,49 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestZramIsValid(t *testing.T) {
+	for _, size := range []string{"512", "ram", "ram / 2", "ram*0.5"} {
+		zram := Zram{
+			Size:                 size,
+			CompressionAlgorithm: "zstd",
+		}
+
+		err := zram.IsValid()
+		assert.NoError(t, err, size)
+	}
+}
+
+func TestZramIsValidEmpty(t *testing.T) {
+	zram := Zram{}
+
+	err := zram.IsValid()
+	assert.NoError(t, err)
+}
+
+func TestZramIsValidBadSize(t *testing.T) {
+	for _, size := range []string{"0", "-1", "4G", "ram / 0", "ram + 2", "ram; rm -rf /"} {
+		zram := Zram{
+			Size: size,
+		}
+
+		err := zram.IsValid()
+		assert.ErrorContains(t, err, "invalid Size value", size)
+	}
+}
+
+func TestZramIsValidBadCompressionAlgorithm(t *testing.T) {
+	zram := Zram{
+		CompressionAlgorithm: "gzip",
+	}
+
+	err := zram.IsValid()
+	assert.ErrorContains(t, err, "invalid CompressionAlgorithm value (gzip)")
+}
@@ -10,7 +10,6 @@ import (
 	"path/filepath"
 	"runtime"
 	"sort"
-	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -1337,89 +1336,7 @@ func createUserWithPassword(installChroot *safechroot.Chroot, user configuration
 // chage works in the same way as invoking "chage -M passwordExpirationInDays username"
 // i.e. it sets the maximum password expiration date.
 func Chage(installChroot safechroot.ChrootInterface, passwordExpirationInDays int64, username string) (err error) {
-	var (
-		shadow            []string
-		usernameWithColon = fmt.Sprintf("%s:", username)
-	)
-
-	installChrootShadowFile := filepath.Join(installChroot.RootDir(), userutils.ShadowFile)
-
-	shadow, err = file.ReadLines(installChrootShadowFile)
-	if err != nil {
-		return
-	}
-
-	for n, entry := range shadow {
-		done := false
-		// Entries in shadow are separated by colon and start with a username
-		// Finding one that starts like that means we've found our entry
-		if strings.HasPrefix(entry, usernameWithColon) {
-			// Each line in shadow contains 9 fields separated by colon ("") in the following order:
-			// login name, encrypted password, date of last password change,
-			// minimum password age, maximum password age, password warning period,
-			// password inactivity period, account expiration date, reserved field for future use
-			const (
-				passwordNeverExpiresValue = -1
-				loginNameField            = 0
-				encryptedPasswordField    = 1
-				passwordChangedField      = 2
-				minPasswordAgeField       = 3
-				maxPasswordAgeField       = 4
-				warnPeriodField           = 5
-				inactivityPeriodField     = 6
-				expirationField           = 7
-				reservedField             = 8
-				totalFieldsCount          = 9
-			)
-
-			fields := strings.Split(entry, ":")
-			// Any value other than totalFieldsCount indicates error in parsing
-			if len(fields) != totalFieldsCount {
-				return fmt.Errorf("invalid shadow entry (%v) for user (%s): %d fields expected, but %d found", fields, username, totalFieldsCount, len(fields))
-			}
-
-			if passwordExpirationInDays == passwordNeverExpiresValue {
-				// If passwordExpirationInDays is equal to -1, it means that password never expires.
-				// This is expressed by leaving account expiration date field (and fields after it) empty.
-				for _, fieldToChange := range []int{maxPasswordAgeField, warnPeriodField, inactivityPeriodField, expirationField, reservedField} {
-					fields[fieldToChange] = ""
-				}
-				// Each user appears only once, since we found one, we are finished; save the changes and exit.
-				done = true
-			} else if passwordExpirationInDays < passwordNeverExpiresValue {
-				// Values smaller than -1 make no sense
-				return fmt.Errorf("invalid value for maximum user's (%s) password expiration: %d; should be greater than %d", username, passwordExpirationInDays, passwordNeverExpiresValue)
-			} else {
-				// If passwordExpirationInDays has any other value, it's the maximum expiration date: set it accordingly
-				// To do so, we need to ensure that passwordChangedField holds a valid value and then sum it with passwordExpirationInDays.
-				var (
-					passwordAge     int64
-					passwordChanged = fields[passwordChangedField]
-				)
-
-				if passwordChanged == "" {
-					// Set to the number of days since epoch
-					fields[passwordChangedField] = fmt.Sprintf("%d", int64(time.Since(time.Unix(0, 0)).Hours()/24))
-				}
-				passwordAge, err = strconv.ParseInt(fields[passwordChangedField], 10, 64)
-				if err != nil {
-					return
-				}
-				fields[expirationField] = fmt.Sprintf("%d", passwordAge+passwordExpirationInDays)
-
-				// Each user appears only once, since we found one, we are finished; save the changes and exit.
-				done = true
-			}
-			if done {
-				// Create and save new shadow file including potential changes from above.
-				shadow[n] = strings.Join(fields, ":")
-				err = file.Write(strings.Join(shadow, "\n"), installChrootShadowFile)
-				return
-			}
-		}
-	}
-
-	return fmt.Errorf(`user "%s" not found when trying to change the password expiration date`, username)
+	return userutils.ChageInChroot(passwordExpirationInDays, username, installChroot.RootDir())
 }
 
 func ConfigureUserGroupMembership(installChroot safechroot.ChrootInterface, username string, primaryGroup string,
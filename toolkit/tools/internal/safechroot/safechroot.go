@@ -104,6 +104,21 @@ func NewMountPoint(source, target, fstype string, flags uintptr, data string) (m
 	}
 }
 
+// Source returns the source of the mount point.
+func (m *MountPoint) Source() string {
+	return m.source
+}
+
+// Target returns the target of the mount point, relative to the chroot's root directory.
+func (m *MountPoint) Target() string {
+	return m.target
+}
+
+// FsType returns the filesystem type of the mount point.
+func (m *MountPoint) FsType() string {
+	return m.fstype
+}
+
 // NewPreDefaultsMountPoint creates a new MountPoint struct to be created by a Chroot but before the default mount points.
 func NewPreDefaultsMountPoint(source, target, fstype string, flags uintptr, data string) (mountPoint *MountPoint) {
 	return &MountPoint{
@@ -5,8 +5,30 @@
 package safeloopback
 
 import (
+	"fmt"
+	"os"
+	"time"
+
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagegen/diskutils"
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/logger"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/retry"
+)
+
+// Attaching a loopback device occasionally fails transiently under load (e.g. losetup races with other processes or
+// the device node hasn't been created yet). So, the attach is retried.
+var (
+	// The number of times to try attaching the loopback device.
+	SetupAttempts = 5
+
+	// The delay before the i-th retry is i * SetupRetryDelay.
+	SetupRetryDelay = 500 * time.Millisecond
+
+	// How long to wait for the loopback's device node to appear.
+	DeviceNodeTimeout = 10 * time.Second
+)
+
+const (
+	deviceNodePollInterval = 100 * time.Millisecond
 )
 
 type Loopback struct {
@@ -33,12 +55,31 @@ func NewLoopback(diskFilePath string) (*Loopback, error) {
 
 func (l *Loopback) newLoopbackHelper() error {
 	// Try to create the mount.
-	devicePath, err := diskutils.SetupLoopbackDevice(l.diskFilePath)
+	err := retry.Run(func() error {
+		devicePath, err := diskutils.SetupLoopbackDevice(l.diskFilePath)
+		if err != nil {
+			return err
+		}
+
+		err = waitForDeviceNode(devicePath, DeviceNodeTimeout)
+		if err != nil {
+			// Release the loopback device, so that the next attempt starts from scratch.
+			detachErr := diskutils.DetachLoopbackDevice(devicePath)
+			if detachErr != nil {
+				logger.Log.Warnf("failed to detach loopback (%s): %s", devicePath, detachErr)
+			}
+
+			return err
+		}
+
+		l.devicePath = devicePath
+		return nil
+	}, SetupAttempts, SetupRetryDelay)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to attach loopback device for (%s) after %d attempts:\n%w", l.diskFilePath,
+			SetupAttempts, err)
 	}
 
-	l.devicePath = devicePath
 	l.isAttached = true
 
 	// Get the disk's IDs.
@@ -104,3 +145,20 @@ func (l *Loopback) close(async bool) error {
 
 	return nil
 }
+
+// waitForDeviceNode waits for a device node to be created (e.g. by devtmpfs).
+func waitForDeviceNode(devicePath string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		info, err := os.Stat(devicePath)
+		if err == nil && info.Mode()&os.ModeDevice != 0 {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for device node (%s) to be created", devicePath)
+		}
+
+		time.Sleep(deviceNodePollInterval)
+	}
+}
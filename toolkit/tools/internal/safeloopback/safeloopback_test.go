@@ -6,6 +6,7 @@ package safeloopback
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagegen/diskutils"
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/buildpipeline"
@@ -53,3 +54,18 @@ func TestLoopbackCloseWithOpenFileHandle(t *testing.T) {
 	err = loopback.CleanClose()
 	assert.NoError(t, err)
 }
+
+func TestWaitForDeviceNodeExists(t *testing.T) {
+	err := waitForDeviceNode("/dev/null", time.Second)
+	assert.NoError(t, err)
+}
+
+func TestWaitForDeviceNodeTimeout(t *testing.T) {
+	err := waitForDeviceNode("/dev/does-not-exist", 200*time.Millisecond)
+	assert.ErrorContains(t, err, "timed out waiting for device node (/dev/does-not-exist)")
+}
+
+func TestWaitForDeviceNodeNotDevice(t *testing.T) {
+	err := waitForDeviceNode(tmpDir, 200*time.Millisecond)
+	assert.ErrorContains(t, err, "timed out waiting for device node")
+}
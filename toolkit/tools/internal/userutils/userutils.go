@@ -7,8 +7,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/file"
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/randomization"
@@ -24,6 +25,21 @@ const (
 	ShadowFile = "/etc/shadow"
 )
 
+// Each line in the shadow file contains 9 fields separated by colons (":") in the following order:
+// login name, encrypted password, date of last password change, minimum password age, maximum password age,
+// password warning period, password inactivity period, account expiration date, reserved field for future use.
+// See, shadow(5).
+const (
+	shadowEncryptedPasswordField = 1
+	shadowPasswordChangedField   = 2
+	shadowMaxPasswordAgeField    = 4
+	shadowWarnPeriodField        = 5
+	shadowInactivityPeriodField  = 6
+	shadowExpirationField        = 7
+	shadowReservedField          = 8
+	shadowFieldsCount            = 9
+)
+
 func HashPassword(password string) (string, error) {
 	const postfixLength = 12
 
@@ -103,30 +119,33 @@ func UpdateUserPassword(installRoot, username, hashedPassword string) error {
 		hashedPassword = "*"
 	}
 
-	// Find the line that starts with "<user>:<password>:..."
-	findUserEntry, err := regexp.Compile(fmt.Sprintf("(?m)^%s:[^:]*:", regexp.QuoteMeta(username)))
-	if err != nil {
-		return fmt.Errorf("failed to compile user (%s) password update regex:\n%w", username, err)
-	}
-
 	// Read in existing /etc/shadow file.
 	shadowFileBytes, err := os.ReadFile(shadowFilePath)
 	if err != nil {
 		return fmt.Errorf("failed to read shadow file (%s) to update user's (%s) password:\n%w", shadowFilePath, username, err)
 	}
 
-	shadowFile := string(shadowFileBytes)
+	// Note: Split on "\n" instead of using file.ReadLines() so that the file's trailing newline (if any) is preserved.
+	shadowLines := strings.Split(string(shadowFileBytes), "\n")
 
-	// Try to find the user's entry.
-	entryIndexes := findUserEntry.FindStringIndex(shadowFile)
-	if entryIndexes == nil {
+	// Find the user's entry.
+	entryIndex := findShadowEntry(shadowLines, username)
+	if entryIndex < 0 {
 		return fmt.Errorf("failed to find user (%s) in shadow file (%s)", username, shadowFilePath)
 	}
 
-	newShadowFile := fmt.Sprintf("%s%s:%s:%s", shadowFile[:entryIndexes[0]], username, hashedPassword, shadowFile[entryIndexes[1]:])
+	fields := strings.Split(shadowLines[entryIndex], ":")
+	if len(fields) != shadowFieldsCount {
+		return fmt.Errorf("invalid shadow entry for user (%s): %d fields expected, but %d found", username,
+			shadowFieldsCount, len(fields))
+	}
+
+	// Replace the password field, regardless of its existing value.
+	fields[shadowEncryptedPasswordField] = hashedPassword
+	shadowLines[entryIndex] = strings.Join(fields, ":")
 
 	// Write new /etc/shadow file.
-	err = file.Write(newShadowFile, shadowFilePath)
+	err = file.Write(strings.Join(shadowLines, "\n"), shadowFilePath)
 	if err != nil {
 		return fmt.Errorf("failed to write new shadow file (%s) to update user's (%s) password:\n%w", shadowFilePath, username, err)
 	}
@@ -134,6 +153,88 @@ func UpdateUserPassword(installRoot, username, hashedPassword string) error {
 	return nil
 }
 
+// findShadowEntry returns the index of the user's entry in the lines of a shadow file or -1 if the user isn't found.
+func findShadowEntry(shadowLines []string, username string) int {
+	// Entries in shadow are separated by colon and start with a username.
+	usernameWithColon := fmt.Sprintf("%s:", username)
+	for i, line := range shadowLines {
+		if strings.HasPrefix(line, usernameWithColon) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// ChageInChroot works in the same way as invoking "chage -M passwordExpirationInDays username" against the shadow
+// file of the root directory chrootDir. That is, it sets the maximum password expiration date.
+func ChageInChroot(passwordExpirationInDays int64, username string, chrootDir string) error {
+	const passwordNeverExpiresValue = -1
+
+	if passwordExpirationInDays < passwordNeverExpiresValue {
+		// Values smaller than -1 make no sense
+		return fmt.Errorf("invalid value for maximum user's (%s) password expiration: %d; should be greater than %d",
+			username, passwordExpirationInDays, passwordNeverExpiresValue)
+	}
+
+	shadowFilePath := filepath.Join(chrootDir, ShadowFile)
+
+	shadowFileBytes, err := os.ReadFile(shadowFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to read shadow file (%s) to update user's (%s) password expiration:\n%w",
+			shadowFilePath, username, err)
+	}
+
+	// Note: Split on "\n" instead of using file.ReadLines() so that the file's trailing newline (if any) is preserved.
+	shadowLines := strings.Split(string(shadowFileBytes), "\n")
+
+	entryIndex := findShadowEntry(shadowLines, username)
+	if entryIndex < 0 {
+		return fmt.Errorf(`user "%s" not found when trying to change the password expiration date`, username)
+	}
+
+	fields := strings.Split(shadowLines[entryIndex], ":")
+	if len(fields) != shadowFieldsCount {
+		return fmt.Errorf("invalid shadow entry for user (%s): %d fields expected, but %d found", username,
+			shadowFieldsCount, len(fields))
+	}
+
+	if passwordExpirationInDays == passwordNeverExpiresValue {
+		// If passwordExpirationInDays is equal to -1, it means that password never expires.
+		// This is expressed by leaving account expiration date field (and fields after it) empty.
+		for _, fieldToChange := range []int{shadowMaxPasswordAgeField, shadowWarnPeriodField,
+			shadowInactivityPeriodField, shadowExpirationField, shadowReservedField} {
+			fields[fieldToChange] = ""
+		}
+	} else {
+		// If passwordExpirationInDays has any other value, it's the maximum expiration date: set it accordingly
+		// To do so, we need to ensure that the password changed field holds a valid value and then sum it with
+		// passwordExpirationInDays.
+		if fields[shadowPasswordChangedField] == "" {
+			// Set to the number of days since epoch
+			fields[shadowPasswordChangedField] = fmt.Sprintf("%d", int64(time.Since(time.Unix(0, 0)).Hours()/24))
+		}
+
+		passwordAge, err := strconv.ParseInt(fields[shadowPasswordChangedField], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid shadow entry for user (%s): invalid date of last password change:\n%w",
+				username, err)
+		}
+
+		fields[shadowExpirationField] = fmt.Sprintf("%d", passwordAge+passwordExpirationInDays)
+	}
+
+	// Create and save new shadow file including the changes from above.
+	shadowLines[entryIndex] = strings.Join(fields, ":")
+	err = file.Write(strings.Join(shadowLines, "\n"), shadowFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to write new shadow file (%s) to update user's (%s) password expiration:\n%w",
+			shadowFilePath, username, err)
+	}
+
+	return nil
+}
+
 func UserHomeDirectory(username string) string {
 	if username == RootUser {
 		return RootHomeDir
@@ -117,6 +117,31 @@ func testUpdateUserPassword(t *testing.T, originalShadowFile string, expectedSha
 	assert.Equal(t, expectedShadowFile, string(actualShadowFileBytes))
 }
 
+func TestUpdateUserPasswordLockedToSomething(t *testing.T) {
+	testUpdateUserPassword(t,
+		"root:!:19634:7:99999:7:::\ntest:!:19634:7:99999:7:::\n",
+		"root:!:19634:7:99999:7:::\ntest:$6$abc:19634:7:99999:7:::\n",
+		"test",
+		"$6$abc")
+}
+
+func TestUpdateUserPasswordSimilarName(t *testing.T) {
+	testUpdateUserPassword(t,
+		"testuser:!:19634:7:99999:7:::\ntest:x:19634:7:99999:7:::",
+		"testuser:!:19634:7:99999:7:::\ntest:$6$abc:19634:7:99999:7:::",
+		"test",
+		"$6$abc")
+}
+
+func TestUpdateUserPasswordInvalidEntry(t *testing.T) {
+	rootFilePath := tmpDir
+
+	writeTestShadowFile(t, rootFilePath, "root:!:19634:7")
+
+	err := UpdateUserPassword(rootFilePath, "root", "")
+	assert.ErrorContains(t, err, "invalid shadow entry")
+}
+
 func TestUpdateUserPasswordMissingUser(t *testing.T) {
 	rootFilePath := tmpDir
 
@@ -128,6 +153,60 @@ func TestUpdateUserPasswordMissingUser(t *testing.T) {
 	}
 }
 
+func TestChageInChroot(t *testing.T) {
+	testChageInChroot(t,
+		"root:*:19634:7:99999:7:::\ntest:*:19634:0:99999:7:::",
+		"root:*:19634:7:99999:7:::\ntest:*:19634:0:99999:7::19754:",
+		"test",
+		120)
+}
+
+func TestChageInChrootNoExpiry(t *testing.T) {
+	testChageInChroot(t,
+		"test:*:19634:0:99999:7:30:19754:",
+		"test:*:19634:0:::::",
+		"test",
+		-1)
+}
+
+func testChageInChroot(t *testing.T, originalShadowFile string, expectedShadowFile string, user string,
+	passwordExpirationInDays int64,
+) {
+	rootFilePath := tmpDir
+
+	writeTestShadowFile(t, rootFilePath, originalShadowFile)
+
+	err := ChageInChroot(passwordExpirationInDays, user, rootFilePath)
+	if !assert.NoError(t, err, "chage") {
+		return
+	}
+
+	actualShadowFileBytes, err := os.ReadFile(filepath.Join(rootFilePath, ShadowFile))
+	if !assert.NoError(t, err, "read updated shadow file") {
+		return
+	}
+
+	assert.Equal(t, expectedShadowFile, string(actualShadowFileBytes))
+}
+
+func TestChageInChrootMissingUser(t *testing.T) {
+	rootFilePath := tmpDir
+
+	writeTestShadowFile(t, rootFilePath, "root:*:19634:7:99999:7:::")
+
+	err := ChageInChroot(120, "test", rootFilePath)
+	assert.ErrorContains(t, err, "not found")
+}
+
+func TestChageInChrootInvalidEntry(t *testing.T) {
+	rootFilePath := tmpDir
+
+	writeTestShadowFile(t, rootFilePath, "test:*:19634:7:99999")
+
+	err := ChageInChroot(120, "test", rootFilePath)
+	assert.ErrorContains(t, err, "invalid shadow entry")
+}
+
 func writeTestShadowFile(t *testing.T, rootFilePath string, content string) {
 	shadowFilePath := filepath.Join(rootFilePath, ShadowFile)
 
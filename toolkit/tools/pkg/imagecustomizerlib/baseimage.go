@@ -0,0 +1,96 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/logger"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/shell"
+)
+
+const (
+	// The offset and value of the boot signature at the end of a disk's first sector.
+	// This is present in both MBR and GPT (protective MBR) partitioned disks.
+	bootSignatureOffset = 510
+)
+
+var (
+	bootSignature = []byte{0x55, 0xAA}
+)
+
+// qemuImageInfo is the subset of the `qemu-img info --output=json` output used by the image customizer.
+type qemuImageInfo struct {
+	Format      string `json:"format"`
+	VirtualSize int64  `json:"virtual-size"`
+}
+
+// validateBaseImageFormat checks that the base image is a disk image that qemu-img can convert.
+// qemu-img treats any file it doesn't recognize as a raw image. So, raw images are also checked for a partition table.
+func validateBaseImageFormat(imageFile string) error {
+	stdout, stderr, err := shell.Execute("qemu-img", "info", "--output=json", imageFile)
+	if err != nil {
+		return fmt.Errorf("failed to identify the format of the base image (%s):\n%s\n%w", imageFile,
+			strings.TrimSpace(stderr), err)
+	}
+
+	info, err := parseQemuImageInfo(stdout)
+	if err != nil {
+		return fmt.Errorf("failed to identify the format of the base image (%s):\n%w", imageFile, err)
+	}
+
+	logger.Log.Infof("Base image format: %s, virtual size: %d bytes", info.Format, info.VirtualSize)
+
+	if info.Format == "raw" {
+		hasBootSignature, err := rawImageHasBootSignature(imageFile)
+		if err != nil {
+			return fmt.Errorf("failed to read the base image (%s):\n%w", imageFile, err)
+		}
+
+		if !hasBootSignature {
+			return fmt.Errorf("base image (%s) is not a recognized disk image format (supported: vhd, vhdx, qcow2, "+
+				"raw disk image with a partition table)", imageFile)
+		}
+	}
+
+	return nil
+}
+
+func parseQemuImageInfo(jsonString string) (qemuImageInfo, error) {
+	var info qemuImageInfo
+	err := json.Unmarshal([]byte(jsonString), &info)
+	if err != nil {
+		return qemuImageInfo{}, fmt.Errorf("failed to parse qemu-img info output:\n%w", err)
+	}
+
+	if info.Format == "" {
+		return qemuImageInfo{}, fmt.Errorf("qemu-img info output is missing the image format")
+	}
+
+	return info, nil
+}
+
+// rawImageHasBootSignature checks if the first sector of a raw disk image ends with the MBR boot signature.
+func rawImageHasBootSignature(imageFile string) (bool, error) {
+	file, err := os.Open(imageFile)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	signature := make([]byte, len(bootSignature))
+	_, err = file.ReadAt(signature, bootSignatureOffset)
+	if err == io.EOF {
+		// The file is smaller than a single sector.
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	return string(signature) == string(bootSignature), nil
+}
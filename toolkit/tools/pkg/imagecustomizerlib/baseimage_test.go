@@ -0,0 +1,68 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseQemuImageInfo(t *testing.T) {
+	info, err := parseQemuImageInfo(`{
+    "virtual-size": 4294967296,
+    "filename": "core.vhdx",
+    "format": "vhdx",
+    "actual-size": 734003200,
+    "dirty-flag": false
+}`)
+	assert.NoError(t, err)
+	assert.Equal(t, qemuImageInfo{Format: "vhdx", VirtualSize: 4294967296}, info)
+}
+
+func TestParseQemuImageInfoMissingFormat(t *testing.T) {
+	_, err := parseQemuImageInfo(`{"virtual-size": 4294967296}`)
+	assert.ErrorContains(t, err, "missing the image format")
+}
+
+func TestParseQemuImageInfoInvalidJson(t *testing.T) {
+	_, err := parseQemuImageInfo("qemu-img: Could not open 'core.vhdx'")
+	assert.ErrorContains(t, err, "failed to parse qemu-img info output")
+}
+
+func TestRawImageHasBootSignature(t *testing.T) {
+	testTmpDir := filepath.Join(tmpDir, "TestRawImageHasBootSignature")
+	err := os.MkdirAll(testTmpDir, os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	diskFile := filepath.Join(testTmpDir, "disk.raw")
+	sector := make([]byte, 512)
+	sector[510] = 0x55
+	sector[511] = 0xAA
+	err = os.WriteFile(diskFile, sector, 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	hasBootSignature, err := rawImageHasBootSignature(diskFile)
+	assert.NoError(t, err)
+	assert.True(t, hasBootSignature)
+
+	textFile := filepath.Join(testTmpDir, "config.yaml")
+	err = os.WriteFile(textFile, []byte("SystemConfig:\n  Hostname: test\n"), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	hasBootSignature, err = rawImageHasBootSignature(textFile)
+	assert.NoError(t, err)
+	assert.False(t, hasBootSignature)
+
+	_, err = rawImageHasBootSignature(filepath.Join(testTmpDir, "missing.raw"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,81 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"fmt"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagegen/diskutils"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/logger"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/safeloopback"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/shell"
+)
+
+// checkImageFilesystems runs a read-only filesystem check (e.g. fsck) on each of the image's partitions, to catch any
+// filesystem corruption introduced during customization.
+func checkImageFilesystems(buildImageFile string) error {
+	imageLoopback, err := safeloopback.NewLoopback(buildImageFile)
+	if err != nil {
+		return err
+	}
+	defer imageLoopback.Close()
+
+	diskPartitions, err := diskutils.GetDiskPartitions(imageLoopback.DevicePath())
+	if err != nil {
+		return err
+	}
+
+	for _, diskPartition := range diskPartitions {
+		if diskPartition.Type != "part" {
+			continue
+		}
+
+		err = checkFilesystem(diskPartition)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = imageLoopback.CleanClose()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func checkFilesystem(diskPartition diskutils.PartitionInfo) error {
+	var command string
+	var args []string
+
+	// Note: All the checks are run in no-modify mode. So, they only report problems.
+	switch diskPartition.FileSystemType {
+	case "ext2", "ext3", "ext4":
+		command = "e2fsck"
+		args = []string{"-f", "-n", diskPartition.Path}
+
+	case "xfs":
+		command = "xfs_repair"
+		args = []string{"-n", diskPartition.Path}
+
+	case "vfat":
+		command = "fsck.vfat"
+		args = []string{"-n", diskPartition.Path}
+
+	default:
+		logger.Log.Debugf("Skipping filesystem check of partition (%s) with filesystem type (%s)", diskPartition.Path,
+			diskPartition.FileSystemType)
+		return nil
+	}
+
+	logger.Log.Infof("Checking filesystem of partition (%s)", diskPartition.Path)
+
+	err := shell.ExecuteLiveWithErr(1, command, args...)
+	if err != nil {
+		return fmt.Errorf("filesystem check failed for partition (%s) with filesystem type (%s):\n%w",
+			diskPartition.Path, diskPartition.FileSystemType, err)
+	}
+
+	return nil
+}
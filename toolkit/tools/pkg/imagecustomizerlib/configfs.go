@@ -0,0 +1,109 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagecustomizerapi"
+)
+
+const (
+	configFSDirName = "configfs"
+)
+
+// CustomizeImageWithConfigFS customizes an image using an in-memory config.
+//
+// Unlike CustomizeImage, the files referenced by the config (e.g. AdditionalFiles, PostInstallScripts, and
+// PackageLists) are resolved against configFS instead of a directory on disk. The file permissions reported by
+// configFS are preserved. So, scripts must have their executable bit set.
+//
+// configFS may be nil if the config doesn't reference any files.
+func CustomizeImageWithConfigFS(buildDir string, configFS fs.FS, config *imagecustomizerapi.Config, imageFile string,
+	rpmsSources []string, outputImageFile string, outputImageFormat string, outputSplitPartitionsFormat string,
+	useBaseImageRpmRepos bool, options CustomizeImageOptions,
+) error {
+	buildDirAbs, err := filepath.Abs(buildDir)
+	if err != nil {
+		return err
+	}
+
+	// Some of the customization steps need the config's files to be on disk (e.g. the scripts are bind mounted into
+	// the image's chroot). So, write the files to a directory within the build directory.
+	baseConfigPath := filepath.Join(buildDirAbs, configFSDirName)
+
+	err = os.RemoveAll(baseConfigPath)
+	if err != nil {
+		return fmt.Errorf("failed to clean config files directory (%s):\n%w", baseConfigPath, err)
+	}
+
+	err = os.MkdirAll(baseConfigPath, os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("failed to create config files directory (%s):\n%w", baseConfigPath, err)
+	}
+	defer os.RemoveAll(baseConfigPath)
+
+	if configFS != nil {
+		err = copyFSToDir(configFS, baseConfigPath)
+		if err != nil {
+			return fmt.Errorf("failed to write config files to directory (%s):\n%w", baseConfigPath, err)
+		}
+	}
+
+	err = CustomizeImage(buildDirAbs, baseConfigPath, config, imageFile, rpmsSources, outputImageFile,
+		outputImageFormat, outputSplitPartitionsFormat, useBaseImageRpmRepos, options)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// copyFSToDir copies all the directories and regular files within a fs.FS to a directory.
+func copyFSToDir(srcFS fs.FS, destDir string) error {
+	err := fs.WalkDir(srcFS, ".", func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		destPath := filepath.Join(destDir, filepath.FromSlash(path))
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case info.IsDir():
+			err = os.MkdirAll(destPath, os.ModePerm)
+			if err != nil {
+				return err
+			}
+
+		case info.Mode().IsRegular():
+			content, err := fs.ReadFile(srcFS, path)
+			if err != nil {
+				return err
+			}
+
+			err = os.WriteFile(destPath, content, info.Mode().Perm())
+			if err != nil {
+				return err
+			}
+
+		default:
+			return fmt.Errorf("unsupported file type (%s): %s", info.Mode().Type(), path)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
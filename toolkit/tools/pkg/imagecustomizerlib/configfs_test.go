@@ -0,0 +1,70 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCopyFSToDir(t *testing.T) {
+	destDir := filepath.Join(tmpDir, "TestCopyFSToDir")
+	defer os.RemoveAll(destDir)
+
+	srcFS := fstest.MapFS{
+		"files/a.txt": &fstest.MapFile{
+			Data: []byte("abcdefg\n"),
+			Mode: 0o644,
+		},
+		"scripts/postinstall.sh": &fstest.MapFile{
+			Data: []byte("#!/bin/sh\necho hello\n"),
+			Mode: 0o755,
+		},
+	}
+
+	err := os.MkdirAll(destDir, os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = copyFSToDir(srcFS, destDir)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	content, err := os.ReadFile(filepath.Join(destDir, "files/a.txt"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "abcdefg\n", string(content))
+	}
+
+	scriptStat, err := os.Stat(filepath.Join(destDir, "scripts/postinstall.sh"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, fs.FileMode(0o755), scriptStat.Mode().Perm())
+	}
+}
+
+func TestCopyFSToDirSymlink(t *testing.T) {
+	destDir := filepath.Join(tmpDir, "TestCopyFSToDirSymlink")
+	defer os.RemoveAll(destDir)
+
+	srcFS := fstest.MapFS{
+		"link": &fstest.MapFile{
+			Data: []byte("a.txt"),
+			Mode: fs.ModeSymlink | 0o777,
+		},
+	}
+
+	err := os.MkdirAll(destDir, os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = copyFSToDir(srcFS, destDir)
+	assert.ErrorContains(t, err, "unsupported file type")
+}
@@ -0,0 +1,203 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagecustomizerapi"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/file"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/logger"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/safechroot"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/sliceutils"
+)
+
+const (
+	kickstartSeedPath  = "/ks.cfg"
+	noCloudSeedDir     = "/var/lib/cloud/seed/nocloud"
+	cloudInitBinary    = "/usr/bin/cloud-init"
+	kickstartEndMarker = "%end"
+)
+
+var (
+	// The kickstart sections that must be terminated by an %end line.
+	kickstartSectionMarkers = []string{
+		"%addon", "%anaconda", "%onerror", "%packages", "%post", "%pre", "%pre-install", "%traceback",
+	}
+
+	// The kickstart directives that may appear on a line of their own, outside of a section.
+	kickstartStandaloneMarkers = []string{"%include", "%ksappend"}
+
+	noCloudRequiredFiles = []string{"meta-data", "user-data"}
+	noCloudOptionalFiles = []string{"network-config", "vendor-data"}
+
+	// The headers that cloud-init recognizes at the start of a user-data file.
+	noCloudUserDataHeaders = []string{"#cloud-config", "#!", "#include", "#cloud-boothook", "Content-Type:"}
+)
+
+func validateAutoinstallSeed(baseConfigPath string, seed *imagecustomizerapi.AutoinstallSeed) error {
+	if seed == nil {
+		return nil
+	}
+
+	fullPath := filepath.Join(baseConfigPath, seed.Path)
+
+	switch seed.Type {
+	case imagecustomizerapi.AutoinstallSeedTypeKickstart:
+		content, err := os.ReadFile(fullPath)
+		if err != nil {
+			return fmt.Errorf("failed to read kickstart file (%s):\n%w", seed.Path, err)
+		}
+
+		err = validateKickstartSections(string(content))
+		if err != nil {
+			return fmt.Errorf("invalid kickstart file (%s):\n%w", seed.Path, err)
+		}
+
+	case imagecustomizerapi.AutoinstallSeedTypeNoCloud:
+		var errs []error
+		for _, name := range noCloudRequiredFiles {
+			isFile, err := file.IsFile(filepath.Join(fullPath, name))
+			if err != nil || !isFile {
+				errs = append(errs, fmt.Errorf("NoCloud seed directory (%s) is missing the %s file", seed.Path, name))
+			}
+		}
+		if len(errs) > 0 {
+			return errors.Join(errs...)
+		}
+
+		userData, err := os.ReadFile(filepath.Join(fullPath, "user-data"))
+		if err != nil {
+			return fmt.Errorf("failed to read NoCloud user-data file (%s):\n%w", seed.Path, err)
+		}
+
+		if !sliceutils.ContainsFunc(noCloudUserDataHeaders, func(header string) bool {
+			return strings.HasPrefix(string(userData), header)
+		}) {
+			return fmt.Errorf("invalid NoCloud user-data file (%s): must start with one of: %s", seed.Path,
+				strings.Join(noCloudUserDataHeaders, ", "))
+		}
+	}
+
+	return nil
+}
+
+// validateKickstartSections does a minimal syntax check of a kickstart file: every section (e.g. %packages, %post)
+// must be closed by an %end line before the next section starts.
+func validateKickstartSections(content string) error {
+	openSection := ""
+	openSectionLine := 0
+
+	lineNumber := 0
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		lineNumber++
+
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "%") {
+			continue
+		}
+
+		marker, _, _ := strings.Cut(line, " ")
+
+		switch {
+		case marker == kickstartEndMarker:
+			if openSection == "" {
+				return fmt.Errorf("line %d: %s without a matching section start", lineNumber, kickstartEndMarker)
+			}
+
+			openSection = ""
+
+		case sliceutils.ContainsValue(kickstartSectionMarkers, marker):
+			if openSection != "" {
+				return fmt.Errorf("line %d: section (%s) started at line %d is not closed with %s", lineNumber,
+					openSection, openSectionLine, kickstartEndMarker)
+			}
+
+			openSection = marker
+			openSectionLine = lineNumber
+
+		case sliceutils.ContainsValue(kickstartStandaloneMarkers, marker):
+			// Allowed anywhere.
+
+		case openSection != "":
+			// Section bodies (e.g. scripts) may contain arbitrary lines.
+
+		default:
+			return fmt.Errorf("line %d: unknown section marker (%s)", lineNumber, marker)
+		}
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return err
+	}
+
+	if openSection != "" {
+		return fmt.Errorf("section (%s) started at line %d is not closed with %s", openSection, openSectionLine,
+			kickstartEndMarker)
+	}
+
+	return nil
+}
+
+// installAutoinstallSeed copies the autoinstall seed into the location that the first boot installer reads it from.
+func installAutoinstallSeed(baseConfigPath string, seed *imagecustomizerapi.AutoinstallSeed,
+	imageChroot *safechroot.Chroot,
+) error {
+	if seed == nil {
+		return nil
+	}
+
+	logger.Log.Infof("Installing %s autoinstall seed", seed.Type)
+
+	sourcePath := filepath.Join(baseConfigPath, seed.Path)
+
+	// Seeds commonly contain credentials. So, only allow root to read them.
+	switch seed.Type {
+	case imagecustomizerapi.AutoinstallSeedTypeKickstart:
+		err := file.CopyAndChangeMode(sourcePath, filepath.Join(imageChroot.RootDir(), kickstartSeedPath), 0o755,
+			0o600)
+		if err != nil {
+			return fmt.Errorf("failed to copy kickstart file (%s):\n%w", seed.Path, err)
+		}
+
+	case imagecustomizerapi.AutoinstallSeedTypeNoCloud:
+		cloudInitExists, err := pathExistsInRoot(imageChroot.RootDir(), cloudInitBinary)
+		if err != nil {
+			return err
+		}
+
+		if !cloudInitExists {
+			logger.Log.Warnf("NoCloud seed is being installed but the image does not contain %s (is cloud-init installed?)",
+				cloudInitBinary)
+		}
+
+		for _, name := range append(noCloudRequiredFiles, noCloudOptionalFiles...) {
+			sourceFile := filepath.Join(sourcePath, name)
+
+			isFile, err := file.IsFile(sourceFile)
+			if err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to stat NoCloud seed file (%s):\n%w", sourceFile, err)
+			}
+
+			if !isFile {
+				continue
+			}
+
+			err = file.CopyAndChangeMode(sourceFile, filepath.Join(imageChroot.RootDir(), noCloudSeedDir, name),
+				0o700, 0o600)
+			if err != nil {
+				return fmt.Errorf("failed to copy NoCloud seed file (%s):\n%w", name, err)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagecustomizerapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateKickstartSections(t *testing.T) {
+	content := `# Kickstart file
+lang en_US.UTF-8
+%include /tmp/part-include
+
+%pre
+echo "%not-a-marker"
+%end
+
+%packages
+@core
+%end
+
+%post --log=/root/ks-post.log
+echo done
+%end
+`
+
+	err := validateKickstartSections(content)
+	assert.NoError(t, err)
+}
+
+func TestValidateKickstartSectionsUnclosed(t *testing.T) {
+	content := "%packages\n@core\n\n%post\necho done\n%end\n"
+
+	err := validateKickstartSections(content)
+	assert.ErrorContains(t, err, "line 4: section (%packages) started at line 1 is not closed with %end")
+}
+
+func TestValidateKickstartSectionsUnclosedAtEnd(t *testing.T) {
+	content := "lang en_US.UTF-8\n%post\necho done\n"
+
+	err := validateKickstartSections(content)
+	assert.ErrorContains(t, err, "section (%post) started at line 2 is not closed with %end")
+}
+
+func TestValidateKickstartSectionsStrayEnd(t *testing.T) {
+	content := "lang en_US.UTF-8\n%end\n"
+
+	err := validateKickstartSections(content)
+	assert.ErrorContains(t, err, "line 2: %end without a matching section start")
+}
+
+func TestValidateKickstartSectionsUnknownMarker(t *testing.T) {
+	content := "%postinstall\necho done\n%end\n"
+
+	err := validateKickstartSections(content)
+	assert.ErrorContains(t, err, "line 1: unknown section marker (%postinstall)")
+}
+
+func TestValidateAutoinstallSeedNoCloud(t *testing.T) {
+	seedDir := filepath.Join(tmpDir, "TestValidateAutoinstallSeedNoCloud")
+
+	err := os.MkdirAll(seedDir, 0o755)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	seed := &imagecustomizerapi.AutoinstallSeed{
+		Type: imagecustomizerapi.AutoinstallSeedTypeNoCloud,
+		Path: ".",
+	}
+
+	err = validateAutoinstallSeed(seedDir, seed)
+	assert.ErrorContains(t, err, "is missing the meta-data file")
+	assert.ErrorContains(t, err, "is missing the user-data file")
+
+	err = os.WriteFile(filepath.Join(seedDir, "meta-data"), []byte("instance-id: test\n"), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = os.WriteFile(filepath.Join(seedDir, "user-data"), []byte("users: []\n"), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = validateAutoinstallSeed(seedDir, seed)
+	assert.ErrorContains(t, err, "invalid NoCloud user-data file")
+
+	err = os.WriteFile(filepath.Join(seedDir, "user-data"), []byte("#cloud-config\nusers: []\n"), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = validateAutoinstallSeed(seedDir, seed)
+	assert.NoError(t, err)
+}
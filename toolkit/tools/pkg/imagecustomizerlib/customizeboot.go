@@ -9,12 +9,16 @@ import (
 	"path/filepath"
 	"regexp"
 
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagecustomizerapi"
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/logger"
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/safechroot"
 )
 
 var (
-	linuxCommandLineRegex = regexp.MustCompile(`\tlinux .* (\$kernelopts)`)
+	linuxCommandLineRegex   = regexp.MustCompile(`\tlinux .* (\$kernelopts)`)
+	grubTimeoutRegex        = regexp.MustCompile(`(?m)^[ \t]*set[ \t]+timeout=.*$`)
+	grubDefaultRegex        = regexp.MustCompile(`(?m)^[ \t]*set[ \t]+default=.*$`)
+	grubSerialTerminalRegex = regexp.MustCompile(`(?m)^[ \t]*(serial|terminal_input|terminal_output)([ \t].*)?\n`)
 )
 
 func handleKernelCommandLine(extraCommandLine string, imageChroot *safechroot.Chroot, partitionsCustomized bool) error {
@@ -41,12 +45,26 @@ func handleKernelCommandLine(extraCommandLine string, imageChroot *safechroot.Ch
 		return fmt.Errorf("failed to read existing grub2 config file: %w", err)
 	}
 
-	grub2ConfigFile := string(grub2ConfigFileBytes)
+	newGrub2ConfigFile, err := insertKernelCommandLineArgs(string(grub2ConfigFileBytes), extraCommandLine)
+	if err != nil {
+		return err
+	}
+
+	// Update grub.cfg file.
+	err = os.WriteFile(grub2ConfigFilePath, []byte(newGrub2ConfigFile), 0)
+	if err != nil {
+		return fmt.Errorf("failed to write new grub2 config file: %w", err)
+	}
+
+	return nil
+}
 
+// insertKernelCommandLineArgs adds extra args to the Linux kernel command line in a grub.cfg file.
+func insertKernelCommandLineArgs(grub2ConfigFile string, args string) (string, error) {
 	// Find the point where the new command line arguments should be added.
 	match := linuxCommandLineRegex.FindStringSubmatchIndex(grub2ConfigFile)
 	if match == nil {
-		return fmt.Errorf("failed to find Linux kernel command line params in grub2 config file")
+		return "", fmt.Errorf("failed to find Linux kernel command line params in grub2 config file")
 	}
 
 	// Get the location of "$kernelopts".
@@ -54,13 +72,88 @@ func handleKernelCommandLine(extraCommandLine string, imageChroot *safechroot.Ch
 	insertIndex := match[2]
 
 	// Insert new command line arguments.
-	newGrub2ConfigFile := grub2ConfigFile[:insertIndex] + extraCommandLine + " " + grub2ConfigFile[insertIndex:]
+	return grub2ConfigFile[:insertIndex] + args + " " + grub2ConfigFile[insertIndex:], nil
+}
+
+// Sets the grub menu's timeout, default entry, and serial console in the grub.cfg file.
+func updateBootloaderSettings(bootloader *imagecustomizerapi.Bootloader, imageChroot *safechroot.Chroot) error {
+	if bootloader == nil {
+		return nil
+	}
+
+	logger.Log.Infof("Updating bootloader settings")
+
+	grub2ConfigFilePath := filepath.Join(imageChroot.RootDir(), "/boot/grub2/grub.cfg")
+
+	grub2ConfigFileBytes, err := os.ReadFile(grub2ConfigFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to read existing grub2 config file:\n%w", err)
+	}
+
+	newGrub2ConfigFile := setGrubCfgMenuSettings(string(grub2ConfigFileBytes), bootloader)
+
+	if bootloader.SerialConsole != "" {
+		newGrub2ConfigFile, err = setGrubCfgSerialConsole(newGrub2ConfigFile, bootloader.SerialConsole)
+		if err != nil {
+			return err
+		}
+	}
 
-	// Update grub.cfg file.
 	err = os.WriteFile(grub2ConfigFilePath, []byte(newGrub2ConfigFile), 0)
 	if err != nil {
-		return fmt.Errorf("failed to write new grub2 config file: %w", err)
+		return fmt.Errorf("failed to write new grub2 config file:\n%w", err)
 	}
 
 	return nil
 }
+
+// setGrubCfgMenuSettings replaces the existing `set timeout=` and `set default=` lines of a grub.cfg file. If a line
+// doesn't exist, then it is added to the top of the file.
+func setGrubCfgMenuSettings(grub2ConfigFile string, bootloader *imagecustomizerapi.Bootloader) string {
+	if bootloader.DefaultEntry != "" {
+		// grub accepts either a menu entry's index or its title.
+		defaultLine := fmt.Sprintf("set default=\"%s\"", bootloader.DefaultEntry)
+		grub2ConfigFile = replaceOrPrependGrubCfgLine(grub2ConfigFile, grubDefaultRegex, defaultLine)
+	}
+
+	if bootloader.Timeout != nil {
+		timeoutLine := fmt.Sprintf("set timeout=%d", *bootloader.Timeout)
+		grub2ConfigFile = replaceOrPrependGrubCfgLine(grub2ConfigFile, grubTimeoutRegex, timeoutLine)
+	}
+
+	return grub2ConfigFile
+}
+
+func replaceOrPrependGrubCfgLine(grub2ConfigFile string, lineRegex *regexp.Regexp, line string) string {
+	if lineRegex.MatchString(grub2ConfigFile) {
+		return lineRegex.ReplaceAllLiteralString(grub2ConfigFile, line)
+	}
+
+	return line + "\n" + grub2ConfigFile
+}
+
+// setGrubCfgSerialConsole configures grub to use a serial terminal and adds the matching `console=` arg to the kernel
+// command line.
+func setGrubCfgSerialConsole(grub2ConfigFile string, serialConsole imagecustomizerapi.SerialConsole) (string, error) {
+	settings, err := serialConsole.Settings()
+	if err != nil {
+		return "", err
+	}
+
+	grub2ConfigFile, err = insertKernelCommandLineArgs(grub2ConfigFile, "console="+string(serialConsole))
+	if err != nil {
+		return "", err
+	}
+
+	grubParity := map[byte]string{'n': "no", 'o': "odd", 'e': "even"}[settings.Parity]
+
+	// Replace any existing terminal settings.
+	grub2ConfigFile = grubSerialTerminalRegex.ReplaceAllLiteralString(grub2ConfigFile, "")
+
+	terminalLines := fmt.Sprintf("serial --unit=%d --speed=%d --word=%d --parity=%s --stop=1\n"+
+		"terminal_input serial console\n"+
+		"terminal_output serial console\n",
+		settings.Unit, settings.BaudRate, settings.WordBits, grubParity)
+
+	return terminalLines + grub2ConfigFile, nil
+}
@@ -0,0 +1,69 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"testing"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagecustomizerapi"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/ptrutils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetGrubCfgMenuSettingsReplace(t *testing.T) {
+	grubCfg := "set timeout=0\nset default=\"0\"\nmenuentry \"CBL-Mariner\" {\n}\n"
+
+	bootloader := &imagecustomizerapi.Bootloader{
+		Timeout:      ptrutils.PtrTo(5),
+		DefaultEntry: "CBL-Mariner",
+	}
+
+	newGrubCfg := setGrubCfgMenuSettings(grubCfg, bootloader)
+	assert.Equal(t, "set timeout=5\nset default=\"CBL-Mariner\"\nmenuentry \"CBL-Mariner\" {\n}\n", newGrubCfg)
+}
+
+func TestSetGrubCfgMenuSettingsAdd(t *testing.T) {
+	grubCfg := "menuentry \"CBL-Mariner\" {\n}\n"
+
+	bootloader := &imagecustomizerapi.Bootloader{
+		Timeout:      ptrutils.PtrTo(3),
+		DefaultEntry: "1",
+	}
+
+	newGrubCfg := setGrubCfgMenuSettings(grubCfg, bootloader)
+	assert.Equal(t, "set timeout=3\nset default=\"1\"\nmenuentry \"CBL-Mariner\" {\n}\n", newGrubCfg)
+}
+
+func TestSetGrubCfgMenuSettingsTimeoutOnly(t *testing.T) {
+	grubCfg := "set timeout=0\nmenuentry \"CBL-Mariner\" {\n}\n"
+
+	bootloader := &imagecustomizerapi.Bootloader{
+		Timeout: ptrutils.PtrTo(10),
+	}
+
+	newGrubCfg := setGrubCfgMenuSettings(grubCfg, bootloader)
+	assert.Equal(t, "set timeout=10\nmenuentry \"CBL-Mariner\" {\n}\n", newGrubCfg)
+}
+
+func TestSetGrubCfgSerialConsole(t *testing.T) {
+	grubCfg := "set timeout=0\nterminal_output console\nmenuentry \"CBL-Mariner\" {\n" +
+		"\tlinux $bootprefix/$mariner_linux rd.auto=1 root=$rootdevice $kernelopts\n}\n"
+
+	newGrubCfg, err := setGrubCfgSerialConsole(grubCfg, "ttyS0,115200")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, "serial --unit=0 --speed=115200 --word=8 --parity=no --stop=1\n"+
+		"terminal_input serial console\n"+
+		"terminal_output serial console\n"+
+		"set timeout=0\nmenuentry \"CBL-Mariner\" {\n"+
+		"\tlinux $bootprefix/$mariner_linux rd.auto=1 root=$rootdevice console=ttyS0,115200 $kernelopts\n}\n",
+		newGrubCfg)
+}
+
+func TestSetGrubCfgSerialConsoleNoLinuxLine(t *testing.T) {
+	_, err := setGrubCfgSerialConsole("set timeout=0\n", "ttyS0")
+	assert.ErrorContains(t, err, "failed to find Linux kernel command line params")
+}
@@ -0,0 +1,247 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/logger"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/safechroot"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/shell"
+)
+
+var (
+	debuginfoPackageSuffixes = []string{"-debuginfo", "-debugsource"}
+)
+
+// installedPackageInfo is an installed package, along with the name of the source RPM it was built from.
+type installedPackageInfo struct {
+	Name          string
+	Version       string
+	Release       string
+	SourceRpmName string
+}
+
+// installDebuginfoPackages installs the -debuginfo and -debugsource packages that match the installed packages.
+// Installed packages that don't have a matching debuginfo package in the RPM sources are skipped.
+func installDebuginfoPackages(releaseVersion string, gpgCheck bool, imageChroot *safechroot.Chroot) error {
+	logger.Log.Infof("Installing debuginfo packages")
+
+	// Check that the debuginfo repo is one of the RPM sources.
+	allDebuginfoPackages, err := queryAvailablePackages([]string{"*-debuginfo"}, releaseVersion, imageChroot)
+	if err != nil {
+		return err
+	}
+
+	if len(allDebuginfoPackages) <= 0 {
+		return fmt.Errorf("PackagesInstallDebuginfo is set but the RPM sources don't contain any debuginfo packages (add the debuginfo repo using --rpm-source)")
+	}
+
+	installedPackages, err := getInstalledPackagesInfo(imageChroot)
+	if err != nil {
+		return err
+	}
+
+	candidates := debuginfoPackageCandidates(installedPackages)
+
+	availablePackages, err := queryAvailablePackages(candidates, releaseVersion, imageChroot)
+	if err != nil {
+		return err
+	}
+
+	packagesToInstall, skipped := filterAvailablePackages(candidates, availablePackages)
+	logger.Log.Debugf("Debuginfo packages not found in the RPM sources: %v", skipped)
+
+	logger.Log.Infof("Installing debuginfo packages: %v", packagesToInstall)
+	err = installOrUpdatePackages("install", packagesToInstall, releaseVersion, gpgCheck, imageChroot)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// getInstalledPackagesInfo lists the installed packages, sorted by name.
+func getInstalledPackagesInfo(imageChroot *safechroot.Chroot) ([]installedPackageInfo, error) {
+	stdout := ""
+	err := imageChroot.UnsafeRun(func() error {
+		var err error
+		stdout, _, err = shell.Execute("rpm", "-qa", "--queryformat",
+			"%{NAME} %{VERSION} %{RELEASE} %{SOURCERPM}\n")
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list installed packages:\n%w", err)
+	}
+
+	return parseInstalledPackagesInfo(stdout), nil
+}
+
+func parseInstalledPackagesInfo(rpmOutput string) []installedPackageInfo {
+	packages := []installedPackageInfo(nil)
+	for _, line := range strings.Split(rpmOutput, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 4 {
+			continue
+		}
+
+		// Packages that don't come from an RPM file (e.g. gpg-pubkey) don't have a source RPM.
+		sourceRpmName, _, _, ok := parseRpmFileName(fields[3])
+		if !ok {
+			continue
+		}
+
+		packages = append(packages, installedPackageInfo{
+			Name:          fields[0],
+			Version:       fields[1],
+			Release:       fields[2],
+			SourceRpmName: sourceRpmName,
+		})
+	}
+
+	sort.Slice(packages, func(i, j int) bool {
+		return packages[i].Name < packages[j].Name
+	})
+
+	return packages
+}
+
+// parseRpmFileName splits an RPM file name (e.g. "openssl-3.1.4-1.cm2.src.rpm") into its name, version, and release.
+func parseRpmFileName(fileName string) (string, string, string, bool) {
+	nvra, found := strings.CutSuffix(fileName, ".rpm")
+	if !found {
+		return "", "", "", false
+	}
+
+	archIndex := strings.LastIndex(nvra, ".")
+	if archIndex < 0 {
+		return "", "", "", false
+	}
+
+	return splitNameVersionRelease(nvra[:archIndex])
+}
+
+// splitNameVersionRelease splits a "<name>-<version>-<release>" string.
+func splitNameVersionRelease(nvr string) (string, string, string, bool) {
+	releaseIndex := strings.LastIndex(nvr, "-")
+	if releaseIndex <= 0 {
+		return "", "", "", false
+	}
+
+	versionIndex := strings.LastIndex(nvr[:releaseIndex], "-")
+	if versionIndex <= 0 {
+		return "", "", "", false
+	}
+
+	return nvr[:versionIndex], nvr[versionIndex+1 : releaseIndex], nvr[releaseIndex+1:], true
+}
+
+// debuginfoPackageCandidates returns the sorted "<name>-<version>-<release>" specs of the debuginfo and
+// debugsource packages that could match the installed packages.
+// Debug packages are usually built per source RPM but some distros build them per binary package. So, both
+// names are included.
+func debuginfoPackageCandidates(installedPackages []installedPackageInfo) []string {
+	candidateSet := make(map[string]bool)
+	for _, installedPackage := range installedPackages {
+		if isDebuginfoPackage(installedPackage.Name) {
+			continue
+		}
+
+		for _, suffix := range debuginfoPackageSuffixes {
+			for _, name := range []string{installedPackage.SourceRpmName, installedPackage.Name} {
+				candidate := fmt.Sprintf("%s%s-%s-%s", name, suffix, installedPackage.Version,
+					installedPackage.Release)
+				candidateSet[candidate] = true
+			}
+		}
+	}
+
+	candidates := []string(nil)
+	for candidate := range candidateSet {
+		candidates = append(candidates, candidate)
+	}
+
+	sort.Strings(candidates)
+	return candidates
+}
+
+func isDebuginfoPackage(packageName string) bool {
+	for _, suffix := range debuginfoPackageSuffixes {
+		if strings.HasSuffix(packageName, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// queryAvailablePackages returns the "<name>-<version>-<release>" of the packages in the RPM sources that match
+// the package specs.
+func queryAvailablePackages(packageSpecs []string, releaseVersion string, imageChroot *safechroot.Chroot,
+) (map[string]bool, error) {
+	if len(packageSpecs) <= 0 {
+		return nil, nil
+	}
+
+	tdnfRepoQueryArgs := []string{
+		"repoquery", "--quiet",
+	}
+	tdnfRepoQueryArgs = append(tdnfRepoQueryArgs, tdnfRepoArgs(releaseVersion)...)
+	tdnfRepoQueryArgs = append(tdnfRepoQueryArgs, packageSpecs...)
+
+	stdout := ""
+	err := imageChroot.Run(func() error {
+		var err error
+		stdout, _, err = shell.Execute("tdnf", tdnfRepoQueryArgs...)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query RPM sources for packages:\n%w", err)
+	}
+
+	return parseRepoQueryOutput(stdout), nil
+}
+
+// parseRepoQueryOutput parses the "<name>-[<epoch>:]<version>-<release>.<arch>" lines printed by
+// `tdnf repoquery` into a set of "<name>-<version>-<release>" strings.
+func parseRepoQueryOutput(stdout string) map[string]bool {
+	packages := make(map[string]bool)
+	for _, line := range strings.Split(stdout, "\n") {
+		line = strings.TrimSpace(line)
+
+		archIndex := strings.LastIndex(line, ".")
+		if archIndex < 0 {
+			continue
+		}
+
+		name, version, release, ok := splitNameVersionRelease(line[:archIndex])
+		if !ok {
+			continue
+		}
+
+		// Strip the epoch.
+		if _, versionWithoutEpoch, found := strings.Cut(version, ":"); found {
+			version = versionWithoutEpoch
+		}
+
+		packages[fmt.Sprintf("%s-%s-%s", name, version, release)] = true
+	}
+
+	return packages
+}
+
+// filterAvailablePackages splits the candidate packages into the ones that are available and the ones that aren't.
+func filterAvailablePackages(candidates []string, availablePackages map[string]bool) ([]string, []string) {
+	available := []string(nil)
+	missing := []string(nil)
+	for _, candidate := range candidates {
+		if availablePackages[candidate] {
+			available = append(available, candidate)
+		} else {
+			missing = append(missing, candidate)
+		}
+	}
+	return available, missing
+}
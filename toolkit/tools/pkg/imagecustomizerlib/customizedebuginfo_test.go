@@ -0,0 +1,71 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseInstalledPackagesInfo(t *testing.T) {
+	rpmOutput := "openssl-libs 3.1.4 1.cm2 openssl-3.1.4-1.cm2.src.rpm\n" +
+		"gpg-pubkey 3135ce90 5e6fda74 (none)\n" +
+		"bash 5.1.8 4.cm2 bash-5.1.8-4.cm2.src.rpm\n"
+
+	packages := parseInstalledPackagesInfo(rpmOutput)
+	assert.Equal(t, []installedPackageInfo{
+		{Name: "bash", Version: "5.1.8", Release: "4.cm2", SourceRpmName: "bash"},
+		{Name: "openssl-libs", Version: "3.1.4", Release: "1.cm2", SourceRpmName: "openssl"},
+	}, packages)
+}
+
+func TestParseRpmFileName(t *testing.T) {
+	name, version, release, ok := parseRpmFileName("python-setuptools-69.0.3-1.cm2.src.rpm")
+	assert.True(t, ok)
+	assert.Equal(t, "python-setuptools", name)
+	assert.Equal(t, "69.0.3", version)
+	assert.Equal(t, "1.cm2", release)
+
+	_, _, _, ok = parseRpmFileName("(none)")
+	assert.False(t, ok)
+
+	_, _, _, ok = parseRpmFileName("bash.src.rpm")
+	assert.False(t, ok)
+}
+
+func TestDebuginfoPackageCandidates(t *testing.T) {
+	candidates := debuginfoPackageCandidates([]installedPackageInfo{
+		{Name: "openssl-libs", Version: "3.1.4", Release: "1.cm2", SourceRpmName: "openssl"},
+		{Name: "openssl", Version: "3.1.4", Release: "1.cm2", SourceRpmName: "openssl"},
+		{Name: "bash-debuginfo", Version: "5.1.8", Release: "4.cm2", SourceRpmName: "bash"},
+	})
+	assert.Equal(t, []string{
+		"openssl-debuginfo-3.1.4-1.cm2",
+		"openssl-debugsource-3.1.4-1.cm2",
+		"openssl-libs-debuginfo-3.1.4-1.cm2",
+		"openssl-libs-debugsource-3.1.4-1.cm2",
+	}, candidates)
+}
+
+func TestParseRepoQueryOutput(t *testing.T) {
+	stdout := "openssl-debuginfo-3.1.4-1.cm2.x86_64\n" +
+		"shadow-utils-debuginfo-2:4.9-12.cm2.x86_64\n" +
+		"\n"
+
+	packages := parseRepoQueryOutput(stdout)
+	assert.Equal(t, map[string]bool{
+		"openssl-debuginfo-3.1.4-1.cm2":     true,
+		"shadow-utils-debuginfo-4.9-12.cm2": true,
+	}, packages)
+}
+
+func TestFilterAvailablePackages(t *testing.T) {
+	available, missing := filterAvailablePackages(
+		[]string{"bash-debuginfo-5.1.8-4.cm2", "openssl-debuginfo-3.1.4-1.cm2"},
+		map[string]bool{"openssl-debuginfo-3.1.4-1.cm2": true},
+	)
+	assert.Equal(t, []string{"openssl-debuginfo-3.1.4-1.cm2"}, available)
+	assert.Equal(t, []string{"bash-debuginfo-5.1.8-4.cm2"}, missing)
+}
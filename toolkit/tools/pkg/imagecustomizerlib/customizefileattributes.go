@@ -0,0 +1,105 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagecustomizerapi"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/logger"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/safechroot"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/shell"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/sliceutils"
+)
+
+var (
+	fileAttributeChattrFlags = map[imagecustomizerapi.FileAttribute]string{
+		imagecustomizerapi.FileAttributeImmutable:  "i",
+		imagecustomizerapi.FileAttributeAppendOnly: "a",
+		imagecustomizerapi.FileAttributeNoAtime:    "A",
+		imagecustomizerapi.FileAttributeNoDump:     "d",
+		imagecustomizerapi.FileAttributeSync:       "S",
+	}
+
+	// The file system types (as reported by `stat --file-system --format=%T`) that support all of the file
+	// attributes.
+	// Note: stat reports ext4 as "ext2/ext3".
+	fileAttributesFileSystemTypes = []string{"ext2/ext3", "xfs", "btrfs"}
+)
+
+// setAdditionalFilesAttributes sets the file system attributes of the AdditionalFiles.
+// This is called after all the other customization steps (including FinalizeImageScripts), since immutable and
+// append-only files can't be modified by later steps.
+func setAdditionalFilesAttributes(additionalFiles map[string]imagecustomizerapi.FileConfigList,
+	imageChroot *safechroot.Chroot,
+) error {
+	// Sort the paths, so that the order is deterministic.
+	var fileConfigs []imagecustomizerapi.FileConfig
+	for _, fileConfigList := range additionalFiles {
+		for _, fileConfig := range fileConfigList {
+			if len(fileConfig.Attributes) > 0 {
+				fileConfigs = append(fileConfigs, fileConfig)
+			}
+		}
+	}
+
+	sort.Slice(fileConfigs, func(i, j int) bool {
+		return fileConfigs[i].Path < fileConfigs[j].Path
+	})
+
+	for _, fileConfig := range fileConfigs {
+		err := setFileAttributes(fileConfig.Path, fileConfig.Attributes, imageChroot)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func setFileAttributes(path string, attributes []imagecustomizerapi.FileAttribute,
+	imageChroot *safechroot.Chroot,
+) error {
+	chattrArg := fileAttributesChattrArg(attributes)
+
+	logger.Log.Infof("Setting file attributes (%s) on (%s)", chattrArg, path)
+
+	err := imageChroot.UnsafeRun(func() error {
+		stdout, _, err := shell.Execute("stat", "--file-system", "--format=%T", path)
+		if err != nil {
+			return fmt.Errorf("failed to get file system type of (%s):\n%w", path, err)
+		}
+
+		fileSystemType := strings.TrimSpace(stdout)
+		if !sliceutils.ContainsValue(fileAttributesFileSystemTypes, fileSystemType) {
+			return fmt.Errorf("file system (%s) of (%s) does not support file attributes", fileSystemType, path)
+		}
+
+		_, stderr, err := shell.Execute("chattr", chattrArg, path)
+		if err != nil {
+			return fmt.Errorf("%s\n%w", strings.TrimSpace(stderr), err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set file attributes (%s) on (%s):\n%w", chattrArg, path, err)
+	}
+
+	return nil
+}
+
+// fileAttributesChattrArg returns the chattr argument (e.g. "+ia") that adds the file attributes.
+func fileAttributesChattrArg(attributes []imagecustomizerapi.FileAttribute) string {
+	arg := "+"
+	for _, attribute := range attributes {
+		flag := fileAttributeChattrFlags[attribute]
+		if !strings.Contains(arg, flag) {
+			arg += flag
+		}
+	}
+	return arg
+}
@@ -0,0 +1,27 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"testing"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagecustomizerapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileAttributesChattrArg(t *testing.T) {
+	arg := fileAttributesChattrArg([]imagecustomizerapi.FileAttribute{
+		imagecustomizerapi.FileAttributeImmutable,
+		imagecustomizerapi.FileAttributeNoDump,
+		imagecustomizerapi.FileAttributeImmutable,
+	})
+	assert.Equal(t, "+id", arg)
+
+	arg = fileAttributesChattrArg([]imagecustomizerapi.FileAttribute{
+		imagecustomizerapi.FileAttributeAppendOnly,
+		imagecustomizerapi.FileAttributeNoAtime,
+		imagecustomizerapi.FileAttributeSync,
+	})
+	assert.Equal(t, "+aAS", arg)
+}
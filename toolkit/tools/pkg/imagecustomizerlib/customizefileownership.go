@@ -0,0 +1,101 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagecustomizerapi"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/safechroot"
+)
+
+const (
+	passwdFilePath = "/etc/passwd"
+	groupFilePath  = "/etc/group"
+)
+
+// setFileOwnership changes the owner and group of files in the image.
+// The owner and group names are resolved using the image's /etc/passwd and /etc/group files.
+func setFileOwnership(paths []string, fileConfig imagecustomizerapi.FileConfig, imageChroot *safechroot.Chroot,
+) error {
+	uid, err := resolveFileOwnerId(filepath.Join(imageChroot.RootDir(), passwdFilePath), fileConfig.Owner)
+	if err != nil {
+		return fmt.Errorf("failed to resolve owner of (%s):\n%w", fileConfig.Path, err)
+	}
+
+	gid, err := resolveFileOwnerId(filepath.Join(imageChroot.RootDir(), groupFilePath), fileConfig.Group)
+	if err != nil {
+		return fmt.Errorf("failed to resolve group of (%s):\n%w", fileConfig.Path, err)
+	}
+
+	for _, path := range paths {
+		err = os.Lchown(filepath.Join(imageChroot.RootDir(), path), uid, gid)
+		if err != nil {
+			return fmt.Errorf("failed to set ownership (%d:%d) of (%s):\n%w", uid, gid, path, err)
+		}
+	}
+
+	return nil
+}
+
+// resolveFileOwnerId returns the ID of a user or group.
+// The name is looked up in the specified passwd or group file, unless it is already a numeric ID.
+// An empty name resolves to root (0).
+func resolveFileOwnerId(databaseFile string, name string) (int, error) {
+	if name == "" {
+		return 0, nil
+	}
+
+	id, err := strconv.Atoi(name)
+	if err == nil {
+		return id, nil
+	}
+
+	file, err := os.Open(databaseFile)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open (%s):\n%w", databaseFile, err)
+	}
+	defer file.Close()
+
+	id, found, err := lookupIdInDatabase(bufio.NewScanner(file), name)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read (%s):\n%w", databaseFile, err)
+	}
+
+	if !found {
+		return 0, fmt.Errorf("(%s) not found in (%s)", name, databaseFile)
+	}
+
+	return id, nil
+}
+
+// lookupIdInDatabase finds the ID of a name in a passwd(5) or group(5) formatted file.
+// Both formats have the name in the first field and the ID in the third field.
+func lookupIdInDatabase(scanner *bufio.Scanner, name string) (int, bool, error) {
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), ":")
+		if len(fields) < 3 || fields[0] != name {
+			continue
+		}
+
+		id, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return 0, false, fmt.Errorf("invalid ID (%s) for (%s):\n%w", fields[2], name, err)
+		}
+
+		return id, true, nil
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return 0, false, err
+	}
+
+	return 0, false, nil
+}
@@ -0,0 +1,61 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testPasswdFile = "root:x:0:0:root:/root:/bin/bash\n" +
+		"app-user:x:1000:1000::/home/app-user:/bin/bash\n" +
+		"broken:x:abc:1001::/home/broken:/bin/bash\n"
+)
+
+func TestLookupIdInDatabase(t *testing.T) {
+	id, found, err := lookupIdInDatabase(bufio.NewScanner(strings.NewReader(testPasswdFile)), "app-user")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, 1000, id)
+
+	_, found, err = lookupIdInDatabase(bufio.NewScanner(strings.NewReader(testPasswdFile)), "missing")
+	assert.NoError(t, err)
+	assert.False(t, found)
+
+	_, _, err = lookupIdInDatabase(bufio.NewScanner(strings.NewReader(testPasswdFile)), "broken")
+	assert.ErrorContains(t, err, "invalid ID (abc) for (broken)")
+}
+
+func TestResolveFileOwnerId(t *testing.T) {
+	passwdFile := filepath.Join(tmpDir, "TestResolveFileOwnerId", "passwd")
+
+	err := os.MkdirAll(filepath.Dir(passwdFile), 0o755)
+	assert.NoError(t, err)
+
+	err = os.WriteFile(passwdFile, []byte(testPasswdFile), 0o644)
+	assert.NoError(t, err)
+
+	// Default is root.
+	id, err := resolveFileOwnerId(passwdFile, "")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, id)
+
+	// Numeric IDs don't need to exist.
+	id, err = resolveFileOwnerId(passwdFile, "2000")
+	assert.NoError(t, err)
+	assert.Equal(t, 2000, id)
+
+	id, err = resolveFileOwnerId(passwdFile, "app-user")
+	assert.NoError(t, err)
+	assert.Equal(t, 1000, id)
+
+	_, err = resolveFileOwnerId(passwdFile, "missing")
+	assert.ErrorContains(t, err, "(missing) not found")
+}
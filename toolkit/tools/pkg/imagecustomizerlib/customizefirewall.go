@@ -0,0 +1,218 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagecustomizerapi"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/file"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/logger"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/safechroot"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/shell"
+)
+
+const (
+	nftablesServiceName         = "nftables.service"
+	nftablesRulesetPath         = "/etc/sysconfig/nftables.conf"
+	nftablesStagingPathInChroot = "/_nftables.conf"
+	nftablesRulesetPermissions  = 0o600
+
+	firewalldServiceName     = "firewalld.service"
+	firewalldConfigPath      = "/etc/firewalld/firewalld.conf"
+	firewalldZonesDir        = "/etc/firewalld/zones"
+	firewalldZonePermissions = 0o644
+)
+
+var (
+	firewalldDefaultZoneRegex = regexp.MustCompile(`(?m)^[ \t]*DefaultZone[ \t]*=.*$`)
+)
+
+func configureFirewall(firewall *imagecustomizerapi.Firewall, imageChroot *safechroot.Chroot) error {
+	if firewall == nil {
+		return nil
+	}
+
+	switch {
+	case firewall.Nftables != nil:
+		return configureNftables(firewall.Nftables, imageChroot)
+
+	case firewall.Firewalld != nil:
+		return configureFirewalld(firewall.Firewalld, imageChroot)
+
+	default:
+		return nil
+	}
+}
+
+func configureNftables(nftables *imagecustomizerapi.NftablesConfig, imageChroot *safechroot.Chroot) error {
+	err := ensureFirewallServiceExists(nftablesServiceName, imageChroot)
+	if err != nil {
+		return err
+	}
+
+	// Validate the ruleset before putting it in place.
+	logger.Log.Infof("Validating nftables ruleset")
+
+	stagingPath := filepath.Join(imageChroot.RootDir(), nftablesStagingPathInChroot)
+
+	err = os.WriteFile(stagingPath, []byte(nftables.Ruleset), nftablesRulesetPermissions)
+	if err != nil {
+		return fmt.Errorf("failed to write nftables ruleset:\n%w", err)
+	}
+	defer os.Remove(stagingPath)
+
+	err = imageChroot.UnsafeRun(func() error {
+		return shell.ExecuteLiveWithErr(1, "nft", "--check", "--file", nftablesStagingPathInChroot)
+	})
+	if err != nil {
+		return fmt.Errorf("nftables ruleset is invalid:\n%w", err)
+	}
+
+	logger.Log.Infof("Writing nftables ruleset (%s)", nftablesRulesetPath)
+
+	rulesetPath := filepath.Join(imageChroot.RootDir(), nftablesRulesetPath)
+
+	err = file.Move(stagingPath, rulesetPath)
+	if err != nil {
+		return fmt.Errorf("failed to move nftables ruleset into place:\n%w", err)
+	}
+
+	err = os.Chmod(rulesetPath, nftablesRulesetPermissions)
+	if err != nil {
+		return fmt.Errorf("failed to set permissions of nftables ruleset:\n%w", err)
+	}
+
+	return enableFirewallService(nftablesServiceName, imageChroot)
+}
+
+func configureFirewalld(firewalld *imagecustomizerapi.FirewalldConfig, imageChroot *safechroot.Chroot) error {
+	err := ensureFirewallServiceExists(firewalldServiceName, imageChroot)
+	if err != nil {
+		return err
+	}
+
+	for _, zone := range firewalld.Zones {
+		err = validateFirewalldZone(zone)
+		if err != nil {
+			return fmt.Errorf("invalid firewalld zone (%s):\n%w", zone.Name, err)
+		}
+	}
+
+	zonesDir := filepath.Join(imageChroot.RootDir(), firewalldZonesDir)
+
+	err = os.MkdirAll(zonesDir, 0o750)
+	if err != nil {
+		return fmt.Errorf("failed to create firewalld zones directory (%s):\n%w", firewalldZonesDir, err)
+	}
+
+	for _, zone := range firewalld.Zones {
+		logger.Log.Infof("Writing firewalld zone (%s)", zone.Name)
+
+		zonePath := filepath.Join(zonesDir, zone.Name+".xml")
+
+		err = os.WriteFile(zonePath, []byte(zone.Content), firewalldZonePermissions)
+		if err != nil {
+			return fmt.Errorf("failed to write firewalld zone (%s):\n%w", zone.Name, err)
+		}
+	}
+
+	if firewalld.DefaultZone != "" {
+		err = setFirewalldDefaultZone(firewalld.DefaultZone, imageChroot)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Have firewalld check the full configuration, including references between zones, services, and ipsets.
+	logger.Log.Infof("Validating firewalld configuration")
+
+	err = imageChroot.UnsafeRun(func() error {
+		return shell.ExecuteLiveWithErr(1, "firewall-offline-cmd", "--check-config")
+	})
+	if err != nil {
+		return fmt.Errorf("firewalld configuration is invalid:\n%w", err)
+	}
+
+	return enableFirewallService(firewalldServiceName, imageChroot)
+}
+
+// validateFirewalldZone checks that the zone's content is a well formed XML document whose root element is <zone>.
+func validateFirewalldZone(zone imagecustomizerapi.FirewalldZone) error {
+	var root struct {
+		XMLName xml.Name
+	}
+
+	err := xml.Unmarshal([]byte(zone.Content), &root)
+	if err != nil {
+		return fmt.Errorf("failed to parse zone XML:\n%w", err)
+	}
+
+	if root.XMLName.Local != "zone" {
+		return fmt.Errorf("zone XML root element must be <zone> but is <%s>", root.XMLName.Local)
+	}
+
+	return nil
+}
+
+func setFirewalldDefaultZone(defaultZone string, imageChroot *safechroot.Chroot) error {
+	logger.Log.Infof("Setting firewalld default zone (%s)", defaultZone)
+
+	configPath := filepath.Join(imageChroot.RootDir(), firewalldConfigPath)
+
+	configContent, err := os.ReadFile(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to read firewalld config file (%s):\n%w", firewalldConfigPath, err)
+	}
+
+	defaultZoneLine := fmt.Sprintf("DefaultZone=%s", defaultZone)
+
+	newConfigContent := string(configContent)
+	if firewalldDefaultZoneRegex.MatchString(newConfigContent) {
+		newConfigContent = firewalldDefaultZoneRegex.ReplaceAllLiteralString(newConfigContent, defaultZoneLine)
+	} else {
+		if newConfigContent != "" && !strings.HasSuffix(newConfigContent, "\n") {
+			newConfigContent += "\n"
+		}
+		newConfigContent += defaultZoneLine + "\n"
+	}
+
+	err = file.Write(newConfigContent, configPath)
+	if err != nil {
+		return fmt.Errorf("failed to write firewalld config file (%s):\n%w", firewalldConfigPath, err)
+	}
+
+	return nil
+}
+
+func ensureFirewallServiceExists(serviceName string, imageChroot *safechroot.Chroot) error {
+	serviceExists, err := systemdUnitExists(serviceName, imageChroot.RootDir())
+	if err != nil {
+		return err
+	}
+
+	if !serviceExists {
+		return fmt.Errorf("firewall config requires the %s unit but the image does not contain it", serviceName)
+	}
+
+	return nil
+}
+
+func enableFirewallService(serviceName string, imageChroot *safechroot.Chroot) error {
+	logger.Log.Infof("Enabling service (%s)", serviceName)
+
+	err := imageChroot.UnsafeRun(func() error {
+		return shell.ExecuteLiveWithErr(1, "systemctl", "enable", serviceName)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to enable service (%s):\n%w", serviceName, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,41 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"testing"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagecustomizerapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateFirewalldZone(t *testing.T) {
+	err := validateFirewalldZone(imagecustomizerapi.FirewalldZone{
+		Name: "appliance",
+		Content: `<?xml version="1.0" encoding="utf-8"?>
+<zone>
+  <short>Appliance</short>
+  <service name="ssh"/>
+  <port protocol="tcp" port="443"/>
+</zone>
+`,
+	})
+	assert.NoError(t, err)
+}
+
+func TestValidateFirewalldZoneWrongRoot(t *testing.T) {
+	err := validateFirewalldZone(imagecustomizerapi.FirewalldZone{
+		Name:    "appliance",
+		Content: `<service><port protocol="tcp" port="443"/></service>`,
+	})
+	assert.ErrorContains(t, err, "root element must be <zone>")
+}
+
+func TestValidateFirewalldZoneMalformed(t *testing.T) {
+	err := validateFirewalldZone(imagecustomizerapi.FirewalldZone{
+		Name:    "appliance",
+		Content: `<zone><service name="ssh"></zone>`,
+	})
+	assert.ErrorContains(t, err, "failed to parse zone XML")
+}
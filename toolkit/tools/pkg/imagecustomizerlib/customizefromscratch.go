@@ -0,0 +1,144 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagecustomizerapi"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/logger"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/safechroot"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/shell"
+)
+
+var (
+	// The packages that are installed into every image that is created from scratch.
+	fromScratchBasePackages = []string{"core-packages-base-image", "kernel"}
+)
+
+// validateFromScratch checks that a config contains everything needed to create a new image when there is no base
+// image.
+func validateFromScratch(config *imagecustomizerapi.Config, rpmsSources []string) error {
+	var errs []error
+
+	if config.Disks == nil {
+		errs = append(errs, fmt.Errorf("Disks must be specified when there is no base image (--image-file)"))
+	} else if len(*config.Disks) > 0 && (*config.Disks)[0].PreservePartitionUuids {
+		errs = append(errs, fmt.Errorf(
+			"PreservePartitionUuids can't be used when there is no base image (--image-file)"))
+	}
+
+	if config.SystemConfig.ReleaseVersion == "" {
+		// There is no release package in the new image for tdnf to derive `$releasever` from.
+		errs = append(errs, fmt.Errorf("ReleaseVersion must be specified when there is no base image (--image-file)"))
+	}
+
+	if len(rpmsSources) <= 0 {
+		errs = append(errs, fmt.Errorf(
+			"at least one RPM source (--rpm-source) must be specified when there is no base image (--image-file)"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// fromScratchPackages returns the packages that are installed into a new image that is created from scratch.
+func fromScratchPackages(bootType imagecustomizerapi.BootType) []string {
+	packages := append([]string(nil), fromScratchBasePackages...)
+
+	switch bootType {
+	case imagecustomizerapi.BootTypeEfi:
+		packages = append(packages, "shim", "grub2-efi-binary")
+
+	case imagecustomizerapi.BootTypeLegacy:
+		packages = append(packages, "grub2-pc")
+	}
+
+	// Install 'initramfs' last to avoid unnecessary regeneration when the other packages (e.g. 'kernel') are
+	// installed.
+	packages = append(packages, "initramfs")
+	return packages
+}
+
+// createImageFromScratch creates a new image using the Disks config and bootstraps the OS by installing the base
+// packages into it.
+func createImageFromScratch(buildDir string, baseConfigPath string, config *imagecustomizerapi.Config,
+	buildImageFile string, rpmsSources []string, preserveRpmSourceDirs bool, repoSigningKeyFile string,
+) error {
+	diskConfig := (*config.Disks)[0]
+
+	installOSFunc := func(imageChroot *safechroot.Chroot) error {
+		return installFromScratchPackages(buildDir, baseConfigPath, &config.SystemConfig, imageChroot, rpmsSources,
+			preserveRpmSourceDirs, repoSigningKeyFile)
+	}
+
+	err := createNewImage(buildImageFile, baseConfigPath, diskConfig, config.SystemConfig.PartitionSettingsWithDefaults(),
+		config.SystemConfig.BootType, config.SystemConfig.KernelCommandLine, buildDir, "newimageroot", installOSFunc,
+		nil)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// installFromScratchPackages installs the base packages into an empty image.
+// Since the image doesn't contain a package manager yet, the host's tdnf is used (with `--installroot`).
+func installFromScratchPackages(buildDir string, baseConfigPath string, config *imagecustomizerapi.SystemConfig,
+	imageChroot *safechroot.Chroot, rpmsSources []string, preserveRpmSourceDirs bool, repoSigningKeyFile string,
+) error {
+	var packagesGpgKeys []string
+	for _, gpgKey := range config.PackagesGpgKeys {
+		packagesGpgKeys = append(packagesGpgKeys, filepath.Join(baseConfigPath, gpgKey))
+	}
+
+	// Note: The new image doesn't have any repos of its own.
+	mounts, err := mountRpmSources(buildDir, baseConfigPath, imageChroot, rpmsSources, false, preserveRpmSourceDirs,
+		repoSigningKeyFile, config.ReleaseVersion, packagesGpgKeys, config.PackagesMetadataExpire)
+	if err != nil {
+		return err
+	}
+	defer mounts.close()
+
+	hostReposDir, err := mounts.writeHostReposConfig()
+	if err != nil {
+		return err
+	}
+
+	if config.PackagesGpgCheck {
+		for _, gpgKey := range mounts.packagesGpgKeyFiles {
+			_, stderr, err := shell.Execute("rpm", "--root", imageChroot.RootDir(), "--import", gpgKey)
+			if err != nil {
+				return fmt.Errorf("failed to import packages GPG key (%s):\n%s\n%w", gpgKey, strings.TrimSpace(stderr),
+					err)
+			}
+		}
+	}
+
+	packages := fromScratchPackages(config.BootType)
+	logger.Log.Infof("Installing base packages: %v", packages)
+
+	tdnfInstallArgs := []string{
+		"-v", "install", "--assumeyes", "--installroot", imageChroot.RootDir(),
+		"--setopt", fmt.Sprintf("reposdir=%s", hostReposDir),
+		"--releasever", config.ReleaseVersion,
+	}
+	tdnfInstallArgs = append(tdnfInstallArgs, tdnfGpgCheckArgs(config.PackagesGpgCheck)...)
+	tdnfInstallArgs = append(tdnfInstallArgs, packages...)
+
+	err = shell.ExecuteLiveWithCallback(tdnfInstallOrUpdateStdoutFilter, logger.Log.Debug, false, "tdnf",
+		tdnfInstallArgs...)
+	if err != nil {
+		return fmt.Errorf("failed to install base packages:\n%w", err)
+	}
+
+	err = mounts.close()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
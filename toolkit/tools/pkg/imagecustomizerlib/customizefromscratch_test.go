@@ -0,0 +1,54 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"testing"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagecustomizerapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromScratchPackages(t *testing.T) {
+	assert.Equal(t, []string{"core-packages-base-image", "kernel", "shim", "grub2-efi-binary", "initramfs"},
+		fromScratchPackages(imagecustomizerapi.BootTypeEfi))
+	assert.Equal(t, []string{"core-packages-base-image", "kernel", "grub2-pc", "initramfs"},
+		fromScratchPackages(imagecustomizerapi.BootTypeLegacy))
+	assert.Equal(t, []string{"core-packages-base-image", "kernel", "initramfs"},
+		fromScratchPackages(imagecustomizerapi.BootTypeNone))
+
+	// The base package list must not be modified.
+	assert.Equal(t, []string{"core-packages-base-image", "kernel"}, fromScratchBasePackages)
+}
+
+func TestValidateFromScratch(t *testing.T) {
+	config := &imagecustomizerapi.Config{
+		Disks: &[]imagecustomizerapi.Disk{{}},
+		SystemConfig: imagecustomizerapi.SystemConfig{
+			ReleaseVersion: "2.0",
+		},
+	}
+
+	err := validateFromScratch(config, []string{"rpms"})
+	assert.NoError(t, err)
+}
+
+func TestValidateFromScratchMissingValues(t *testing.T) {
+	err := validateFromScratch(&imagecustomizerapi.Config{}, nil)
+	assert.ErrorContains(t, err, "Disks must be specified when there is no base image")
+	assert.ErrorContains(t, err, "ReleaseVersion must be specified when there is no base image")
+	assert.ErrorContains(t, err, "at least one RPM source (--rpm-source) must be specified")
+}
+
+func TestValidateFromScratchPreservePartitionUuids(t *testing.T) {
+	config := &imagecustomizerapi.Config{
+		Disks: &[]imagecustomizerapi.Disk{{PreservePartitionUuids: true}},
+		SystemConfig: imagecustomizerapi.SystemConfig{
+			ReleaseVersion: "2.0",
+		},
+	}
+
+	err := validateFromScratch(config, []string{"rpms"})
+	assert.ErrorContains(t, err, "PreservePartitionUuids can't be used when there is no base image")
+}
@@ -0,0 +1,75 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagecustomizerapi"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/logger"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/safechroot"
+)
+
+// writeLoginBanners writes the /etc/motd, /etc/issue, and /etc/issue.net files.
+func writeLoginBanners(loginBanners *imagecustomizerapi.LoginBanners, toolVersion string, buildTime string,
+	imageChroot *safechroot.Chroot,
+) error {
+	if loginBanners == nil {
+		return nil
+	}
+
+	templateData := imagecustomizerapi.LoginBannerTemplateData{
+		ToolVersion: toolVersion,
+		BuildTime:   buildTime,
+	}
+
+	banners := []struct {
+		path    string
+		content *string
+	}{
+		{"/etc/motd", loginBanners.Motd},
+		{"/etc/issue", loginBanners.Issue},
+		{"/etc/issue.net", loginBanners.IssueNet},
+	}
+
+	for _, banner := range banners {
+		if banner.content == nil {
+			continue
+		}
+
+		logger.Log.Infof("Writing login banner (%s)", banner.path)
+
+		content, err := renderLoginBanner(*banner.content, templateData)
+		if err != nil {
+			return fmt.Errorf("failed to render login banner (%s):\n%w", banner.path, err)
+		}
+
+		fullPath := filepath.Join(imageChroot.RootDir(), banner.path)
+
+		err = os.WriteFile(fullPath, []byte(content), 0o644)
+		if err != nil {
+			return fmt.Errorf("failed to write login banner (%s):\n%w", banner.path, err)
+		}
+	}
+
+	return nil
+}
+
+func renderLoginBanner(content string, templateData imagecustomizerapi.LoginBannerTemplateData) (string, error) {
+	bannerTemplate, err := imagecustomizerapi.ParseLoginBannerTemplate(content)
+	if err != nil {
+		return "", err
+	}
+
+	var rendered strings.Builder
+	err = bannerTemplate.Execute(&rendered, templateData)
+	if err != nil {
+		return "", err
+	}
+
+	return rendered.String(), nil
+}
@@ -0,0 +1,28 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"testing"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagecustomizerapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderLoginBanner(t *testing.T) {
+	templateData := imagecustomizerapi.LoginBannerTemplateData{
+		ToolVersion: "0.3.0",
+		BuildTime:   "2024-01-02T03:04:05Z",
+	}
+
+	content, err := renderLoginBanner("Image built by v{{.ToolVersion}} at {{.BuildTime}}.\n\\S \\n \\l\n",
+		templateData)
+	assert.NoError(t, err)
+	assert.Equal(t, "Image built by v0.3.0 at 2024-01-02T03:04:05Z.\n\\S \\n \\l\n", content)
+}
+
+func TestRenderLoginBannerUnknownValue(t *testing.T) {
+	_, err := renderLoginBanner("{{.Hostname}}\n", imagecustomizerapi.LoginBannerTemplateData{})
+	assert.ErrorContains(t, err, "Hostname")
+}
@@ -0,0 +1,154 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagecustomizerapi"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagegen/configuration"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagegen/diskutils"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/file"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/logger"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/shell"
+)
+
+const (
+	crypttabPath          = "/etc/crypttab"
+	dracutCryptConfigPath = "/etc/dracut.conf.d/10-crypt.conf"
+	dracutCryptConfig     = "add_dracutmodules+=\" crypt \"\n"
+)
+
+type luksDevice struct {
+	partitionID string
+	luksUuid    string
+	mappingName string
+	tpm2Unlock  bool
+}
+
+func (d *luksDevice) MappedDevicePath() string {
+	return filepath.Join("/dev/mapper", d.mappingName)
+}
+
+// encryptPartitions encrypts the partitions that have Luks set, opens them, and then formats the opened devices.
+// The partIDToDevPathMap is updated to point to the opened devices.
+func encryptPartitions(imageConnection *ImageConnection, baseConfigPath string, diskConfig imagecustomizerapi.Disk,
+	imagerDiskConfig configuration.Disk, partIDToDevPathMap map[string]string,
+) error {
+	for i, partition := range diskConfig.Partitions {
+		if partition.Luks == nil {
+			continue
+		}
+
+		partitionDevPath := partIDToDevPathMap[partition.ID]
+		passphraseFile := filepath.Join(baseConfigPath, partition.Luks.PassphraseFile)
+
+		luksDevice, err := encryptPartition(partition.ID, partitionDevPath, passphraseFile, partition.Luks.Tpm2Unlock)
+		if err != nil {
+			return err
+		}
+
+		imageConnection.AddLuksDevice(luksDevice)
+
+		_, err = diskutils.FormatSinglePartition(luksDevice.MappedDevicePath(), imagerDiskConfig.Partitions[i])
+		if err != nil {
+			return fmt.Errorf("failed to format encrypted partition (%s):\n%w", partition.ID, err)
+		}
+
+		partIDToDevPathMap[partition.ID] = luksDevice.MappedDevicePath()
+	}
+
+	return nil
+}
+
+func encryptPartition(partitionID string, partitionDevPath string, passphraseFile string, tpm2Unlock bool,
+) (*luksDevice, error) {
+	logger.Log.Infof("Encrypting partition (%s)", partitionID)
+
+	// Note: The passphrase is passed to cryptsetup using a file so that it never appears in the process's command-line
+	// or in the logs.
+	_, stderr, err := shell.Execute("cryptsetup", "--batch-mode", "--type", "luks2", "--key-file", passphraseFile,
+		"luksFormat", partitionDevPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt partition (%s):\n%s\n%w", partitionID, strings.TrimSpace(stderr), err)
+	}
+
+	stdout, stderr, err := shell.Execute("cryptsetup", "luksUUID", partitionDevPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read LUKS UUID of partition (%s):\n%s\n%w", partitionID,
+			strings.TrimSpace(stderr), err)
+	}
+
+	luksUuid := strings.TrimSpace(stdout)
+	mappingName := diskutils.GetLuksMappingName(luksUuid)
+
+	_, stderr, err = shell.Execute("cryptsetup", "--key-file", passphraseFile, "open", partitionDevPath, mappingName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open encrypted partition (%s):\n%s\n%w", partitionID,
+			strings.TrimSpace(stderr), err)
+	}
+
+	device := &luksDevice{
+		partitionID: partitionID,
+		luksUuid:    luksUuid,
+		mappingName: mappingName,
+		tpm2Unlock:  tpm2Unlock,
+	}
+	return device, nil
+}
+
+func closeLuksDevice(device *luksDevice) error {
+	_, stderr, err := shell.Execute("cryptsetup", "close", device.mappingName)
+	if err != nil {
+		return fmt.Errorf("failed to close encrypted partition (%s):\n%s\n%w", device.partitionID,
+			strings.TrimSpace(stderr), err)
+	}
+
+	return nil
+}
+
+// configureLuksDevices adds the encrypted partitions to the image's crypttab file and enables the dracut crypt module,
+// so that the partitions are unlocked during boot.
+func configureLuksDevices(luksDevices []*luksDevice, imageRoot string) error {
+	if len(luksDevices) <= 0 {
+		return nil
+	}
+
+	logger.Log.Infof("Configuring encrypted partitions")
+
+	crypttabFullPath := filepath.Join(imageRoot, crypttabPath)
+	for _, device := range luksDevices {
+		err := file.Append(crypttabEntry(device), crypttabFullPath)
+		if err != nil {
+			return fmt.Errorf("failed to write crypttab file:\n%w", err)
+		}
+	}
+
+	dracutConfigFullPath := filepath.Join(imageRoot, dracutCryptConfigPath)
+
+	err := os.MkdirAll(filepath.Dir(dracutConfigFullPath), 0o755)
+	if err != nil {
+		return fmt.Errorf("failed to create dracut config directory:\n%w", err)
+	}
+
+	err = file.Write(dracutCryptConfig, dracutConfigFullPath)
+	if err != nil {
+		return fmt.Errorf("failed to write dracut crypt config file:\n%w", err)
+	}
+
+	return nil
+}
+
+func crypttabEntry(device *luksDevice) string {
+	// The partition is unlocked using the passphrase (prompted for during boot) unless TPM unlocking is enabled.
+	options := "luks,discard"
+	if device.tpm2Unlock {
+		options += ",tpm2-device=auto"
+	}
+
+	return fmt.Sprintf("%s UUID=%s none %s\n", device.mappingName, device.luksUuid, options)
+}
@@ -0,0 +1,77 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagecustomizerapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCrypttabEntry(t *testing.T) {
+	device := &luksDevice{
+		partitionID: "data",
+		luksUuid:    "0a1b2c3d-0000-4000-8000-000000000000",
+		mappingName: "luks-0a1b2c3d-0000-4000-8000-000000000000",
+	}
+
+	entry := crypttabEntry(device)
+	assert.Equal(t, "luks-0a1b2c3d-0000-4000-8000-000000000000 UUID=0a1b2c3d-0000-4000-8000-000000000000 none luks,discard\n",
+		entry)
+
+	device.tpm2Unlock = true
+
+	entry = crypttabEntry(device)
+	assert.Equal(t, "luks-0a1b2c3d-0000-4000-8000-000000000000 UUID=0a1b2c3d-0000-4000-8000-000000000000 none luks,discard,tpm2-device=auto\n",
+		entry)
+}
+
+func TestConfigureLuksDevices(t *testing.T) {
+	imageRoot := filepath.Join(tmpDir, "TestConfigureLuksDevices")
+
+	err := os.MkdirAll(filepath.Join(imageRoot, "etc"), 0o755)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	devices := []*luksDevice{{
+		partitionID: "data",
+		luksUuid:    "0a1b2c3d-0000-4000-8000-000000000000",
+		mappingName: "luks-0a1b2c3d-0000-4000-8000-000000000000",
+	}}
+
+	err = configureLuksDevices(devices, imageRoot)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	crypttab, err := os.ReadFile(filepath.Join(imageRoot, "etc/crypttab"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, crypttabEntry(devices[0]), string(crypttab))
+
+	dracutConfig, err := os.ReadFile(filepath.Join(imageRoot, "etc/dracut.conf.d/10-crypt.conf"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "add_dracutmodules+=\" crypt \"\n", string(dracutConfig))
+}
+
+func TestValidateDisksMissingPassphraseFile(t *testing.T) {
+	disks := []imagecustomizerapi.Disk{{
+		Partitions: []imagecustomizerapi.Partition{{
+			ID: "data",
+			Luks: &imagecustomizerapi.Luks{
+				PassphraseFile: "files/does-not-exist.txt",
+			},
+		}},
+	}}
+
+	err := validateDisks(testDir, &disks)
+	assert.ErrorContains(t, err, "invalid partition (data) Luks PassphraseFile (files/does-not-exist.txt)")
+}
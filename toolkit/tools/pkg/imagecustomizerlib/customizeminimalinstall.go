@@ -0,0 +1,314 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/logger"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/safechroot"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/shell"
+)
+
+const (
+	rpmNoProviderPrefix = "no package provides "
+
+	// The pseudo-package that rpm uses to store imported GPG keys.
+	rpmGpgPubkeyPackageName = "gpg-pubkey"
+)
+
+var (
+	// Keywords and operators that can appear in rich (boolean) dependencies.
+	// See: https://rpm-software-management.github.io/rpm/manual/boolean_dependencies.html
+	rpmRichDependencyKeywords = map[string]bool{
+		"and":     true,
+		"or":      true,
+		"if":      true,
+		"else":    true,
+		"with":    true,
+		"without": true,
+		"unless":  true,
+	}
+
+	rpmVersionOperators = map[string]bool{
+		"<":  true,
+		"<=": true,
+		"=":  true,
+		">=": true,
+		">":  true,
+	}
+)
+
+// removeUnneededPackages removes all the installed packages that are not in the keep list and that are not
+// required (directly or indirectly) by a package in the keep list.
+func removeUnneededPackages(packagesKeep []string, imageChroot *safechroot.Chroot) error {
+	logger.Log.Infof("Removing packages not required by: %v", packagesKeep)
+
+	installedPackages, err := getInstalledPackages(imageChroot)
+	if err != nil {
+		return err
+	}
+
+	requiredPackages, err := resolveRequiredPackages(packagesKeep, imageChroot)
+	if err != nil {
+		return err
+	}
+
+	var packagesToRemove []string
+	for nevra, name := range installedPackages {
+		if name == rpmGpgPubkeyPackageName || requiredPackages[name] {
+			continue
+		}
+
+		packagesToRemove = append(packagesToRemove, nevra)
+	}
+
+	if len(packagesToRemove) <= 0 {
+		logger.Log.Infof("No unneeded packages found")
+		return nil
+	}
+
+	sort.Strings(packagesToRemove)
+	logger.Log.Infof("Removing %d unneeded packages: %v", len(packagesToRemove), packagesToRemove)
+
+	// Remove all the packages in a single transaction.
+	// rpm checks the dependencies of the transaction as a whole. So, if a kept package somehow depends on a package
+	// that is being removed, then rpm will fail instead of leaving the image in a broken state.
+	rpmEraseArgs := append([]string{"-e"}, packagesToRemove...)
+
+	err = imageChroot.UnsafeRun(func() error {
+		return shell.ExecuteLiveWithErr(1, "rpm", rpmEraseArgs...)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove unneeded packages:\n%w", err)
+	}
+
+	return nil
+}
+
+// getInstalledPackages returns a map of the NEVRA of each installed package to its name.
+func getInstalledPackages(imageChroot *safechroot.Chroot) (map[string]string, error) {
+	stdout := ""
+	err := imageChroot.UnsafeRun(func() error {
+		var err error
+		stdout, _, err = shell.Execute("rpm", "-qa", "--queryformat", "%{NAME} %{NEVRA}\n")
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list installed packages:\n%w", err)
+	}
+
+	installedPackages := make(map[string]string)
+	for _, line := range strings.Split(stdout, "\n") {
+		name, nevra, found := strings.Cut(strings.TrimSpace(line), " ")
+		if !found {
+			continue
+		}
+
+		installedPackages[nevra] = name
+	}
+
+	return installedPackages, nil
+}
+
+// resolveRequiredPackages returns the names of the packages that provide the keep list and the names of all the
+// packages that they require, recursively.
+func resolveRequiredPackages(packagesKeep []string, imageChroot *safechroot.Chroot) (map[string]bool, error) {
+	keepPackageNames, missing, err := queryPackageProviders(packagesKeep, imageChroot)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("kept packages are not installed (%s)", strings.Join(missing, ", "))
+	}
+
+	requiredPackages := make(map[string]bool)
+	pending := keepPackageNames
+	for len(pending) > 0 {
+		packageName := pending[0]
+		pending = pending[1:]
+
+		if requiredPackages[packageName] {
+			continue
+		}
+
+		requiredPackages[packageName] = true
+
+		capabilities, err := queryPackageRequires(packageName, imageChroot)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(capabilities) <= 0 {
+			continue
+		}
+
+		// Include every package that provides each capability, to avoid removing a package that is needed.
+		// Capabilities that no installed package provides (e.g. rpmlib features) are ignored.
+		providers, _, err := queryPackageProviders(capabilities, imageChroot)
+		if err != nil {
+			return nil, err
+		}
+
+		pending = append(pending, providers...)
+	}
+
+	return requiredPackages, nil
+}
+
+// queryPackageRequires returns the list of capabilities required by an installed package.
+func queryPackageRequires(packageName string, imageChroot *safechroot.Chroot) ([]string, error) {
+	stdout := ""
+	err := imageChroot.UnsafeRun(func() error {
+		var err error
+		stdout, _, err = shell.Execute("rpm", "-q", "--requires", packageName)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query requirements of package (%s):\n%w", packageName, err)
+	}
+
+	capabilitiesSet := make(map[string]bool)
+	for _, line := range strings.Split(stdout, "\n") {
+		for _, capability := range parseRpmRequiresLine(line) {
+			capabilitiesSet[capability] = true // dummy value
+		}
+	}
+
+	capabilities := make([]string, 0, len(capabilitiesSet))
+	for capability := range capabilitiesSet {
+		capabilities = append(capabilities, capability)
+	}
+
+	sort.Strings(capabilities)
+	return capabilities, nil
+}
+
+// parseRpmRequiresLine returns the capability names referenced by a line of `rpm -q --requires` output.
+// Version constraints are dropped so that all the providers of a capability are considered.
+func parseRpmRequiresLine(line string) []string {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "rpmlib(") {
+		return nil
+	}
+
+	if !strings.HasPrefix(line, "(") {
+		// Simple dependency (e.g. "glibc >= 2.35").
+		return strings.Fields(line)[:1]
+	}
+
+	// Rich dependency (e.g. "(a >= 1.0 or b)").
+	// Conservatively include every capability that is referenced.
+	var capabilities []string
+	skipNext := false
+	for _, field := range tokenizeRpmRichDependency(line) {
+		switch {
+		case skipNext:
+			// Skip the version that follows an operator.
+			skipNext = false
+
+		case rpmVersionOperators[field]:
+			skipNext = true
+
+		case rpmRichDependencyKeywords[field], strings.HasPrefix(field, "rpmlib("):
+
+		default:
+			capabilities = append(capabilities, field)
+		}
+	}
+
+	return capabilities
+}
+
+// tokenizeRpmRichDependency splits a rich dependency into its words (capabilities, operators, versions and
+// keywords), dropping the grouping parentheses.
+//
+// A parenthesis that directly follows a word's character is part of the capability's name (e.g.
+// "libfoo.so.1()(64bit)" or "perl(Foo::Bar)"). Whereas, a grouping parenthesis always follows whitespace or another
+// grouping parenthesis.
+func tokenizeRpmRichDependency(dependency string) []string {
+	var tokens []string
+	var token strings.Builder
+
+	// The number of unclosed parentheses within the current token.
+	tokenParenDepth := 0
+
+	endToken := func() {
+		if token.Len() > 0 {
+			tokens = append(tokens, token.String())
+			token.Reset()
+		}
+		tokenParenDepth = 0
+	}
+
+	for _, c := range dependency {
+		switch {
+		case c == ' ' || c == '\t':
+			endToken()
+
+		case c == '(' && token.Len() <= 0:
+			// Grouping parenthesis.
+
+		case c == '(':
+			tokenParenDepth++
+			token.WriteRune(c)
+
+		case c == ')' && tokenParenDepth > 0:
+			tokenParenDepth--
+			token.WriteRune(c)
+
+		case c == ')':
+			// Grouping parenthesis.
+			endToken()
+
+		default:
+			token.WriteRune(c)
+		}
+	}
+
+	endToken()
+	return tokens
+}
+
+// queryPackageProviders returns the names of the installed packages that provide the capabilities and the list
+// of capabilities that no installed package provides.
+func queryPackageProviders(capabilities []string, imageChroot *safechroot.Chroot) ([]string, []string, error) {
+	rpmArgs := append([]string{"-q", "--whatprovides", "--queryformat", "%{NAME}\n"}, capabilities...)
+
+	stdout := ""
+	err := imageChroot.UnsafeRun(func() error {
+		var err error
+		stdout, _, err = shell.Execute("rpm", rpmArgs...)
+		return err
+	})
+	if err != nil {
+		// rpm returns the number of capabilities that weren't found as the exit code.
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return nil, nil, fmt.Errorf("failed to query package providers:\n%w", err)
+		}
+	}
+
+	var providers []string
+	var missing []string
+	for _, line := range strings.Split(stdout, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+
+		case strings.HasPrefix(line, rpmNoProviderPrefix):
+			missing = append(missing, strings.TrimPrefix(line, rpmNoProviderPrefix))
+
+		default:
+			providers = append(providers, line)
+		}
+	}
+
+	return providers, missing, nil
+}
@@ -0,0 +1,34 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRpmRequiresLine(t *testing.T) {
+	assert.Equal(t, []string{"glibc"}, parseRpmRequiresLine("glibc >= 2.35"))
+	assert.Equal(t, []string{"libc.so.6()(64bit)"}, parseRpmRequiresLine("libc.so.6()(64bit)"))
+	assert.Equal(t, []string{"/bin/sh"}, parseRpmRequiresLine("/bin/sh"))
+	assert.Equal(t, []string{"a", "b"}, parseRpmRequiresLine("(a >= 1.0 or b)"))
+	assert.Equal(t, []string{"c", "d", "e"}, parseRpmRequiresLine("(c if (d = 2.0-1 with e))"))
+	assert.Empty(t, parseRpmRequiresLine("rpmlib(CompressedFileNames) <= 3.0.4-1"))
+	assert.Empty(t, parseRpmRequiresLine(""))
+}
+
+func TestParseRpmRequiresLineRichDependencyCapabilities(t *testing.T) {
+	assert.Equal(t, []string{"libfoo.so.1()(64bit)", "libbar.so.2()(64bit)"},
+		parseRpmRequiresLine("(libfoo.so.1()(64bit) or libbar.so.2()(64bit))"))
+	assert.Equal(t, []string{"perl(Foo::Bar)", "libfoo.so.1(FOO_1.0)(64bit)"},
+		parseRpmRequiresLine("(perl(Foo::Bar) >= 1.2 if (libfoo.so.1(FOO_1.0)(64bit)))"))
+	assert.Equal(t, []string{"a", "libc.so.6()(64bit)"},
+		parseRpmRequiresLine("((a or rpmlib(RichDependencies)) with libc.so.6()(64bit))"))
+}
+
+func TestTokenizeRpmRichDependency(t *testing.T) {
+	assert.Equal(t, []string{"libfoo.so.1()(64bit)", ">=", "1.0", "or", "b"},
+		tokenizeRpmRichDependency("(libfoo.so.1()(64bit) >= 1.0 or (b))"))
+}
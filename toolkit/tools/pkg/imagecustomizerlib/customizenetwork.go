@@ -0,0 +1,162 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagecustomizerapi"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/file"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/logger"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/safechroot"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/shell"
+	"gopkg.in/ini.v1"
+)
+
+var (
+	// The directories that systemd searches for system unit files.
+	systemdSystemUnitDirs = []string{"/usr/lib/systemd/system", "/lib/systemd/system", "/etc/systemd/system"}
+
+	// The section that each type of network config file must contain.
+	networkConfigRequiredSections = map[string]string{
+		".network":      "Match",
+		".link":         "Link",
+		".netdev":       "NetDev",
+		".nmconnection": "connection",
+	}
+)
+
+type networkStackInfo struct {
+	serviceName     string
+	configDir       string
+	filePermissions os.FileMode
+}
+
+var (
+	networkStackInfos = map[imagecustomizerapi.NetworkStack]networkStackInfo{
+		imagecustomizerapi.NetworkStackNetworkd: {
+			serviceName:     "systemd-networkd.service",
+			configDir:       "/etc/systemd/network",
+			filePermissions: 0o644,
+		},
+		imagecustomizerapi.NetworkStackNetworkManager: {
+			serviceName: "NetworkManager.service",
+			configDir:   "/etc/NetworkManager/system-connections",
+			// NetworkManager ignores connection files that are readable by non-root users.
+			filePermissions: 0o600,
+		},
+	}
+)
+
+func addNetworkConfigFiles(config *imagecustomizerapi.SystemConfig, imageChroot *safechroot.Chroot) error {
+	networkStack, err := config.NetworkStack()
+	if err != nil {
+		return err
+	}
+
+	if networkStack == "" {
+		// No network config files.
+		return nil
+	}
+
+	stackInfo := networkStackInfos[networkStack]
+
+	// Ensure the image actually uses the network stack that the files target.
+	serviceExists, err := systemdUnitExists(stackInfo.serviceName, imageChroot.RootDir())
+	if err != nil {
+		return err
+	}
+
+	if !serviceExists {
+		return fmt.Errorf("network config files target %s but the image does not contain the %s unit", networkStack,
+			stackInfo.serviceName)
+	}
+
+	for _, networkConfigFile := range config.NetworkConfigFiles {
+		err = validateNetworkConfigFile(networkConfigFile)
+		if err != nil {
+			return fmt.Errorf("invalid network config file (%s):\n%w", networkConfigFile.Name, err)
+		}
+	}
+
+	configDir := filepath.Join(imageChroot.RootDir(), stackInfo.configDir)
+
+	err = os.MkdirAll(configDir, 0o755)
+	if err != nil {
+		return fmt.Errorf("failed to create network config directory (%s):\n%w", stackInfo.configDir, err)
+	}
+
+	for _, networkConfigFile := range config.NetworkConfigFiles {
+		logger.Log.Infof("Writing network config file (%s)", networkConfigFile.Name)
+
+		fullPath := filepath.Join(configDir, networkConfigFile.Name)
+
+		err = os.WriteFile(fullPath, []byte(networkConfigFile.Content), stackInfo.filePermissions)
+		if err != nil {
+			return fmt.Errorf("failed to write network config file (%s):\n%w", networkConfigFile.Name, err)
+		}
+
+		// Ensure the permissions are correct, even if the file already existed.
+		err = os.Chmod(fullPath, stackInfo.filePermissions)
+		if err != nil {
+			return fmt.Errorf("failed to set permissions of network config file (%s):\n%w", networkConfigFile.Name, err)
+		}
+	}
+
+	logger.Log.Infof("Enabling service (%s)", stackInfo.serviceName)
+
+	err = imageChroot.UnsafeRun(func() error {
+		return shell.ExecuteLiveWithErr(1, "systemctl", "enable", stackInfo.serviceName)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to enable service (%s):\n%w", stackInfo.serviceName, err)
+	}
+
+	return nil
+}
+
+// validateNetworkConfigFile checks that the file is a well formed INI-style file that contains the section required
+// by its type.
+func validateNetworkConfigFile(networkConfigFile imagecustomizerapi.NetworkConfigFile) error {
+	loadOptions := ini.LoadOptions{
+		// systemd-networkd allows both keys and sections to be repeated (e.g. multiple [Address] sections).
+		AllowShadows:           true,
+		AllowNonUniqueSections: true,
+	}
+
+	iniFile, err := ini.LoadSources(loadOptions, []byte(networkConfigFile.Content))
+	if err != nil {
+		return fmt.Errorf("failed to parse file:\n%w", err)
+	}
+
+	if len(iniFile.Section(ini.DefaultSection).Keys()) > 0 {
+		return fmt.Errorf("file must not contain values outside of a section")
+	}
+
+	requiredSection := networkConfigRequiredSections[filepath.Ext(networkConfigFile.Name)]
+	if !iniFile.HasSection(requiredSection) {
+		return fmt.Errorf("file is missing the [%s] section", requiredSection)
+	}
+
+	return nil
+}
+
+func systemdUnitExists(unitName string, rootDir string) (bool, error) {
+	for _, unitDir := range systemdSystemUnitDirs {
+		unitPath := filepath.Join(rootDir, unitDir, unitName)
+
+		exists, err := file.PathExists(unitPath)
+		if err != nil {
+			return false, fmt.Errorf("failed to check if systemd unit (%s) exists:\n%w", unitName, err)
+		}
+
+		if exists {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
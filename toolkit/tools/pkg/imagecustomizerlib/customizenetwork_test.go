@@ -0,0 +1,63 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"testing"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagecustomizerapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateNetworkConfigFileNetworkd(t *testing.T) {
+	err := validateNetworkConfigFile(imagecustomizerapi.NetworkConfigFile{
+		Name: "10-eth0.network",
+		Content: `[Match]
+Name=eth0
+
+[Network]
+Address=192.168.1.10/24
+Address=192.168.1.11/24
+Gateway=192.168.1.1
+
+[Route]
+Destination=10.0.0.0/8
+
+[Route]
+Destination=172.16.0.0/12
+`,
+	})
+	assert.NoError(t, err)
+}
+
+func TestValidateNetworkConfigFileNetworkManager(t *testing.T) {
+	err := validateNetworkConfigFile(imagecustomizerapi.NetworkConfigFile{
+		Name: "eth0.nmconnection",
+		Content: `[connection]
+id=eth0
+type=ethernet
+interface-name=eth0
+
+[ipv4]
+method=auto
+`,
+	})
+	assert.NoError(t, err)
+}
+
+func TestValidateNetworkConfigFileMissingSection(t *testing.T) {
+	err := validateNetworkConfigFile(imagecustomizerapi.NetworkConfigFile{
+		Name:    "10-br0.netdev",
+		Content: "[Match]\nName=br0\n",
+	})
+	assert.ErrorContains(t, err, "missing the [NetDev] section")
+}
+
+func TestValidateNetworkConfigFileKeyOutsideSection(t *testing.T) {
+	err := validateNetworkConfigFile(imagecustomizerapi.NetworkConfigFile{
+		Name:    "10-eth0.network",
+		Content: "Name=eth0\n[Match]\nName=eth0\n",
+	})
+	assert.ErrorContains(t, err, "outside of a section")
+}
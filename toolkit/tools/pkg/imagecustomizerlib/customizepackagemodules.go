@@ -0,0 +1,109 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagecustomizerapi"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/logger"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/safechroot"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/shell"
+)
+
+// configurePackageModules enables and disables module streams and installs module profiles.
+func configurePackageModules(modules imagecustomizerapi.PackageModules, releaseVersion string, gpgCheck bool,
+	imageChroot *safechroot.Chroot,
+) error {
+	if !modules.HasOperations() {
+		return nil
+	}
+
+	err := checkTdnfModuleSupport(imageChroot)
+	if err != nil {
+		return err
+	}
+
+	for _, module := range modules.Disable {
+		logger.Log.Infof("Disabling module (%s)", module)
+
+		err = runTdnfModuleCommand("disable", module, releaseVersion, nil, imageChroot)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, stream := range modules.Enable {
+		logger.Log.Infof("Enabling module stream (%s)", stream)
+
+		err = runTdnfModuleCommand("enable", stream, releaseVersion, nil, imageChroot)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, profile := range modules.Install {
+		logger.Log.Infof("Installing module profile (%s)", profile)
+
+		err = runTdnfModuleCommand("install", profile, releaseVersion, tdnfGpgCheckArgs(gpgCheck), imageChroot)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkTdnfModuleSupport checks that the image's tdnf has the `module` command.
+func checkTdnfModuleSupport(imageChroot *safechroot.Chroot) error {
+	stdout := ""
+	err := imageChroot.UnsafeRun(func() error {
+		var err error
+		stdout, _, err = shell.Execute("tdnf", "--help")
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to query tdnf's commands:\n%w", err)
+	}
+
+	if !tdnfHelpHasCommand(stdout, "module") {
+		return fmt.Errorf("the image's tdnf doesn't support modules (PackageModules)")
+	}
+
+	return nil
+}
+
+// tdnfHelpHasCommand checks if the output of `tdnf --help` lists a command.
+func tdnfHelpHasCommand(helpOutput string, command string) bool {
+	for _, line := range strings.Split(helpOutput, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 0 && fields[0] == command {
+			return true
+		}
+	}
+
+	return false
+}
+
+func runTdnfModuleCommand(action string, module string, releaseVersion string, extraArgs []string,
+	imageChroot *safechroot.Chroot,
+) error {
+	tdnfModuleArgs := []string{
+		"-v", "module", action, "--assumeyes",
+	}
+	tdnfModuleArgs = append(tdnfModuleArgs, extraArgs...)
+	tdnfModuleArgs = append(tdnfModuleArgs, tdnfRepoArgs(releaseVersion)...)
+	tdnfModuleArgs = append(tdnfModuleArgs, module)
+
+	err := imageChroot.Run(func() error {
+		return shell.ExecuteLiveWithCallback(tdnfInstallOrUpdateStdoutFilter, logger.Log.Debug, false, "tdnf",
+			tdnfModuleArgs...)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to %s module (%s):\n%w", action, module, err)
+	}
+
+	return nil
+}
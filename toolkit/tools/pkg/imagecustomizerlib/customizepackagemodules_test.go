@@ -0,0 +1,29 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTdnfHelpHasCommand(t *testing.T) {
+	helpOutput := "usage: tdnf [options] COMMAND\n\nList of Main Commands\n\n" +
+		"check                     Checks for problems in installed and available packages\n" +
+		"install                   Install a package or packages on your system\n" +
+		"module                    Manage module streams\n"
+
+	assert.True(t, tdnfHelpHasCommand(helpOutput, "module"))
+	assert.True(t, tdnfHelpHasCommand(helpOutput, "install"))
+	assert.False(t, tdnfHelpHasCommand(helpOutput, "swap"))
+}
+
+func TestTdnfHelpHasCommandNoModule(t *testing.T) {
+	helpOutput := "List of Main Commands\n\n" +
+		"install                   Install a package or packages on your system\n" +
+		"makecache                 Generate the metadata cache (enables module-like caching)\n"
+
+	assert.False(t, tdnfHelpHasCommand(helpOutput, "module"))
+}
@@ -4,8 +4,13 @@
 package imagecustomizerlib
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"os/exec"
 	"path"
+	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagecustomizerapi"
@@ -14,29 +19,92 @@ import (
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/shell"
 )
 
+const (
+	preflightDownloadDirInChroot = "/_preflightrpms"
+
+	// The prefix of tdnf's and rpm's warning messages (compared case-insensitively).
+	tdnfWarningPrefix = "warning:"
+
+	// The message rpm reports for a file that is owned by two packages.
+	tdnfFileConflictMessage = " conflicts with file from package "
+)
+
 func addRemoveAndUpdatePackages(buildDir string, baseConfigPath string, config *imagecustomizerapi.SystemConfig,
-	imageChroot *safechroot.Chroot, rpmsSources []string, useBaseImageRpmRepos bool, partitionsCustomized bool,
+	imageChroot *safechroot.Chroot, rpmsSources []string, useBaseImageRpmRepos bool, preserveRpmSourceDirs bool,
+	repoSigningKeyFile string, partitionsCustomized bool,
 ) error {
 	var err error
 
+	hasLocalRpms, err := hasLocalRpmFiles(rpmsSources)
+	if err != nil {
+		return err
+	}
+
 	// Note: The 'validatePackageLists' function read the PackageLists files and merged them into the inline package lists.
 	needRpmsSources := len(config.PackagesInstall) > 0 || len(config.PackagesUpdate) > 0 ||
-		config.UpdateBaseImagePackages || partitionsCustomized
+		len(config.PackagesDowngrade) > 0 || config.UpdateBaseImagePackages || partitionsCustomized || hasLocalRpms ||
+		config.PackageModules.HasOperations() || len(config.TdnfCommands) > 0 || config.PackagesInstallDebuginfo
+
+	var packagesGpgKeys []string
+	for _, gpgKey := range config.PackagesGpgKeys {
+		packagesGpgKeys = append(packagesGpgKeys, filepath.Join(baseConfigPath, gpgKey))
+	}
 
 	// Mount RPM sources.
 	var mounts *rpmSourcesMounts
 	if needRpmsSources {
-		mounts, err = mountRpmSources(buildDir, imageChroot, rpmsSources, useBaseImageRpmRepos)
+		mounts, err = mountRpmSources(buildDir, baseConfigPath, imageChroot, rpmsSources, useBaseImageRpmRepos,
+			preserveRpmSourceDirs, repoSigningKeyFile, config.ReleaseVersion, packagesGpgKeys,
+			config.PackagesMetadataExpire)
 		if err != nil {
 			return err
 		}
 		defer mounts.close()
+
+		if config.PackagesGpgCheck {
+			err = importPackagesGpgKeys(mounts.packagesGpgKeysInChroot(), imageChroot)
+			if err != nil {
+				return err
+			}
+		}
+
+		if config.PackagesMakeCache {
+			err = makeTdnfCache(config.ReleaseVersion, imageChroot)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	gpgCheck := config.PackagesGpgCheck
+
+	// Note: The module streams are configured first, since they change which package versions are available.
+	err = configurePackageModules(config.PackageModules, config.ReleaseVersion, gpgCheck, imageChroot)
+	if err != nil {
+		return err
+	}
+
+	// Check that all the requested package versions are available before any packages are changed, so that a
+	// missing version doesn't fail the build part way through the package operations.
+	// Note: This is done after the module streams are configured, since they change which package versions are
+	// available.
+	err = checkPackagesAvailable(config.PackagesDowngrade, config.ReleaseVersion, imageChroot)
+	if err != nil {
+		return err
+	}
+
+	if config.PreflightPackages {
+		err = preflightPackages(config, imageChroot)
+		if err != nil {
+			return err
+		}
 	}
 
 	if partitionsCustomized {
 		logger.Log.Infof("Updating initrd file")
 
-		err = installOrUpdatePackages("reinstall", []string{"initramfs"}, imageChroot)
+		err = installOrUpdatePackages("reinstall", []string{"initramfs"}, config.ReleaseVersion, gpgCheck,
+			imageChroot)
 		if err != nil {
 			return err
 		}
@@ -47,21 +115,65 @@ func addRemoveAndUpdatePackages(buildDir string, baseConfigPath string, config *
 		return err
 	}
 
+	if config.PackagesAutoremove {
+		err = autoremovePackages(imageChroot)
+		if err != nil {
+			return err
+		}
+	}
+
 	if config.UpdateBaseImagePackages {
-		err = updateAllPackages(imageChroot)
+		err = updateAllPackages(config.ReleaseVersion, gpgCheck, imageChroot)
+		if err != nil {
+			return err
+		}
+	}
+
+	packagesToInstall := config.PackagesInstall
+	if config.SkipInstalledPackages {
+		packagesToInstall, err = filterOutInstalledPackages(config.PackagesInstall, imageChroot)
 		if err != nil {
 			return err
 		}
 	}
 
-	logger.Log.Infof("Installing packages: %v", config.PackagesInstall)
-	err = installOrUpdatePackages("install", config.PackagesInstall, imageChroot)
+	logger.Log.Infof("Installing packages: %v", packagesToInstall)
+	err = installOrUpdatePackages("install", packagesToInstall, config.ReleaseVersion, gpgCheck, imageChroot)
 	if err != nil {
 		return err
 	}
 
+	if hasLocalRpms {
+		err = installLocalRpmFiles(mounts.localRpmFilesInChroot(), config.ReleaseVersion, gpgCheck, imageChroot)
+		if err != nil {
+			return err
+		}
+	}
+
 	logger.Log.Infof("Updating packages: %v", config.PackagesUpdate)
-	err = installOrUpdatePackages("update", config.PackagesUpdate, imageChroot)
+	err = installOrUpdatePackages("update", config.PackagesUpdate, config.ReleaseVersion, gpgCheck, imageChroot)
+	if err != nil {
+		return err
+	}
+
+	// Note: Downgrades are done last so that the package updates don't undo them.
+	if len(config.PackagesDowngrade) > 0 {
+		err = downgradePackages(config.PackagesDowngrade, config.ReleaseVersion, gpgCheck, imageChroot)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Note: This is done after all the other package operations, so that the debuginfo packages match the final
+	// package versions.
+	if config.PackagesInstallDebuginfo {
+		err = installDebuginfoPackages(config.ReleaseVersion, gpgCheck, imageChroot)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = runTdnfCommands(config.TdnfCommands, config.ReleaseVersion, gpgCheck, imageChroot)
 	if err != nil {
 		return err
 	}
@@ -74,9 +186,191 @@ func addRemoveAndUpdatePackages(buildDir string, baseConfigPath string, config *
 		}
 	}
 
+	if len(config.PackagesKeep) > 0 {
+		err = removeUnneededPackages(config.PackagesKeep, imageChroot)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// runTdnfCommands runs the user's custom tdnf commands, in order, using the RPM sources.
+func runTdnfCommands(commands [][]string, releaseVersion string, gpgCheck bool, imageChroot *safechroot.Chroot,
+) error {
+	for i, command := range commands {
+		logger.Log.Infof("Running tdnf command %d of %d: tdnf %s", i+1, len(commands), strings.Join(command, " "))
+
+		tdnfArgs := tdnfCommandArgs(command, releaseVersion, gpgCheck)
+
+		err := imageChroot.Run(func() error {
+			return shell.ExecuteLiveWithCallback(tdnfInstallOrUpdateStdoutFilter, logger.Log.Debug, false, "tdnf",
+				tdnfArgs...)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to run tdnf command (tdnf %s):\n%w", strings.Join(command, " "), err)
+		}
+	}
+
+	return nil
+}
+
+func tdnfCommandArgs(command []string, releaseVersion string, gpgCheck bool) []string {
+	args := []string{"-v", "--assumeyes"}
+	args = append(args, tdnfGpgCheckArgs(gpgCheck)...)
+	args = append(args, tdnfRepoArgs(releaseVersion)...)
+	args = append(args, command...)
+	return args
+}
+
+// makeTdnfCache downloads the metadata of all the repos, so that the package operations don't need to.
+func makeTdnfCache(releaseVersion string, imageChroot *safechroot.Chroot) error {
+	logger.Log.Infof("Downloading repos' metadata")
+
+	tdnfMakeCacheArgs := []string{
+		"makecache",
+	}
+	tdnfMakeCacheArgs = append(tdnfMakeCacheArgs, tdnfRepoArgs(releaseVersion)...)
+
+	err := imageChroot.Run(func() error {
+		return shell.ExecuteLiveWithErr(1, "tdnf", tdnfMakeCacheArgs...)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to download repos' metadata:\n%w", err)
+	}
+
+	return nil
+}
+
+// preflightPackages checks that all the packages to install, update and downgrade (and their dependencies) can be
+// resolved using the RPM sources, before any changes are made to the image.
+// Note: The packages are resolved against the image as it is before the package removals, the autoremove and the
+// base image update are applied. So, a package that only resolves after those steps may fail the preflight.
+func preflightPackages(config *imagecustomizerapi.SystemConfig, imageChroot *safechroot.Chroot) error {
+	logger.Log.Infof("Resolving packages")
+
+	// tdnf doesn't have a resolve-only mode. So, download the packages to a temporary directory instead.
+	downloadDir := filepath.Join(imageChroot.RootDir(), preflightDownloadDirInChroot)
+
+	err := os.Mkdir(downloadDir, os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("failed to create package preflight directory (%s):\n%w", downloadDir, err)
+	}
+
+	defer func() {
+		cleanupErr := os.RemoveAll(downloadDir)
+		if cleanupErr != nil {
+			logger.Log.Warnf("failed to delete package preflight directory (%s): %s", downloadDir, cleanupErr)
+		}
+	}()
+
+	// Report the problems for all the lists at once.
+	var errs []error
+
+	err = preflightPackagesAction("install", config.PackagesInstall, config.ReleaseVersion, imageChroot)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	err = preflightPackagesAction("update", config.PackagesUpdate, config.ReleaseVersion, imageChroot)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	err = preflightPackagesAction("downgrade", config.PackagesDowngrade, config.ReleaseVersion, imageChroot)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("package preflight failed:\n%w", errors.Join(errs...))
+	}
+
+	return nil
+}
+
+func preflightPackagesAction(action string, packages []string, releaseVersion string,
+	imageChroot *safechroot.Chroot,
+) error {
+	if len(packages) <= 0 {
+		return nil
+	}
+
+	// Resolve all the packages together, so that tdnf reports all the unresolvable packages at once.
+	// Note: The packages are only downloaded here. So, their signatures are checked later when they are installed.
+	tdnfArgs := []string{
+		action, "--downloadonly", "--downloaddir", preflightDownloadDirInChroot, "--nogpgcheck", "--assumeyes",
+	}
+	tdnfArgs = append(tdnfArgs, tdnfRepoArgs(releaseVersion)...)
+	tdnfArgs = append(tdnfArgs, packages...)
+
+	stdout := ""
+	stderr := ""
+	err := imageChroot.Run(func() error {
+		var err error
+		stdout, stderr, err = shell.Execute("tdnf", tdnfArgs...)
+		return err
+	})
+	if err != nil {
+		output := strings.TrimSpace(strings.TrimSpace(stdout) + "\n" + strings.TrimSpace(stderr))
+		return fmt.Errorf("failed to resolve packages to %s (%s):\n%s\n%w", action, strings.Join(packages, ", "),
+			output, err)
+	}
+
 	return nil
 }
 
+// filterOutInstalledPackages returns the list of packages that aren't already installed in the image.
+func filterOutInstalledPackages(packages []string, imageChroot *safechroot.Chroot) ([]string, error) {
+	var missingPackages []string
+	var installedPackages []string
+	for _, packageName := range packages {
+		installed, err := isPackageInstalled(packageName, imageChroot)
+		if err != nil {
+			return nil, err
+		}
+
+		if installed {
+			installedPackages = append(installedPackages, packageName)
+		} else {
+			missingPackages = append(missingPackages, packageName)
+		}
+	}
+
+	logger.Log.Infof("Skipping %d packages that are already installed: %v", len(installedPackages), installedPackages)
+	logger.Log.Infof("Found %d packages that need to be installed", len(missingPackages))
+
+	return missingPackages, nil
+}
+
+// isPackageInstalled checks if a package is installed in the image.
+// The package may be specified either by name (optionally with a version) or by a capability it provides.
+func isPackageInstalled(packageName string, imageChroot *safechroot.Chroot) (bool, error) {
+	queries := [][]string{
+		{"-q", packageName},
+		{"-q", "--whatprovides", packageName},
+	}
+
+	for _, queryArgs := range queries {
+		err := imageChroot.UnsafeRun(func() error {
+			_, _, err := shell.Execute("rpm", queryArgs...)
+			return err
+		})
+		if err == nil {
+			return true, nil
+		}
+
+		// rpm returns the number of packages that weren't found as the exit code.
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+			return false, fmt.Errorf("failed to query if package (%s) is installed:\n%w", packageName, err)
+		}
+	}
+
+	return false, nil
+}
+
 func collectPackagesList(baseConfigPath string, packageLists []string, packages []string) ([]string, error) {
 	var err error
 
@@ -110,9 +404,20 @@ func removePackages(allPackagesToRemove []string, imageChroot *safechroot.Chroot
 	// Remove packages.
 	// Do this one at a time, to avoid running out of memory.
 	for _, packageName := range allPackagesToRemove {
+		// A package that isn't installed has nothing to remove. So, don't fail the build because of it.
+		installed, err := isPackageInstalled(packageName, imageChroot)
+		if err != nil {
+			return err
+		}
+
+		if !installed {
+			logger.Log.Warnf("Skipping removal of package (%s): package is not installed", packageName)
+			continue
+		}
+
 		tnfRemoveArgs[len(tnfRemoveArgs)-1] = packageName
 
-		err := imageChroot.Run(func() error {
+		err = imageChroot.Run(func() error {
 			return shell.ExecuteLiveWithCallback(tdnfRemoveStdoutFilter, logger.Log.Debug, false, "tdnf",
 				tnfRemoveArgs...)
 		})
@@ -124,6 +429,49 @@ func removePackages(allPackagesToRemove []string, imageChroot *safechroot.Chroot
 	return nil
 }
 
+// autoremovePackages removes the packages that were only installed as dependencies of packages that are no longer
+// installed.
+func autoremovePackages(imageChroot *safechroot.Chroot) error {
+	logger.Log.Infof("Removing orphaned dependencies")
+
+	packagesBefore, err := getInstalledPackages(imageChroot)
+	if err != nil {
+		return err
+	}
+
+	err = imageChroot.Run(func() error {
+		return shell.ExecuteLiveWithCallback(tdnfRemoveStdoutFilter, logger.Log.Debug, false, "tdnf",
+			"-v", "autoremove", "--assumeyes", "--disablerepo", "*")
+	})
+	if err != nil {
+		return fmt.Errorf("failed to autoremove packages:\n%w", err)
+	}
+
+	packagesAfter, err := getInstalledPackages(imageChroot)
+	if err != nil {
+		return err
+	}
+
+	autoremoved := removedPackages(packagesBefore, packagesAfter)
+	logger.Log.Infof("Autoremoved %d packages: %v", len(autoremoved), autoremoved)
+
+	return nil
+}
+
+// removedPackages returns the sorted NEVRAs of the packages that are in the before list but not the after list.
+// The lists are maps of NEVRA to package name, as returned by getInstalledPackages.
+func removedPackages(packagesBefore map[string]string, packagesAfter map[string]string) []string {
+	removed := []string(nil)
+	for nevra := range packagesBefore {
+		if _, found := packagesAfter[nevra]; !found {
+			removed = append(removed, nevra)
+		}
+	}
+
+	sort.Strings(removed)
+	return removed
+}
+
 // Process the stdout of a `tdnf install -v` call and send the list of installed packages to the debug log.
 func tdnfRemoveStdoutFilter(args ...interface{}) {
 	const tdnfInstallPrefix = "Removing: "
@@ -133,6 +481,11 @@ func tdnfRemoveStdoutFilter(args ...interface{}) {
 	}
 
 	line := args[0].(string)
+	if isTdnfWarningLine(line) {
+		logger.Log.Warn(line)
+		return
+	}
+
 	if !strings.HasPrefix(line, tdnfInstallPrefix) {
 		return
 	}
@@ -140,13 +493,14 @@ func tdnfRemoveStdoutFilter(args ...interface{}) {
 	logger.Log.Debug(line)
 }
 
-func updateAllPackages(imageChroot *safechroot.Chroot) error {
+func updateAllPackages(releaseVersion string, gpgCheck bool, imageChroot *safechroot.Chroot) error {
 	logger.Log.Infof("Updating base image packages")
 
 	tnfUpdateArgs := []string{
-		"-v", "update", "--nogpgcheck", "--assumeyes",
-		"--setopt", fmt.Sprintf("reposdir=%s", rpmsMountParentDirInChroot),
+		"-v", "update", "--assumeyes",
 	}
+	tnfUpdateArgs = append(tnfUpdateArgs, tdnfGpgCheckArgs(gpgCheck)...)
+	tnfUpdateArgs = append(tnfUpdateArgs, tdnfRepoArgs(releaseVersion)...)
 
 	err := imageChroot.Run(func() error {
 		return shell.ExecuteLiveWithCallback(tdnfInstallOrUpdateStdoutFilter, logger.Log.Debug, false, "tdnf",
@@ -159,20 +513,26 @@ func updateAllPackages(imageChroot *safechroot.Chroot) error {
 	return nil
 }
 
-func installOrUpdatePackages(action string, allPackagesToAdd []string, imageChroot *safechroot.Chroot) error {
+func installOrUpdatePackages(action string, allPackagesToAdd []string, releaseVersion string, gpgCheck bool,
+	imageChroot *safechroot.Chroot,
+) error {
 	// Create tdnf command args.
 	// Note: When using `--repofromdir`, tdnf will not use any default repos and will only use the last
 	// `--repofromdir` specified.
 	tnfInstallArgs := []string{
-		"-v", action, "--nogpgcheck", "--assumeyes",
-		"--setopt", fmt.Sprintf("reposdir=%s", rpmsMountParentDirInChroot),
-		// Placeholder for package name.
-		"",
+		"-v", action, "--assumeyes",
 	}
+	tnfInstallArgs = append(tnfInstallArgs, tdnfGpgCheckArgs(gpgCheck)...)
+	tnfInstallArgs = append(tnfInstallArgs, tdnfRepoArgs(releaseVersion)...)
+	// Placeholder for package name.
+	tnfInstallArgs = append(tnfInstallArgs, "")
 
 	// Install packages.
 	// Do this one at a time, to avoid running out of memory.
-	for _, packageName := range allPackagesToAdd {
+	for i, packageName := range allPackagesToAdd {
+		logger.Log.Infof("%s package %d of %d: %s", tdnfActionProgressVerb(action), i+1, len(allPackagesToAdd),
+			packageName)
+
 		tnfInstallArgs[len(tnfInstallArgs)-1] = packageName
 
 		err := imageChroot.Run(func() error {
@@ -187,7 +547,175 @@ func installOrUpdatePackages(action string, allPackagesToAdd []string, imageChro
 	return nil
 }
 
+// installLocalRpmFiles installs RPM files directly, resolving their dependencies using the RPM sources.
+// The files are installed using a single tdnf call, so that they may depend on each other.
+func installLocalRpmFiles(rpmFilePaths []string, releaseVersion string, gpgCheck bool,
+	imageChroot *safechroot.Chroot,
+) error {
+	if gpgCheck {
+		err := verifyRpmFileSignatures(rpmFilePaths, imageChroot)
+		if err != nil {
+			return err
+		}
+	}
+
+	logger.Log.Infof("Installing local RPM files: %v", rpmFilePaths)
+
+	// Note: tdnf doesn't have any GPG keys configured for the local RPM files. So, their signatures are checked
+	// separately using rpm's keyring instead.
+	tdnfInstallArgs := []string{
+		"-v", "install", "--nogpgcheck", "--assumeyes",
+	}
+	tdnfInstallArgs = append(tdnfInstallArgs, tdnfRepoArgs(releaseVersion)...)
+	tdnfInstallArgs = append(tdnfInstallArgs, rpmFilePaths...)
+
+	err := imageChroot.Run(func() error {
+		return shell.ExecuteLiveWithCallback(tdnfInstallOrUpdateStdoutFilter, logger.Log.Debug, false, "tdnf",
+			tdnfInstallArgs...)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to install local RPM files:\n%w", err)
+	}
+
+	return nil
+}
+
+// tdnfActionProgressVerb returns the verb used in the progress logs of a tdnf action.
+func tdnfActionProgressVerb(action string) string {
+	switch action {
+	case "install":
+		return "Installing"
+
+	case "update":
+		return "Updating"
+
+	case "downgrade":
+		return "Downgrading"
+
+	default:
+		return action
+	}
+}
+
+// downgradePackages downgrades packages to the requested versions.
+// Note: The availability of the versions is checked by checkPackagesAvailable before any packages are changed.
+func downgradePackages(allPackagesToDowngrade []string, releaseVersion string, gpgCheck bool,
+	imageChroot *safechroot.Chroot,
+) error {
+	logger.Log.Infof("Downgrading packages: %v", allPackagesToDowngrade)
+	err := installOrUpdatePackages("downgrade", allPackagesToDowngrade, releaseVersion, gpgCheck, imageChroot)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// checkPackagesAvailable checks that the RPM sources contain a package that matches each of the package specs.
+func checkPackagesAvailable(packages []string, releaseVersion string, imageChroot *safechroot.Chroot) error {
+	for _, packageName := range packages {
+		err := ensurePackageAvailable(packageName, releaseVersion, imageChroot)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ensurePackageAvailable checks that the RPM sources contain a package that matches the package spec.
+func ensurePackageAvailable(packageName string, releaseVersion string, imageChroot *safechroot.Chroot) error {
+	tdnfRepoQueryArgs := []string{
+		"repoquery", "--quiet",
+	}
+	tdnfRepoQueryArgs = append(tdnfRepoQueryArgs, tdnfRepoArgs(releaseVersion)...)
+	tdnfRepoQueryArgs = append(tdnfRepoQueryArgs, packageName)
+
+	stdout := ""
+	err := imageChroot.Run(func() error {
+		var err error
+		stdout, _, err = shell.Execute("tdnf", tdnfRepoQueryArgs...)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to query RPM sources for package (%s):\n%w", packageName, err)
+	}
+
+	if strings.TrimSpace(stdout) == "" {
+		return fmt.Errorf("package (%s) was not found in the RPM sources", packageName)
+	}
+
+	return nil
+}
+
+// tdnfGpgCheckArgs returns the tdnf args that disable the package signature checks, unless they were requested.
+func tdnfGpgCheckArgs(gpgCheck bool) []string {
+	if gpgCheck {
+		return nil
+	}
+
+	return []string{"--nogpgcheck"}
+}
+
+// importPackagesGpgKeys imports the GPG keys used to verify the packages' signatures into the image's rpm keyring.
+func importPackagesGpgKeys(gpgKeysInChroot []string, imageChroot *safechroot.Chroot) error {
+	for _, gpgKey := range gpgKeysInChroot {
+		logger.Log.Debugf("Importing packages GPG key (%s)", gpgKey)
+
+		err := imageChroot.UnsafeRun(func() error {
+			_, stderr, err := shell.Execute("rpm", "--import", gpgKey)
+			if err != nil {
+				return fmt.Errorf("%s\n%w", strings.TrimSpace(stderr), err)
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to import packages GPG key (%s):\n%w", gpgKey, err)
+		}
+	}
+
+	return nil
+}
+
+// verifyRpmFileSignatures checks the signatures of RPM files using the image's rpm keyring.
+func verifyRpmFileSignatures(rpmFilePaths []string, imageChroot *safechroot.Chroot) error {
+	for _, rpmFilePath := range rpmFilePaths {
+		stdout := ""
+		err := imageChroot.UnsafeRun(func() error {
+			var err error
+			stdout, _, err = shell.Execute("rpm", "--checksig", rpmFilePath)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to verify signature of RPM file (%s):\n%s\n%w", rpmFilePath,
+				strings.TrimSpace(stdout), err)
+		}
+
+		// rpm only checks the digests of unsigned RPM files.
+		if !strings.Contains(stdout, "signatures OK") {
+			return fmt.Errorf("RPM file (%s) is not signed", rpmFilePath)
+		}
+	}
+
+	return nil
+}
+
+// tdnfRepoArgs returns the tdnf args that select the mounted RPM sources.
+func tdnfRepoArgs(releaseVersion string) []string {
+	args := []string{
+		"--setopt", fmt.Sprintf("reposdir=%s", rpmsMountParentDirInChroot),
+	}
+
+	if releaseVersion != "" {
+		// Override the `$releasever` value that tdnf would otherwise derive from the image's release package.
+		args = append(args, "--releasever", releaseVersion)
+	}
+
+	return args
+}
+
 // Process the stdout of a `tdnf install -v` call and send the list of installed packages to the debug log.
+// Any file conflicts or warnings are sent to the warning log.
 func tdnfInstallOrUpdateStdoutFilter(args ...interface{}) {
 	const tdnfInstallPrefix = "Installing/Updating: "
 
@@ -196,9 +724,30 @@ func tdnfInstallOrUpdateStdoutFilter(args ...interface{}) {
 	}
 
 	line := args[0].(string)
+	if isTdnfWarningLine(line) {
+		logger.Log.Warn(line)
+		return
+	}
+
 	if !strings.HasPrefix(line, tdnfInstallPrefix) {
 		return
 	}
 
 	logger.Log.Debug(line)
 }
+
+// isTdnfWarningLine returns true if a line of tdnf's output reports a warning or a file conflict.
+// These are easy to miss when only the debug log is captured and they can indicate a subtly broken image.
+//
+// Only tdnf's (and rpm's) message forms are matched, so that package names that contain words like "warning" (e.g.
+// python3-warnings) aren't reported.
+func isTdnfWarningLine(line string) bool {
+	trimmedLine := strings.TrimSpace(line)
+	if len(trimmedLine) >= len(tdnfWarningPrefix) &&
+		strings.EqualFold(trimmedLine[:len(tdnfWarningPrefix)], tdnfWarningPrefix) {
+		return true
+	}
+
+	// e.g. "file /etc/foo.conf from install of a-1.0 conflicts with file from package b-1.0"
+	return strings.HasPrefix(trimmedLine, "file ") && strings.Contains(trimmedLine, tdnfFileConflictMessage)
+}
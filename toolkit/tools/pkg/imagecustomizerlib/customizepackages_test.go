@@ -0,0 +1,58 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsTdnfWarningLine(t *testing.T) {
+	assert.True(t, isTdnfWarningLine("file /etc/foo.conf from install of a-1.0 conflicts with file from package b-1.0"))
+	assert.True(t, isTdnfWarningLine("Warning: /etc/foo.conf created as /etc/foo.conf.rpmnew"))
+	assert.True(t, isTdnfWarningLine("warning: unable to find key"))
+	assert.False(t, isTdnfWarningLine("Installing/Updating: jq-1.6-1.cm2.x86_64"))
+	assert.False(t, isTdnfWarningLine("Removing: jq-1.6-1.cm2.x86_64"))
+}
+
+func TestIsTdnfWarningLinePackageNames(t *testing.T) {
+	assert.True(t, isTdnfWarningLine("  file /usr/lib/python3.9/warnings.py from install of python3-warnings-1.0-1.cm2 "+
+		"conflicts with file from package python3-3.9.14-1.cm2"))
+	assert.False(t, isTdnfWarningLine("Installing/Updating: python3-warnings-1.0-1.cm2.noarch"))
+	assert.False(t, isTdnfWarningLine("Installing/Updating: conflict-resolver-2.0-1.cm2.x86_64"))
+	assert.False(t, isTdnfWarningLine("Removing: perl-Warnings-Illegalproto-0.001-1.cm2.noarch"))
+	assert.False(t, isTdnfWarningLine("python3-warnings    x86_64    1.0-1.cm2    mariner-official-base    12.00k"))
+	assert.False(t, isTdnfWarningLine("file-conflicts-checker-1.0-1.cm2.noarch"))
+}
+
+func TestTdnfCommandArgs(t *testing.T) {
+	args := tdnfCommandArgs([]string{"mark", "install", "jq"}, "2.0", false)
+	assert.Equal(t, []string{
+		"-v", "--assumeyes", "--nogpgcheck", "--setopt", "reposdir=" + rpmsMountParentDirInChroot,
+		"--releasever", "2.0", "mark", "install", "jq",
+	}, args)
+
+	args = tdnfCommandArgs([]string{"autoremove"}, "", true)
+	assert.Equal(t, []string{
+		"-v", "--assumeyes", "--setopt", "reposdir=" + rpmsMountParentDirInChroot, "autoremove",
+	}, args)
+}
+
+func TestRemovedPackages(t *testing.T) {
+	packagesBefore := map[string]string{
+		"jq-1.6-1.cm2.x86_64":          "jq",
+		"oniguruma-6.9.7-1.cm2.x86_64": "oniguruma",
+		"bash-5.1.8-4.cm2.x86_64":      "bash",
+	}
+	packagesAfter := map[string]string{
+		"bash-5.1.8-4.cm2.x86_64": "bash",
+	}
+
+	removed := removedPackages(packagesBefore, packagesAfter)
+	assert.Equal(t, []string{"jq-1.6-1.cm2.x86_64", "oniguruma-6.9.7-1.cm2.x86_64"}, removed)
+
+	removed = removedPackages(packagesAfter, packagesAfter)
+	assert.Empty(t, removed)
+}
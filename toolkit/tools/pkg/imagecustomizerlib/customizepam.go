@@ -0,0 +1,175 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagecustomizerapi"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/file"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/logger"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/safechroot"
+)
+
+var (
+	// The directories that PAM searches for modules that are specified using a relative path.
+	pamModuleDirs = []string{"/usr/lib64/security", "/usr/lib/security", "/lib64/security", "/lib/security"}
+)
+
+// addPamConfigFiles writes the PAM configuration files into the image.
+//
+// A broken PAM config can prevent all users from logging in. So, all the /etc/pam.d files are sanity checked before
+// any of the files are written.
+func addPamConfigFiles(pamConfigFiles []imagecustomizerapi.PamConfigFile, imageChroot *safechroot.Chroot) error {
+	if len(pamConfigFiles) <= 0 {
+		return nil
+	}
+
+	for _, pamConfigFile := range pamConfigFiles {
+		if !pamConfigFile.IsInPamDir() {
+			continue
+		}
+
+		logger.Log.Infof("Validating PAM config file (%s)", pamConfigFile.Path)
+
+		err := validatePamServiceConfig(pamConfigFile.Content, imageChroot.RootDir())
+		if err != nil {
+			return fmt.Errorf("invalid PAM config file (%s):\n%w", pamConfigFile.Path, err)
+		}
+	}
+
+	for _, pamConfigFile := range pamConfigFiles {
+		logger.Log.Infof("Writing PAM config file (%s)", pamConfigFile.Path)
+
+		fullPath := filepath.Join(imageChroot.RootDir(), pamConfigFile.Path)
+
+		err := os.MkdirAll(filepath.Dir(fullPath), 0o755)
+		if err != nil {
+			return fmt.Errorf("failed to create directory for PAM config file (%s):\n%w", pamConfigFile.Path, err)
+		}
+
+		err = os.WriteFile(fullPath, []byte(pamConfigFile.Content), 0o644)
+		if err != nil {
+			return fmt.Errorf("failed to write PAM config file (%s):\n%w", pamConfigFile.Path, err)
+		}
+	}
+
+	return nil
+}
+
+// validatePamServiceConfig checks that the config is well formed and that every (non-optional) module it references
+// exists in the image.
+func validatePamServiceConfig(content string, rootDir string) error {
+	modules, err := parsePamModuleReferences(content)
+	if err != nil {
+		return err
+	}
+
+	for _, module := range modules {
+		exists, err := pamModuleExists(module, rootDir)
+		if err != nil {
+			return err
+		}
+
+		if !exists {
+			return fmt.Errorf("PAM module (%s) does not exist in the image", module)
+		}
+	}
+
+	return nil
+}
+
+// parsePamModuleReferences parses the contents of a /etc/pam.d file and returns the list of required modules.
+// Modules referenced by rules whose type is prefixed with '-' are optional and are therefore not returned.
+//
+// See, pam.d(5).
+func parsePamModuleReferences(content string) ([]string, error) {
+	// Join lines that are split using a trailing '\'.
+	content = strings.ReplaceAll(content, "\\\n", " ")
+
+	var modules []string
+	for i, line := range strings.Split(content, "\n") {
+		// Remove comments.
+		commentIndex := strings.Index(line, "#")
+		if commentIndex >= 0 {
+			line = line[:commentIndex]
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0] == "@include" {
+			continue
+		}
+
+		moduleType, optional := strings.CutPrefix(fields[0], "-")
+		switch strings.ToLower(moduleType) {
+		case "account", "auth", "password", "session":
+
+		default:
+			return nil, fmt.Errorf("line %d: unknown PAM rule type (%s)", i+1, fields[0])
+		}
+
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("line %d: PAM rule is missing a control or module-path value", i+1)
+		}
+
+		// Find the end of the control value, which may be a bracketed list containing spaces.
+		moduleIndex := 2
+		control := fields[1]
+		if strings.HasPrefix(control, "[") {
+			for moduleIndex = 1; moduleIndex < len(fields); moduleIndex++ {
+				if strings.HasSuffix(fields[moduleIndex], "]") {
+					break
+				}
+			}
+			moduleIndex++
+
+			if moduleIndex >= len(fields) {
+				return nil, fmt.Errorf("line %d: PAM rule has an unterminated control value or is missing a module-path value",
+					i+1)
+			}
+		}
+
+		switch control {
+		case "include", "substack":
+			// The rule references another config file, not a module.
+			continue
+		}
+
+		if optional {
+			continue
+		}
+
+		modules = append(modules, fields[moduleIndex])
+	}
+
+	return modules, nil
+}
+
+func pamModuleExists(module string, rootDir string) (bool, error) {
+	candidates := []string{module}
+	if !filepath.IsAbs(module) {
+		candidates = nil
+		for _, moduleDir := range pamModuleDirs {
+			candidates = append(candidates, filepath.Join(moduleDir, module))
+		}
+	}
+
+	for _, candidate := range candidates {
+		candidateFullPath := filepath.Join(rootDir, candidate)
+
+		exists, err := file.PathExists(candidateFullPath)
+		if err != nil {
+			return false, fmt.Errorf("failed to check if PAM module (%s) exists:\n%w", candidate, err)
+		}
+
+		if exists {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
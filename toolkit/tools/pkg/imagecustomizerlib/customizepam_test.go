@@ -0,0 +1,61 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePamModuleReferences(t *testing.T) {
+	content := `#%PAM-1.0
+# Comment line.
+auth       required     pam_env.so # Trailing comment.
+auth       [success=1 default=ignore] pam_unix.so nullok
+-auth      optional     pam_systemd_home.so
+account    include      system-account
+password   substack     system-password
+session    required     /usr/lib64/security/pam_limits.so \
+           conf=/etc/security/limits.conf
+@include common-session
+`
+
+	modules, err := parsePamModuleReferences(content)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"pam_env.so", "pam_unix.so", "/usr/lib64/security/pam_limits.so"}, modules)
+}
+
+func TestParsePamModuleReferencesUnknownType(t *testing.T) {
+	_, err := parsePamModuleReferences("authentication required pam_env.so\n")
+	assert.ErrorContains(t, err, "unknown PAM rule type")
+}
+
+func TestParsePamModuleReferencesMissingModule(t *testing.T) {
+	_, err := parsePamModuleReferences("auth required\n")
+	assert.ErrorContains(t, err, "missing")
+}
+
+func TestParsePamModuleReferencesUnterminatedControl(t *testing.T) {
+	_, err := parsePamModuleReferences("auth [success=1 default=ignore pam_unix.so\n")
+	assert.ErrorContains(t, err, "unterminated")
+}
+
+func TestValidatePamServiceConfig(t *testing.T) {
+	rootDir := filepath.Join(tmpDir, "TestValidatePamServiceConfig")
+
+	err := os.MkdirAll(filepath.Join(rootDir, "usr/lib64/security"), os.ModePerm)
+	assert.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(rootDir, "usr/lib64/security/pam_unix.so"), []byte{}, 0o755)
+	assert.NoError(t, err)
+
+	err = validatePamServiceConfig("auth required pam_unix.so\n-auth optional pam_missing.so\n", rootDir)
+	assert.NoError(t, err)
+
+	err = validatePamServiceConfig("auth required pam_missing.so\n", rootDir)
+	assert.ErrorContains(t, err, "PAM module (pam_missing.so) does not exist")
+}
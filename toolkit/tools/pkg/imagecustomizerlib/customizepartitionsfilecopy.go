@@ -23,12 +23,21 @@ func customizePartitionsUsingFileCopy(buildDir string, baseConfigPath string, co
 
 	diskConfig := (*config.Disks)[0]
 
+	var basePartitionUuids map[string]partitionUuids
+	if diskConfig.PreservePartitionUuids {
+		basePartitionUuids, err = readBasePartitionUuids(existingImageConnection)
+		if err != nil {
+			return err
+		}
+	}
+
 	installOSFunc := func(imageChroot *safechroot.Chroot) error {
 		return copyFilesIntoNewDisk(existingImageConnection.Chroot(), imageChroot)
 	}
 
-	err = createNewImage(newBuildImageFile, diskConfig, config.SystemConfig.PartitionSettings,
-		config.SystemConfig.BootType, config.SystemConfig.KernelCommandLine, buildDir, "newimageroot", installOSFunc)
+	err = createNewImage(newBuildImageFile, baseConfigPath, diskConfig, config.SystemConfig.PartitionSettingsWithDefaults(),
+		config.SystemConfig.BootType, config.SystemConfig.KernelCommandLine, buildDir, "newimageroot", installOSFunc,
+		basePartitionUuids)
 	if err != nil {
 		return err
 	}
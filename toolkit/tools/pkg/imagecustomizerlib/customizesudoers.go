@@ -0,0 +1,97 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagecustomizerapi"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/file"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/logger"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/safechroot"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/shell"
+)
+
+const (
+	sudoersDropInDir             = "/etc/sudoers.d"
+	sudoersStagingDirInChroot    = "/_sudoers"
+	sudoersFilePermissions       = 0o440
+	sudoersDropInDirPermissions  = 0o750
+	sudoersStagingDirPermissions = 0o700
+)
+
+// addSudoersFiles writes the sudoers drop-in files into the image.
+//
+// A broken sudoers file can lock every user out of sudo. So, all the files are validated with `visudo` before any of
+// them are placed in the /etc/sudoers.d directory.
+func addSudoersFiles(sudoersFiles []imagecustomizerapi.SudoersFile, imageChroot *safechroot.Chroot) error {
+	if len(sudoersFiles) <= 0 {
+		return nil
+	}
+
+	stagingDir := filepath.Join(imageChroot.RootDir(), sudoersStagingDirInChroot)
+
+	// Remove any staging directory left behind by an earlier run that was interrupted.
+	err := os.RemoveAll(stagingDir)
+	if err != nil {
+		return fmt.Errorf("failed to remove old sudoers staging directory (%s):\n%w", stagingDir, err)
+	}
+
+	err = os.Mkdir(stagingDir, sudoersStagingDirPermissions)
+	if err != nil {
+		return fmt.Errorf("failed to create sudoers staging directory (%s):\n%w", stagingDir, err)
+	}
+	defer os.RemoveAll(stagingDir)
+
+	// Validate all the files first.
+	for _, sudoersFile := range sudoersFiles {
+		logger.Log.Infof("Validating sudoers file (%s)", sudoersFile.Name)
+
+		stagingFilePath := filepath.Join(stagingDir, sudoersFile.Name)
+
+		err = os.WriteFile(stagingFilePath, []byte(sudoersFile.Content), sudoersFilePermissions)
+		if err != nil {
+			return fmt.Errorf("failed to write sudoers file (%s):\n%w", sudoersFile.Name, err)
+		}
+
+		stagingFilePathInChroot := filepath.Join(sudoersStagingDirInChroot, sudoersFile.Name)
+
+		err = imageChroot.UnsafeRun(func() error {
+			return shell.ExecuteLiveWithErr(1, "visudo", "--check", "--file", stagingFilePathInChroot)
+		})
+		if err != nil {
+			return fmt.Errorf("sudoers file (%s) is invalid:\n%w", sudoersFile.Name, err)
+		}
+	}
+
+	// Move the files into place.
+	dropInDir := filepath.Join(imageChroot.RootDir(), sudoersDropInDir)
+
+	err = os.MkdirAll(dropInDir, sudoersDropInDirPermissions)
+	if err != nil {
+		return fmt.Errorf("failed to create sudoers drop-in directory (%s):\n%w", dropInDir, err)
+	}
+
+	for _, sudoersFile := range sudoersFiles {
+		logger.Log.Infof("Adding sudoers file (%s)", sudoersFile.Name)
+
+		stagingFilePath := filepath.Join(stagingDir, sudoersFile.Name)
+		dropInFilePath := filepath.Join(dropInDir, sudoersFile.Name)
+
+		err = file.Move(stagingFilePath, dropInFilePath)
+		if err != nil {
+			return fmt.Errorf("failed to move sudoers file (%s) into place:\n%w", sudoersFile.Name, err)
+		}
+
+		// Ensure the permissions are correct, since sudo ignores files that are writable by anyone other than root.
+		err = os.Chmod(dropInFilePath, sudoersFilePermissions)
+		if err != nil {
+			return fmt.Errorf("failed to set permissions of sudoers file (%s):\n%w", sudoersFile.Name, err)
+		}
+	}
+
+	return nil
+}
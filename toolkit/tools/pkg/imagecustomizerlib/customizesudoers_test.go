@@ -0,0 +1,179 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagecustomizerapi"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/buildpipeline"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/safechroot"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddSudoersFiles(t *testing.T) {
+	chroot := createSudoersTestChroot(t, "TestAddSudoersFiles")
+	if chroot == nil {
+		return
+	}
+	defer chroot.Close(false)
+
+	sudoersFiles := []imagecustomizerapi.SudoersFile{
+		{
+			Name:    "admins",
+			Content: "%admins ALL=(ALL) ALL\n",
+		},
+	}
+
+	err := addSudoersFiles(sudoersFiles, chroot)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// Ensure the file was installed with the permissions that sudo requires.
+	sudoersFilePath := filepath.Join(chroot.RootDir(), "etc/sudoers.d/admins")
+
+	contents, err := os.ReadFile(sudoersFilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, "%admins ALL=(ALL) ALL\n", string(contents))
+
+	stat, err := os.Stat(sudoersFilePath)
+	if assert.NoError(t, err) {
+		assert.Equal(t, os.FileMode(0o440), stat.Mode().Perm())
+	}
+
+	// Ensure the staging directory was cleaned up.
+	assert.NoDirExists(t, filepath.Join(chroot.RootDir(), sudoersStagingDirInChroot))
+}
+
+func TestAddSudoersFilesInvalid(t *testing.T) {
+	chroot := createSudoersTestChroot(t, "TestAddSudoersFilesInvalid")
+	if chroot == nil {
+		return
+	}
+	defer chroot.Close(false)
+
+	sudoersFiles := []imagecustomizerapi.SudoersFile{
+		{
+			Name:    "admins",
+			Content: "%admins ALL=(ALL) ALL\n",
+		},
+		{
+			Name:    "broken",
+			Content: "%broken ALL=(ALL\n",
+		},
+	}
+
+	err := addSudoersFiles(sudoersFiles, chroot)
+	assert.ErrorContains(t, err, "sudoers file (broken) is invalid")
+
+	// Ensure none of the files were installed, including the valid one.
+	assert.NoFileExists(t, filepath.Join(chroot.RootDir(), "etc/sudoers.d/admins"))
+	assert.NoFileExists(t, filepath.Join(chroot.RootDir(), "etc/sudoers.d/broken"))
+	assert.NoDirExists(t, filepath.Join(chroot.RootDir(), sudoersStagingDirInChroot))
+}
+
+func TestAddSudoersFilesLeftoverStagingDir(t *testing.T) {
+	chroot := createSudoersTestChroot(t, "TestAddSudoersFilesLeftoverStagingDir")
+	if chroot == nil {
+		return
+	}
+	defer chroot.Close(false)
+
+	// Simulate an earlier run that was interrupted after the files were staged.
+	stagingDir := filepath.Join(chroot.RootDir(), sudoersStagingDirInChroot)
+
+	err := os.Mkdir(stagingDir, 0o700)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = os.WriteFile(filepath.Join(stagingDir, "stale"), []byte("%stale ALL=(ALL) ALL\n"), 0o440)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	sudoersFiles := []imagecustomizerapi.SudoersFile{
+		{
+			Name:    "admins",
+			Content: "%admins ALL=(ALL) ALL\n",
+		},
+	}
+
+	err = addSudoersFiles(sudoersFiles, chroot)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.FileExists(t, filepath.Join(chroot.RootDir(), "etc/sudoers.d/admins"))
+	assert.NoFileExists(t, filepath.Join(chroot.RootDir(), "etc/sudoers.d/stale"))
+	assert.NoDirExists(t, stagingDir)
+}
+
+// createSudoersTestChroot creates a chroot that can run the host's visudo, by bind mounting the host's program and
+// library directories into it.
+// Returns nil if the test is skipped or the chroot couldn't be created.
+func createSudoersTestChroot(t *testing.T, name string) *safechroot.Chroot {
+	if os.Geteuid() != 0 {
+		t.Skip("Test must be run as root because it uses a chroot")
+	}
+
+	if !buildpipeline.IsRegularBuild() {
+		t.Skip("bind mounts not available")
+	}
+
+	_, err := exec.LookPath("visudo")
+	if err != nil {
+		t.Skip("visudo not available")
+	}
+
+	proposedDir := filepath.Join(tmpDir, name)
+
+	// Recreate the host's directory layout, so that visudo and the libraries it depends on can be found.
+	// For example, /bin is a symlink to /usr/bin on hosts with a merged /usr.
+	extraDirectories := []string(nil)
+	mountPoints := []*safechroot.MountPoint{
+		safechroot.NewMountPoint("/usr", "/usr", "", safechroot.BindMountPointFlags, ""),
+	}
+	symlinks := make(map[string]string)
+
+	for _, dir := range []string{"/bin", "/sbin", "/lib", "/lib64"} {
+		stat, err := os.Lstat(dir)
+		if err != nil {
+			continue
+		}
+
+		if stat.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Readlink(dir)
+			if !assert.NoError(t, err) {
+				return nil
+			}
+
+			symlinks[dir] = target
+		} else {
+			extraDirectories = append(extraDirectories, dir)
+			mountPoints = append(mountPoints,
+				safechroot.NewMountPoint(dir, dir, "", safechroot.BindMountPointFlags, ""))
+		}
+	}
+
+	chroot := safechroot.NewChroot(proposedDir, false)
+	err = chroot.Initialize("", append(extraDirectories, "/usr", "/etc"), mountPoints, true)
+	if !assert.NoError(t, err) {
+		return nil
+	}
+
+	for dir, target := range symlinks {
+		err = os.Symlink(target, filepath.Join(chroot.RootDir(), dir))
+		if !assert.NoError(t, err) {
+			chroot.Close(false)
+			return nil
+		}
+	}
+
+	return chroot
+}
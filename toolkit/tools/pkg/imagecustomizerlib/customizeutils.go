@@ -4,11 +4,16 @@
 package imagecustomizerlib
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagecustomizerapi"
@@ -18,6 +23,7 @@ import (
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/safechroot"
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/safemount"
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/shell"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/sliceutils"
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/userutils"
 	"golang.org/x/sys/unix"
 )
@@ -25,10 +31,32 @@ import (
 const (
 	configDirMountPathInChroot = "/_imageconfigs"
 	resolveConfPath            = "/etc/resolv.conf"
+	loginDefsPath              = "/etc/login.defs"
+	umaskProfileScriptPath     = "/etc/profile.d/umask.sh"
+	setcapBinary               = "/usr/sbin/setcap"
+)
+
+var (
+	loginDefsUmaskRegex = regexp.MustCompile(`(?m)^[ \t]*UMASK[ \t]+.*$`)
+
+	// The packages that provide commonly used login shells, keyed by the shell's file name.
+	userShellPackages = map[string]string{
+		"bash":    "bash",
+		"sh":      "bash",
+		"zsh":     "zsh",
+		"fish":    "fish",
+		"tcsh":    "tcsh",
+		"csh":     "tcsh",
+		"mksh":    "mksh",
+		"ksh":     "mksh",
+		"dash":    "dash",
+		"nologin": "util-linux",
+	}
 )
 
 func doCustomizations(buildDir string, baseConfigPath string, config *imagecustomizerapi.Config,
-	imageChroot *safechroot.Chroot, rpmsSources []string, useBaseImageRpmRepos bool, partitionsCustomized bool,
+	imageChroot *safechroot.Chroot, rpmsSources []string, useBaseImageRpmRepos bool, preserveRpmSourceDirs bool,
+	repoSigningKeyFile string, partitionsCustomized bool, packageManifestFile string,
 ) error {
 	var err error
 
@@ -43,7 +71,12 @@ func doCustomizations(buildDir string, baseConfigPath string, config *imagecusto
 	}
 
 	err = addRemoveAndUpdatePackages(buildDir, baseConfigPath, &config.SystemConfig, imageChroot, rpmsSources,
-		useBaseImageRpmRepos, partitionsCustomized)
+		useBaseImageRpmRepos, preserveRpmSourceDirs, repoSigningKeyFile, partitionsCustomized)
+	if err != nil {
+		return &PackageInstallError{Err: err}
+	}
+
+	err = writePackageManifest(packageManifestFile, imageChroot)
 	if err != nil {
 		return err
 	}
@@ -53,16 +86,56 @@ func doCustomizations(buildDir string, baseConfigPath string, config *imagecusto
 		return err
 	}
 
+	err = updateTimezone(config.SystemConfig.Timezone, imageChroot)
+	if err != nil {
+		return err
+	}
+
+	err = updateLocale(config.SystemConfig.Locale, imageChroot)
+	if err != nil {
+		return err
+	}
+
+	err = removeFiles(config.SystemConfig.RemoveFiles, imageChroot)
+	if err != nil {
+		return err
+	}
+
 	err = copyAdditionalFiles(baseConfigPath, config.SystemConfig.AdditionalFiles, imageChroot)
 	if err != nil {
 		return err
 	}
 
+	err = createSymlinks(config.SystemConfig.Symlinks, config.SystemConfig.SymlinksOverwrite, imageChroot)
+	if err != nil {
+		return err
+	}
+
 	err = AddOrUpdateUsers(config.SystemConfig.Users, baseConfigPath, imageChroot)
 	if err != nil {
 		return err
 	}
 
+	err = addSudoersFiles(config.SystemConfig.Sudoers, imageChroot)
+	if err != nil {
+		return err
+	}
+
+	err = addPamConfigFiles(config.SystemConfig.PamConfigFiles, imageChroot)
+	if err != nil {
+		return err
+	}
+
+	err = addNetworkConfigFiles(&config.SystemConfig, imageChroot)
+	if err != nil {
+		return err
+	}
+
+	err = configureFirewall(config.SystemConfig.Firewall, imageChroot)
+	if err != nil {
+		return err
+	}
+
 	err = enableOrDisableServices(config.SystemConfig.Services, imageChroot)
 	if err != nil {
 		return err
@@ -73,6 +146,26 @@ func doCustomizations(buildDir string, baseConfigPath string, config *imagecusto
 		return err
 	}
 
+	err = configureZram(config.SystemConfig.Zram, imageChroot)
+	if err != nil {
+		return err
+	}
+
+	err = updateUmask(config.SystemConfig.Umask, imageChroot)
+	if err != nil {
+		return err
+	}
+
+	err = writeLoginBanners(config.SystemConfig.LoginBanners, ToolVersion, buildTime, imageChroot)
+	if err != nil {
+		return err
+	}
+
+	err = installAutoinstallSeed(baseConfigPath, config.SystemConfig.AutoinstallSeed, imageChroot)
+	if err != nil {
+		return err
+	}
+
 	err = addCustomizerRelease(imageChroot, ToolVersion, buildTime)
 	if err != nil {
 		return err
@@ -83,12 +176,17 @@ func doCustomizations(buildDir string, baseConfigPath string, config *imagecusto
 		return err
 	}
 
-	err = handleKernelCommandLine(config.SystemConfig.KernelCommandLine.ExtraCommandLine, imageChroot,
+	err = handleKernelCommandLine(config.SystemConfig.KernelCommandLine.CommandLine(), imageChroot,
 		partitionsCustomized)
 	if err != nil {
 		return fmt.Errorf("failed to add extra kernel command line: %w", err)
 	}
 
+	err = updateBootloaderSettings(config.SystemConfig.Bootloader, imageChroot)
+	if err != nil {
+		return err
+	}
+
 	err = runScripts(baseConfigPath, config.SystemConfig.FinalizeImageScripts, imageChroot)
 	if err != nil {
 		return err
@@ -99,6 +197,11 @@ func doCustomizations(buildDir string, baseConfigPath string, config *imagecusto
 		return err
 	}
 
+	err = setAdditionalFilesAttributes(config.SystemConfig.AdditionalFiles, imageChroot)
+	if err != nil {
+		return err
+	}
+
 	err = enableVerityPartition(config.SystemConfig.Verity, imageChroot)
 	if err != nil {
 		return err
@@ -162,22 +265,413 @@ func updateHostname(hostname string, imageChroot *safechroot.Chroot) error {
 	return nil
 }
 
+// Sets the OS's timezone by pointing the /etc/localtime symlink at the timezone's zoneinfo file.
+// The /etc/timezone file is also written, for the programs that read the timezone's name from there.
+func updateTimezone(timezone string, imageChroot *safechroot.Chroot) error {
+	if timezone == "" {
+		return nil
+	}
+
+	logger.Log.Infof("Setting timezone (%s)", timezone)
+
+	zoneinfoPath := filepath.Join("/usr/share/zoneinfo", timezone)
+
+	// The config's validation checks the timezone against the tool's own copy of the zoneinfo database.
+	// So, also check that the image has the timezone.
+	zoneinfoStat, err := os.Stat(filepath.Join(imageChroot.RootDir(), zoneinfoPath))
+	if err != nil {
+		return fmt.Errorf("timezone (%s) not found in the image (is the tzdata package installed?):\n%w", timezone, err)
+	}
+
+	if !zoneinfoStat.Mode().IsRegular() {
+		return fmt.Errorf("timezone (%s) is not a zoneinfo file (%s)", timezone, zoneinfoPath)
+	}
+
+	localtimePath := filepath.Join(imageChroot.RootDir(), "etc/localtime")
+
+	err = os.Remove(localtimePath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove localtime file:\n%w", err)
+	}
+
+	// Use a relative symlink, in the same way as systemd's timedatectl.
+	err = os.Symlink(filepath.Join("..", zoneinfoPath), localtimePath)
+	if err != nil {
+		return fmt.Errorf("failed to create localtime symlink:\n%w", err)
+	}
+
+	timezoneFilePath := filepath.Join(imageChroot.RootDir(), "etc/timezone")
+	err = file.Write(timezone+"\n", timezoneFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to write timezone file:\n%w", err)
+	}
+
+	return nil
+}
+
+// Sets the OS's default locale by writing the /etc/locale.conf file.
+// If the image has the locale-gen tool, then it is also used to generate the locale's data.
+func updateLocale(locale string, imageChroot *safechroot.Chroot) error {
+	if locale == "" {
+		return nil
+	}
+
+	logger.Log.Infof("Setting locale (%s)", locale)
+
+	localeConfPath := filepath.Join(imageChroot.RootDir(), "etc/locale.conf")
+	err := file.Write(fmt.Sprintf("LANG=%s\n", locale), localeConfPath)
+	if err != nil {
+		return fmt.Errorf("failed to write locale.conf file:\n%w", err)
+	}
+
+	// Note: localectl can't be used, since it requires systemd-localed to be running.
+	localeGenExists, err := file.PathExists(filepath.Join(imageChroot.RootDir(), "usr/sbin/locale-gen"))
+	if err != nil {
+		return fmt.Errorf("failed to check if locale-gen exists:\n%w", err)
+	}
+
+	if !localeGenExists {
+		logger.Log.Debugf("Skipping locale generation: locale-gen is not installed")
+		return nil
+	}
+
+	err = imageChroot.UnsafeRun(func() error {
+		return shell.ExecuteLiveWithErr(1, "locale-gen", locale)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to generate locale (%s):\n%w", locale, err)
+	}
+
+	return nil
+}
+
+// Sets the default umask for both the login programs (via /etc/login.defs) and login shells (via /etc/profile.d).
+func updateUmask(umask *imagecustomizerapi.Umask, imageChroot *safechroot.Chroot) error {
+	if umask == nil {
+		return nil
+	}
+
+	logger.Log.Infof("Setting default umask (%s)", umask)
+
+	// Update the UMASK value in the login.defs file.
+	imageLoginDefsPath := filepath.Join(imageChroot.RootDir(), loginDefsPath)
+
+	loginDefsBytes, err := os.ReadFile(imageLoginDefsPath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read login.defs file:\n%w", err)
+	}
+
+	loginDefs := string(loginDefsBytes)
+	umaskLine := fmt.Sprintf("UMASK\t\t%s", umask)
+
+	if loginDefsUmaskRegex.MatchString(loginDefs) {
+		loginDefs = loginDefsUmaskRegex.ReplaceAllLiteralString(loginDefs, umaskLine)
+	} else {
+		if loginDefs != "" && !strings.HasSuffix(loginDefs, "\n") {
+			loginDefs += "\n"
+		}
+		loginDefs += umaskLine + "\n"
+	}
+
+	err = file.Write(loginDefs, imageLoginDefsPath)
+	if err != nil {
+		return fmt.Errorf("failed to write login.defs file:\n%w", err)
+	}
+
+	// Set the umask for login shells.
+	imageUmaskProfileScriptPath := filepath.Join(imageChroot.RootDir(), umaskProfileScriptPath)
+
+	err = os.MkdirAll(filepath.Dir(imageUmaskProfileScriptPath), 0o755)
+	if err != nil {
+		return fmt.Errorf("failed to create profile.d directory:\n%w", err)
+	}
+
+	err = file.Write(fmt.Sprintf("umask %s\n", umask), imageUmaskProfileScriptPath)
+	if err != nil {
+		return fmt.Errorf("failed to write umask profile script:\n%w", err)
+	}
+
+	return nil
+}
+
+// removeFiles deletes files and directories from the image.
+// The paths are deleted from within the chroot, so that symlinks (in the path or the path itself) can't be used to
+// reach outside of the image's rootfs.
+func removeFiles(paths []string, imageChroot *safechroot.Chroot) error {
+	for _, path := range paths {
+		logger.Log.Infof("Removing file (%s)", path)
+
+		err := imageChroot.UnsafeRun(func() error {
+			_, err := os.Lstat(path)
+			if errors.Is(err, os.ErrNotExist) {
+				logger.Log.Debugf("File (%s) is already absent", path)
+				return nil
+			} else if err != nil {
+				return err
+			}
+
+			return os.RemoveAll(path)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to remove file (%s):\n%w", path, err)
+		}
+	}
+
+	return nil
+}
+
+// createSymlinks creates symlinks in the image.
+// Like removeFiles, the symlinks are created from within the chroot, so that the link paths can't be used to reach
+// outside of the image's rootfs.
+func createSymlinks(symlinks map[string]string, overwrite bool, imageChroot *safechroot.Chroot) error {
+	linkPaths := make([]string, 0, len(symlinks))
+	for linkPath := range symlinks {
+		linkPaths = append(linkPaths, linkPath)
+	}
+	sort.Strings(linkPaths)
+
+	for _, linkPath := range linkPaths {
+		target := symlinks[linkPath]
+
+		logger.Log.Infof("Creating symlink (%s) -> (%s)", linkPath, target)
+
+		err := imageChroot.UnsafeRun(func() error {
+			return createSymlink(linkPath, target, overwrite)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create symlink (%s):\n%w", linkPath, err)
+		}
+	}
+
+	return nil
+}
+
+func createSymlink(linkPath string, target string, overwrite bool) error {
+	fileInfo, err := os.Lstat(linkPath)
+	if err == nil {
+		if !overwrite {
+			return fmt.Errorf("file already exists (set SymlinksOverwrite to replace it)")
+		}
+
+		if fileInfo.IsDir() {
+			return fmt.Errorf("a directory already exists at the link path")
+		}
+
+		err = os.Remove(linkPath)
+		if err != nil {
+			return err
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(linkPath), 0o755)
+	if err != nil {
+		return err
+	}
+
+	err = os.Symlink(target, linkPath)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func copyAdditionalFiles(baseConfigPath string, additionalFiles map[string]imagecustomizerapi.FileConfigList, imageChroot *safechroot.Chroot) error {
 	for sourceFile, fileConfigs := range additionalFiles {
+		sourcePath := filepath.Join(baseConfigPath, sourceFile)
+
+		isDir, err := file.IsDir(sourcePath)
+		if err != nil {
+			return err
+		}
+
 		for _, fileConfig := range fileConfigs {
 			logger.Log.Infof("Copying: %s", fileConfig.Path)
 
+			if isDir {
+				err = copyAdditionalDir(sourcePath, fileConfig, imageChroot)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+
 			fileToCopy := safechroot.FileToCopy{
-				Src:         filepath.Join(baseConfigPath, sourceFile),
+				Src:         sourcePath,
 				Dest:        fileConfig.Path,
 				Permissions: (*fs.FileMode)(fileConfig.Permissions),
 			}
 
-			err := imageChroot.AddFiles(fileToCopy)
+			err = imageChroot.AddFiles(fileToCopy)
 			if err != nil {
 				return err
 			}
+
+			err = setFileOwnership([]string{fileConfig.Path}, fileConfig, imageChroot)
+			if err != nil {
+				return err
+			}
+
+			if fileConfig.NormalizeLineEndings {
+				err = normalizeLineEndings(filepath.Join(imageChroot.RootDir(), fileConfig.Path))
+				if err != nil {
+					return fmt.Errorf("failed to normalize line endings of (%s):\n%w", fileConfig.Path, err)
+				}
+			}
+
+			if fileConfig.Capabilities != "" {
+				err = setFileCapabilities(fileConfig.Path, fileConfig.Capabilities, imageChroot)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// copyAdditionalDir recursively copies a directory into the image.
+// The Permissions override, if specified, is applied to every file.
+func copyAdditionalDir(sourceDir string, fileConfig imagecustomizerapi.FileConfig,
+	imageChroot *safechroot.Chroot,
+) error {
+	dirs, filesToCopy, err := additionalDirFilesToCopy(sourceDir, fileConfig.Path,
+		(*fs.FileMode)(fileConfig.Permissions))
+	if err != nil {
+		return fmt.Errorf("failed to read AdditionalFiles source directory (%s):\n%w", sourceDir, err)
+	}
+
+	// Create the directories, so that empty directories are also copied.
+	for _, dir := range dirs {
+		err = os.MkdirAll(filepath.Join(imageChroot.RootDir(), dir), 0o755)
+		if err != nil {
+			return fmt.Errorf("failed to create directory (%s):\n%w", dir, err)
+		}
+	}
+
+	err = imageChroot.AddFiles(filesToCopy...)
+	if err != nil {
+		return err
+	}
+
+	paths := dirs
+	for _, fileToCopy := range filesToCopy {
+		paths = append(paths, fileToCopy.Dest)
+	}
+
+	err = setFileOwnership(paths, fileConfig, imageChroot)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// additionalDirFilesToCopy lists the directories and files under a source directory, with their destination paths.
+func additionalDirFilesToCopy(sourceDir string, destDir string, permissions *fs.FileMode,
+) ([]string, []safechroot.FileToCopy, error) {
+	var dirs []string
+	var filesToCopy []safechroot.FileToCopy
+
+	err := filepath.WalkDir(sourceDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(sourceDir, path)
+		if err != nil {
+			return err
+		}
+
+		destPath := filepath.Join(destDir, relPath)
+
+		switch {
+		case d.IsDir():
+			dirs = append(dirs, destPath)
+
+		case d.Type().IsRegular():
+			filesToCopy = append(filesToCopy, safechroot.FileToCopy{
+				Src:         path,
+				Dest:        destPath,
+				Permissions: permissions,
+			})
+
+		default:
+			return fmt.Errorf("unsupported file type (%s): only regular files and directories may be copied",
+				relPath)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return dirs, filesToCopy, nil
+}
+
+// setFileCapabilities sets the Linux capabilities of a file using the image's setcap.
+// File capabilities are stored in the security.capability extended attribute. So, if the file system doesn't support
+// extended attributes, then a warning is logged instead of failing the build.
+func setFileCapabilities(path string, capabilities string, imageChroot *safechroot.Chroot) error {
+	logger.Log.Infof("Setting capabilities (%s) on (%s)", capabilities, path)
+
+	setcapExists, err := pathExistsInRoot(imageChroot.RootDir(), setcapBinary)
+	if err != nil {
+		return err
+	}
+
+	if !setcapExists {
+		return fmt.Errorf("failed to set capabilities on (%s): the image does not contain %s (is libcap installed?)",
+			path, setcapBinary)
+	}
+
+	var stderr string
+	err = imageChroot.UnsafeRun(func() error {
+		var err error
+		_, stderr, err = shell.Execute(setcapBinary, capabilities, path)
+		return err
+	})
+	if err != nil {
+		if strings.Contains(stderr, "Operation not supported") {
+			logger.Log.Warnf("Capabilities (%s) were NOT set on (%s): the file system does not support extended attributes",
+				capabilities, path)
+			return nil
 		}
+
+		return fmt.Errorf("failed to set capabilities (%s) on (%s):\n%s\n%w", capabilities, path,
+			strings.TrimSpace(stderr), err)
+	}
+
+	return nil
+}
+
+// normalizeLineEndings converts the Windows (CRLF) line endings of a text file to Unix (LF) line endings.
+// Binary files are left unchanged.
+func normalizeLineEndings(filePath string) error {
+	contents, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	// Like git, treat files that contain a NUL byte as binary.
+	if bytes.IndexByte(contents, 0) >= 0 {
+		logger.Log.Warnf("Skipping line ending normalization of binary file (%s)", filePath)
+		return nil
+	}
+
+	normalized := bytes.ReplaceAll(contents, []byte("\r\n"), []byte("\n"))
+	if len(normalized) == len(contents) {
+		return nil
+	}
+
+	// Note: WriteFile keeps the existing file's permissions.
+	err = os.WriteFile(filePath, normalized, 0)
+	if err != nil {
+		return err
 	}
 
 	return nil
@@ -235,7 +729,7 @@ func addOrUpdateUser(user imagecustomizerapi.User, baseConfigPath string, imageC
 
 	logger.Log.Infof("Adding/updating user (%s)", user.Name)
 
-	password := user.Password
+	password := string(user.Password)
 	if user.PasswordPath != "" {
 		// Read password from file.
 		passwordFullPath := filepath.Join(baseConfigPath, user.PasswordPath)
@@ -284,7 +778,7 @@ func addOrUpdateUser(user imagecustomizerapi.User, baseConfigPath string, imageC
 
 	// Set user's password expiry.
 	if user.PasswordExpiresDays != nil {
-		err = installutils.Chage(imageChroot, *user.PasswordExpiresDays, user.Name)
+		err = userutils.ChageInChroot(*user.PasswordExpiresDays, user.Name, imageChroot.RootDir())
 		if err != nil {
 			return err
 		}
@@ -310,6 +804,11 @@ func addOrUpdateUser(user imagecustomizerapi.User, baseConfigPath string, imageC
 	}
 
 	// Set user's startup command.
+	err = checkUserStartupCommandExists(user, imageChroot.RootDir())
+	if err != nil {
+		return err
+	}
+
 	err = installutils.ConfigureUserStartupCommand(imageChroot, user.Name, user.StartupCommand)
 	if err != nil {
 		return err
@@ -318,36 +817,169 @@ func addOrUpdateUser(user imagecustomizerapi.User, baseConfigPath string, imageC
 	return nil
 }
 
+// checkUserStartupCommandExists logs a warning if the user's startup command (i.e. login shell) is missing from the
+// image, since the user won't be able to log in.
+func checkUserStartupCommandExists(user imagecustomizerapi.User, rootDir string) error {
+	if user.StartupCommand == "" || !filepath.IsAbs(user.StartupCommand) {
+		return nil
+	}
+
+	exists, err := pathExistsInRoot(rootDir, user.StartupCommand)
+	if err != nil {
+		return fmt.Errorf("failed to check if user's (%s) startup command (%s) exists:\n%w", user.Name,
+			user.StartupCommand, err)
+	}
+
+	if !exists {
+		logger.Log.Warnf("User's (%s) startup command (%s) does not exist in the image, so the user may not be able to log in%s",
+			user.Name, user.StartupCommand, userShellPackageHint(user.StartupCommand))
+	}
+
+	return nil
+}
+
+// warnUserShellPackagesRemoved logs a warning for each user whose startup command (i.e. login shell) is provided by a
+// package that is being removed.
+//
+// Note: This must be called after 'validatePackageLists' has merged the package list files into the inline package
+// lists.
+func warnUserShellPackagesRemoved(config *imagecustomizerapi.SystemConfig) {
+	for _, user := range config.Users {
+		if user.StartupCommand == "" {
+			continue
+		}
+
+		shellPackage, found := userShellPackages[filepath.Base(user.StartupCommand)]
+		if !found {
+			continue
+		}
+
+		if sliceutils.ContainsValue(config.PackagesRemove, shellPackage) &&
+			!sliceutils.ContainsValue(config.PackagesInstall, shellPackage) {
+			logger.Log.Warnf("User's (%s) startup command (%s) is provided by the (%s) package, which is being removed",
+				user.Name, user.StartupCommand, shellPackage)
+		}
+	}
+}
+
+func userShellPackageHint(startupCommand string) string {
+	shellPackage, found := userShellPackages[filepath.Base(startupCommand)]
+	if !found {
+		return ""
+	}
+
+	return fmt.Sprintf(" (is the %s package installed?)", shellPackage)
+}
+
 func enableOrDisableServices(services imagecustomizerapi.Services, imageChroot *safechroot.Chroot) error {
 	var err error
 
+	changedServices := []string(nil)
+
 	// Handle enabling services
 	for _, service := range services.Enable {
 		logger.Log.Infof("Enabling service (%s)", service.Name)
 
+		// Note: An error here is ignored since 'systemctl enable' will report a more useful error if the unit
+		// doesn't exist.
+		previousState, _ := getServiceEnabledState(service.Name, imageChroot)
+
 		err = imageChroot.UnsafeRun(func() error {
 			return shell.ExecuteLiveWithErr(1, "systemctl", "enable", service.Name)
 		})
 		if err != nil {
 			return fmt.Errorf("failed to enable service (%s):\n%w", service.Name, err)
 		}
+
+		err = verifyServiceEnabled(service.Name, imageChroot)
+		if err != nil {
+			return err
+		}
+
+		if !serviceEnabledStateIsEnabled(previousState) {
+			changedServices = append(changedServices, service.Name)
+		}
 	}
 
 	// Handle disabling services
 	for _, service := range services.Disable {
 		logger.Log.Infof("Disabling service (%s)", service.Name)
 
+		previousState, _ := getServiceEnabledState(service.Name, imageChroot)
+
 		err = imageChroot.UnsafeRun(func() error {
 			return shell.ExecuteLiveWithErr(1, "systemctl", "disable", service.Name)
 		})
 		if err != nil {
 			return fmt.Errorf("failed to disable service (%s):\n%w", service.Name, err)
 		}
+
+		if serviceEnabledStateIsEnabled(previousState) {
+			changedServices = append(changedServices, service.Name)
+		}
+	}
+
+	if len(services.Enable) > 0 || len(services.Disable) > 0 {
+		logger.Log.Infof("Services changed: %s", formatServicesList(changedServices))
 	}
 
 	return nil
 }
 
+func formatServicesList(services []string) string {
+	if len(services) <= 0 {
+		return "(none)"
+	}
+
+	return strings.Join(services, ", ")
+}
+
+// verifyServiceEnabled checks that 'systemctl enable' actually enabled the service.
+// 'systemctl enable' succeeds without doing anything for units that can't be enabled (e.g. units without an [Install]
+// section).
+func verifyServiceEnabled(serviceName string, imageChroot *safechroot.Chroot) error {
+	state, err := getServiceEnabledState(serviceName, imageChroot)
+	if err != nil {
+		return err
+	}
+
+	if !serviceEnabledStateIsEnabled(state) {
+		return fmt.Errorf("service (%s) was not enabled (state: %s): the unit might not have an [Install] section",
+			serviceName, state)
+	}
+
+	return nil
+}
+
+// getServiceEnabledState returns the output of 'systemctl is-enabled' for the service.
+func getServiceEnabledState(serviceName string, imageChroot *safechroot.Chroot) (string, error) {
+	var stdout string
+	err := imageChroot.UnsafeRun(func() error {
+		var err error
+		// Note: 'systemctl is-enabled' returns a non-zero exit code for many of the states. So, the state is
+		// checked instead of the exit code.
+		stdout, _, err = shell.Execute("systemctl", "is-enabled", serviceName)
+		return err
+	})
+
+	state := strings.TrimSpace(stdout)
+	if state == "" {
+		return "", fmt.Errorf("failed to check if service (%s) is enabled:\n%w", serviceName, err)
+	}
+
+	return state, nil
+}
+
+func serviceEnabledStateIsEnabled(state string) bool {
+	switch state {
+	case "enabled", "enabled-runtime", "alias":
+		return true
+
+	default:
+		return false
+	}
+}
+
 func loadOrDisableModules(modules imagecustomizerapi.Modules, imageChroot *safechroot.Chroot) error {
 	var err error
 
@@ -355,10 +987,18 @@ func loadOrDisableModules(modules imagecustomizerapi.Modules, imageChroot *safec
 		logger.Log.Infof("Loading kernel module (%s)", module.Name)
 		moduleFileName := module.Name + ".conf"
 		moduleFilePath := filepath.Join(imageChroot.RootDir(), "/etc/modules-load.d/", moduleFileName)
-		err = file.Write(module.Name, moduleFilePath)
+		err = file.Write(module.Name+"\n", moduleFilePath)
 		if err != nil {
 			return fmt.Errorf("failed to write module load configuration: %w", err)
 		}
+
+		if len(module.Options) > 0 {
+			moduleOptionsFilePath := filepath.Join(imageChroot.RootDir(), "/etc/modprobe.d/", moduleFileName)
+			err = file.Write(moduleOptionsLine(module.Name, module.Options), moduleOptionsFilePath)
+			if err != nil {
+				return fmt.Errorf("failed to write module options configuration: %w", err)
+			}
+		}
 	}
 
 	for _, module := range modules.Disable {
@@ -375,6 +1015,29 @@ func loadOrDisableModules(modules imagecustomizerapi.Modules, imageChroot *safec
 	return nil
 }
 
+// moduleOptionsLine returns the modprobe.d(5) "options" line for a module.
+// The options are sorted by name, so that the output is deterministic.
+func moduleOptionsLine(moduleName string, options map[string]string) string {
+	optionNames := make([]string, 0, len(options))
+	for name := range options {
+		optionNames = append(optionNames, name)
+	}
+	sort.Strings(optionNames)
+
+	line := "options " + moduleName
+	for _, name := range optionNames {
+		value := options[name]
+		if value == "" || strings.ContainsAny(value, " \t") {
+			// Values containing whitespace must be quoted.
+			value = "\"" + value + "\""
+		}
+
+		line += " " + name + "=" + value
+	}
+
+	return line + "\n"
+}
+
 func addCustomizerRelease(imageChroot *safechroot.Chroot, toolVersion string, buildTime string) error {
 	var err error
 
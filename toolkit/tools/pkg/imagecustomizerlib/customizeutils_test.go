@@ -5,6 +5,7 @@ package imagecustomizerlib
 
 import (
 	"bufio"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
@@ -43,6 +44,197 @@ func TestUpdateHostname(t *testing.T) {
 	assert.Equal(t, expectedHostname, string(actualHostname))
 }
 
+func TestUpdateTimezone(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("Test must be run as root because it uses a chroot")
+	}
+
+	// Setup environment.
+	proposedDir := filepath.Join(tmpDir, "TestUpdateTimezone")
+	chroot := safechroot.NewChroot(proposedDir, false)
+	err := chroot.Initialize("", []string{}, []*safechroot.MountPoint{}, false)
+	assert.NoError(t, err)
+	defer chroot.Close(false)
+
+	zoneinfoDir := filepath.Join(chroot.RootDir(), "usr/share/zoneinfo/America")
+	err = os.MkdirAll(zoneinfoDir, os.ModePerm)
+	assert.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(zoneinfoDir, "Los_Angeles"), []byte("TZif"), 0o644)
+	assert.NoError(t, err)
+
+	err = os.MkdirAll(filepath.Join(chroot.RootDir(), "etc"), os.ModePerm)
+	assert.NoError(t, err)
+
+	// Simulate the base image's existing timezone.
+	err = os.Symlink("../usr/share/zoneinfo/UTC", filepath.Join(chroot.RootDir(), "etc/localtime"))
+	assert.NoError(t, err)
+
+	// Set timezone.
+	err = updateTimezone("America/Los_Angeles", chroot)
+	assert.NoError(t, err)
+
+	// Ensure timezone was correctly set.
+	localtimeTarget, err := os.Readlink(filepath.Join(chroot.RootDir(), "etc/localtime"))
+	assert.NoError(t, err)
+	assert.Equal(t, "../usr/share/zoneinfo/America/Los_Angeles", localtimeTarget)
+
+	timezoneFile, err := os.ReadFile(filepath.Join(chroot.RootDir(), "etc/timezone"))
+	assert.NoError(t, err)
+	assert.Equal(t, "America/Los_Angeles\n", string(timezoneFile))
+
+	// A timezone that isn't in the image's zoneinfo database.
+	err = updateTimezone("Europe/London", chroot)
+	assert.ErrorContains(t, err, "timezone (Europe/London) not found in the image")
+}
+
+func TestUpdateLocale(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("Test must be run as root because it uses a chroot")
+	}
+
+	// Setup environment.
+	proposedDir := filepath.Join(tmpDir, "TestUpdateLocale")
+	chroot := safechroot.NewChroot(proposedDir, false)
+	err := chroot.Initialize("", []string{}, []*safechroot.MountPoint{}, false)
+	assert.NoError(t, err)
+	defer chroot.Close(false)
+
+	err = os.MkdirAll(filepath.Join(chroot.RootDir(), "etc"), os.ModePerm)
+	assert.NoError(t, err)
+
+	// Set locale.
+	// Note: The chroot doesn't have locale-gen. So, only the locale.conf file is written.
+	err = updateLocale("en_US.UTF-8", chroot)
+	assert.NoError(t, err)
+
+	// Ensure locale was correctly set.
+	localeConf, err := os.ReadFile(filepath.Join(chroot.RootDir(), "etc/locale.conf"))
+	assert.NoError(t, err)
+	assert.Equal(t, "LANG=en_US.UTF-8\n", string(localeConf))
+}
+
+func TestRemoveFiles(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("Test must be run as root because it uses a chroot")
+	}
+
+	// Setup environment.
+	proposedDir := filepath.Join(tmpDir, "TestRemoveFiles")
+	chroot := safechroot.NewChroot(proposedDir, false)
+	err := chroot.Initialize("", []string{}, []*safechroot.MountPoint{}, false)
+	assert.NoError(t, err)
+	defer chroot.Close(false)
+
+	err = os.MkdirAll(filepath.Join(chroot.RootDir(), "usr/share/doc/bash"), os.ModePerm)
+	assert.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(chroot.RootDir(), "usr/share/doc/bash/README"), []byte("bash"), 0o644)
+	assert.NoError(t, err)
+
+	err = os.MkdirAll(filepath.Join(chroot.RootDir(), "etc"), os.ModePerm)
+	assert.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(chroot.RootDir(), "etc/motd"), []byte("hello"), 0o644)
+	assert.NoError(t, err)
+
+	// A file outside of the chroot that an (absolute) symlink in the image would point to, if it were followed on the
+	// host.
+	outsideFile := filepath.Join(tmpDir, "TestRemoveFilesOutside")
+	err = os.WriteFile(outsideFile, []byte("outside"), 0o644)
+	assert.NoError(t, err)
+	defer os.Remove(outsideFile)
+
+	err = os.Symlink(filepath.Dir(outsideFile), filepath.Join(chroot.RootDir(), "escape"))
+	assert.NoError(t, err)
+
+	err = removeFiles([]string{"/etc/motd", "/usr/share/doc", "/var/missing", "/escape/TestRemoveFilesOutside"},
+		chroot)
+	assert.NoError(t, err)
+
+	assert.NoFileExists(t, filepath.Join(chroot.RootDir(), "etc/motd"))
+	assert.NoDirExists(t, filepath.Join(chroot.RootDir(), "usr/share/doc"))
+	assert.DirExists(t, filepath.Join(chroot.RootDir(), "usr/share"))
+	assert.FileExists(t, outsideFile)
+}
+
+func TestCreateSymlinks(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("Test must be run as root because it uses a chroot")
+	}
+
+	// Setup environment.
+	proposedDir := filepath.Join(tmpDir, "TestCreateSymlinks")
+	chroot := safechroot.NewChroot(proposedDir, false)
+	err := chroot.Initialize("", []string{}, []*safechroot.MountPoint{}, false)
+	assert.NoError(t, err)
+	defer chroot.Close(false)
+
+	err = os.MkdirAll(filepath.Join(chroot.RootDir(), "usr/bin"), os.ModePerm)
+	assert.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(chroot.RootDir(), "usr/bin/python"), []byte("python2"), 0o755)
+	assert.NoError(t, err)
+
+	symlinks := map[string]string{
+		"/usr/bin/python":        "python3",
+		"/usr/local/bin/python3": "/usr/bin/python3",
+	}
+
+	// An existing file isn't replaced, unless requested.
+	err = createSymlinks(symlinks, false, chroot)
+	assert.ErrorContains(t, err, "failed to create symlink (/usr/bin/python)")
+	assert.ErrorContains(t, err, "file already exists")
+
+	err = createSymlinks(symlinks, true, chroot)
+	assert.NoError(t, err)
+
+	target, err := os.Readlink(filepath.Join(chroot.RootDir(), "usr/bin/python"))
+	assert.NoError(t, err)
+	assert.Equal(t, "python3", target)
+
+	target, err = os.Readlink(filepath.Join(chroot.RootDir(), "usr/local/bin/python3"))
+	assert.NoError(t, err)
+	assert.Equal(t, "/usr/bin/python3", target)
+
+	// Directories are never replaced.
+	err = createSymlinks(map[string]string{"/usr/bin": "/bin"}, true, chroot)
+	assert.ErrorContains(t, err, "a directory already exists at the link path")
+}
+
+func TestUpdateUmask(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("Test must be run as root because it uses a chroot")
+	}
+
+	// Setup environment.
+	proposedDir := filepath.Join(tmpDir, "TestUpdateUmask")
+	chroot := safechroot.NewChroot(proposedDir, false)
+	err := chroot.Initialize("", []string{}, []*safechroot.MountPoint{}, false)
+	assert.NoError(t, err)
+	defer chroot.Close(false)
+
+	err = os.MkdirAll(filepath.Join(chroot.RootDir(), "etc"), os.ModePerm)
+	assert.NoError(t, err)
+
+	loginDefsFilePath := filepath.Join(chroot.RootDir(), "etc/login.defs")
+	err = os.WriteFile(loginDefsFilePath, []byte("PASS_MAX_DAYS\t99999\nUMASK\t\t022\nUSERGROUPS_ENAB yes\n"), 0o644)
+	assert.NoError(t, err)
+
+	// Set umask.
+	err = updateUmask(ptrutils.PtrTo(imagecustomizerapi.Umask(0o027)), chroot)
+	assert.NoError(t, err)
+
+	// Ensure the umask was correctly set.
+	actualLoginDefs, err := os.ReadFile(loginDefsFilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, "PASS_MAX_DAYS\t99999\nUMASK\t\t027\nUSERGROUPS_ENAB yes\n", string(actualLoginDefs))
+
+	actualProfileScript, err := os.ReadFile(filepath.Join(chroot.RootDir(), "etc/profile.d/umask.sh"))
+	assert.NoError(t, err)
+	assert.Equal(t, "umask 027\n", string(actualProfileScript))
+}
+
 func TestCopyAdditionalFiles(t *testing.T) {
 	if os.Geteuid() != 0 {
 		t.Skip("Test must be run as root because it uses a chroot")
@@ -102,6 +294,47 @@ func TestCopyAdditionalFiles(t *testing.T) {
 	assert.Equal(t, orig_contents, copy_2_contents)
 }
 
+func TestNormalizeLineEndings(t *testing.T) {
+	testTmpDir := filepath.Join(tmpDir, "TestNormalizeLineEndings")
+	defer os.RemoveAll(testTmpDir)
+
+	err := os.MkdirAll(testTmpDir, os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	textFilePath := filepath.Join(testTmpDir, "script.sh")
+	err = os.WriteFile(textFilePath, []byte("#!/bin/sh\r\necho hello\r\n"), 0o755)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	binaryContents := []byte("\x7fELF\x00\r\n")
+	binaryFilePath := filepath.Join(testTmpDir, "binary")
+	err = os.WriteFile(binaryFilePath, binaryContents, 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = normalizeLineEndings(textFilePath)
+	assert.NoError(t, err)
+
+	err = normalizeLineEndings(binaryFilePath)
+	assert.NoError(t, err)
+
+	textContents, err := os.ReadFile(textFilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, "#!/bin/sh\necho hello\n", string(textContents))
+
+	textStat, err := os.Stat(textFilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o755), textStat.Mode()&os.ModePerm)
+
+	actualBinaryContents, err := os.ReadFile(binaryFilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, binaryContents, actualBinaryContents)
+}
+
 func TestAddCustomizerRelease(t *testing.T) {
 	if os.Geteuid() != 0 {
 		t.Skip("Test must be run as root because it uses a chroot")
@@ -145,3 +378,107 @@ func TestAddCustomizerRelease(t *testing.T) {
 	assert.Equal(t, expectedVersion, config["TOOL_VERSION"])
 	assert.Equal(t, expectedDate, config["BUILD_DATE"])
 }
+
+func TestCheckUserStartupCommandExists(t *testing.T) {
+	rootDir := filepath.Join(tmpDir, "TestCheckUserStartupCommandExists")
+
+	err := os.MkdirAll(filepath.Join(rootDir, "usr/bin"), os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = os.WriteFile(filepath.Join(rootDir, "usr/bin/bash"), []byte{}, 0o755)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = os.Symlink("usr/bin", filepath.Join(rootDir, "bin"))
+	if err != nil && !os.IsExist(err) {
+		assert.NoError(t, err)
+		return
+	}
+
+	// A missing startup command only produces a warning.
+	for _, startupCommand := range []string{"/bin/bash", "/bin/zsh", "bash", ""} {
+		user := imagecustomizerapi.User{
+			Name:           "test",
+			StartupCommand: startupCommand,
+		}
+
+		err = checkUserStartupCommandExists(user, rootDir)
+		assert.NoError(t, err, startupCommand)
+	}
+}
+
+func TestUserShellPackageHint(t *testing.T) {
+	assert.Equal(t, " (is the zsh package installed?)", userShellPackageHint("/usr/bin/zsh"))
+	assert.Equal(t, " (is the tcsh package installed?)", userShellPackageHint("/bin/csh"))
+	assert.Equal(t, "", userShellPackageHint("/usr/local/bin/custom-shell"))
+}
+
+func TestServiceEnabledStateIsEnabled(t *testing.T) {
+	assert.True(t, serviceEnabledStateIsEnabled("enabled"))
+	assert.True(t, serviceEnabledStateIsEnabled("enabled-runtime"))
+	assert.True(t, serviceEnabledStateIsEnabled("alias"))
+	assert.False(t, serviceEnabledStateIsEnabled("static"))
+	assert.False(t, serviceEnabledStateIsEnabled("indirect"))
+	assert.False(t, serviceEnabledStateIsEnabled("disabled"))
+	assert.False(t, serviceEnabledStateIsEnabled("masked"))
+}
+
+func TestFormatServicesList(t *testing.T) {
+	assert.Equal(t, "(none)", formatServicesList(nil))
+	assert.Equal(t, "sshd", formatServicesList([]string{"sshd"}))
+	assert.Equal(t, "sshd, chronyd", formatServicesList([]string{"sshd", "chronyd"}))
+}
+
+func TestModuleOptionsLine(t *testing.T) {
+	assert.Equal(t, "options nf_conntrack nf_conntrack_helper=1\n",
+		moduleOptionsLine("nf_conntrack", map[string]string{"nf_conntrack_helper": "1"}))
+	assert.Equal(t, "options mlx5_core debug_mask=0x1 prof_sel=2\n",
+		moduleOptionsLine("mlx5_core", map[string]string{"prof_sel": "2", "debug_mask": "0x1"}))
+	assert.Equal(t, "options dummy empty=\"\" name=\"a b\"\n",
+		moduleOptionsLine("dummy", map[string]string{"name": "a b", "empty": ""}))
+}
+
+func TestAdditionalDirFilesToCopy(t *testing.T) {
+	sourceDir := filepath.Join(tmpDir, "TestAdditionalDirFilesToCopy")
+	err := os.RemoveAll(sourceDir)
+	assert.NoError(t, err)
+
+	err = os.MkdirAll(filepath.Join(sourceDir, "conf.d"), os.ModePerm)
+	assert.NoError(t, err)
+
+	err = os.MkdirAll(filepath.Join(sourceDir, "empty"), os.ModePerm)
+	assert.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(sourceDir, "app.conf"), []byte("a"), 0o644)
+	assert.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(sourceDir, "conf.d/10-b.conf"), []byte("b"), 0o644)
+	assert.NoError(t, err)
+
+	permissions := fs.FileMode(0o600)
+	dirs, filesToCopy, err := additionalDirFilesToCopy(sourceDir, "/etc/app", &permissions)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/etc/app", "/etc/app/conf.d", "/etc/app/empty"}, dirs)
+	assert.Equal(t, []safechroot.FileToCopy{
+		{
+			Src:         filepath.Join(sourceDir, "app.conf"),
+			Dest:        "/etc/app/app.conf",
+			Permissions: &permissions,
+		},
+		{
+			Src:         filepath.Join(sourceDir, "conf.d/10-b.conf"),
+			Dest:        "/etc/app/conf.d/10-b.conf",
+			Permissions: &permissions,
+		},
+	}, filesToCopy)
+
+	// Symlinks aren't supported.
+	err = os.Symlink("app.conf", filepath.Join(sourceDir, "link.conf"))
+	assert.NoError(t, err)
+
+	_, _, err = additionalDirFilesToCopy(sourceDir, "/etc/app", nil)
+	assert.ErrorContains(t, err, "unsupported file type (link.conf)")
+}
@@ -14,9 +14,15 @@ import (
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagegen/diskutils"
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/file"
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/safechroot"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/safemount"
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/shell"
 )
 
+const (
+	veritytabPath       = "/etc/veritytab"
+	veritytabFieldCount = 5
+)
+
 func enableVerityPartition(verity *imagecustomizerapi.Verity, imageChroot *safechroot.Chroot) error {
 	var err error
 
@@ -24,6 +30,14 @@ func enableVerityPartition(verity *imagecustomizerapi.Verity, imageChroot *safec
 		return nil
 	}
 
+	if verity.GetMountPoint() == imagecustomizerapi.VerityMountPointUsr {
+		// The rootfs is still writable when /usr is protected. So, the fstab file can be pointed at the verity device.
+		err = updateFstabForVerityUsr(imageChroot)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Integrate systemd veritysetup dracut module into initramfs img.
 	systemdVerityDracutModule := "systemd-veritysetup"
 	err = buildDracutModule(systemdVerityDracutModule, imageChroot)
@@ -131,7 +145,91 @@ func updateMarinerCfgWithInitramfs(imageChroot *safechroot.Chroot) error {
 	return nil
 }
 
-func updateGrubConfig(dataPartitionIdType imagecustomizerapi.IdType, dataPartitionId string,
+// updateFstabForVerityUsr changes the /usr entry of the fstab file to use the verity device.
+func updateFstabForVerityUsr(imageChroot *safechroot.Chroot) error {
+	fstabPath := filepath.Join(imageChroot.RootDir(), "etc/fstab")
+
+	lines, err := file.ReadLines(fstabPath)
+	if err != nil {
+		return fmt.Errorf("failed to read fstab file:\n%w", err)
+	}
+
+	lines, err = setFstabEntrySource(lines, imagecustomizerapi.VerityMountPointUsr,
+		verityDevicePath(imagecustomizerapi.VerityMountPointUsr), "ro")
+	if err != nil {
+		return err
+	}
+
+	err = file.WriteLines(lines, fstabPath)
+	if err != nil {
+		return fmt.Errorf("failed to write fstab file:\n%w", err)
+	}
+
+	return nil
+}
+
+// setFstabEntrySource changes the source of the fstab entry with the specified target and adds an option to it.
+func setFstabEntrySource(lines []string, target string, source string, option string) ([]string, error) {
+	found := false
+	updatedLines := []string(nil)
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) >= 4 && !strings.HasPrefix(fields[0], "#") && fields[1] == target {
+			fields[0] = source
+
+			options := []string(nil)
+			for _, existingOption := range strings.Split(fields[3], ",") {
+				if existingOption != "rw" && existingOption != option && existingOption != "defaults" {
+					options = append(options, existingOption)
+				}
+			}
+			fields[3] = strings.Join(append(options, option), ",")
+
+			line = strings.Join(fields, " ")
+			found = true
+		}
+
+		updatedLines = append(updatedLines, line)
+	}
+
+	if !found {
+		return nil, fmt.Errorf("fstab file has no entry for (%s)", target)
+	}
+
+	return updatedLines, nil
+}
+
+// verityDeviceName returns the name of the device-mapper device that systemd creates for the verity mount point.
+func verityDeviceName(mountPoint string) string {
+	if mountPoint == imagecustomizerapi.VerityMountPointUsr {
+		return "usr"
+	}
+	return "root"
+}
+
+func verityDevicePath(mountPoint string) string {
+	return "/dev/mapper/" + verityDeviceName(mountPoint)
+}
+
+// verityKernelArgs returns the kernel command line args that the systemd-veritysetup-generator uses to set up the
+// verity device for the mount point.
+func verityKernelArgs(mountPoint string, dataPartition string, hashPartition string, rootHash string) string {
+	name := verityDeviceName(mountPoint)
+
+	args := fmt.Sprintf(
+		"rd.systemd.verity=1 %shash=%s systemd.verity_%s_data=%s systemd.verity_%s_hash=%s systemd.verity_%s_options=panic-on-corruption",
+		name, rootHash, name, dataPartition, name, hashPartition, name,
+	)
+
+	if mountPoint == imagecustomizerapi.VerityMountPointUsr {
+		// Have the initramfs mount /usr, so that it is available when the system switches to the rootfs.
+		args += fmt.Sprintf(" mount.usr=%s mount.usrflags=ro", verityDevicePath(mountPoint))
+	}
+
+	return args
+}
+
+func updateGrubConfig(mountPoint string, dataPartitionIdType imagecustomizerapi.IdType, dataPartitionId string,
 	hashPartitionIdType imagecustomizerapi.IdType, hashPartitionId string, rootHash string, grubCfgFullPath string,
 ) error {
 	var err error
@@ -146,10 +244,7 @@ func updateGrubConfig(dataPartitionIdType imagecustomizerapi.IdType, dataPartiti
 		return err
 	}
 
-	newArgs := fmt.Sprintf(
-		"rd.systemd.verity=1 roothash=%s systemd.verity_root_data=%s systemd.verity_root_hash=%s systemd.verity_root_options=panic-on-corruption",
-		rootHash, formattedDataPartition, formattedHashPartition,
-	)
+	newArgs := verityKernelArgs(mountPoint, formattedDataPartition, formattedHashPartition, rootHash)
 
 	// Read grub.cfg using the internal method
 	lines, err := file.ReadLines(grubCfgFullPath)
@@ -164,17 +259,20 @@ func updateGrubConfig(dataPartitionIdType imagecustomizerapi.IdType, dataPartiti
 		if linuxLineRegex.MatchString(trimmedLine) {
 			// Replace existing arguments
 			verityRegexPattern := `rd.systemd.verity=(1|0)` +
-				`( roothash=[^ ]*)?` +
-				`( systemd.verity_root_data=[^ ]*)?` +
-				`( systemd.verity_root_hash=[^ ]*)?` +
-				`( systemd.verity_root_options=[^ ]*)?`
+				`( (root|usr)hash=[^ ]*)?` +
+				`( systemd.verity_(root|usr)_data=[^ ]*)?` +
+				`( systemd.verity_(root|usr)_hash=[^ ]*)?` +
+				`( systemd.verity_(root|usr)_options=[^ ]*)?` +
+				`( mount.usr=[^ ]*)?` +
+				`( mount.usrflags=[^ ]*)?`
 			verityRegex := regexp.MustCompile(verityRegexPattern)
 			newLinuxLine := verityRegex.ReplaceAllString(trimmedLine, newArgs)
 			updatedLines = append(updatedLines, newLinuxLine)
 		} else if strings.HasPrefix(trimmedLine, "linux ") {
 			// Append new arguments
 			updatedLines = append(updatedLines, line+" "+newArgs)
-		} else if strings.HasPrefix(trimmedLine, "set rootdevice=PARTUUID=") {
+		} else if strings.HasPrefix(trimmedLine, "set rootdevice=PARTUUID=") &&
+			mountPoint == imagecustomizerapi.VerityMountPointRoot {
 			line = "set rootdevice=/dev/mapper/root"
 			updatedLines = append(updatedLines, line)
 		} else {
@@ -191,6 +289,122 @@ func updateGrubConfig(dataPartitionIdType imagecustomizerapi.IdType, dataPartiti
 	return nil
 }
 
+// addVeritytabEntryToRootfs adds the verity device's entry to the rootfs's /etc/veritytab file.
+//
+// Note: The rootfs can't contain its own verity entry, since the root hash depends on the rootfs's contents. So, this
+// is only used when the rootfs isn't the protected file system.
+func addVeritytabEntryToRootfs(verity *imagecustomizerapi.Verity, rootHash string,
+	rootfsPartition *diskutils.PartitionInfo, buildDir string,
+) error {
+	entry, err := veritytabEntryForConfig(verity, rootHash)
+	if err != nil {
+		return err
+	}
+
+	tmpDir := filepath.Join(buildDir, tmpParitionDirName)
+
+	rootfsPartitionMount, err := safemount.NewMount(rootfsPartition.Path, tmpDir, rootfsPartition.FileSystemType, 0,
+		"", true)
+	if err != nil {
+		return fmt.Errorf("failed to mount rootfs partition (%s):\n%w", rootfsPartition.Path, err)
+	}
+	defer rootfsPartitionMount.Close()
+
+	err = addVeritytabEntry(filepath.Join(tmpDir, veritytabPath), entry)
+	if err != nil {
+		return err
+	}
+
+	err = rootfsPartitionMount.CleanClose()
+	if err != nil {
+		return fmt.Errorf("failed to close rootfs partition mount (%s):\n%w", rootfsPartition.Path, err)
+	}
+
+	return nil
+}
+
+func veritytabEntryForConfig(verity *imagecustomizerapi.Verity, rootHash string) (veritytabLine, error) {
+	dataDevice, err := systemdFormatPartitionId(verity.DataPartition.IdType, verity.DataPartition.Id)
+	if err != nil {
+		return veritytabLine{}, fmt.Errorf("invalid verity data partition:\n%w", err)
+	}
+
+	hashDevice, err := systemdFormatPartitionId(verity.HashPartition.IdType, verity.HashPartition.Id)
+	if err != nil {
+		return veritytabLine{}, fmt.Errorf("invalid verity hash partition:\n%w", err)
+	}
+
+	entry := veritytabLine{
+		name:       verityDeviceName(verity.GetMountPoint()),
+		dataDevice: dataDevice,
+		hashDevice: hashDevice,
+		rootHash:   rootHash,
+		options:    "panic-on-corruption",
+	}
+	return entry, nil
+}
+
+// veritytabLine is an entry of the /etc/veritytab file (see veritytab(5)).
+type veritytabLine struct {
+	name       string
+	dataDevice string
+	hashDevice string
+	rootHash   string
+	options    string
+}
+
+// format formats the entry, checking that it has the expected number of fields.
+func (l veritytabLine) format() (string, error) {
+	fields := []string{l.name, l.dataDevice, l.hashDevice, l.rootHash, l.options}
+	for _, field := range fields {
+		if field == "" || strings.ContainsAny(field, " \t\n") {
+			return "", fmt.Errorf("invalid veritytab entry (%s): must have %d non-empty fields without whitespace",
+				strings.Join(fields, " "), veritytabFieldCount)
+		}
+	}
+
+	return strings.Join(fields, " "), nil
+}
+
+// addVeritytabEntry adds an entry to the veritytab file, replacing an existing entry with the same name.
+func addVeritytabEntry(veritytabFullPath string, entry veritytabLine) error {
+	newLine, err := entry.format()
+	if err != nil {
+		return err
+	}
+
+	var lines []string
+	exists, err := file.PathExists(veritytabFullPath)
+	if err != nil {
+		return fmt.Errorf("failed to check if veritytab file exists:\n%w", err)
+	}
+
+	if exists {
+		lines, err = file.ReadLines(veritytabFullPath)
+		if err != nil {
+			return fmt.Errorf("failed to read veritytab file:\n%w", err)
+		}
+	}
+
+	updatedLines := []string(nil)
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) > 0 && fields[0] == entry.name {
+			continue
+		}
+
+		updatedLines = append(updatedLines, line)
+	}
+	updatedLines = append(updatedLines, newLine)
+
+	err = file.WriteLines(updatedLines, veritytabFullPath)
+	if err != nil {
+		return fmt.Errorf("failed to write veritytab file:\n%w", err)
+	}
+
+	return nil
+}
+
 // idToPartitionBlockDevicePath returns the block device path for a given idType and id.
 func idToPartitionBlockDevicePath(idType imagecustomizerapi.IdType, id string, nbdDevice string, diskPartitions []diskutils.PartitionInfo) (string, error) {
 	// Iterate over each partition to find the matching id.
@@ -0,0 +1,137 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagecustomizerapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerityKernelArgsRoot(t *testing.T) {
+	args := verityKernelArgs("/", "PARTLABEL=root", "PARTLABEL=root-hash", "abc123")
+	assert.Equal(t, "rd.systemd.verity=1 roothash=abc123 systemd.verity_root_data=PARTLABEL=root "+
+		"systemd.verity_root_hash=PARTLABEL=root-hash systemd.verity_root_options=panic-on-corruption", args)
+}
+
+func TestVerityKernelArgsUsr(t *testing.T) {
+	args := verityKernelArgs("/usr", "PARTLABEL=usr", "PARTLABEL=usr-hash", "abc123")
+	assert.Equal(t, "rd.systemd.verity=1 usrhash=abc123 systemd.verity_usr_data=PARTLABEL=usr "+
+		"systemd.verity_usr_hash=PARTLABEL=usr-hash systemd.verity_usr_options=panic-on-corruption "+
+		"mount.usr=/dev/mapper/usr mount.usrflags=ro", args)
+}
+
+func TestUpdateGrubConfigUsr(t *testing.T) {
+	grubCfgPath := filepath.Join(tmpDir, "TestUpdateGrubConfigUsr.cfg")
+	grubCfg := "set rootdevice=PARTUUID=7b1367a6-5845-43f2-99b1-a742d873f590\n" +
+		"linux $bootprefix/$mariner_linux $kernelopts\n"
+
+	err := os.WriteFile(grubCfgPath, []byte(grubCfg), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = updateGrubConfig("/usr", imagecustomizerapi.IdTypePartLabel, "usr", imagecustomizerapi.IdTypePartLabel,
+		"usr-hash", "abc123", grubCfgPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// Running the update again should replace the existing args instead of adding them again.
+	err = updateGrubConfig("/usr", imagecustomizerapi.IdTypePartLabel, "usr", imagecustomizerapi.IdTypePartLabel,
+		"usr-hash", "def456", grubCfgPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	actual, err := os.ReadFile(grubCfgPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// The rootfs isn't protected. So, the root device is unchanged.
+	assert.Equal(t, "set rootdevice=PARTUUID=7b1367a6-5845-43f2-99b1-a742d873f590\n"+
+		"linux $bootprefix/$mariner_linux $kernelopts rd.systemd.verity=1 usrhash=def456 "+
+		"systemd.verity_usr_data=PARTLABEL=usr systemd.verity_usr_hash=PARTLABEL=usr-hash "+
+		"systemd.verity_usr_options=panic-on-corruption mount.usr=/dev/mapper/usr mount.usrflags=ro\n",
+		string(actual))
+}
+
+func TestSetFstabEntrySource(t *testing.T) {
+	lines := []string{
+		"# /usr was on a separate partition",
+		"PARTUUID=7b1367a6-5845-43f2-99b1-a742d873f590 / ext4 defaults 0 1",
+		"PARTUUID=a2dc5b7a-0fe1-4a5e-a4e4-5d7c1d4ef48a /usr ext4 rw,nodev 0 2",
+	}
+
+	updatedLines, err := setFstabEntrySource(lines, "/usr", "/dev/mapper/usr", "ro")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"# /usr was on a separate partition",
+		"PARTUUID=7b1367a6-5845-43f2-99b1-a742d873f590 / ext4 defaults 0 1",
+		"/dev/mapper/usr /usr ext4 nodev,ro 0 2",
+	}, updatedLines)
+
+	_, err = setFstabEntrySource(lines, "/var", "/dev/mapper/var", "ro")
+	assert.ErrorContains(t, err, "fstab file has no entry for (/var)")
+}
+
+func TestAddVeritytabEntry(t *testing.T) {
+	veritytabPath := filepath.Join(tmpDir, "TestAddVeritytabEntry")
+
+	err := os.WriteFile(veritytabPath, []byte("# comment\nusr PARTLABEL=old PARTLABEL=old-hash 000 panic-on-corruption\n"+
+		"data PARTLABEL=data PARTLABEL=data-hash 111 -\n"), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	verity := &imagecustomizerapi.Verity{
+		MountPoint:    imagecustomizerapi.VerityMountPointUsr,
+		DataPartition: imagecustomizerapi.VerityPartition{IdType: imagecustomizerapi.IdTypePartLabel, Id: "usr"},
+		HashPartition: imagecustomizerapi.VerityPartition{IdType: imagecustomizerapi.IdTypePartUuid,
+			Id: "7b1367a6-5845-43f2-99b1-a742d873f590"},
+	}
+
+	entry, err := veritytabEntryForConfig(verity, "abc123")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = addVeritytabEntry(veritytabPath, entry)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	actual, err := os.ReadFile(veritytabPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, "# comment\n"+
+		"data PARTLABEL=data PARTLABEL=data-hash 111 -\n"+
+		"usr PARTLABEL=usr PARTUUID=7b1367a6-5845-43f2-99b1-a742d873f590 abc123 panic-on-corruption\n",
+		string(actual))
+}
+
+func TestVeritytabLineFormatBadFieldCount(t *testing.T) {
+	entry := veritytabLine{
+		name:       "usr",
+		dataDevice: "PARTLABEL=usr",
+		hashDevice: "PARTLABEL=usr hash",
+		rootHash:   "abc123",
+		options:    "panic-on-corruption",
+	}
+
+	_, err := entry.format()
+	assert.ErrorContains(t, err, "must have 5 non-empty fields without whitespace")
+
+	entry.hashDevice = "PARTLABEL=usr-hash"
+	entry.rootHash = ""
+
+	_, err = entry.format()
+	assert.ErrorContains(t, err, "must have 5 non-empty fields without whitespace")
+}
@@ -0,0 +1,71 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagecustomizerapi"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/file"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/logger"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/safechroot"
+)
+
+const (
+	zramGeneratorPackage     = "zram-generator"
+	zramGeneratorConfigPath  = "/etc/systemd/zram-generator.conf"
+	zramSetupServiceTemplate = "systemd-zram-setup@.service"
+)
+
+// configureZram writes the zram-generator config file.
+// The zram-generator is a systemd generator and so it doesn't need to be enabled. It creates the zram device's swap
+// units during boot based on the config file.
+func configureZram(zram *imagecustomizerapi.Zram, imageChroot *safechroot.Chroot) error {
+	if zram == nil {
+		return nil
+	}
+
+	logger.Log.Infof("Configuring zram swap")
+
+	unitExists, err := systemdUnitExists(zramSetupServiceTemplate, imageChroot.RootDir())
+	if err != nil {
+		return err
+	}
+
+	if !unitExists {
+		return fmt.Errorf("zram config requires the %s unit but the image does not contain it (is %s installed?)",
+			zramSetupServiceTemplate, zramGeneratorPackage)
+	}
+
+	configPath := filepath.Join(imageChroot.RootDir(), zramGeneratorConfigPath)
+
+	err = os.MkdirAll(filepath.Dir(configPath), 0o755)
+	if err != nil {
+		return fmt.Errorf("failed to create zram-generator config directory:\n%w", err)
+	}
+
+	err = file.Write(zramGeneratorConfig(zram), configPath)
+	if err != nil {
+		return fmt.Errorf("failed to write zram-generator config file (%s):\n%w", zramGeneratorConfigPath, err)
+	}
+
+	return nil
+}
+
+func zramGeneratorConfig(zram *imagecustomizerapi.Zram) string {
+	config := "[zram0]\n"
+
+	// When a value isn't specified, the zram-generator's default is used.
+	if zram.Size != "" {
+		config += fmt.Sprintf("zram-size = %s\n", zram.Size)
+	}
+
+	if zram.CompressionAlgorithm != "" {
+		config += fmt.Sprintf("compression-algorithm = %s\n", zram.CompressionAlgorithm)
+	}
+
+	return config
+}
@@ -0,0 +1,37 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"testing"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagecustomizerapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestZramGeneratorConfig(t *testing.T) {
+	config := zramGeneratorConfig(&imagecustomizerapi.Zram{
+		Size:                 "ram / 2",
+		CompressionAlgorithm: "zstd",
+	})
+	assert.Equal(t, "[zram0]\nzram-size = ram / 2\ncompression-algorithm = zstd\n", config)
+}
+
+func TestZramGeneratorConfigDefaults(t *testing.T) {
+	config := zramGeneratorConfig(&imagecustomizerapi.Zram{})
+	assert.Equal(t, "[zram0]\n", config)
+}
+
+func TestValidatePackageListsZram(t *testing.T) {
+	config := &imagecustomizerapi.SystemConfig{
+		PackagesInstall: []string{"jq"},
+		Zram:            &imagecustomizerapi.Zram{},
+	}
+
+	err := validatePackageLists(testDir, config)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []string{"jq", "zram-generator"}, config.PackagesInstall)
+}
@@ -0,0 +1,44 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagecustomizerapi"
+)
+
+// DumpConfigWithConfigFiles reads, merges and validates a list of config files and then writes the resulting config
+// as YAML to writer. Secrets (e.g. user passwords) are redacted.
+//
+// The config files are processed the same way as CustomizeImageWithConfigFiles does. So, the output is the config
+// that would be used to customize the image.
+func DumpConfigWithConfigFiles(configFiles []string, listMergeStrategy imagecustomizerapi.ListMergeStrategy,
+	expandEnvVars bool, profile string, writer io.Writer,
+) error {
+	absBaseConfigPath, config, err := loadConfigFiles(configFiles, listMergeStrategy, expandEnvVars, profile)
+	if err != nil {
+		return err
+	}
+
+	// Note: Validation also merges the package list files into the inline package lists.
+	err = validateConfigContents(absBaseConfigPath, config)
+	if err != nil {
+		return &ConfigValidationError{Err: err}
+	}
+
+	// Note: The secrets (e.g. imagecustomizerapi.Password) mask themselves when they are serialized.
+	yamlData, err := imagecustomizerapi.MarshalYaml(config)
+	if err != nil {
+		return fmt.Errorf("failed to serialize config:\n%w", err)
+	}
+
+	_, err = writer.Write(yamlData)
+	if err != nil {
+		return fmt.Errorf("failed to write config:\n%w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagecustomizerapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDumpConfigWithConfigFiles(t *testing.T) {
+	testTmpDir := filepath.Join(tmpDir, "TestDumpConfigWithConfigFiles")
+	err := os.MkdirAll(testTmpDir, os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	configFile := filepath.Join(testTmpDir, "config.yaml")
+	configContents := `
+SystemConfig:
+  Hostname: testname
+  Users:
+  - Name: test
+    Password: supersecretpassword
+`
+	err = os.WriteFile(configFile, []byte(configContents), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var buffer bytes.Buffer
+	err = DumpConfigWithConfigFiles([]string{configFile}, imagecustomizerapi.ListMergeStrategyUnset, false, "",
+		&buffer)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	output := buffer.String()
+	assert.Contains(t, output, "Hostname: testname")
+	assert.Contains(t, output, "Password: <redacted>")
+	assert.NotContains(t, output, "supersecretpassword")
+}
+
+func TestDumpConfigWithConfigFilesInvalid(t *testing.T) {
+	configFile := filepath.Join(testDir, "does-not-exist.yaml")
+
+	var buffer bytes.Buffer
+	err := DumpConfigWithConfigFiles([]string{configFile}, imagecustomizerapi.ListMergeStrategyUnset, false, "",
+		&buffer)
+	assert.Error(t, err)
+	assert.Empty(t, buffer.String())
+}
@@ -0,0 +1,61 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+// The error types returned by the image customizer, so that callers can use `errors.As` to branch on the category of
+// a failure. Each type wraps the underlying error and uses the underlying error's message.
+
+// ConfigValidationError is returned when the config is invalid (e.g. a config file can't be parsed or references a
+// file that doesn't exist).
+type ConfigValidationError struct {
+	Err error
+}
+
+func (e *ConfigValidationError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ConfigValidationError) Unwrap() error {
+	return e.Err
+}
+
+// PackageInstallError is returned when the packages can't be resolved, installed, updated, or removed.
+type PackageInstallError struct {
+	Err error
+}
+
+func (e *PackageInstallError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PackageInstallError) Unwrap() error {
+	return e.Err
+}
+
+// MountError is returned when the image's disk or filesystems can't be mounted.
+type MountError struct {
+	Err error
+}
+
+func (e *MountError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *MountError) Unwrap() error {
+	return e.Err
+}
+
+// PartitionDiscoveryError is returned when the partitions of the base image (e.g. the rootfs partition) can't be
+// found.
+type PartitionDiscoveryError struct {
+	Err error
+}
+
+func (e *PartitionDiscoveryError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PartitionDiscoveryError) Unwrap() error {
+	return e.Err
+}
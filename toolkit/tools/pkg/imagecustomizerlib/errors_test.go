@@ -0,0 +1,50 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagecustomizerapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorTypesPreserveMessage(t *testing.T) {
+	cause := os.ErrNotExist
+	err := fmt.Errorf("failed to customize image:\n%w", &PartitionDiscoveryError{
+		Err: fmt.Errorf("failed to find disk partitions:\n%w", cause),
+	})
+
+	assert.Equal(t, "failed to customize image:\nfailed to find disk partitions:\nfile does not exist", err.Error())
+
+	var partitionDiscoveryError *PartitionDiscoveryError
+	assert.True(t, errors.As(err, &partitionDiscoveryError))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	var mountError *MountError
+	assert.False(t, errors.As(err, &mountError))
+}
+
+func TestValidateConfigFileMissingIsConfigValidationError(t *testing.T) {
+	err := ValidateConfigFile(filepath.Join(testDir, "missing-config.yaml"))
+
+	var configValidationError *ConfigValidationError
+	assert.True(t, errors.As(err, &configValidationError))
+}
+
+func TestValidateConfigMissingPackageListIsConfigValidationError(t *testing.T) {
+	err := ValidateConfig(testDir, &imagecustomizerapi.Config{
+		SystemConfig: imagecustomizerapi.SystemConfig{
+			PackageListsInstall: []string{"lists/missing.yaml"},
+		},
+	})
+	assert.ErrorContains(t, err, "failed to read package list file")
+
+	var configValidationError *ConfigValidationError
+	assert.True(t, errors.As(err, &configValidationError))
+}
@@ -0,0 +1,289 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagegen/diskutils"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/file"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/logger"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/packagerepo/repomanager/rpmrepomanager"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/shell"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	extractedRpmsDirName    = "extracted_rpms"
+	extractedRpmsLockSuffix = ".lock"
+
+	// The default maximum size (in MiB) of the extracted RPMs cache.
+	DefaultExtractedRpmsCacheMaxSize = 10 * 1024
+)
+
+type extractedRpmsCacheEntry struct {
+	path     string
+	lastUsed time.Time
+	size     uint64
+}
+
+// extractRpmsTarball extracts a tarball of RPMs into the build directory's extracted RPMs cache, turns it into an
+// RPM repo, and returns the directory that the RPMs were extracted to.
+// The cache is keyed by the tarball's SHA256 hash. So, a tarball that was extracted by a previous run is reused.
+//
+// The returned file holds a shared lock on the cache entry, which prevents the entry from being evicted. It must be
+// closed once the directory is no longer in use.
+func extractRpmsTarball(buildDir string, tarballPath string) (string, *os.File, error) {
+	hash, err := file.GenerateSHA256(tarballPath)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to hash RPMs tarball (%s):\n%w", tarballPath, err)
+	}
+
+	cacheDir := filepath.Join(buildDir, extractedRpmsDirName)
+	extractDir := filepath.Join(cacheDir, hash)
+
+	err = os.MkdirAll(cacheDir, os.ModePerm)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create extracted RPMs directory (%s):\n%w", cacheDir, err)
+	}
+
+	lock, err := os.OpenFile(extractDir+extractedRpmsLockSuffix, os.O_RDONLY|os.O_CREATE, 0o644)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to open extracted RPMs lock file (%s):\n%w", extractDir, err)
+	}
+
+	err = extractRpmsTarballLocked(tarballPath, cacheDir, extractDir, lock)
+	if err != nil {
+		lock.Close()
+		return "", nil, err
+	}
+
+	return extractDir, lock, nil
+}
+
+func extractRpmsTarballLocked(tarballPath string, cacheDir string, extractDir string, lock *os.File) error {
+	for {
+		// Take an exclusive lock while checking and populating the cache entry, so that concurrent processes don't
+		// extract the same tarball at the same time.
+		err := unix.Flock(int(lock.Fd()), unix.LOCK_EX)
+		if err != nil {
+			return fmt.Errorf("failed to lock extracted RPMs directory (%s):\n%w", extractDir, err)
+		}
+
+		exists, err := file.DirExists(extractDir)
+		if err != nil {
+			return fmt.Errorf("failed to check if RPMs tarball was already extracted (%s):\n%w", extractDir, err)
+		}
+
+		if exists {
+			logger.Log.Debugf("Using previously extracted RPMs tarball (%s)", extractDir)
+		} else {
+			err = extractRpmsTarballHelper(tarballPath, cacheDir, extractDir)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Record when the cache entry was last used, for the cache eviction.
+		now := time.Now()
+		err = os.Chtimes(extractDir, now, now)
+		if err != nil {
+			return fmt.Errorf("failed to update extracted RPMs directory's timestamp (%s):\n%w", extractDir, err)
+		}
+
+		// Downgrade to a shared lock, so that other processes can use the cache entry at the same time.
+		// Note: flock doesn't convert locks atomically. So, the entry might have been evicted in between.
+		err = unix.Flock(int(lock.Fd()), unix.LOCK_SH)
+		if err != nil {
+			return fmt.Errorf("failed to lock extracted RPMs directory (%s):\n%w", extractDir, err)
+		}
+
+		exists, err = file.DirExists(extractDir)
+		if err != nil {
+			return fmt.Errorf("failed to check if RPMs tarball was already extracted (%s):\n%w", extractDir, err)
+		}
+
+		if exists {
+			return nil
+		}
+	}
+}
+
+func extractRpmsTarballHelper(tarballPath string, cacheDir string, extractDir string) error {
+	logger.Log.Infof("Extracting RPMs tarball (%s)", tarballPath)
+
+	// Extract to a temporary directory first, so that a failed extraction doesn't leave behind a partial cache entry.
+	tempDir, err := os.MkdirTemp(cacheDir, filepath.Base(extractDir)+".tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory for RPMs tarball:\n%w", err)
+	}
+
+	err = shell.ExecuteLiveWithErr(1, "tar", "-xf", tarballPath, "-C", tempDir)
+	if err != nil {
+		os.RemoveAll(tempDir)
+		return fmt.Errorf("failed to extract RPMs tarball (%s):\n%w", tarballPath, err)
+	}
+
+	// Turn directory into an RPM repo.
+	err = rpmrepomanager.CreateOrUpdateRepo(tempDir)
+	if err != nil {
+		os.RemoveAll(tempDir)
+		return fmt.Errorf("failed create RPMs repo from tarball (%s):\n%w", tarballPath, err)
+	}
+
+	err = os.Rename(tempDir, extractDir)
+	if err != nil {
+		os.RemoveAll(tempDir)
+		return fmt.Errorf("failed to move extracted RPMs to (%s):\n%w", extractDir, err)
+	}
+
+	return nil
+}
+
+// EvictExtractedRpmsCache removes the least recently used entries from the build directory's cache of extracted RPM
+// tarballs until the cache's total size is no more than maxSize (in MiB).
+// A maxSize of 0 means that the cache size is not limited.
+func EvictExtractedRpmsCache(buildDir string, maxSize uint64) error {
+	if maxSize <= 0 {
+		return nil
+	}
+
+	cacheDir := filepath.Join(buildDir, extractedRpmsDirName)
+	entries, err := readExtractedRpmsCache(cacheDir)
+	if err != nil {
+		return err
+	}
+
+	totalSize := uint64(0)
+	for _, entry := range entries {
+		totalSize += entry.size
+	}
+
+	maxSizeBytes := maxSize * diskutils.MiB
+	if totalSize <= maxSizeBytes {
+		return nil
+	}
+
+	// Evict the least recently used entries first.
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].lastUsed.Before(entries[j].lastUsed)
+	})
+
+	for _, entry := range entries {
+		if totalSize <= maxSizeBytes {
+			break
+		}
+
+		evicted, err := evictExtractedRpmsCacheEntry(entry.path)
+		if err != nil {
+			return err
+		}
+
+		if evicted {
+			totalSize -= entry.size
+		}
+	}
+
+	return nil
+}
+
+// evictExtractedRpmsCacheEntry deletes a cache entry, unless it is currently in use by another process.
+func evictExtractedRpmsCacheEntry(entryPath string) (bool, error) {
+	lock, err := os.OpenFile(entryPath+extractedRpmsLockSuffix, os.O_RDONLY|os.O_CREATE, 0o644)
+	if err != nil {
+		return false, fmt.Errorf("failed to open extracted RPMs lock file (%s):\n%w", entryPath, err)
+	}
+	defer lock.Close()
+
+	err = unix.Flock(int(lock.Fd()), unix.LOCK_EX|unix.LOCK_NB)
+	if errors.Is(err, unix.EWOULDBLOCK) {
+		logger.Log.Debugf("Skipping eviction of extracted RPMs that are in use (%s)", entryPath)
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to lock extracted RPMs directory (%s):\n%w", entryPath, err)
+	}
+
+	logger.Log.Infof("Evicting extracted RPMs (%s)", entryPath)
+
+	// Note: The lock file is left behind, since another process might have it open.
+	err = os.RemoveAll(entryPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete extracted RPMs directory (%s):\n%w", entryPath, err)
+	}
+
+	return true, nil
+}
+
+func readExtractedRpmsCache(cacheDir string) ([]extractedRpmsCacheEntry, error) {
+	dirEntries, err := os.ReadDir(cacheDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read extracted RPMs directory (%s):\n%w", cacheDir, err)
+	}
+
+	var entries []extractedRpmsCacheEntry
+	for _, dirEntry := range dirEntries {
+		// Skip temporary directories of in-progress extractions.
+		if !dirEntry.IsDir() || strings.Contains(dirEntry.Name(), ".") {
+			continue
+		}
+
+		entryPath := filepath.Join(cacheDir, dirEntry.Name())
+
+		info, err := dirEntry.Info()
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat extracted RPMs directory (%s):\n%w", entryPath, err)
+		}
+
+		size, err := getDirSize(entryPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get size of extracted RPMs directory (%s):\n%w", entryPath, err)
+		}
+
+		entries = append(entries, extractedRpmsCacheEntry{
+			path:     entryPath,
+			lastUsed: info.ModTime(),
+			size:     size,
+		})
+	}
+
+	return entries, nil
+}
+
+// getDirSize returns the total size of the regular files within a directory.
+func getDirSize(dirPath string) (uint64, error) {
+	size := uint64(0)
+	err := filepath.WalkDir(dirPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		size += uint64(info.Size())
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return size, nil
+}
@@ -0,0 +1,202 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagegen/diskutils"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/shell"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+)
+
+func createTestRpmsTarball(t *testing.T, testTmpDir string) string {
+	rpmsDir := filepath.Join(testTmpDir, "rpms")
+	tarballPath := filepath.Join(testTmpDir, "rpms.tar.gz")
+
+	err := os.MkdirAll(rpmsDir, os.ModePerm)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	err = os.WriteFile(filepath.Join(rpmsDir, "a.rpm"), []byte("a"), 0o644)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	err = shell.ExecuteLiveWithErr(1, "tar", "-czf", tarballPath, "-C", rpmsDir, ".")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	return tarballPath
+}
+
+func TestExtractRpmsTarball(t *testing.T) {
+	if _, err := exec.LookPath("createrepo"); err != nil {
+		t.Skip("Test requires createrepo")
+	}
+
+	testTmpDir := filepath.Join(tmpDir, "TestExtractRpmsTarball")
+	buildDir := filepath.Join(testTmpDir, "build")
+	tarballPath := createTestRpmsTarball(t, testTmpDir)
+
+	extractDir, lock, err := extractRpmsTarball(buildDir, tarballPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, filepath.Join(buildDir, extractedRpmsDirName), filepath.Dir(extractDir))
+	assert.FileExists(t, filepath.Join(extractDir, "a.rpm"))
+	assert.DirExists(t, filepath.Join(extractDir, "repodata"))
+
+	lock.Close()
+
+	// Extracting the same tarball again should reuse the existing directory.
+	extractDir2, lock2, err := extractRpmsTarball(buildDir, tarballPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer lock2.Close()
+
+	assert.Equal(t, extractDir, extractDir2)
+
+	// Only the entry and its lock file should exist. (i.e. no temporary directories were left behind.)
+	entries, err := os.ReadDir(filepath.Dir(extractDir))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+}
+
+func TestExtractRpmsTarballConcurrent(t *testing.T) {
+	if _, err := exec.LookPath("createrepo"); err != nil {
+		t.Skip("Test requires createrepo")
+	}
+
+	testTmpDir := filepath.Join(tmpDir, "TestExtractRpmsTarballConcurrent")
+	buildDir := filepath.Join(testTmpDir, "build")
+	tarballPath := createTestRpmsTarball(t, testTmpDir)
+
+	// Note: flock locks belong to the open file. So, each extraction coordinates with the others the same way that
+	// separate processes would.
+	const count = 8
+	extractDirs := make([]string, count)
+	locks := make([]*os.File, count)
+	errs := make([]error, count)
+
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			extractDirs[i], locks[i], errs[i] = extractRpmsTarball(buildDir, tarballPath)
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < count; i++ {
+		if assert.NoError(t, errs[i]) {
+			locks[i].Close()
+		}
+
+		assert.Equal(t, extractDirs[0], extractDirs[i])
+	}
+
+	assert.FileExists(t, filepath.Join(extractDirs[0], "a.rpm"))
+
+	entries, err := os.ReadDir(filepath.Join(buildDir, extractedRpmsDirName))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+}
+
+func TestEvictExtractedRpmsCache(t *testing.T) {
+	buildDir := filepath.Join(tmpDir, "TestEvictExtractedRpmsCache")
+	cacheDir := filepath.Join(buildDir, extractedRpmsDirName)
+
+	// Create 3 cache entries of 1 MiB each, with "a" being the least recently used.
+	now := time.Now()
+	names := []string{"a", "b", "c"}
+	for i, name := range names {
+		entryDir := filepath.Join(cacheDir, name)
+		err := os.MkdirAll(entryDir, os.ModePerm)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		err = os.WriteFile(filepath.Join(entryDir, "pkg.rpm"), []byte(strings.Repeat("x", diskutils.MiB)), 0o644)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		lastUsed := now.Add(time.Duration(i-len(names)) * time.Hour)
+		err = os.Chtimes(entryDir, lastUsed, lastUsed)
+		if !assert.NoError(t, err) {
+			return
+		}
+	}
+
+	// No limit.
+	err := EvictExtractedRpmsCache(buildDir, 0)
+	assert.NoError(t, err)
+	assert.DirExists(t, filepath.Join(cacheDir, "a"))
+
+	// Within limit.
+	err = EvictExtractedRpmsCache(buildDir, 3)
+	assert.NoError(t, err)
+	assert.DirExists(t, filepath.Join(cacheDir, "a"))
+
+	// Over limit.
+	err = EvictExtractedRpmsCache(buildDir, 2)
+	assert.NoError(t, err)
+	assert.NoDirExists(t, filepath.Join(cacheDir, "a"))
+	assert.DirExists(t, filepath.Join(cacheDir, "b"))
+	assert.DirExists(t, filepath.Join(cacheDir, "c"))
+}
+
+func TestEvictExtractedRpmsCacheMissingDir(t *testing.T) {
+	err := EvictExtractedRpmsCache(filepath.Join(tmpDir, "TestEvictExtractedRpmsCacheMissingDir"), 1)
+	assert.NoError(t, err)
+}
+
+func TestEvictExtractedRpmsCacheInUse(t *testing.T) {
+	buildDir := filepath.Join(tmpDir, "TestEvictExtractedRpmsCacheInUse")
+	entryDir := filepath.Join(buildDir, extractedRpmsDirName, "a")
+
+	err := os.MkdirAll(entryDir, os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = os.WriteFile(filepath.Join(entryDir, "pkg.rpm"), []byte(strings.Repeat("x", 2*diskutils.MiB)), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	lock, err := os.OpenFile(entryDir+extractedRpmsLockSuffix, os.O_RDONLY|os.O_CREATE, 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = unix.Flock(int(lock.Fd()), unix.LOCK_SH)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// The entry can't be evicted while it is in use.
+	err = EvictExtractedRpmsCache(buildDir, 1)
+	assert.NoError(t, err)
+	assert.DirExists(t, entryDir)
+
+	lock.Close()
+
+	err = EvictExtractedRpmsCache(buildDir, 1)
+	assert.NoError(t, err)
+	assert.NoDirExists(t, entryDir)
+}
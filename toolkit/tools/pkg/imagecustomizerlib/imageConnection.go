@@ -6,6 +6,7 @@ package imagecustomizerlib
 import (
 	"fmt"
 
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/logger"
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/safechroot"
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/safeloopback"
 )
@@ -14,6 +15,7 @@ type ImageConnection struct {
 	loopback            *safeloopback.Loopback
 	chroot              *safechroot.Chroot
 	chrootIsExistingDir bool
+	luksDevices         []*luksDevice
 }
 
 func NewImageConnection() *ImageConnection {
@@ -59,11 +61,28 @@ func (c *ImageConnection) Loopback() *safeloopback.Loopback {
 	return c.loopback
 }
 
+// AddLuksDevice registers an opened encrypted partition, so that it is closed before the loopback is disconnected.
+func (c *ImageConnection) AddLuksDevice(device *luksDevice) {
+	c.luksDevices = append(c.luksDevices, device)
+}
+
+func (c *ImageConnection) LuksDevices() []*luksDevice {
+	return c.luksDevices
+}
+
 func (c *ImageConnection) Close() {
 	if c.chroot != nil {
 		c.chroot.Close(c.chrootIsExistingDir)
 	}
 
+	for _, device := range c.luksDevices {
+		err := closeLuksDevice(device)
+		if err != nil {
+			logger.Log.Warnf("%s", err)
+		}
+	}
+	c.luksDevices = nil
+
 	if c.loopback != nil {
 		c.loopback.Close()
 	}
@@ -75,6 +94,14 @@ func (c *ImageConnection) CleanClose() error {
 		return err
 	}
 
+	for len(c.luksDevices) > 0 {
+		err = closeLuksDevice(c.luksDevices[0])
+		if err != nil {
+			return err
+		}
+		c.luksDevices = c.luksDevices[1:]
+	}
+
 	err = c.loopback.CleanClose()
 	if err != nil {
 		return err
@@ -4,10 +4,12 @@
 package imagecustomizerlib
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"regexp"
+	"strings"
 
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagecustomizerapi"
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagegen/diskutils"
@@ -16,6 +18,7 @@ import (
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/safeloopback"
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/safemount"
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/shell"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/sliceutils"
 )
 
 const (
@@ -31,27 +34,77 @@ var (
 	ToolVersion = ""
 )
 
-func CustomizeImageWithConfigFile(buildDir string, configFile string, imageFile string,
-	rpmsSources []string, outputImageFile string, outputImageFormat string,
-	outputSplitPartitionsFormat string, useBaseImageRpmRepos bool,
-) error {
-	var err error
+// CustomizeImageOptions contains the optional behaviors of the image customization.
+//
+// The zero value provides the default behavior of each option.
+type CustomizeImageOptions struct {
+	// PreserveRpmSourceDirs creates the RPM repo metadata of the RPM source directories in the build directory,
+	// instead of within the directories themselves.
+	PreserveRpmSourceDirs bool
 
-	var config imagecustomizerapi.Config
-	err = imagecustomizerapi.UnmarshalYamlFile(configFile, &config)
-	if err != nil {
-		return err
-	}
+	// RepoSigningKeyFile is the path of a GPG secret key file used to sign the metadata of the RPM repos created
+	// from the RPM source directories and tarballs. If empty, the metadata is not signed.
+	RepoSigningKeyFile string
 
-	baseConfigPath, _ := filepath.Split(configFile)
+	// OverwriteOutput allows the output image file to be replaced if it already exists.
+	OverwriteOutput bool
 
-	absBaseConfigPath, err := filepath.Abs(baseConfigPath)
+	// CheckFilesystems runs a read-only filesystem check (e.g. fsck) on each of the image's partitions after
+	// customization.
+	CheckFilesystems bool
+
+	// TrimFilesystems discards the unused blocks of the image's filesystems before the output image is written.
+	TrimFilesystems bool
+
+	// OutputImagePreallocation is the preallocation mode of the output image (off, metadata, falloc or full).
+	// If empty, qemu-img's default is used.
+	OutputImagePreallocation string
+
+	// VerifyRootfs mounts the image's filesystems read-only after customization and checks that the OS's init and
+	// kernel files exist.
+	VerifyRootfs bool
+
+	// OutputImageCompression is the compression type (zstd or gzip) of the raw output image. If empty, the output
+	// image is not compressed.
+	OutputImageCompression string
+
+	// PackageManifestFile is the path to write the sorted list of the image's installed packages to. If empty, no
+	// manifest is written.
+	PackageManifestFile string
+
+	// ExtractedRpmsCacheMaxSize is the maximum size (in MiB) of the build directory's cache of extracted RPM
+	// tarballs. The least recently used entries are evicted at the end of the customization. 0 means no limit.
+	ExtractedRpmsCacheMaxSize uint64
+}
+
+func CustomizeImageWithConfigFile(buildDir string, configFile string, imageFile string, rpmsSources []string,
+	outputImageFile string, outputImageFormat string, outputSplitPartitionsFormat string, useBaseImageRpmRepos bool,
+	options CustomizeImageOptions,
+) error {
+	return CustomizeImageWithConfigFiles(buildDir, []string{configFile}, imagecustomizerapi.ListMergeStrategyUnset,
+		false, "", imageFile, rpmsSources, outputImageFile, outputImageFormat, outputSplitPartitionsFormat,
+		useBaseImageRpmRepos, options)
+}
+
+// CustomizeImageWithConfigFiles customizes an image using a list of config files that are deep-merged in order.
+// See imagecustomizerapi.MergeConfig for the merge rules.
+//
+// If profile is not empty, then the config's profile with that name is merged into the config after the config files.
+//
+// The config files must all be in the same directory, since the relative file paths within the configs are resolved
+// relative to the config file's directory.
+func CustomizeImageWithConfigFiles(buildDir string, configFiles []string,
+	listMergeStrategy imagecustomizerapi.ListMergeStrategy, expandEnvVars bool, profile string, imageFile string,
+	rpmsSources []string, outputImageFile string, outputImageFormat string, outputSplitPartitionsFormat string,
+	useBaseImageRpmRepos bool, options CustomizeImageOptions,
+) error {
+	absBaseConfigPath, config, err := loadConfigFiles(configFiles, listMergeStrategy, expandEnvVars, profile)
 	if err != nil {
-		return fmt.Errorf("failed to get absolute path of config file directory:\n%w", err)
+		return err
 	}
 
-	err = CustomizeImage(buildDir, absBaseConfigPath, &config, imageFile, rpmsSources, outputImageFile, outputImageFormat,
-		outputSplitPartitionsFormat, useBaseImageRpmRepos)
+	err = CustomizeImage(buildDir, absBaseConfigPath, config, imageFile, rpmsSources, outputImageFile,
+		outputImageFormat, outputSplitPartitionsFormat, useBaseImageRpmRepos, options)
 	if err != nil {
 		return err
 	}
@@ -60,10 +113,18 @@ func CustomizeImageWithConfigFile(buildDir string, configFile string, imageFile
 }
 
 func CustomizeImage(buildDir string, baseConfigPath string, config *imagecustomizerapi.Config, imageFile string,
-	rpmsSources []string, outputImageFile string, outputImageFormat string, outputSplitPartitionsFormat string, useBaseImageRpmRepos bool,
+	rpmsSources []string, outputImageFile string, outputImageFormat string, outputSplitPartitionsFormat string,
+	useBaseImageRpmRepos bool, options CustomizeImageOptions,
 ) error {
 	var err error
 	var qemuOutputImageFormat string
+	var qemuOutputImageOptions []string
+
+	// The path of the output image, which differs from outputImageFile when the output image is compressed.
+	finalOutputImageFile := outputImageFile
+
+	// The image formats are case-insensitive.
+	outputImageFormat = strings.ToLower(outputImageFormat)
 
 	// Validate 'outputImageFormat' value if specified.
 	if outputImageFormat != "" {
@@ -71,12 +132,63 @@ func CustomizeImage(buildDir string, baseConfigPath string, config *imagecustomi
 		if err != nil {
 			return err
 		}
+
+		qemuOutputImageOptions, err = toQemuPreallocationOptions(outputImageFormat, options.OutputImagePreallocation)
+		if err != nil {
+			return err
+		}
+	} else if options.OutputImagePreallocation != "" {
+		return fmt.Errorf("output image preallocation requires an output image format")
+	}
+
+	// Validate 'OutputImageCompression' value if specified.
+	if options.OutputImageCompression != "" {
+		err = validateOutputImageCompression(outputImageFormat, options.OutputImagePreallocation,
+			options.OutputImageCompression)
+		if err != nil {
+			return err
+		}
+
+		finalOutputImageFile = compressedOutputImageFile(outputImageFile, options.OutputImageCompression)
+	}
+
+	// Don't clobber a previous output image, unless asked to.
+	// Note: This is checked before any of the (slow) customization steps are run.
+	if outputImageFormat != "" && !options.OverwriteOutput {
+		exists, err := file.PathExists(finalOutputImageFile)
+		if err != nil {
+			return fmt.Errorf("failed to check if output image file exists (%s):\n%w", finalOutputImageFile, err)
+		}
+
+		if exists {
+			return fmt.Errorf("output image file (%s) already exists (use --force to overwrite it)",
+				finalOutputImageFile)
+		}
+	}
+
+	// Keep the build directory's cache bounded, even if the customization fails.
+	defer func() {
+		cacheErr := EvictExtractedRpmsCache(buildDir, options.ExtractedRpmsCacheMaxSize)
+		if cacheErr != nil {
+			logger.Log.Warnf("Failed to evict extracted RPMs cache entries: %s", cacheErr)
+		}
+	}()
+
+	// Higher priority RPM sources take precedence over lower priority ones.
+	rpmsSources, err = orderRpmSourcesByPriority(rpmsSources)
+	if err != nil {
+		return err
+	}
+
+	if imageFile == "" {
+		// There is no base image to provide RPM repos.
+		useBaseImageRpmRepos = false
 	}
 
 	// Validate config.
-	err = validateConfig(baseConfigPath, config, rpmsSources, useBaseImageRpmRepos)
+	err = validateCustomizeImageConfig(baseConfigPath, config, imageFile, rpmsSources, useBaseImageRpmRepos)
 	if err != nil {
-		return fmt.Errorf("invalid image config:\n%w", err)
+		return err
 	}
 
 	// Normalize 'buildDir' path.
@@ -91,24 +203,46 @@ func CustomizeImage(buildDir string, baseConfigPath string, config *imagecustomi
 		return err
 	}
 
-	// Convert image file to raw format, so that a kernel loop device can be used to make changes to the image.
 	buildImageFile := filepath.Join(buildDirAbs, BaseImageName)
+	partitionsCustomized := false
 
-	logger.Log.Infof("Mounting base image: %s", buildImageFile)
-	err = shell.ExecuteLiveWithErr(1, "qemu-img", "convert", "-O", "raw", imageFile, buildImageFile)
-	if err != nil {
-		return fmt.Errorf("failed to convert image file to raw format:\n%w", err)
-	}
+	if imageFile == "" {
+		logger.Log.Infof("Creating new image: %s", buildImageFile)
+		err = createImageFromScratch(buildDirAbs, baseConfigPath, config, buildImageFile, rpmsSources,
+			options.PreserveRpmSourceDirs, options.RepoSigningKeyFile)
+		if err != nil {
+			return err
+		}
 
-	// Customize the partitions.
-	partitionsCustomized, buildImageFile, err := customizePartitions(buildDirAbs, baseConfigPath, config, buildImageFile)
-	if err != nil {
-		return err
+		// The base packages were installed without the chroot's default mounts (e.g. /proc). So, regenerate the
+		// initramfs within the full chroot.
+		partitionsCustomized = true
+	} else {
+		// Check the base image is a disk image, so that a bad input produces a clear error instead of a cryptic
+		// qemu-img one.
+		err = validateBaseImageFormat(imageFile)
+		if err != nil {
+			return err
+		}
+
+		// Convert image file to raw format, so that a kernel loop device can be used to make changes to the image.
+		logger.Log.Infof("Mounting base image: %s", buildImageFile)
+		err = shell.ExecuteLiveWithErr(1, "qemu-img", "convert", "-O", "raw", imageFile, buildImageFile)
+		if err != nil {
+			return fmt.Errorf("failed to convert image file to raw format:\n%w", err)
+		}
+
+		// Customize the partitions.
+		partitionsCustomized, buildImageFile, err = customizePartitions(buildDirAbs, baseConfigPath, config,
+			buildImageFile)
+		if err != nil {
+			return err
+		}
 	}
 
 	// Customize the raw image file.
 	err = customizeImageHelper(buildDirAbs, baseConfigPath, config, buildImageFile, rpmsSources, useBaseImageRpmRepos,
-		partitionsCustomized)
+		options, partitionsCustomized)
 	if err != nil {
 		return err
 	}
@@ -122,13 +256,16 @@ func CustomizeImage(buildDir string, baseConfigPath string, config *imagecustomi
 	}
 
 	// Create final output image file if requested.
-	if outputImageFormat != "" {
-		logger.Log.Infof("Writing: %s", outputImageFile)
-
-		outDir := filepath.Dir(outputImageFile)
-		os.MkdirAll(outDir, os.ModePerm)
+	switch {
+	case options.OutputImageCompression != "":
+		err = writeCompressedOutputImage(buildImageFile, finalOutputImageFile, options.OutputImageCompression)
+		if err != nil {
+			return fmt.Errorf("failed to write compressed output image (%s):\n%w",
+				options.OutputImageCompression, err)
+		}
 
-		err = shell.ExecuteLiveWithErr(1, "qemu-img", "convert", "-O", qemuOutputImageFormat, buildImageFile, outputImageFile)
+	case outputImageFormat != "":
+		err = writeOutputImage(buildImageFile, outputImageFile, qemuOutputImageFormat, qemuOutputImageOptions)
 		if err != nil {
 			return fmt.Errorf("failed to convert image file to format: %s:\n%w", outputImageFormat, err)
 		}
@@ -148,7 +285,96 @@ func CustomizeImage(buildDir string, baseConfigPath string, config *imagecustomi
 	return nil
 }
 
+// ValidateConfigWithConfigFiles reads, merges and validates a list of config files, in the same way as
+// CustomizeImageWithConfigFiles, but without customizing the image.
+//
+// This includes checking that the files referenced by the config exist, that the scripts are executable and that
+// there are RPM sources for the config's package operations. The base image is not read and no build directory is
+// created.
+func ValidateConfigWithConfigFiles(configFiles []string, listMergeStrategy imagecustomizerapi.ListMergeStrategy,
+	expandEnvVars bool, profile string, imageFile string, rpmsSources []string, useBaseImageRpmRepos bool,
+) error {
+	absBaseConfigPath, config, err := loadConfigFiles(configFiles, listMergeStrategy, expandEnvVars, profile)
+	if err != nil {
+		return err
+	}
+
+	rpmsSources, err = orderRpmSourcesByPriority(rpmsSources)
+	if err != nil {
+		return err
+	}
+
+	if imageFile == "" {
+		// There is no base image to provide RPM repos.
+		useBaseImageRpmRepos = false
+	}
+
+	err = validateCustomizeImageConfig(absBaseConfigPath, config, imageFile, rpmsSources, useBaseImageRpmRepos)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// validateCustomizeImageConfig validates a config against the arguments that the image will be customized with.
+func validateCustomizeImageConfig(baseConfigPath string, config *imagecustomizerapi.Config, imageFile string,
+	rpmsSources []string, useBaseImageRpmRepos bool,
+) error {
+	if imageFile == "" {
+		err := validateFromScratch(config, rpmsSources)
+		if err != nil {
+			return &ConfigValidationError{Err: fmt.Errorf("invalid image config:\n%w", err)}
+		}
+	}
+
+	err := validateConfig(baseConfigPath, config, rpmsSources, useBaseImageRpmRepos)
+	if err != nil {
+		return &ConfigValidationError{Err: fmt.Errorf("invalid image config:\n%w", err)}
+	}
+
+	return nil
+}
+
+// loadConfigFiles reads and merges a list of config files.
+// Returns the directory that the config's relative file paths are resolved against and the merged config.
+func loadConfigFiles(configFiles []string, listMergeStrategy imagecustomizerapi.ListMergeStrategy,
+	expandEnvVars bool, profile string,
+) (string, *imagecustomizerapi.Config, error) {
+	if len(configFiles) <= 0 {
+		return "", nil, fmt.Errorf("no config files specified")
+	}
+
+	absBaseConfigPath, err := getConfigFileDir(configFiles[0])
+	if err != nil {
+		return "", nil, err
+	}
+
+	for _, configFile := range configFiles[1:] {
+		configFileDir, err := getConfigFileDir(configFile)
+		if err != nil {
+			return "", nil, err
+		}
+
+		if configFileDir != absBaseConfigPath {
+			return "", nil, fmt.Errorf("config files must all be in the same directory (%s) but (%s) is not",
+				absBaseConfigPath, configFile)
+		}
+	}
+
+	var config imagecustomizerapi.Config
+	err = imagecustomizerapi.UnmarshalAndMergeYamlFiles(configFiles, listMergeStrategy, expandEnvVars, profile,
+		&config)
+	if err != nil {
+		return "", nil, &ConfigValidationError{Err: err}
+	}
+
+	return absBaseConfigPath, &config, nil
+}
+
 func toQemuImageFormat(imageFormat string) (string, error) {
+	imageFormat = strings.ToLower(imageFormat)
+
 	switch imageFormat {
 	case "vhd":
 		return "vpc", nil
@@ -156,25 +382,138 @@ func toQemuImageFormat(imageFormat string) (string, error) {
 	case "vhdx", "raw", "qcow2":
 		return imageFormat, nil
 
+	case "raw-sparse":
+		// A raw image whose sparseness is enforced by toQemuPreallocationOptions.
+		return "raw", nil
+
+	default:
+		return "", fmt.Errorf("unsupported image format (supported: vhd, vhdx, raw, raw-sparse, qcow2): %s",
+			imageFormat)
+	}
+}
+
+// toQemuPreallocationOptions returns the `qemu-img convert` args for the requested output image preallocation mode.
+// An empty mode means the format's default (i.e. sparse output).
+func toQemuPreallocationOptions(imageFormat string, preallocation string) ([]string, error) {
+	if preallocation == "" && imageFormat != "raw-sparse" {
+		return nil, nil
+	}
+
+	switch imageFormat {
+	case "raw-sparse":
+		// Explicitly request that every run of zeros, down to a single 4K block, is written as a hole. That way, the
+		// output is sparse regardless of qemu-img's defaults.
+		switch preallocation {
+		case "", "off":
+			return []string{"-S", "4k", "-o", "preallocation=off"}, nil
+		}
+
+		return nil, fmt.Errorf("unsupported preallocation for raw-sparse image format (supported: off): %s",
+			preallocation)
+
+	case "raw":
+		switch preallocation {
+		case "off", "falloc", "full":
+			return []string{"-o", "preallocation=" + preallocation}, nil
+		}
+
+		return nil, fmt.Errorf("unsupported preallocation for raw image format (supported: off, falloc, full): %s",
+			preallocation)
+
+	case "qcow2":
+		switch preallocation {
+		case "off", "metadata", "falloc", "full":
+			return []string{"-o", "preallocation=" + preallocation}, nil
+		}
+
+		return nil, fmt.Errorf(
+			"unsupported preallocation for qcow2 image format (supported: off, metadata, falloc, full): %s",
+			preallocation)
+
+	case "vhd", "vhdx":
+		// VHD and VHDX files are either dynamic (sparse) or fixed (fully allocated).
+		switch preallocation {
+		case "off":
+			return []string{"-o", "subformat=dynamic"}, nil
+
+		case "full":
+			return []string{"-o", "subformat=fixed"}, nil
+		}
+
+		return nil, fmt.Errorf("unsupported preallocation for %s image format (supported: off, full): %s",
+			imageFormat, preallocation)
+
 	default:
-		return "", fmt.Errorf("unsupported image format (supported: vhd, vhdx, raw, qcow2): %s", imageFormat)
+		return nil, fmt.Errorf("unsupported image format (supported: vhd, vhdx, raw, raw-sparse, qcow2): %s",
+			imageFormat)
+	}
+}
+
+// ValidateConfigFile reads and validates a config file, including checking that the files referenced by the config
+// exist.
+//
+// Unknown fields in the config file are treated as errors.
+func ValidateConfigFile(configFile string) error {
+	var config imagecustomizerapi.Config
+	err := imagecustomizerapi.UnmarshalAndMergeYamlFiles([]string{configFile},
+		imagecustomizerapi.ListMergeStrategyUnset, false, "", &config)
+	if err != nil {
+		return &ConfigValidationError{Err: err}
+	}
+
+	absBaseConfigPath, err := getConfigFileDir(configFile)
+	if err != nil {
+		return err
+	}
+
+	err = ValidateConfig(absBaseConfigPath, &config)
+	if err != nil {
+		return err
 	}
+
+	return nil
+}
+
+// ValidateConfig validates a config, including checking that the files referenced by the config exist.
+// baseConfigPath is the directory that the config's relative file paths are resolved against.
+//
+// Since the RPM sources are only provided when the image is customized, this doesn't check that RPM sources are
+// available for the config's package operations.
+func ValidateConfig(baseConfigPath string, config *imagecustomizerapi.Config) error {
+	// Validation merges the package list files into the inline package lists. So, validate a copy of the config to
+	// avoid modifying the caller's value.
+	configCopy := *config
+
+	err := validateConfigContents(baseConfigPath, &configCopy)
+	if err != nil {
+		return &ConfigValidationError{Err: err}
+	}
+
+	return nil
+}
+
+func getConfigFileDir(configFile string) (string, error) {
+	baseConfigPath, _ := filepath.Split(configFile)
+
+	absBaseConfigPath, err := filepath.Abs(baseConfigPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to get absolute path of config file directory:\n%w", err)
+	}
+
+	return absBaseConfigPath, nil
 }
 
 func validateConfig(baseConfigPath string, config *imagecustomizerapi.Config, rpmsSources []string,
 	useBaseImageRpmRepos bool,
 ) error {
-	// Note: This IsValid() check does duplicate the one in UnmarshalYamlFile().
-	// But it is useful for functions that call CustomizeImage() directly. For example, test code.
-	err := config.IsValid()
+	err := validateConfigContents(baseConfigPath, config)
 	if err != nil {
 		return err
 	}
 
 	partitionsCustomized := hasPartitionCustomizations(config)
 
-	err = validateSystemConfig(baseConfigPath, &config.SystemConfig, rpmsSources, useBaseImageRpmRepos,
-		partitionsCustomized)
+	err = validateRpmSources(&config.SystemConfig, rpmsSources, useBaseImageRpmRepos, partitionsCustomized)
 	if err != nil {
 		return err
 	}
@@ -182,47 +521,124 @@ func validateConfig(baseConfigPath string, config *imagecustomizerapi.Config, rp
 	return nil
 }
 
+func validateConfigContents(baseConfigPath string, config *imagecustomizerapi.Config) error {
+	// Note: This IsValid() check does duplicate the one in UnmarshalYamlFile().
+	// But it is useful for functions that call CustomizeImage() directly. For example, test code.
+	//
+	// All of the errors are reported together, so that they can all be fixed at once.
+	return errors.Join(
+		config.IsValid(),
+		validateDisks(baseConfigPath, config.Disks),
+		validateSystemConfig(baseConfigPath, &config.SystemConfig),
+	)
+}
+
+func validateDisks(baseConfigPath string, disks *[]imagecustomizerapi.Disk) error {
+	if disks == nil {
+		return nil
+	}
+
+	var errs []error
+
+	// The config API validates multiple disks (e.g. a separate data disk). But the image customizer can only create
+	// and write a single disk image file. So, the other disks can't be built yet.
+	if len(*disks) > 1 {
+		errs = append(errs, fmt.Errorf("writing more than 1 disk (%d specified) is not currently supported",
+			len(*disks)))
+	}
+
+	for _, disk := range *disks {
+		for _, partition := range disk.Partitions {
+			if partition.Luks == nil {
+				continue
+			}
+
+			passphraseFileFullPath := filepath.Join(baseConfigPath, partition.Luks.PassphraseFile)
+			isFile, err := file.IsFile(passphraseFileFullPath)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("invalid partition (%s) Luks PassphraseFile (%s):\n%w", partition.ID,
+					partition.Luks.PassphraseFile, err))
+				continue
+			}
+
+			if !isFile {
+				errs = append(errs, fmt.Errorf("invalid partition (%s) Luks PassphraseFile (%s): not a file",
+					partition.ID, partition.Luks.PassphraseFile))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
 func hasPartitionCustomizations(config *imagecustomizerapi.Config) bool {
 	return config.Disks != nil
 }
 
-func validateSystemConfig(baseConfigPath string, config *imagecustomizerapi.SystemConfig,
-	rpmsSources []string, useBaseImageRpmRepos bool, partitionsCustomized bool,
-) error {
-	var err error
+func validateSystemConfig(baseConfigPath string, config *imagecustomizerapi.SystemConfig) error {
+	var errs []error
 
-	err = validatePackageLists(baseConfigPath, config, rpmsSources, useBaseImageRpmRepos, partitionsCustomized)
+	err := validatePackageLists(baseConfigPath, config)
 	if err != nil {
-		return err
+		errs = append(errs, err)
 	}
 
-	for sourceFile := range config.AdditionalFiles {
+	warnUserShellPackagesRemoved(config)
+
+	for sourceFile, fileConfigs := range config.AdditionalFiles {
 		sourceFileFullPath := filepath.Join(baseConfigPath, sourceFile)
-		isFile, err := file.IsFile(sourceFileFullPath)
+		isDir, err := file.IsDir(sourceFileFullPath)
 		if err != nil {
-			return fmt.Errorf("invalid AdditionalFiles source file (%s):\n%w", sourceFile, err)
+			errs = append(errs, fmt.Errorf("invalid AdditionalFiles source file (%s):\n%w", sourceFile, err))
+			continue
+		}
+
+		if isDir {
+			// A directory is copied recursively. So, the per-file options don't apply.
+			for _, fileConfig := range fileConfigs {
+				if fileConfig.NormalizeLineEndings || fileConfig.Capabilities != "" {
+					errs = append(errs, fmt.Errorf(
+						"invalid AdditionalFiles source directory (%s): NormalizeLineEndings and Capabilities are "+
+							"only supported for files", sourceFile))
+					break
+				}
+			}
+		}
+	}
+
+	for _, gpgKey := range config.PackagesGpgKeys {
+		gpgKeyFullPath := filepath.Join(baseConfigPath, gpgKey)
+		isFile, err := file.IsFile(gpgKeyFullPath)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid PackagesGpgKeys file (%s):\n%w", gpgKey, err))
+			continue
 		}
 
 		if !isFile {
-			return fmt.Errorf("invalid AdditionalFiles source file (%s): not a file", sourceFile)
+			errs = append(errs, fmt.Errorf("invalid PackagesGpgKeys file (%s): not a file", gpgKey))
 		}
 	}
 
+	err = validateAutoinstallSeed(baseConfigPath, config.AutoinstallSeed)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid AutoinstallSeed:\n%w", err))
+	}
+
 	for i, script := range config.PostInstallScripts {
 		err = validateScript(baseConfigPath, &script)
 		if err != nil {
-			return fmt.Errorf("invalid PostInstallScripts item at index %d: %w", i, err)
+			errs = append(errs, fmt.Errorf("invalid PostInstallScripts item at index %d: %w", i, err))
 		}
 	}
 
 	for i, script := range config.FinalizeImageScripts {
 		err = validateScript(baseConfigPath, &script)
 		if err != nil {
-			return fmt.Errorf("invalid FinalizeImageScripts item at index %d: %w", i, err)
+			errs = append(errs, fmt.Errorf("invalid FinalizeImageScripts item at index %d: %w", i, err))
 		}
 	}
 
-	return nil
+	return errors.Join(errs...)
 }
 
 func validateScript(baseConfigPath string, script *imagecustomizerapi.Script) error {
@@ -248,9 +664,7 @@ func validateScript(baseConfigPath string, script *imagecustomizerapi.Script) er
 	return nil
 }
 
-func validatePackageLists(baseConfigPath string, config *imagecustomizerapi.SystemConfig, rpmsSources []string,
-	useBaseImageRpmRepos bool, partitionsCustomized bool,
-) error {
+func validatePackageLists(baseConfigPath string, config *imagecustomizerapi.SystemConfig) error {
 	allPackagesRemove, err := collectPackagesList(baseConfigPath, config.PackageListsRemove, config.PackagesRemove)
 	if err != nil {
 		return err
@@ -266,31 +680,121 @@ func validatePackageLists(baseConfigPath string, config *imagecustomizerapi.Syst
 		return err
 	}
 
-	hasRpmSources := len(rpmsSources) > 0 || useBaseImageRpmRepos
+	allPackagesDowngrade, err := collectPackagesList(baseConfigPath, config.PackageListsDowngrade,
+		config.PackagesDowngrade)
+	if err != nil {
+		return err
+	}
 
-	if !hasRpmSources {
-		needRpmsSources := len(allPackagesInstall) > 0 || len(allPackagesUpdate) > 0 || config.UpdateBaseImagePackages
+	allPackagesKeep, err := collectPackagesList(baseConfigPath, config.PackageListsKeep, config.PackagesKeep)
+	if err != nil {
+		return err
+	}
 
-		if needRpmsSources {
-			return fmt.Errorf("have packages to install or update but no RPM sources were specified")
-		} else if partitionsCustomized {
-			return fmt.Errorf("partitions were customized so the initramfs package needs to be reinstalled but no RPM sources were specified")
-		}
+	// zram swap is set up by zram-generator.
+	if config.Zram != nil && !sliceutils.ContainsValue(allPackagesInstall, zramGeneratorPackage) {
+		allPackagesInstall = append(allPackagesInstall, zramGeneratorPackage)
 	}
 
 	config.PackagesRemove = allPackagesRemove
 	config.PackagesInstall = allPackagesInstall
 	config.PackagesUpdate = allPackagesUpdate
+	config.PackagesDowngrade = allPackagesDowngrade
+	config.PackagesKeep = allPackagesKeep
 
 	config.PackageListsRemove = nil
 	config.PackageListsInstall = nil
 	config.PackageListsUpdate = nil
+	config.PackageListsDowngrade = nil
+	config.PackageListsKeep = nil
+
+	return nil
+}
+
+// validateRpmSources checks that there are RPM sources available for the config's package operations.
+//
+// Note: This must be called after 'validatePackageLists' has merged the package list files into the inline package
+// lists.
+func validateRpmSources(config *imagecustomizerapi.SystemConfig, rpmsSources []string, useBaseImageRpmRepos bool,
+	partitionsCustomized bool,
+) error {
+	hasRpmSources := len(rpmsSources) > 0 || useBaseImageRpmRepos
+
+	if config.UpdateBaseImagePackages && !hasRpmSources {
+		return fmt.Errorf("UpdateBaseImagePackages is set but no RPM sources were specified (use --rpm-source or don't disable the base image's RPM repos)")
+	}
+
+	if !hasRpmSources {
+		needRpmsSources := len(config.PackagesInstall) > 0 || len(config.PackagesUpdate) > 0 ||
+			len(config.PackagesDowngrade) > 0 || config.UpdateBaseImagePackages || config.PackageModules.HasOperations() ||
+			len(config.TdnfCommands) > 0 || config.PackagesInstallDebuginfo
+
+		if needRpmsSources {
+			return fmt.Errorf("have packages or modules to install, update, or downgrade, or TdnfCommands to run, but no RPM sources were specified")
+		} else if partitionsCustomized {
+			return fmt.Errorf("partitions were customized so the initramfs package needs to be reinstalled but no RPM sources were specified")
+		}
+	}
+
+	hasRepoSource := useBaseImageRpmRepos
+	for _, rpmSource := range rpmsSources {
+		fileType, err := getRpmSourceFileType(rpmSource)
+		if err != nil {
+			return fmt.Errorf("failed to get RPM source file type (%s):\n%w", rpmSource, err)
+		}
+
+		if fileType == "rpm" {
+			err = validateRpmFile(rpmSource)
+			if err != nil {
+				return fmt.Errorf("invalid RPM source:\n%w", err)
+			}
+		} else {
+			hasRepoSource = true
+		}
+	}
+
+	// Updates are only pulled from repos. Local RPM files are installed as is.
+	if config.UpdateBaseImagePackages && !hasRepoSource {
+		return fmt.Errorf("UpdateBaseImagePackages is set but the RPM sources only contain RPM files (specify an RPM repo source using --rpm-source)")
+	}
+
+	if config.PackagesInstallDebuginfo && !hasRepoSource {
+		return fmt.Errorf("PackagesInstallDebuginfo is set but the RPM sources only contain RPM files (specify the debuginfo repo using --rpm-source)")
+	}
+
+	if config.ReleaseVersion == "" {
+		// The image's release version might not match the repos. So, require the release version to be specified
+		// explicitly.
+		for _, rpmSource := range rpmsSources {
+			fileType, err := getRpmSourceFileType(rpmSource)
+			if err != nil {
+				return fmt.Errorf("failed to get RPM source file type (%s):\n%w", rpmSource, err)
+			}
+
+			usesReleaseVersion := false
+			switch fileType {
+			case "repo":
+				usesReleaseVersion, err = repoConfigUsesVariable(rpmSource, "releasever")
+				if err != nil {
+					return err
+				}
+
+			case "url":
+				usesReleaseVersion = valueUsesRepoVariable(rpmSource, "releasever")
+			}
+
+			if usesReleaseVersion {
+				return fmt.Errorf("RPM source (%s) uses $releasever but ReleaseVersion is not specified", rpmSource)
+			}
+		}
+	}
 
 	return nil
 }
 
 func customizeImageHelper(buildDir string, baseConfigPath string, config *imagecustomizerapi.Config,
-	buildImageFile string, rpmsSources []string, useBaseImageRpmRepos bool, partitionsCustomized bool,
+	buildImageFile string, rpmsSources []string, useBaseImageRpmRepos bool, options CustomizeImageOptions,
+	partitionsCustomized bool,
 ) error {
 	imageConnection, err := connectToExistingImage(buildImageFile, buildDir, "imageroot", true)
 	if err != nil {
@@ -300,7 +804,8 @@ func customizeImageHelper(buildDir string, baseConfigPath string, config *imagec
 
 	// Do the actual customizations.
 	err = doCustomizations(buildDir, baseConfigPath, config, imageConnection.Chroot(), rpmsSources,
-		useBaseImageRpmRepos, partitionsCustomized)
+		useBaseImageRpmRepos, options.PreserveRpmSourceDirs, options.RepoSigningKeyFile, partitionsCustomized,
+		options.PackageManifestFile)
 	if err != nil {
 		return err
 	}
@@ -310,6 +815,30 @@ func customizeImageHelper(buildDir string, baseConfigPath string, config *imagec
 		return err
 	}
 
+	// Discard the unused blocks, so that the output image is sparse.
+	if options.TrimFilesystems {
+		err = trimImageFilesystems(buildDir, buildImageFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Check for any filesystem corruption introduced by the customizations.
+	if options.CheckFilesystems {
+		err = checkImageFilesystems(buildImageFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Check that the customizations didn't break the OS (e.g. by deleting init).
+	if options.VerifyRootfs {
+		err = verifyImageRootfs(buildDir, buildImageFile)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -414,8 +943,10 @@ func customizeVerityImageHelper(buildDir string, baseConfigPath string, config *
 		return fmt.Errorf("failed to stat file (%s):\n%w", grubCfgFullPath, err)
 	}
 
-	err = updateGrubConfig(config.SystemConfig.Verity.DataPartition.IdType, config.SystemConfig.Verity.DataPartition.Id,
-		config.SystemConfig.Verity.HashPartition.IdType, config.SystemConfig.Verity.HashPartition.Id, rootHash, grubCfgFullPath)
+	err = updateGrubConfig(config.SystemConfig.Verity.GetMountPoint(),
+		config.SystemConfig.Verity.DataPartition.IdType, config.SystemConfig.Verity.DataPartition.Id,
+		config.SystemConfig.Verity.HashPartition.IdType, config.SystemConfig.Verity.HashPartition.Id, rootHash,
+		grubCfgFullPath)
 	if err != nil {
 		return err
 	}
@@ -425,5 +956,18 @@ func customizeVerityImageHelper(buildDir string, baseConfigPath string, config *
 		return err
 	}
 
+	if config.SystemConfig.Verity.GetMountPoint() != imagecustomizerapi.VerityMountPointRoot {
+		// The rootfs isn't protected by verity. So, it can still be modified.
+		rootfsPartition, err := findRootfsPartitionFromEsp(systemBootPartition, diskPartitions, buildDir)
+		if err != nil {
+			return err
+		}
+
+		err = addVeritytabEntryToRootfs(config.SystemConfig.Verity, rootHash, rootfsPartition, buildDir)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
@@ -47,8 +47,8 @@ func TestCustomizeImageEmptyConfig(t *testing.T) {
 	}
 
 	// Customize image.
-	err = CustomizeImage(buildDir, buildDir, &imagecustomizerapi.Config{}, diskFilePath, nil, outImageFilePath,
-		"vhd", "", false)
+	err = CustomizeImage(buildDir, buildDir, &imagecustomizerapi.Config{}, diskFilePath, nil, outImageFilePath, "vhd",
+		"", false, CustomizeImageOptions{})
 	if !assert.NoError(t, err) {
 		return
 	}
@@ -83,7 +83,8 @@ func TestCustomizeImageCopyFiles(t *testing.T) {
 	}
 
 	// Customize image.
-	err = CustomizeImageWithConfigFile(buildDir, configFile, diskFilePath, nil, outImageFilePath, "raw", "", false)
+	err = CustomizeImageWithConfigFile(buildDir, configFile, diskFilePath, nil, outImageFilePath, "raw", "", false,
+		CustomizeImageOptions{})
 	if !assert.NoError(t, err) {
 		return
 	}
@@ -155,10 +156,39 @@ func TestValidateConfigdditionalFilesIsDir(t *testing.T) {
 	err := validateConfig(testDir, &imagecustomizerapi.Config{
 		SystemConfig: imagecustomizerapi.SystemConfig{
 			AdditionalFiles: map[string]imagecustomizerapi.FileConfigList{
-				"files": {{Path: "/a.txt"}},
+				"files": {{Path: "/usr/local/share/files"}},
 			},
 		}}, nil, true)
-	assert.Error(t, err)
+	assert.NoError(t, err)
+}
+
+func TestValidateConfigdditionalFilesIsDirCapabilities(t *testing.T) {
+	err := validateConfig(testDir, &imagecustomizerapi.Config{
+		SystemConfig: imagecustomizerapi.SystemConfig{
+			AdditionalFiles: map[string]imagecustomizerapi.FileConfigList{
+				"files": {{Path: "/usr/local/share/files", Capabilities: "cap_net_raw+ep"}},
+			},
+		}}, nil, true)
+	assert.ErrorContains(t, err, "invalid AdditionalFiles source directory (files): NormalizeLineEndings and "+
+		"Capabilities are only supported for files")
+}
+
+func TestValidateConfigPackagesGpgKeys(t *testing.T) {
+	err := validateConfig(testDir, &imagecustomizerapi.Config{
+		SystemConfig: imagecustomizerapi.SystemConfig{
+			PackagesGpgCheck: true,
+			PackagesGpgKeys:  []string{"files/a.txt"},
+		}}, nil, true)
+	assert.NoError(t, err)
+}
+
+func TestValidateConfigMissingPackagesGpgKeys(t *testing.T) {
+	err := validateConfig(testDir, &imagecustomizerapi.Config{
+		SystemConfig: imagecustomizerapi.SystemConfig{
+			PackagesGpgCheck: true,
+			PackagesGpgKeys:  []string{"files/missing-key.asc"},
+		}}, nil, true)
+	assert.ErrorContains(t, err, "invalid PackagesGpgKeys file (files/missing-key.asc)")
 }
 
 func TestValidateConfigScript(t *testing.T) {
@@ -202,6 +232,224 @@ func TestValidateConfigScriptNonExecutable(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestValidateConfigFile(t *testing.T) {
+	err := ValidateConfigFile(filepath.Join(testDir, "addfiles-config.yaml"))
+	assert.NoError(t, err)
+}
+
+func TestValidateConfigWithConfigFiles(t *testing.T) {
+	err := ValidateConfigWithConfigFiles([]string{filepath.Join(testDir, "runscripts-config.yaml")},
+		imagecustomizerapi.ListMergeStrategyUnset, false, "", "base.vhdx", nil, true)
+	assert.NoError(t, err)
+}
+
+func TestValidateConfigWithConfigFilesNoRpmSources(t *testing.T) {
+	// Unlike ValidateConfigFile, the RPM sources are checked.
+	err := ValidateConfigWithConfigFiles([]string{filepath.Join(testDir, "updatepackages-config.yaml")},
+		imagecustomizerapi.ListMergeStrategyUnset, false, "", "base.vhdx", nil, false)
+	assert.ErrorContains(t, err, "invalid image config")
+}
+
+func TestValidateConfigWithConfigFilesFromScratch(t *testing.T) {
+	// Creating a new image requires Disks.
+	err := ValidateConfigWithConfigFiles([]string{filepath.Join(testDir, "runscripts-config.yaml")},
+		imagecustomizerapi.ListMergeStrategyUnset, false, "", "", nil, true)
+	assert.ErrorContains(t, err, "Disks must be specified")
+}
+
+func TestCustomizeImageOutputExists(t *testing.T) {
+	buildDir := filepath.Join(tmpDir, "TestCustomizeImageOutputExists")
+	outImageFilePath := filepath.Join(buildDir, "image.vhd")
+
+	err := os.MkdirAll(buildDir, os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = os.WriteFile(outImageFilePath, []byte{}, 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = CustomizeImage(buildDir, buildDir, &imagecustomizerapi.Config{}, "base.vhdx", nil, outImageFilePath, "vhd",
+		"", false, CustomizeImageOptions{})
+	assert.ErrorContains(t, err, "output image file ("+outImageFilePath+") already exists")
+}
+
+func TestToQemuImageFormat(t *testing.T) {
+	testCases := []struct {
+		imageFormat     string
+		qemuImageFormat string
+	}{
+		{"vhd", "vpc"},
+		{"VHD", "vpc"},
+		{"vhdx", "vhdx"},
+		{"VHDX", "vhdx"},
+		{"QCOW2", "qcow2"},
+		{"Raw", "raw"},
+		{"raw-sparse", "raw"},
+		{"RAW-SPARSE", "raw"},
+	}
+
+	for _, testCase := range testCases {
+		qemuImageFormat, err := toQemuImageFormat(testCase.imageFormat)
+		assert.NoError(t, err, testCase.imageFormat)
+		assert.Equal(t, testCase.qemuImageFormat, qemuImageFormat, testCase.imageFormat)
+	}
+
+	_, err := toQemuImageFormat("iso")
+	assert.ErrorContains(t, err, "unsupported image format")
+}
+
+func TestToQemuPreallocationOptions(t *testing.T) {
+	options, err := toQemuPreallocationOptions("qcow2", "")
+	assert.NoError(t, err)
+	assert.Nil(t, options)
+
+	options, err = toQemuPreallocationOptions("qcow2", "metadata")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"-o", "preallocation=metadata"}, options)
+
+	options, err = toQemuPreallocationOptions("raw", "full")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"-o", "preallocation=full"}, options)
+
+	options, err = toQemuPreallocationOptions("vhdx", "full")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"-o", "subformat=fixed"}, options)
+
+	_, err = toQemuPreallocationOptions("raw", "metadata")
+	assert.ErrorContains(t, err, "unsupported preallocation for raw image format")
+
+	_, err = toQemuPreallocationOptions("vhd", "falloc")
+	assert.ErrorContains(t, err, "unsupported preallocation for vhd image format")
+
+	options, err = toQemuPreallocationOptions("raw-sparse", "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"-S", "4k", "-o", "preallocation=off"}, options)
+
+	options, err = toQemuPreallocationOptions("raw-sparse", "off")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"-S", "4k", "-o", "preallocation=off"}, options)
+
+	_, err = toQemuPreallocationOptions("raw-sparse", "full")
+	assert.ErrorContains(t, err, "unsupported preallocation for raw-sparse image format")
+}
+
+func TestValidateConfigFileNoRpmSources(t *testing.T) {
+	// RPM sources are provided separately from the config. So, they aren't checked.
+	err := ValidateConfigFile(filepath.Join(testDir, "updatepackages-config.yaml"))
+	assert.NoError(t, err)
+}
+
+func TestValidateConfigFileUnknownField(t *testing.T) {
+	testTmpDir := filepath.Join(tmpDir, "TestValidateConfigFileUnknownField")
+	defer os.RemoveAll(testTmpDir)
+
+	err := os.MkdirAll(testTmpDir, os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	configFile := filepath.Join(testTmpDir, "config.yaml")
+
+	err = os.WriteFile(configFile, []byte("SystemConfig:\n  HostName: test\n"), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = ValidateConfigFile(configFile)
+	assert.ErrorContains(t, err, "field HostName not found")
+}
+
+func TestValidateConfigFileMissing(t *testing.T) {
+	err := ValidateConfigFile(filepath.Join(testDir, "missing-config.yaml"))
+	assert.Error(t, err)
+}
+
+func TestValidateConfigDoesNotModifyConfig(t *testing.T) {
+	config := &imagecustomizerapi.Config{
+		SystemConfig: imagecustomizerapi.SystemConfig{
+			PackageListsInstall: []string{"lists/dracut-fips.yaml"},
+		},
+	}
+
+	err := ValidateConfig(testDir, config)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"lists/dracut-fips.yaml"}, config.SystemConfig.PackageListsInstall)
+	assert.Empty(t, config.SystemConfig.PackagesInstall)
+}
+
+func TestValidateConfigMissingPackageList(t *testing.T) {
+	err := ValidateConfig(testDir, &imagecustomizerapi.Config{
+		SystemConfig: imagecustomizerapi.SystemConfig{
+			PackageListsInstall: []string{"lists/missing.yaml"},
+		},
+	})
+	assert.ErrorContains(t, err, "failed to read package list file")
+}
+
+func TestValidateRpmSourcesReleaseVersion(t *testing.T) {
+	repoFilePath := filepath.Join(tmpDir, "TestValidateRpmSourcesReleaseVersion.repo")
+	repoFileContents := "[mariner]\nbaseurl=https://example.com/$releasever/prod/base/$basearch\n"
+
+	err := os.WriteFile(repoFilePath, []byte(repoFileContents), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	config := imagecustomizerapi.SystemConfig{
+		PackagesInstall: []string{"jq"},
+	}
+
+	err = validateRpmSources(&config, []string{repoFilePath}, false, false)
+	assert.ErrorContains(t, err, "uses $releasever but ReleaseVersion is not specified")
+
+	config.ReleaseVersion = "2.0"
+
+	err = validateRpmSources(&config, []string{repoFilePath}, false, false)
+	assert.NoError(t, err)
+}
+
+func TestValidateRpmSourcesUpdateBaseImagePackages(t *testing.T) {
+	config := imagecustomizerapi.SystemConfig{
+		UpdateBaseImagePackages: true,
+	}
+
+	err := validateRpmSources(&config, nil, false, false)
+	assert.ErrorContains(t, err, "UpdateBaseImagePackages is set but no RPM sources were specified")
+
+	err = validateRpmSources(&config, nil, true, false)
+	assert.NoError(t, err)
+
+	err = validateRpmSources(&config, []string{tmpDir}, false, false)
+	assert.NoError(t, err)
+}
+
+func TestValidateRpmSourcesTdnfCommands(t *testing.T) {
+	config := imagecustomizerapi.SystemConfig{
+		TdnfCommands: [][]string{{"mark", "install", "jq"}},
+	}
+
+	err := validateRpmSources(&config, nil, false, false)
+	assert.ErrorContains(t, err, "TdnfCommands to run, but no RPM sources were specified")
+
+	err = validateRpmSources(&config, nil, true, false)
+	assert.NoError(t, err)
+}
+
+func TestValidateRpmSourcesPackagesInstallDebuginfo(t *testing.T) {
+	config := imagecustomizerapi.SystemConfig{
+		PackagesInstallDebuginfo: true,
+	}
+
+	err := validateRpmSources(&config, nil, false, false)
+	assert.ErrorContains(t, err, "no RPM sources were specified")
+
+	err = validateRpmSources(&config, nil, true, false)
+	assert.NoError(t, err)
+}
+
 func TestCustomizeImageKernelCommandLineAdd(t *testing.T) {
 	var err error
 
@@ -235,7 +483,8 @@ func TestCustomizeImageKernelCommandLineAdd(t *testing.T) {
 		},
 	}
 
-	err = CustomizeImage(buildDir, buildDir, config, diskFilePath, nil, outImageFilePath, "raw", "", false)
+	err = CustomizeImage(buildDir, buildDir, config, diskFilePath, nil, outImageFilePath, "raw", "", false,
+		CustomizeImageOptions{})
 	if !assert.NoError(t, err) {
 		return
 	}
@@ -317,8 +566,8 @@ func createFakeEfiImage(buildDir string) (string, error) {
 		return nil
 	}
 
-	err = createNewImage(rawDisk, diskConfig, partitionSettings, "efi",
-		imagecustomizerapi.KernelCommandLine{}, buildDir, testImageRootDirName, installOS)
+	err = createNewImage(rawDisk, testDir, diskConfig, partitionSettings, "efi",
+		imagecustomizerapi.KernelCommandLine{}, buildDir, testImageRootDirName, installOS, nil)
 	if err != nil {
 		return "", err
 	}
@@ -359,3 +608,51 @@ func getImageFileType(filePath string) (string, error) {
 
 	return "", fmt.Errorf("unknown file type: %s", filePath)
 }
+
+func TestValidateDisksMultipleDisks(t *testing.T) {
+	disks := []imagecustomizerapi.Disk{
+		{
+			PartitionTableType: imagecustomizerapi.PartitionTableTypeGpt,
+			MaxSize:            4096,
+			Partitions: []imagecustomizerapi.Partition{{
+				ID:     "rootfs",
+				FsType: imagecustomizerapi.FileSystemTypeExt4,
+				Start:  1,
+			}},
+		},
+		{
+			PartitionTableType: imagecustomizerapi.PartitionTableTypeGpt,
+			MaxSize:            4096,
+			Partitions: []imagecustomizerapi.Partition{{
+				ID:     "data",
+				FsType: imagecustomizerapi.FileSystemTypeExt4,
+				Start:  1,
+			}},
+		},
+	}
+
+	// Multiple disks are valid in the config API, but they can't be built.
+	err := validateDisks(testDir, &disks)
+	assert.ErrorContains(t, err, "writing more than 1 disk (2 specified) is not currently supported")
+}
+
+func TestValidateConfigMultipleErrors(t *testing.T) {
+	config := &imagecustomizerapi.Config{
+		SystemConfig: imagecustomizerapi.SystemConfig{
+			Hostname: "bad_hostname",
+			AdditionalFiles: map[string]imagecustomizerapi.FileConfigList{
+				"files/does-not-exist.txt": {{Path: "/a.txt"}},
+			},
+			PostInstallScripts: []imagecustomizerapi.Script{
+				{
+					Path: "scripts/does-not-exist.sh",
+				},
+			},
+		},
+	}
+
+	err := ValidateConfig(testDir, config)
+	assert.ErrorContains(t, err, "invalid hostname: bad_hostname")
+	assert.ErrorContains(t, err, "invalid AdditionalFiles source file (files/does-not-exist.txt)")
+	assert.ErrorContains(t, err, "invalid PostInstallScripts item at index 0")
+}
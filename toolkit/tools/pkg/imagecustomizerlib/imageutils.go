@@ -7,13 +7,16 @@ import (
 	"fmt"
 	"path/filepath"
 	"sort"
+	"strings"
 
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagecustomizerapi"
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagegen/configuration"
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagegen/diskutils"
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagegen/installutils"
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/file"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/logger"
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/safechroot"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/shell"
 )
 
 type installOSFunc func(imageChroot *safechroot.Chroot) error
@@ -38,13 +41,13 @@ func connectToExistingImageHelper(imageConnection *ImageConnection, imageFilePat
 	// Connect to image file using loopback device.
 	err := imageConnection.ConnectLoopback(imageFilePath)
 	if err != nil {
-		return err
+		return &MountError{Err: err}
 	}
 
 	// Look for all the partitions on the image.
 	newMountDirectories, mountPoints, err := findPartitions(buildDir, imageConnection.Loopback().DevicePath())
 	if err != nil {
-		return fmt.Errorf("failed to find disk partitions:\n%w", err)
+		return &PartitionDiscoveryError{Err: fmt.Errorf("failed to find disk partitions:\n%w", err)}
 	}
 
 	// Create chroot environment.
@@ -52,19 +55,19 @@ func connectToExistingImageHelper(imageConnection *ImageConnection, imageFilePat
 
 	err = imageConnection.ConnectChroot(imageChrootDir, false, newMountDirectories, mountPoints, includeDefaultMounts)
 	if err != nil {
-		return err
+		return &MountError{Err: err}
 	}
 
 	return nil
 }
 
-func createNewImage(filename string, diskConfig imagecustomizerapi.Disk,
+func createNewImage(filename string, baseConfigPath string, diskConfig imagecustomizerapi.Disk,
 	partitionSettings []imagecustomizerapi.PartitionSetting, bootType imagecustomizerapi.BootType,
 	kernelCommandLine imagecustomizerapi.KernelCommandLine, buildDir string, chrootDirName string,
-	installOS installOSFunc,
+	installOS installOSFunc, basePartitionUuids map[string]partitionUuids,
 ) error {
-	err := createNewImageHelper(filename, diskConfig, partitionSettings, bootType, kernelCommandLine,
-		buildDir, chrootDirName, installOS,
+	err := createNewImageHelper(filename, baseConfigPath, diskConfig, partitionSettings, bootType, kernelCommandLine,
+		buildDir, chrootDirName, installOS, basePartitionUuids,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to create new image:\n%w", err)
@@ -73,26 +76,21 @@ func createNewImage(filename string, diskConfig imagecustomizerapi.Disk,
 	return nil
 }
 
-func createNewImageHelper(filename string, diskConfig imagecustomizerapi.Disk,
+func createNewImageHelper(filename string, baseConfigPath string, diskConfig imagecustomizerapi.Disk,
 	partitionSettings []imagecustomizerapi.PartitionSetting, bootType imagecustomizerapi.BootType,
 	kernelCommandLine imagecustomizerapi.KernelCommandLine, buildDir string, chrootDirName string,
-	installOS installOSFunc,
+	installOS installOSFunc, basePartitionUuids map[string]partitionUuids,
 ) error {
 	imageConnection := NewImageConnection()
 	defer imageConnection.Close()
 
 	// Convert config to image config types, so that the imager's utils can be used.
-	imagerBootType, err := bootTypeToImager(bootType)
-	if err != nil {
-		return err
-	}
-
 	imagerDiskConfig, err := diskConfigToImager(diskConfig)
 	if err != nil {
 		return err
 	}
 
-	imagerPartitionSettings, err := partitionSettingsToImager(partitionSettings)
+	imagerPartitionSettings, err := partitionSettingsToImager(partitionSettingsForDisk(diskConfig, partitionSettings))
 	if err != nil {
 		return err
 	}
@@ -108,8 +106,8 @@ func createNewImageHelper(filename string, diskConfig imagecustomizerapi.Disk,
 	})
 
 	// Create imager boilerplate.
-	mountPointMap, tmpFstabFile, err := createImageBoilerplate(imageConnection, filename, buildDir, chrootDirName, imagerDiskConfig,
-		imagerPartitionSettings)
+	mountPointMap, tmpFstabFile, err := createImageBoilerplate(imageConnection, filename, buildDir, chrootDirName,
+		baseConfigPath, diskConfig, imagerDiskConfig, imagerPartitionSettings, basePartitionUuids)
 	if err != nil {
 		return err
 	}
@@ -128,12 +126,25 @@ func createNewImageHelper(filename string, diskConfig imagecustomizerapi.Disk,
 		return fmt.Errorf("failed to move fstab into new image:\n%w", err)
 	}
 
-	// Configure the boot loader.
-	err = installutils.ConfigureDiskBootloader(imagerBootType, false, false, imagerPartitionSettings,
-		imagerKernelCommandLine, imageConnection.Chroot(), imageConnection.Loopback().DevicePath(),
-		mountPointMap, diskutils.EncryptedRootDevice{}, diskutils.VerityDevice{})
+	// Configure the encrypted partitions to be unlocked during boot.
+	err = configureLuksDevices(imageConnection.LuksDevices(), imageConnection.Chroot().RootDir())
 	if err != nil {
-		return fmt.Errorf("failed to install bootloader:\n%w", err)
+		return err
+	}
+
+	// Configure the boot loader.
+	if bootType != imagecustomizerapi.BootTypeNone {
+		imagerBootType, err := bootTypeToImager(bootType)
+		if err != nil {
+			return err
+		}
+
+		err = installutils.ConfigureDiskBootloader(imagerBootType, false, false, imagerPartitionSettings,
+			imagerKernelCommandLine, imageConnection.Chroot(), imageConnection.Loopback().DevicePath(),
+			mountPointMap, diskutils.EncryptedRootDevice{}, diskutils.VerityDevice{})
+		if err != nil {
+			return fmt.Errorf("failed to install bootloader:\n%w", err)
+		}
 	}
 
 	// Close image.
@@ -146,7 +157,8 @@ func createNewImageHelper(filename string, diskConfig imagecustomizerapi.Disk,
 }
 
 func createImageBoilerplate(imageConnection *ImageConnection, filename string, buildDir string, chrootDirName string,
-	imagerDiskConfig configuration.Disk, imagerPartitionSettings []configuration.PartitionSetting,
+	baseConfigPath string, diskConfig imagecustomizerapi.Disk, imagerDiskConfig configuration.Disk,
+	imagerPartitionSettings []configuration.PartitionSetting, basePartitionUuids map[string]partitionUuids,
 ) (map[string]string, string, error) {
 	// Create raw disk image file.
 	err := diskutils.CreateSparseDisk(filename, imagerDiskConfig.MaxSize, 0o644)
@@ -157,15 +169,22 @@ func createImageBoilerplate(imageConnection *ImageConnection, filename string, b
 	// Connect raw disk image file.
 	err = imageConnection.ConnectLoopback(filename)
 	if err != nil {
-		return nil, "", err
+		return nil, "", &MountError{Err: err}
 	}
 
 	// Set up partitions.
-	partIDToDevPathMap, partIDToFsTypeMap, _, _, err := diskutils.CreatePartitions(
-		imageConnection.Loopback().DevicePath(), imagerDiskConfig, configuration.RootEncryption{},
-		configuration.ReadOnlyVerityRoot{})
+	partIDToDevPathMap, partIDToFsTypeMap, err := createPartitionsHelper(imageConnection, baseConfigPath, diskConfig,
+		imagerDiskConfig)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to create partitions on disk (%s):\n%w", imageConnection.Loopback().DevicePath(), err)
+		return nil, "", err
+	}
+
+	// Restore the base image's partition UUIDs, if requested.
+	// Note: This is done before the fstab file is generated, so that the fstab file uses the restored UUIDs.
+	err = restorePartitionUuids(imageConnection.Loopback().DevicePath(), diskConfig, imagerPartitionSettings,
+		partIDToDevPathMap, basePartitionUuids)
+	if err != nil {
+		return nil, "", err
 	}
 
 	// Read the disk partitions.
@@ -206,8 +225,88 @@ func createImageBoilerplate(imageConnection *ImageConnection, filename string, b
 
 	err = imageConnection.ConnectChroot(imageChrootDir, false, nil, mountPoints, false)
 	if err != nil {
-		return nil, "", err
+		return nil, "", &MountError{Err: err}
 	}
 
 	return mountPointMap, tmpFstabFile, nil
 }
+
+// CreatePartitions creates the disk's partitions and formats their filesystems on the image connection's loopback
+// device.
+// Encrypted partitions are opened and added to the image connection, so that they are closed when the connection is
+// closed.
+// Returns the device paths and the filesystem types of the partitions, keyed by partition ID.
+func CreatePartitions(imageConnection *ImageConnection, baseConfigPath string, diskConfig imagecustomizerapi.Disk,
+) (map[string]string, map[string]string, error) {
+	if imageConnection.Loopback() == nil {
+		return nil, nil, fmt.Errorf("loopback not connected")
+	}
+
+	imagerDiskConfig, err := diskConfigToImager(diskConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return createPartitionsHelper(imageConnection, baseConfigPath, diskConfig, imagerDiskConfig)
+}
+
+func createPartitionsHelper(imageConnection *ImageConnection, baseConfigPath string,
+	diskConfig imagecustomizerapi.Disk, imagerDiskConfig configuration.Disk,
+) (map[string]string, map[string]string, error) {
+	diskDevPath := imageConnection.Loopback().DevicePath()
+
+	partIDToDevPathMap, partIDToFsTypeMap, _, _, err := diskutils.CreatePartitions(diskDevPath, imagerDiskConfig,
+		configuration.RootEncryption{}, configuration.ReadOnlyVerityRoot{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create partitions on disk (%s):\n%w", diskDevPath, err)
+	}
+
+	// Set the partitions' GPT attributes, which the imager's utils don't support.
+	err = setPartitionsGptAttributes(diskDevPath, diskConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Encrypt the partitions, which the imager's utils only support for the root partition.
+	err = encryptPartitions(imageConnection, baseConfigPath, diskConfig, imagerDiskConfig, partIDToDevPathMap)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return partIDToDevPathMap, partIDToFsTypeMap, nil
+}
+
+// setPartitionsGptAttributes sets the GPT attribute bits (e.g. read-only, no-automount) of the disk's partitions.
+func setPartitionsGptAttributes(diskDevPath string, diskConfig imagecustomizerapi.Disk) error {
+	args := gptAttributesSgdiskArgs(diskConfig)
+	if len(args) <= 0 {
+		return nil
+	}
+
+	logger.Log.Infof("Setting partition GPT attributes")
+
+	args = append(args, diskDevPath)
+
+	_, stderr, err := shell.Execute("sgdisk", args...)
+	if err != nil {
+		return fmt.Errorf("failed to set partition GPT attributes on disk (%s):\n%s\n%w", diskDevPath,
+			strings.TrimSpace(stderr), err)
+	}
+
+	return nil
+}
+
+// gptAttributesSgdiskArgs returns the sgdisk args that set the partitions' GPT attribute bits.
+func gptAttributesSgdiskArgs(diskConfig imagecustomizerapi.Disk) []string {
+	args := []string(nil)
+	for i, partition := range diskConfig.Partitions {
+		// The partitions are numbered in the order they are listed.
+		partitionNumber := i + 1
+
+		for _, attribute := range partition.GptAttributes {
+			args = append(args, fmt.Sprintf("--attributes=%d:set:%d", partitionNumber, attribute))
+		}
+	}
+
+	return args
+}
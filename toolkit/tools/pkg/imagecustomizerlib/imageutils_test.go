@@ -0,0 +1,120 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagecustomizerapi"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagegen/diskutils"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/buildpipeline"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/ptrutils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGptAttributesSgdiskArgs(t *testing.T) {
+	diskConfig := imagecustomizerapi.Disk{
+		PartitionTableType: imagecustomizerapi.PartitionTableTypeGpt,
+		Partitions: []imagecustomizerapi.Partition{
+			{ID: "esp"},
+			{ID: "verityhash", GptAttributes: []uint{60}},
+			{ID: "data", GptAttributes: []uint{60, 63}},
+		},
+	}
+
+	args := gptAttributesSgdiskArgs(diskConfig)
+	assert.Equal(t, []string{"--attributes=2:set:60", "--attributes=3:set:60", "--attributes=3:set:63"}, args)
+}
+
+func TestGptAttributesSgdiskArgsNone(t *testing.T) {
+	diskConfig := imagecustomizerapi.Disk{
+		PartitionTableType: imagecustomizerapi.PartitionTableTypeGpt,
+		Partitions: []imagecustomizerapi.Partition{
+			{ID: "esp"},
+		},
+	}
+
+	args := gptAttributesSgdiskArgs(diskConfig)
+	assert.Empty(t, args)
+}
+
+func TestCreatePartitions(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Short mode enabled")
+	}
+
+	if !buildpipeline.IsRegularBuild() {
+		t.Skip("loopback block device not available")
+	}
+
+	if os.Geteuid() != 0 {
+		t.Skip("Test must be run as root because it uses a loopback device")
+	}
+
+	buildDir := filepath.Join(tmpDir, "TestCreatePartitions")
+	diskFilePath := filepath.Join(buildDir, "disk.raw")
+
+	err := os.MkdirAll(buildDir, os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	diskConfig := imagecustomizerapi.Disk{
+		PartitionTableType: imagecustomizerapi.PartitionTableTypeGpt,
+		MaxSize:            64,
+		Partitions: []imagecustomizerapi.Partition{
+			{
+				ID:     "esp",
+				Flags:  []imagecustomizerapi.PartitionFlag{"esp", "boot"},
+				Start:  1,
+				End:    ptrutils.PtrTo(uint64(9)),
+				FsType: "fat32",
+			},
+			{
+				ID:     "rootfs",
+				Start:  9,
+				FsType: "ext4",
+			},
+		},
+	}
+
+	err = diskutils.CreateSparseDisk(diskFilePath, diskConfig.MaxSize, 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	imageConnection := NewImageConnection()
+	defer imageConnection.Close()
+
+	err = imageConnection.ConnectLoopback(diskFilePath)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	partIDToDevPathMap, partIDToFsTypeMap, err := CreatePartitions(imageConnection, testDir, diskConfig)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Len(t, partIDToDevPathMap, 2)
+	assert.Equal(t, "vfat", partIDToFsTypeMap["esp"])
+	assert.Equal(t, "ext4", partIDToFsTypeMap["rootfs"])
+
+	diskPartitions, err := diskutils.GetDiskPartitions(imageConnection.Loopback().DevicePath())
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	fsTypes := make(map[string]string)
+	for _, diskPartition := range diskPartitions {
+		if diskPartition.Type == "part" {
+			fsTypes[diskPartition.Path] = diskPartition.FileSystemType
+		}
+	}
+
+	assert.Equal(t, "vfat", fsTypes[partIDToDevPathMap["esp"]])
+	assert.Equal(t, "ext4", fsTypes[partIDToDevPathMap["rootfs"]])
+}
@@ -0,0 +1,169 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/logger"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/shell"
+)
+
+const (
+	outputImageTempFileSuffix = ".tmp"
+)
+
+var (
+	compressionFileExtensions = map[string]string{
+		"zstd": "zst",
+		"gzip": "gz",
+	}
+)
+
+// writeOutputImage converts the raw build image into the final output image.
+// The image is written to a temporary file first and then renamed, so that the output file is either absent or
+// complete, even if the build is interrupted.
+func writeOutputImage(buildImageFile string, outputImageFile string, qemuOutputImageFormat string,
+	qemuOutputImageOptions []string,
+) error {
+	logger.Log.Infof("Writing: %s", outputImageFile)
+
+	outDir := filepath.Dir(outputImageFile)
+	os.MkdirAll(outDir, os.ModePerm)
+
+	tempOutputImageFile := outputImageFile + outputImageTempFileSuffix
+
+	args := []string{"convert", "-O", qemuOutputImageFormat}
+	args = append(args, qemuOutputImageOptions...)
+	args = append(args, buildImageFile, tempOutputImageFile)
+
+	err := shell.ExecuteLiveWithErr(1, "qemu-img", args...)
+	if err != nil {
+		os.Remove(tempOutputImageFile)
+		return err
+	}
+
+	// The temp file is in the same directory as the output file. So, the rename replaces the output file in a single
+	// step.
+	err = os.Rename(tempOutputImageFile, outputImageFile)
+	if err != nil {
+		os.Remove(tempOutputImageFile)
+		return fmt.Errorf("failed to move output image file into place (%s):\n%w", outputImageFile, err)
+	}
+
+	return nil
+}
+
+// validateOutputImageCompression checks that the output image compression type is supported and that it can be used
+// with the other output image options.
+func validateOutputImageCompression(outputImageFormat string, outputImagePreallocation string,
+	outputImageCompression string,
+) error {
+	_, err := compressionProgramArgs(outputImageCompression)
+	if err != nil {
+		return err
+	}
+
+	if outputImageFormat != "raw" {
+		return fmt.Errorf("output image compression requires the 'raw' output image format")
+	}
+
+	// The compressed file doesn't retain the raw image's allocation.
+	if outputImagePreallocation != "" {
+		return fmt.Errorf("output image preallocation can't be used with output image compression")
+	}
+
+	return nil
+}
+
+// compressedOutputImageFile returns the output image file's path with the compression type's file extension appended,
+// if it isn't already present.
+func compressedOutputImageFile(outputImageFile string, outputImageCompression string) string {
+	extension := "." + compressionFileExtensions[outputImageCompression]
+	if strings.HasSuffix(outputImageFile, extension) {
+		return outputImageFile
+	}
+
+	return outputImageFile + extension
+}
+
+// writeCompressedOutputImage compresses the raw build image into the final output image.
+// The build image is streamed directly into the compressor. So, there is no intermediate copy of the raw image.
+func writeCompressedOutputImage(buildImageFile string, outputImageFile string, outputImageCompression string) error {
+	logger.Log.Infof("Writing: %s", outputImageFile)
+
+	args, err := compressionProgramArgs(outputImageCompression)
+	if err != nil {
+		return err
+	}
+
+	args = append(args, buildImageFile)
+
+	outDir := filepath.Dir(outputImageFile)
+	os.MkdirAll(outDir, os.ModePerm)
+
+	tempOutputImageFile := outputImageFile + outputImageTempFileSuffix
+
+	err = compressFile(args[0], args[1:], tempOutputImageFile)
+	if err != nil {
+		os.Remove(tempOutputImageFile)
+		return err
+	}
+
+	// The temp file is in the same directory as the output file. So, the rename replaces the output file in a single
+	// step.
+	err = os.Rename(tempOutputImageFile, outputImageFile)
+	if err != nil {
+		os.Remove(tempOutputImageFile)
+		return fmt.Errorf("failed to move output image file into place (%s):\n%w", outputImageFile, err)
+	}
+
+	return nil
+}
+
+func compressFile(program string, args []string, outputFile string) error {
+	output, err := os.Create(outputFile)
+	if err != nil {
+		return fmt.Errorf("failed to create compressed file (%s):\n%w", outputFile, err)
+	}
+	defer output.Close()
+
+	stderr := &strings.Builder{}
+
+	cmd := exec.Command(program, args...)
+	cmd.Stdout = output
+	cmd.Stderr = stderr
+
+	err = cmd.Run()
+	if err != nil {
+		return fmt.Errorf("failed to compress file with %s:\n%s\n%w", program, strings.TrimSpace(stderr.String()), err)
+	}
+
+	err = output.Close()
+	if err != nil {
+		return fmt.Errorf("failed to write compressed file (%s):\n%w", outputFile, err)
+	}
+
+	return nil
+}
+
+// compressionProgramArgs returns the command-line (excluding the input file) that writes the compressed data to
+// stdout.
+func compressionProgramArgs(outputImageCompression string) ([]string, error) {
+	switch outputImageCompression {
+	case "zstd":
+		return []string{"zstd", "-9", "-T0", "--quiet", "--stdout"}, nil
+
+	case "gzip":
+		return []string{"gzip", "--stdout"}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported output image compression (supported: zstd, gzip): %s",
+			outputImageCompression)
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompressedOutputImageFile(t *testing.T) {
+	assert.Equal(t, "image.raw.zst", compressedOutputImageFile("image.raw", "zstd"))
+	assert.Equal(t, "image.raw.gz", compressedOutputImageFile("image.raw", "gzip"))
+	assert.Equal(t, "image.raw.gz", compressedOutputImageFile("image.raw.gz", "gzip"))
+}
+
+func TestValidateOutputImageCompression(t *testing.T) {
+	err := validateOutputImageCompression("raw", "", "zstd")
+	assert.NoError(t, err)
+
+	err = validateOutputImageCompression("raw", "", "xz")
+	assert.ErrorContains(t, err, "unsupported output image compression (supported: zstd, gzip): xz")
+
+	err = validateOutputImageCompression("qcow2", "", "gzip")
+	assert.ErrorContains(t, err, "output image compression requires the 'raw' output image format")
+
+	err = validateOutputImageCompression("raw", "full", "gzip")
+	assert.ErrorContains(t, err, "output image preallocation can't be used with output image compression")
+}
+
+func TestWriteCompressedOutputImageGzip(t *testing.T) {
+	testTmpDir := filepath.Join(tmpDir, "TestWriteCompressedOutputImageGzip")
+	defer os.RemoveAll(testTmpDir)
+
+	err := os.MkdirAll(testTmpDir, os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	buildImageFile := filepath.Join(testTmpDir, "image.raw")
+	outputImageFile := filepath.Join(testTmpDir, "out", "image.raw.gz")
+
+	err = os.WriteFile(buildImageFile, []byte("raw image contents"), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = writeCompressedOutputImage(buildImageFile, outputImageFile, "gzip")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	compressedFile, err := os.Open(outputImageFile)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer compressedFile.Close()
+
+	reader, err := gzip.NewReader(compressedFile)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	contents, err := io.ReadAll(reader)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "raw image contents", string(contents))
+	assert.NoFileExists(t, outputImageFile+outputImageTempFileSuffix)
+}
@@ -0,0 +1,65 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/logger"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/safechroot"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/shell"
+)
+
+// writePackageManifest writes the name-version-release of each of the image's installed packages to a file.
+// The list is sorted, so that the manifests of different builds can be diffed.
+func writePackageManifest(packageManifestFile string, imageChroot *safechroot.Chroot) error {
+	if packageManifestFile == "" {
+		return nil
+	}
+
+	logger.Log.Infof("Writing package manifest (%s)", packageManifestFile)
+
+	stdout := ""
+	err := imageChroot.UnsafeRun(func() error {
+		var err error
+		stdout, _, err = shell.Execute("rpm", "-qa", "--queryformat", "%{NAME}-%{VERSION}-%{RELEASE}\n")
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list installed packages:\n%w", err)
+	}
+
+	err = os.WriteFile(packageManifestFile, []byte(packageManifestContents(stdout)), 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to write package manifest (%s):\n%w", packageManifestFile, err)
+	}
+
+	return nil
+}
+
+// packageManifestContents converts the output of `rpm -qa` into the contents of a package manifest file.
+func packageManifestContents(rpmOutput string) string {
+	packages := []string(nil)
+	for _, line := range strings.Split(rpmOutput, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		packages = append(packages, line)
+	}
+
+	sort.Strings(packages)
+
+	builder := strings.Builder{}
+	for _, nvr := range packages {
+		builder.WriteString(nvr)
+		builder.WriteString("\n")
+	}
+
+	return builder.String()
+}
@@ -0,0 +1,21 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPackageManifestContents(t *testing.T) {
+	rpmOutput := "zlib-1.2.13-2.cm2\nbash-5.1.8-4.cm2\n\nkernel-5.15.148.2-2.cm2\n"
+
+	assert.Equal(t, "bash-5.1.8-4.cm2\nkernel-5.15.148.2-2.cm2\nzlib-1.2.13-2.cm2\n",
+		packageManifestContents(rpmOutput))
+}
+
+func TestPackageManifestContentsEmpty(t *testing.T) {
+	assert.Equal(t, "", packageManifestContents(""))
+}
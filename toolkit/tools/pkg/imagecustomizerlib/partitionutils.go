@@ -8,12 +8,15 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagegen/diskutils"
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/file"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/logger"
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/safechroot"
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/safemount"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/sliceutils"
 )
 
 var (
@@ -332,6 +335,16 @@ func fstabEntriesToMountPoints(fstabEntries []diskutils.FstabEntry, diskPartitio
 			continue
 		}
 
+		// Encrypted partitions are only unlocked while a new image is being created.
+		if diskutils.IsEncryptedDevice(fstabEntry.Source) &&
+			!sliceutils.ContainsFunc(diskPartitions, func(partition diskutils.PartitionInfo) bool {
+				return partition.Path == fstabEntry.Source
+			}) {
+			logger.Log.Infof("Skipping mount of locked encrypted partition (%s) at (%s)", fstabEntry.Source,
+				fstabEntry.Target)
+			continue
+		}
+
 		source, err := findSourcePartition(fstabEntry.Source, diskPartitions)
 		if err != nil {
 			return nil, err
@@ -357,6 +370,12 @@ func fstabEntriesToMountPoints(fstabEntries []diskutils.FstabEntry, diskPartitio
 		return nil, fmt.Errorf("image has invalid fstab file: no root partition found")
 	}
 
+	// Ensure parent directories are mounted before their children (e.g. /boot before /boot/efi), regardless of the
+	// order of the fstab file. Otherwise, a separate /boot partition would hide the ESP's mount.
+	sort.SliceStable(mountPoints, func(i, j int) bool {
+		return mountPoints[i].Target() < mountPoints[j].Target()
+	})
+
 	return mountPoints, nil
 }
 
@@ -372,5 +391,37 @@ func findSourcePartition(source string, partitions []diskutils.PartitionInfo) (s
 		return "", fmt.Errorf("partition not found: %s", source)
 	}
 
+	uuid, isUuid := strings.CutPrefix(source, "UUID=")
+	if isUuid {
+		for _, partition := range partitions {
+			if partition.Uuid == uuid {
+				return partition.Path, nil
+			}
+		}
+
+		return "", fmt.Errorf("partition not found: %s", source)
+	}
+
+	partLabel, isPartLabel := strings.CutPrefix(source, "PARTLABEL=")
+	if isPartLabel {
+		for _, partition := range partitions {
+			if partition.PartLabel == partLabel {
+				return partition.Path, nil
+			}
+		}
+
+		return "", fmt.Errorf("partition not found: %s", source)
+	}
+
+	if diskutils.IsEncryptedDevice(source) {
+		for _, partition := range partitions {
+			if partition.Path == source {
+				return partition.Path, nil
+			}
+		}
+
+		return "", fmt.Errorf("encrypted partition not found: %s", source)
+	}
+
 	return "", fmt.Errorf("unknown fstab source type: %s", source)
 }
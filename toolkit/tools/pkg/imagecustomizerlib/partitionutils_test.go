@@ -0,0 +1,43 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"testing"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagegen/diskutils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFstabEntriesToMountPointsSeparateBoot(t *testing.T) {
+	diskPartitions := []diskutils.PartitionInfo{
+		{Path: "/dev/loop0p1", Uuid: "4BD9-3A78", PartUuid: "a2dc5b7a-0fe1-4a5e-a4e4-5d7c1d4ef48a"},
+		{Path: "/dev/loop0p2", Uuid: "0b4c8e5b-4f8a-4b0e-9a2a-6c1b8b8e2f11", PartLabel: "boot"},
+		{Path: "/dev/loop0p3", Uuid: "f3b1c9e2-7a5d-4e6f-8b9c-0d1e2f3a4b5c", PartUuid: "7b1367a6-5845-43f2-99b1-a742d873f590"},
+	}
+
+	// The ESP is listed before /boot, which would hide the ESP if mounted in fstab order.
+	fstabEntries := []diskutils.FstabEntry{
+		{Source: "PARTUUID=7b1367a6-5845-43f2-99b1-a742d873f590", Target: "/", FsType: "ext4"},
+		{Source: "UUID=4BD9-3A78", Target: "/boot/efi", FsType: "vfat"},
+		{Source: "PARTLABEL=boot", Target: "/boot", FsType: "ext4"},
+		{Source: "proc", Target: "/proc", FsType: "proc"},
+	}
+
+	mountPoints, err := fstabEntriesToMountPoints(fstabEntries, diskPartitions)
+	assert.NoError(t, err)
+	if assert.Len(t, mountPoints, 3) {
+		assert.Equal(t, "/", mountPoints[0].Target())
+		assert.Equal(t, "/dev/loop0p3", mountPoints[0].Source())
+		assert.Equal(t, "/boot", mountPoints[1].Target())
+		assert.Equal(t, "/dev/loop0p2", mountPoints[1].Source())
+		assert.Equal(t, "/boot/efi", mountPoints[2].Target())
+		assert.Equal(t, "/dev/loop0p1", mountPoints[2].Source())
+	}
+}
+
+func TestFindSourcePartitionUnknownUuid(t *testing.T) {
+	_, err := findSourcePartition("UUID=missing", []diskutils.PartitionInfo{{Path: "/dev/loop0p1", Uuid: "4BD9-3A78"}})
+	assert.ErrorContains(t, err, "partition not found: UUID=missing")
+}
@@ -0,0 +1,160 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagecustomizerapi"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagegen/configuration"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagegen/diskutils"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/logger"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/safechroot"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/shell"
+)
+
+// partitionUuids are the UUIDs of a base image's partition, which are reapplied to the recreated partition that has
+// the same mount point.
+type partitionUuids struct {
+	PartUuid string
+	FsUuid   string
+	FsType   string
+}
+
+// readBasePartitionUuids reads the UUIDs of the base image's partitions, keyed by mount point.
+func readBasePartitionUuids(imageConnection *ImageConnection) (map[string]partitionUuids, error) {
+	diskPartitions, err := diskutils.GetDiskPartitions(imageConnection.Loopback().DevicePath())
+	if err != nil {
+		return nil, err
+	}
+
+	fstabPath := filepath.Join(imageConnection.Chroot().RootDir(), "etc/fstab")
+	mountPoints, err := findMountsFromFstabFile(fstabPath, diskPartitions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read base image's partition UUIDs:\n%w", err)
+	}
+
+	return partitionUuidsByMountPoint(mountPoints, diskPartitions), nil
+}
+
+func partitionUuidsByMountPoint(mountPoints []*safechroot.MountPoint, diskPartitions []diskutils.PartitionInfo,
+) map[string]partitionUuids {
+	uuids := make(map[string]partitionUuids)
+	for _, mountPoint := range mountPoints {
+		for _, diskPartition := range diskPartitions {
+			if diskPartition.Path == mountPoint.Source() {
+				uuids[mountPoint.Target()] = partitionUuids{
+					PartUuid: diskPartition.PartUuid,
+					FsUuid:   diskPartition.Uuid,
+					FsType:   diskPartition.FileSystemType,
+				}
+				break
+			}
+		}
+	}
+
+	return uuids
+}
+
+// restorePartitionUuids applies the base image's partition UUIDs to the newly created (and formatted) partitions.
+// This must be called before the partitions are mounted and before the new image's fstab file is generated.
+func restorePartitionUuids(diskDevPath string, diskConfig imagecustomizerapi.Disk,
+	partitionSettings []configuration.PartitionSetting, partIDToDevPathMap map[string]string,
+	basePartitionUuids map[string]partitionUuids,
+) error {
+	if basePartitionUuids == nil {
+		return nil
+	}
+
+	logger.Log.Infof("Restoring base image's partition UUIDs")
+
+	sgdiskArgs := []string(nil)
+	for i, partition := range diskConfig.Partitions {
+		mountPoint := ""
+		for _, partitionSetting := range partitionSettings {
+			if partitionSetting.ID == partition.ID {
+				mountPoint = partitionSetting.MountPoint
+				break
+			}
+		}
+
+		uuids, found := basePartitionUuids[mountPoint]
+		if mountPoint == "" || !found {
+			logger.Log.Infof("Partition (%s) has no matching base image partition", partition.ID)
+			continue
+		}
+
+		if uuids.PartUuid != "" && diskConfig.PartitionTableType == imagecustomizerapi.PartitionTableTypeGpt {
+			// The partitions are numbered in the order they are listed.
+			sgdiskArgs = append(sgdiskArgs, fmt.Sprintf("--partition-guid=%d:%s", i+1, uuids.PartUuid))
+		}
+
+		if uuids.FsUuid == "" || partition.Luks != nil {
+			continue
+		}
+
+		partDevPath := partIDToDevPathMap[partition.ID]
+
+		program, args, supported := filesystemUuidCommand(partition.FsType, uuids.FsType, partDevPath, uuids.FsUuid)
+		if !supported {
+			logger.Log.Warnf("Can't restore filesystem UUID of partition (%s): base filesystem type (%s) differs "+
+				"from new filesystem type (%s)", partition.ID, uuids.FsType, partition.FsType)
+			continue
+		}
+
+		_, stderr, err := shell.Execute(program, args...)
+		if err != nil {
+			return fmt.Errorf("failed to set filesystem UUID of partition (%s):\n%s\n%w", partition.ID,
+				strings.TrimSpace(stderr), err)
+		}
+	}
+
+	if len(sgdiskArgs) > 0 {
+		sgdiskArgs = append(sgdiskArgs, diskDevPath)
+
+		_, stderr, err := shell.Execute("sgdisk", sgdiskArgs...)
+		if err != nil {
+			return fmt.Errorf("failed to set partition UUIDs on disk (%s):\n%s\n%w", diskDevPath,
+				strings.TrimSpace(stderr), err)
+		}
+
+		_, stderr, err = shell.Execute("flock", "--timeout", "5", diskDevPath, "partprobe", "-s", diskDevPath)
+		if err != nil {
+			return fmt.Errorf("failed to reload partition table of disk (%s):\n%s\n%w", diskDevPath,
+				strings.TrimSpace(stderr), err)
+		}
+	}
+
+	// Ensure the new UUIDs are visible to lsblk.
+	err := diskutils.WaitForDevicesToSettle()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// filesystemUuidCommand returns the command that sets a new filesystem's UUID to the base filesystem's UUID.
+// Returns false if the base filesystem's UUID can't be applied to the new filesystem.
+func filesystemUuidCommand(fsType imagecustomizerapi.FileSystemType, baseFsType string, partDevPath string,
+	uuid string,
+) (string, []string, bool) {
+	switch fsType {
+	case imagecustomizerapi.FileSystemTypeExt4:
+		switch baseFsType {
+		case "ext2", "ext3", "ext4":
+			return "tune2fs", []string{"-U", uuid, partDevPath}, true
+		}
+
+	case imagecustomizerapi.FileSystemTypeFat32:
+		if baseFsType == "vfat" {
+			// FAT volume IDs are formatted as XXXX-XXXX but fatlabel expects them without the dash.
+			return "fatlabel", []string{"-i", partDevPath, strings.ReplaceAll(uuid, "-", "")}, true
+		}
+	}
+
+	return "", nil, false
+}
@@ -0,0 +1,74 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"testing"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagecustomizerapi"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagegen/diskutils"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/safechroot"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartitionUuidsByMountPoint(t *testing.T) {
+	diskPartitions := []diskutils.PartitionInfo{
+		{
+			Path:           "/dev/loop0p1",
+			FileSystemType: "vfat",
+			Uuid:           "4BD9-3A78",
+			PartUuid:       "7b1367a6-5845-43f2-99b1-a742d873f590",
+		},
+		{
+			Path:           "/dev/loop0p2",
+			FileSystemType: "ext4",
+			Uuid:           "a2b3c4d5-1111-2222-3333-444455556666",
+			PartUuid:       "0e5e1c5a-7b0c-4c39-9d4f-2a4e0b6f5d11",
+		},
+		{
+			Path: "/dev/loop0p3",
+		},
+	}
+
+	mountPoints := []*safechroot.MountPoint{
+		safechroot.NewPreDefaultsMountPoint("/dev/loop0p2", "/", "ext4", 0, ""),
+		safechroot.NewMountPoint("/dev/loop0p1", "/boot/efi", "vfat", 0, ""),
+	}
+
+	uuids := partitionUuidsByMountPoint(mountPoints, diskPartitions)
+	assert.Equal(t, map[string]partitionUuids{
+		"/": {
+			PartUuid: "0e5e1c5a-7b0c-4c39-9d4f-2a4e0b6f5d11",
+			FsUuid:   "a2b3c4d5-1111-2222-3333-444455556666",
+			FsType:   "ext4",
+		},
+		"/boot/efi": {
+			PartUuid: "7b1367a6-5845-43f2-99b1-a742d873f590",
+			FsUuid:   "4BD9-3A78",
+			FsType:   "vfat",
+		},
+	}, uuids)
+}
+
+func TestFilesystemUuidCommand(t *testing.T) {
+	program, args, supported := filesystemUuidCommand(imagecustomizerapi.FileSystemTypeExt4, "ext4", "/dev/loop1p2",
+		"a2b3c4d5-1111-2222-3333-444455556666")
+	assert.True(t, supported)
+	assert.Equal(t, "tune2fs", program)
+	assert.Equal(t, []string{"-U", "a2b3c4d5-1111-2222-3333-444455556666", "/dev/loop1p2"}, args)
+
+	program, args, supported = filesystemUuidCommand(imagecustomizerapi.FileSystemTypeFat32, "vfat", "/dev/loop1p1",
+		"4BD9-3A78")
+	assert.True(t, supported)
+	assert.Equal(t, "fatlabel", program)
+	assert.Equal(t, []string{"-i", "/dev/loop1p1", "4BD93A78"}, args)
+
+	_, _, supported = filesystemUuidCommand(imagecustomizerapi.FileSystemTypeExt4, "xfs", "/dev/loop1p2",
+		"a2b3c4d5-1111-2222-3333-444455556666")
+	assert.False(t, supported)
+
+	_, _, supported = filesystemUuidCommand(imagecustomizerapi.FileSystemTypeXfs, "xfs", "/dev/loop1p2",
+		"a2b3c4d5-1111-2222-3333-444455556666")
+	assert.False(t, supported)
+}
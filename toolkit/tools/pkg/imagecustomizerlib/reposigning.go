@@ -0,0 +1,193 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/file"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/logger"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/shell"
+)
+
+const (
+	repoSigningPublicKeyFileName = "repo-signing-key.asc"
+	signedRepoMetadataDirName    = "signed_repodata"
+)
+
+// Signs the metadata of the local RPM repos, so that tdnf can verify the repos.
+type repoSigner struct {
+	gnupgHome   string
+	fingerprint string
+}
+
+// newRepoSigner imports a GPG secret key into a temporary keyring and checks that it can be used to sign files.
+func newRepoSigner(buildDir string, signingKeyFile string) (*repoSigner, error) {
+	gnupgHome, err := os.MkdirTemp(buildDir, "gnupg-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GPG home directory:\n%w", err)
+	}
+
+	signer := &repoSigner{
+		gnupgHome: gnupgHome,
+	}
+
+	err = signer.importKey(signingKeyFile)
+	if err != nil {
+		signer.close()
+		return nil, fmt.Errorf("invalid repo signing key (%s):\n%w", signingKeyFile, err)
+	}
+
+	return signer, nil
+}
+
+func (s *repoSigner) importKey(signingKeyFile string) error {
+	_, stderr, err := shell.Execute("gpg", "--homedir", s.gnupgHome, "--batch", "--import", signingKeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to import key:\n%s\n%w", strings.TrimSpace(stderr), err)
+	}
+
+	stdout, _, err := shell.Execute("gpg", "--homedir", s.gnupgHome, "--batch", "--with-colons", "--list-secret-keys")
+	if err != nil {
+		return fmt.Errorf("failed to list secret keys:\n%w", err)
+	}
+
+	s.fingerprint = parseGpgSecretKeyFingerprint(stdout)
+	if s.fingerprint == "" {
+		return fmt.Errorf("file doesn't contain a secret key")
+	}
+
+	// Check that the key can actually be used to sign (e.g. it isn't expired or protected by a passphrase).
+	testFile := filepath.Join(s.gnupgHome, "test")
+	err = file.Write("test", testFile)
+	if err != nil {
+		return err
+	}
+
+	err = s.signFile(testFile)
+	if err != nil {
+		return fmt.Errorf("key can't be used for signing:\n%w", err)
+	}
+
+	return nil
+}
+
+// parseGpgSecretKeyFingerprint returns the fingerprint of the first secret key listed in the output of
+// `gpg --with-colons --list-secret-keys`.
+func parseGpgSecretKeyFingerprint(listOutput string) string {
+	foundSecretKey := false
+	for _, line := range strings.Split(listOutput, "\n") {
+		fields := strings.Split(line, ":")
+		switch {
+		case fields[0] == "sec":
+			foundSecretKey = true
+
+		case fields[0] == "fpr" && foundSecretKey && len(fields) > 9:
+			return fields[9]
+		}
+	}
+
+	return ""
+}
+
+// signRepoCopy copies the metadata of an RPM repo into a new directory within the build directory and creates a
+// detached signature (repomd.xml.asc) for the copy.
+// The repo itself isn't modified, since it may be a shared extracted RPMs cache entry or the user's RPM source
+// directory.
+// Returns the directory containing the signed copy of the metadata.
+func (s *repoSigner) signRepoCopy(buildDir string, repoDir string) (string, error) {
+	repodataDir := filepath.Join(repoDir, "repodata")
+	copiesDir := filepath.Join(buildDir, signedRepoMetadataDirName)
+
+	err := os.MkdirAll(copiesDir, os.ModePerm)
+	if err != nil {
+		return "", fmt.Errorf("failed to create signed repo metadata directory (%s):\n%w", copiesDir, err)
+	}
+
+	copyDir, err := os.MkdirTemp(copiesDir, "repodata-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create signed repo metadata copy directory:\n%w", err)
+	}
+
+	err = s.signRepoCopyHelper(repodataDir, copyDir)
+	if err != nil {
+		cleanupErr := os.RemoveAll(copyDir)
+		if cleanupErr != nil {
+			logger.Log.Warnf("failed to delete signed repo metadata copy directory (%s): %s", copyDir, cleanupErr)
+		}
+		return "", err
+	}
+
+	return copyDir, nil
+}
+
+func (s *repoSigner) signRepoCopyHelper(repodataDir string, copyDir string) error {
+	entries, err := os.ReadDir(repodataDir)
+	if err != nil {
+		return fmt.Errorf("failed to read repo metadata directory (%s):\n%w", repodataDir, err)
+	}
+
+	for _, entry := range entries {
+		// Don't copy an existing signature, since it is replaced below.
+		if !entry.Type().IsRegular() || entry.Name() == "repomd.xml.asc" {
+			continue
+		}
+
+		sourcePath := filepath.Join(repodataDir, entry.Name())
+		err = file.Copy(sourcePath, filepath.Join(copyDir, entry.Name()))
+		if err != nil {
+			return fmt.Errorf("failed to copy repo metadata file (%s):\n%w", sourcePath, err)
+		}
+	}
+
+	repomdFile := filepath.Join(copyDir, "repomd.xml")
+
+	logger.Log.Debugf("Signing repo metadata (%s)", filepath.Join(repodataDir, "repomd.xml"))
+
+	err = s.signFile(repomdFile)
+	if err != nil {
+		return fmt.Errorf("failed to sign repo metadata (%s):\n%w", filepath.Join(repodataDir, "repomd.xml"), err)
+	}
+
+	return nil
+}
+
+func (s *repoSigner) signFile(filePath string) error {
+	_, stderr, err := shell.Execute("gpg", "--homedir", s.gnupgHome, "--batch", "--yes", "--local-user",
+		s.fingerprint, "--armor", "--detach-sign", "--output", filePath+".asc", filePath)
+	if err != nil {
+		return fmt.Errorf("%s\n%w", strings.TrimSpace(stderr), err)
+	}
+
+	return nil
+}
+
+// exportPublicKey writes the public key of the signing key to a file.
+func (s *repoSigner) exportPublicKey(publicKeyFile string) error {
+	_, stderr, err := shell.Execute("gpg", "--homedir", s.gnupgHome, "--batch", "--yes", "--armor", "--output",
+		publicKeyFile, "--export", s.fingerprint)
+	if err != nil {
+		return fmt.Errorf("failed to export repo signing public key:\n%s\n%w", strings.TrimSpace(stderr), err)
+	}
+
+	return nil
+}
+
+func (s *repoSigner) close() error {
+	// Stop the gpg-agent that gpg started for the temporary GPG home directory.
+	_, _, err := shell.Execute("gpgconf", "--homedir", s.gnupgHome, "--kill", "gpg-agent")
+	if err != nil {
+		logger.Log.Debugf("Failed to stop gpg-agent: %s", err)
+	}
+
+	err = os.RemoveAll(s.gnupgHome)
+	if err != nil {
+		return fmt.Errorf("failed to delete GPG home directory (%s):\n%w", s.gnupgHome, err)
+	}
+
+	return nil
+}
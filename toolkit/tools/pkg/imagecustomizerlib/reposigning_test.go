@@ -0,0 +1,96 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/shell"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseGpgSecretKeyFingerprint(t *testing.T) {
+	listOutput := `sec:u:3072:1:0123456789ABCDEF:1700000000:::u:::scESC:::+:::23::0:
+fpr:::::::::0000111122223333444455550123456789ABCDEF:
+grp:::::::::AAAABBBBCCCCDDDDEEEEFFFF0000111122223333:
+uid:u::::1700000000::0000000000000000000000000000000000000000::Repo Signing <repo@example.com>::::::::::0:
+ssb:u:3072:1:FEDCBA9876543210:1700000000::::::e:::+:::23:
+fpr:::::::::99998888777766665555FEDCBA9876543210:
+`
+
+	fingerprint := parseGpgSecretKeyFingerprint(listOutput)
+	assert.Equal(t, "0000111122223333444455550123456789ABCDEF", fingerprint)
+}
+
+func TestParseGpgSecretKeyFingerprintNoSecretKey(t *testing.T) {
+	fingerprint := parseGpgSecretKeyFingerprint("")
+	assert.Equal(t, "", fingerprint)
+}
+
+func TestSignRepoCopy(t *testing.T) {
+	if _, err := exec.LookPath("gpg"); err != nil {
+		t.Skip("gpg not available")
+	}
+
+	// Note: gpg-agent's socket is placed in the GPG home directory. So, use a short path, since Unix socket paths
+	// have a small length limit.
+	testDir, err := os.MkdirTemp("", "TestSignRepoCopy-")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	buildDir := filepath.Join(testDir, "build")
+	repoDir := filepath.Join(testDir, "repo")
+	keyHome := filepath.Join(testDir, "keyhome")
+	keyFile := filepath.Join(testDir, "key.asc")
+
+	defer os.RemoveAll(testDir)
+
+	for _, dir := range []string{buildDir, filepath.Join(repoDir, "repodata"), keyHome} {
+		err := os.MkdirAll(dir, 0o700)
+		if !assert.NoError(t, err) {
+			return
+		}
+	}
+
+	err = os.WriteFile(filepath.Join(repoDir, "repodata", "repomd.xml"), []byte("<repomd/>\n"), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// Create a signing key.
+	_, _, err = shell.Execute("gpg", "--homedir", keyHome, "--batch", "--passphrase", "", "--quick-gen-key",
+		"Repo Signing <repo@example.com>", "default", "sign", "never")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, _, err = shell.Execute("gpg", "--homedir", keyHome, "--batch", "--armor", "--output", keyFile,
+		"--export-secret-keys")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	defer shell.Execute("gpgconf", "--homedir", keyHome, "--kill", "gpg-agent")
+
+	signer, err := newRepoSigner(buildDir, keyFile)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer signer.close()
+
+	copyDir, err := signer.signRepoCopy(buildDir, repoDir)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// Ensure the copy was signed and the repo itself wasn't modified.
+	assert.FileExists(t, filepath.Join(copyDir, "repomd.xml"))
+	assert.FileExists(t, filepath.Join(copyDir, "repomd.xml.asc"))
+	assert.NoFileExists(t, filepath.Join(repoDir, "repodata", "repomd.xml.asc"))
+	assert.Equal(t, filepath.Join(buildDir, signedRepoMetadataDirName), filepath.Dir(copyDir))
+}
@@ -0,0 +1,93 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/file"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	rpmsDirectoryCopiesDirName = "rpm_sources"
+)
+
+// isDirWritable checks if the current process can write to a directory.
+func isDirWritable(dirPath string) (bool, error) {
+	err := unix.Access(dirPath, unix.W_OK)
+	if errors.Is(err, unix.EACCES) || errors.Is(err, unix.EROFS) || errors.Is(err, unix.EPERM) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check if directory (%s) is writable:\n%w", dirPath, err)
+	}
+
+	return true, nil
+}
+
+// copyRpmsDirectory copies the RPM files in a directory (including its subdirectories) into a new directory within
+// the build directory, so that the repo metadata can be created without modifying the source directory.
+// The copy is deleted when the RPM sources are unmounted.
+func (m *rpmSourcesMounts) copyRpmsDirectory(buildDir string, rpmSource string) (string, error) {
+	copiesDir := filepath.Join(buildDir, rpmsDirectoryCopiesDirName)
+
+	err := os.MkdirAll(copiesDir, os.ModePerm)
+	if err != nil {
+		return "", fmt.Errorf("failed to create RPM sources copy directory (%s):\n%w", copiesDir, err)
+	}
+
+	copyDir, err := os.MkdirTemp(copiesDir, sanitizeMountName(filepath.Base(rpmSource))+"-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create RPM source copy directory:\n%w", err)
+	}
+
+	m.rpmsDirectoryCopies = append(m.rpmsDirectoryCopies, copyDir)
+
+	err = copyRpmFiles(rpmSource, copyDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to copy RPM source directory (%s):\n%w", rpmSource, err)
+	}
+
+	return copyDir, nil
+}
+
+// copyRpmFiles copies the RPM files within a directory tree, preserving their relative paths.
+// Hard links are used where possible, since they are much faster than copying the files.
+func copyRpmFiles(sourceDir string, targetDir string) error {
+	return filepath.WalkDir(sourceDir, func(sourcePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !d.Type().IsRegular() || !strings.HasSuffix(d.Name(), ".rpm") {
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(sourceDir, sourcePath)
+		if err != nil {
+			return err
+		}
+
+		targetPath := filepath.Join(targetDir, relativePath)
+
+		err = os.MkdirAll(filepath.Dir(targetPath), os.ModePerm)
+		if err != nil {
+			return err
+		}
+
+		err = os.Link(sourcePath, targetPath)
+		if err == nil {
+			return nil
+		}
+
+		// Hard links don't work across filesystems. So, fallback to copying the file.
+		return file.Copy(sourcePath, targetPath)
+	})
+}
@@ -0,0 +1,63 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCopyRpmFiles(t *testing.T) {
+	testTmpDir := filepath.Join(tmpDir, "TestCopyRpmFiles")
+	sourceDir := filepath.Join(testTmpDir, "source")
+	targetDir := filepath.Join(testTmpDir, "target")
+
+	files := []string{
+		"a.rpm",
+		"sub/b.rpm",
+		"repodata/repomd.xml",
+		"README.md",
+	}
+
+	for _, name := range files {
+		filePath := filepath.Join(sourceDir, name)
+
+		err := os.MkdirAll(filepath.Dir(filePath), os.ModePerm)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		err = os.WriteFile(filePath, []byte(name), 0o644)
+		if !assert.NoError(t, err) {
+			return
+		}
+	}
+
+	err := copyRpmFiles(sourceDir, targetDir)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.FileExists(t, filepath.Join(targetDir, "a.rpm"))
+	assert.FileExists(t, filepath.Join(targetDir, "sub/b.rpm"))
+	assert.NoFileExists(t, filepath.Join(targetDir, "repodata/repomd.xml"))
+	assert.NoFileExists(t, filepath.Join(targetDir, "README.md"))
+
+	// The source directory should be left unmodified.
+	entries, err := os.ReadDir(sourceDir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 4)
+}
+
+func TestIsDirWritable(t *testing.T) {
+	writable, err := isDirWritable(tmpDir)
+	assert.NoError(t, err)
+	assert.True(t, writable)
+
+	_, err = isDirWritable(filepath.Join(tmpDir, "TestIsDirWritableMissing"))
+	assert.Error(t, err)
+}
@@ -4,16 +4,26 @@
 package imagecustomizerlib
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"unicode"
 
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagecustomizerapi"
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/file"
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/logger"
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/packagerepo/repomanager/rpmrepomanager"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/rpm"
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/safechroot"
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/safemount"
 	"github.com/sirupsen/logrus"
@@ -25,21 +35,83 @@ const (
 	rpmsMountParentDirInChroot = "/_localrpms"
 )
 
+var (
+	// The repo config keys that contain URLs.
+	repoUrlKeys = []string{"baseurl", "mirrorlist", "metalink"}
+
+	// The URL schemes of the RPM sources that are remote repos.
+	rpmSourceUrlSchemes = []string{"http", "https"}
+
+	// The RPM source file extensions that contain more than one dot.
+	rpmSourceMultiPartFileExts = []string{".tar.gz", ".tar.xz", ".tar.bz2"}
+
+	// The magic number at the start of every RPM file.
+	rpmLeadMagic = []byte{0xed, 0xab, 0xee, 0xdb}
+
+	// Matches the runs of characters that aren't safe to use in a mount directory name.
+	mountNameUnsafeCharsRegex = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+	// Matches the priority suffix of an RPM source (e.g. `=10` in `/rpms=10`).
+	rpmSourcePriorityRegex = regexp.MustCompile(`^[+-]?[0-9]+$`)
+
+	// Matches the repo config lines that contain secrets (e.g. `password=...` for a repo that requires authentication).
+	repoConfigSecretRegex = regexp.MustCompile(`(?mi)^(\s*(?:password|proxy_password)\s*=).*$`)
+
+	// Matches a repo config variable reference (e.g. `$basearch` or `${basearch}`).
+	repoVariableRegex = regexp.MustCompile(`\$(?:\{(\w+)\}|(\w+))`)
+
+	// The INI options used to read and write repo config files.
+	// These try to match how tdnf parses the files, so that the repo configs survive the round-trip into the
+	// allrepos.repo file unmodified.
+	repoConfigLoadOptions = ini.LoadOptions{
+		// Allow values to be split over multiple lines (e.g. a list of baseurls or gpgkeys).
+		AllowPythonMultilineValues: true,
+		// tdnf doesn't support inline comments. So, '#' and ';' are valid value characters (e.g. in URLs).
+		// This also stops the values from being wrapped in backticks when the file is written.
+		IgnoreInlineComment: true,
+		// tdnf doesn't strip quotes from values.
+		PreserveSurroundedQuote: true,
+	}
+)
+
 // Used to manage (including cleanup) the mounts required by package installation/update.
 type rpmSourcesMounts struct {
 	rpmsMountParentDir        string
 	rpmsMountParentDirCreated bool
 	mounts                    []*safemount.Mount
 	allReposConfigFilePath    string
+	repoVariables             map[string]string
+	baseConfigPath            string
+	extractedRpmsLocks        []*os.File
+	rpmsDirectoryCopies       []string
+	signedRepoMetadataMounts  []*safemount.Mount
+	signedRepoMetadataCopies  []string
+	preserveRpmSourceDirs     bool
+	repoSigner                *repoSigner
+	repoSigningKeyFilePath    string
+	localRpmFiles             []string
+	packagesGpgKeyFiles       []string
+	hostReposConfigDir        string
+	metadataExpire            string
 }
 
-func mountRpmSources(buildDir string, imageChroot *safechroot.Chroot, rpmsSources []string,
-	useBaseImageRpmRepos bool,
+func mountRpmSources(buildDir string, baseConfigPath string, imageChroot *safechroot.Chroot, rpmsSources []string,
+	useBaseImageRpmRepos bool, preserveRpmSourceDirs bool, repoSigningKeyFile string, releaseVersion string,
+	packagesGpgKeys []string, metadataExpire string,
 ) (*rpmSourcesMounts, error) {
 	var err error
 
 	var mounts rpmSourcesMounts
-	err = mounts.mountRpmSourcesHelper(buildDir, imageChroot, rpmsSources, useBaseImageRpmRepos)
+	mounts.baseConfigPath = baseConfigPath
+	mounts.preserveRpmSourceDirs = preserveRpmSourceDirs
+	mounts.metadataExpire = metadataExpire
+	mounts.repoVariables, err = getRepoVariables(releaseVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	err = mounts.mountRpmSourcesHelper(buildDir, imageChroot, rpmsSources, useBaseImageRpmRepos, repoSigningKeyFile,
+		packagesGpgKeys)
 	if err != nil {
 		cleanupErr := mounts.close()
 		if cleanupErr != nil {
@@ -52,7 +124,7 @@ func mountRpmSources(buildDir string, imageChroot *safechroot.Chroot, rpmsSource
 }
 
 func (m *rpmSourcesMounts) mountRpmSourcesHelper(buildDir string, imageChroot *safechroot.Chroot, rpmsSources []string,
-	useBaseImageRpmRepos bool,
+	useBaseImageRpmRepos bool, repoSigningKeyFile string, packagesGpgKeys []string,
 ) error {
 	var err error
 
@@ -66,10 +138,32 @@ func (m *rpmSourcesMounts) mountRpmSourcesHelper(buildDir string, imageChroot *s
 
 	m.rpmsMountParentDirCreated = true
 
+	if repoSigningKeyFile != "" {
+		err = m.setupRepoSigning(buildDir, repoSigningKeyFile)
+		if err != nil {
+			return err
+		}
+
+		// The signing key is only needed while the local repos are being created.
+		defer func() {
+			cleanupErr := m.repoSigner.close()
+			if cleanupErr != nil {
+				logger.Log.Warnf("repo signer cleanup failed: %s", cleanupErr)
+			}
+			m.repoSigner = nil
+		}()
+	}
+
+	err = m.addPackagesGpgKeys(packagesGpgKeys)
+	if err != nil {
+		return err
+	}
+
 	// Unfortunatley, tdnf doesn't support the repository priority field.
 	// So, to ensure repos are used in the correct order, create a single config file containing all the repos, specified
-	// in the order of highest priority to lowest priority.
-	allReposConfig := ini.Empty()
+	// in the order of lowest priority to highest priority.
+	// Note: The RPM sources have already been ordered by their priority (see orderRpmSourcesByPriority()).
+	allReposConfig := ini.Empty(repoConfigLoadOptions)
 
 	// Include base image's RPM sources.
 	if useBaseImageRpmRepos {
@@ -106,11 +200,20 @@ func (m *rpmSourcesMounts) mountRpmSourcesHelper(buildDir string, imageChroot *s
 
 		switch fileType {
 		case "dir":
-			err = m.createRepoFromDirectory(rpmSource, allReposConfig, imageChroot)
+			err = m.createRepoFromDirectory(buildDir, rpmSource, allReposConfig, imageChroot)
 
 		case "repo":
 			err = m.createRepoFromRepoConfig(rpmSource, true, allReposConfig, imageChroot)
 
+		case "tar":
+			err = m.createRepoFromRpmsTarball(buildDir, rpmSource, allReposConfig, imageChroot)
+
+		case "rpm":
+			err = m.addLocalRpmFile(rpmSource)
+
+		case "url":
+			err = m.createRepoFromUrl(rpmSource, allReposConfig)
+
 		default:
 			return fmt.Errorf("unknown RPM source type (%s)", rpmSource)
 		}
@@ -119,6 +222,13 @@ func (m *rpmSourcesMounts) mountRpmSourcesHelper(buildDir string, imageChroot *s
 		}
 	}
 
+	if m.metadataExpire != "" {
+		err = setReposMetadataExpire(allReposConfig, m.metadataExpire)
+		if err != nil {
+			return fmt.Errorf("failed to set repos' metadata_expire:\n%w", err)
+		}
+	}
+
 	// Create all-repos config file.
 	m.allReposConfigFilePath = filepath.Join(imageChroot.RootDir(), rpmsMountParentDirInChroot, "allrepos.repo")
 	logger.Log.Debugf("Writing allrepos.repo (%s)", m.allReposConfigFilePath)
@@ -131,32 +241,249 @@ func (m *rpmSourcesMounts) mountRpmSourcesHelper(buildDir string, imageChroot *s
 	if logger.Log.IsLevelEnabled(logrus.TraceLevel) {
 		allReposConfigString, err := os.ReadFile(m.allReposConfigFilePath)
 		if err == nil {
-			logger.Log.Tracef("allrepos.repo:\n%s", allReposConfigString)
+			logger.Log.Tracef("allrepos.repo:\n%s", redactRepoConfigSecrets(string(allReposConfigString)))
 		}
 	}
 
 	return nil
 }
 
-func (m *rpmSourcesMounts) createRepoFromDirectory(rpmSource string, allReposConfig *ini.File,
+func (m *rpmSourcesMounts) createRepoFromDirectory(buildDir string, rpmSource string, allReposConfig *ini.File,
 	imageChroot *safechroot.Chroot,
 ) error {
+	rpmsDirectory := rpmSource
+
+	writable, err := isDirWritable(rpmSource)
+	if err != nil {
+		return err
+	}
+
+	if m.preserveRpmSourceDirs || !writable {
+		// Create the repo in a copy of the directory, so that the directory isn't modified.
+		// This is required when the directory is read-only.
+		logger.Log.Infof("Creating repo from a copy of the RPM source directory (%s)", rpmSource)
+
+		rpmsDirectory, err = m.copyRpmsDirectory(buildDir, rpmSource)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Turn directory into an RPM repo.
-	err := rpmrepomanager.CreateOrUpdateRepo(rpmSource)
+	err = rpmrepomanager.CreateOrUpdateRepo(rpmsDirectory)
 	if err != nil {
 		return fmt.Errorf("failed create RPMs repo from directory (%s):\n%w", rpmSource, err)
 	}
 
 	rpmSourceName := path.Base(rpmSource)
+	return m.addLocalRepo(buildDir, rpmSourceName, rpmsDirectory, allReposConfig, imageChroot)
+}
+
+// createRepoFromUrl adds a remote (http/https) repo to the allrepos.repo file.
+// Nothing is mounted for the repo. Instead, tdnf accesses the repo over the network, using the host's resolv.conf
+// file (see overrideResolvConf).
+func (m *rpmSourcesMounts) createRepoFromUrl(rpmSource string, allReposConfig *ini.File) error {
+	repoUrl, err := url.Parse(rpmSource)
+	if err != nil {
+		return fmt.Errorf("invalid RPM source URL (%s):\n%w", rpmSource, err)
+	}
+
+	// Give each repo a unique name, so that the URL repos don't merge with each other or with the other repos.
+	i := len(allReposConfig.Sections())
+	repoName := fmt.Sprintf("%02d%s", i, sanitizeMountName(repoUrl.Host+repoUrl.Path))
+
+	iniSection, err := allReposConfig.NewSection(repoName)
+	if err != nil {
+		return err
+	}
+
+	_, err = iniSection.NewKey("name", repoName)
+	if err != nil {
+		return err
+	}
+
+	_, err = iniSection.NewKey("baseurl", substituteRepoVariables(rpmSource, m.repoVariables))
+	if err != nil {
+		return err
+	}
+
+	_, err = iniSection.NewKey("enabled", "1")
+	if err != nil {
+		return err
+	}
+
+	err = setRepoGpgKeys(iniSection, "", m.packagesGpgKeysInChroot())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (m *rpmSourcesMounts) createRepoFromRpmsTarball(buildDir string, rpmSource string, allReposConfig *ini.File,
+	imageChroot *safechroot.Chroot,
+) error {
+	// Note: The extracted directory is already an RPM repo.
+	extractDir, lock, err := extractRpmsTarball(buildDir, rpmSource)
+	if err != nil {
+		return err
+	}
+
+	// Hold the lock until the RPMs are unmounted, so that the directory isn't evicted while it is in use.
+	m.extractedRpmsLocks = append(m.extractedRpmsLocks, lock)
+
+	rpmSourceName, _ := splitRpmSourceFileExt(path.Base(rpmSource))
+
+	return m.addLocalRepo(buildDir, rpmSourceName, extractDir, allReposConfig, imageChroot)
+}
+
+// addLocalRpmFile places an RPM file in the chroot, so that it can be installed directly (i.e. not via a repo).
+func (m *rpmSourcesMounts) addLocalRpmFile(rpmSource string) error {
+	// Note: The mount directory names never contain a '.'. So, the RPM file names can't clash with them.
+	fileName := filepath.Base(rpmSource)
+	targetPath := filepath.Join(m.rpmsMountParentDir, fileName)
+
+	exists, err := file.PathExists(targetPath)
+	if err != nil {
+		return fmt.Errorf("failed to check if RPM file exists (%s):\n%w", targetPath, err)
+	}
 
+	if exists {
+		return fmt.Errorf("multiple RPM sources have the same RPM file name (%s)", fileName)
+	}
+
+	err = os.Link(rpmSource, targetPath)
+	if err != nil {
+		// Hard links don't work across filesystems. So, fallback to copying the file.
+		err = file.Copy(rpmSource, targetPath)
+		if err != nil {
+			return fmt.Errorf("failed to copy RPM file (%s):\n%w", rpmSource, err)
+		}
+	}
+
+	m.localRpmFiles = append(m.localRpmFiles, targetPath)
+	return nil
+}
+
+// localRpmFilesInChroot returns the paths (within the chroot) of the RPM files that were added by addLocalRpmFile.
+func (m *rpmSourcesMounts) localRpmFilesInChroot() []string {
+	var paths []string
+	for _, localRpmFile := range m.localRpmFiles {
+		paths = append(paths, path.Join(rpmsMountParentDirInChroot, filepath.Base(localRpmFile)))
+	}
+	return paths
+}
+
+// writeHostReposConfig writes a copy of the allrepos.repo file that can be used from outside of the chroot (e.g. by
+// `tdnf --installroot`), by replacing the in-chroot paths of the local repos with their host paths.
+// Returns the directory containing the copy.
+func (m *rpmSourcesMounts) writeHostReposConfig() (string, error) {
+	allReposConfig, err := os.ReadFile(m.allReposConfigFilePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read all-repos config file (%s):\n%w", m.allReposConfigFilePath, err)
+	}
+
+	hostReposConfig := hostReposConfigContents(string(allReposConfig), m.rpmsMountParentDir)
+
+	// Note: The mount directory names always start with a number. So, the directory name can't clash with them.
+	hostReposConfigDir := filepath.Join(m.rpmsMountParentDir, "host")
+
+	err = os.Mkdir(hostReposConfigDir, os.ModePerm)
+	if err != nil {
+		return "", fmt.Errorf("failed to create host repos config directory (%s):\n%w", hostReposConfigDir, err)
+	}
+
+	m.hostReposConfigDir = hostReposConfigDir
+
+	hostReposConfigFilePath := filepath.Join(hostReposConfigDir, "allrepos.repo")
+
+	err = os.WriteFile(hostReposConfigFilePath, []byte(hostReposConfig), 0o644)
+	if err != nil {
+		return "", fmt.Errorf("failed to write host repos config file (%s):\n%w", hostReposConfigFilePath, err)
+	}
+
+	return hostReposConfigDir, nil
+}
+
+// hostReposConfigContents replaces the in-chroot `file://` paths in a repo config with the equivalent host paths.
+func hostReposConfigContents(reposConfig string, rpmsMountParentDir string) string {
+	return strings.ReplaceAll(reposConfig, "file://"+rpmsMountParentDirInChroot+"/",
+		"file://"+rpmsMountParentDir+"/")
+}
+
+// addPackagesGpgKeys places the GPG keys used to verify the packages' signatures in the chroot, so that the repo
+// configs can reference them.
+func (m *rpmSourcesMounts) addPackagesGpgKeys(packagesGpgKeys []string) error {
+	for i, gpgKey := range packagesGpgKeys {
+		// Note: The mount directory names never contain a '.' and the RPM files always end in '.rpm'. So, the key
+		// file names can't clash with them.
+		targetPath := filepath.Join(m.rpmsMountParentDir, fmt.Sprintf("packages-gpg-key-%02d.asc", i))
+
+		err := file.Copy(gpgKey, targetPath)
+		if err != nil {
+			return fmt.Errorf("failed to copy packages GPG key (%s):\n%w", gpgKey, err)
+		}
+
+		m.packagesGpgKeyFiles = append(m.packagesGpgKeyFiles, targetPath)
+	}
+
+	return nil
+}
+
+// packagesGpgKeysInChroot returns the paths (within the chroot) of the GPG keys that were added by
+// addPackagesGpgKeys.
+func (m *rpmSourcesMounts) packagesGpgKeysInChroot() []string {
+	var paths []string
+	for _, gpgKeyFile := range m.packagesGpgKeyFiles {
+		paths = append(paths, path.Join(rpmsMountParentDirInChroot, filepath.Base(gpgKeyFile)))
+	}
+	return paths
+}
+
+// setupRepoSigning imports the repo signing key and places its public key in the chroot, so that tdnf can verify the
+// local repos.
+func (m *rpmSourcesMounts) setupRepoSigning(buildDir string, repoSigningKeyFile string) error {
+	var err error
+
+	m.repoSigner, err = newRepoSigner(buildDir, repoSigningKeyFile)
+	if err != nil {
+		return err
+	}
+
+	publicKeyFilePath := filepath.Join(m.rpmsMountParentDir, repoSigningPublicKeyFileName)
+
+	err = m.repoSigner.exportPublicKey(publicKeyFilePath)
+	if err != nil {
+		return err
+	}
+
+	m.repoSigningKeyFilePath = publicKeyFilePath
+	return nil
+}
+
+// addLocalRepo mounts a directory containing an RPM repo into the chroot and adds it to the all-repos config.
+func (m *rpmSourcesMounts) addLocalRepo(buildDir string, rpmSourceName string, rpmsDirectory string,
+	allReposConfig *ini.File, imageChroot *safechroot.Chroot,
+) error {
 	// Mount the directory.
-	mountTargetDirectoryInChroot, err := m.mountRpmsDirectory(rpmSourceName, rpmSource, imageChroot)
+	mountTargetDirectoryInChroot, err := m.mountRpmsDirectory(rpmSourceName, rpmsDirectory, imageChroot)
 	if err != nil {
 		return err
 	}
 
+	gpgKeyPathInChroot := ""
+	if m.repoSigner != nil {
+		err = m.mountSignedRepoMetadata(buildDir, rpmsDirectory, mountTargetDirectoryInChroot, imageChroot)
+		if err != nil {
+			return err
+		}
+
+		gpgKeyPathInChroot = path.Join(rpmsMountParentDirInChroot, repoSigningPublicKeyFileName)
+	}
+
 	// Add local repo config.
-	err = appendLocalRepo(allReposConfig, mountTargetDirectoryInChroot)
+	err = appendLocalRepo(allReposConfig, mountTargetDirectoryInChroot, gpgKeyPathInChroot,
+		m.packagesGpgKeysInChroot())
 	if err != nil {
 		return fmt.Errorf("failed to append local repo config:\n%w", err)
 	}
@@ -168,9 +495,9 @@ func (m *rpmSourcesMounts) createRepoFromRepoConfig(rpmSource string, isHostConf
 	imageChroot *safechroot.Chroot,
 ) error {
 	// Parse the repo config file.
-	reposConfig, err := ini.Load(rpmSource)
+	reposConfig, err := loadRepoConfigFile(rpmSource)
 	if err != nil {
-		return fmt.Errorf("failed load repo config file (%s):\n%w", rpmSource, err)
+		return err
 	}
 
 	// Iterate through the list of repos.
@@ -183,29 +510,38 @@ func (m *rpmSourcesMounts) createRepoFromRepoConfig(rpmSource string, isHostConf
 			continue
 		}
 
-		if isHostConfig {
-			baseUrlKey, err := repoConfig.GetKey("baseurl")
-			if err != nil {
-				return fmt.Errorf("invalid repo config (%s):\n%w", rpmSource, err)
+		// Substitute the known variables in the URLs, so that the local directory detection below sees the
+		// actual paths.
+		for _, keyName := range repoUrlKeys {
+			if repoConfig.HasKey(keyName) {
+				urlKey := repoConfig.Key(keyName)
+				urlKey.SetValue(substituteRepoVariables(urlKey.String(), m.repoVariables))
 			}
+		}
 
-			// Check if the repo points to a local directory.
-			baseurl := baseUrlKey.String()
-			filePath, hasFilePrefix := strings.CutPrefix(baseurl, "file://")
-			if hasFilePrefix {
-				// Mount the directory in the chroot.
-				rpmSourceName := path.Base(baseurl)
-				mountTargetDirectoryInChroot, err := m.mountRpmsDirectory(rpmSourceName, filePath, imageChroot)
-				if err != nil {
-					return fmt.Errorf("failed mount repo config local directory (%s):\n%w", rpmSource, err)
-				}
-
-				// Change the baseurl to point to the bind mount directory.
-				newBaseurl := fmt.Sprintf("file://%s", mountTargetDirectoryInChroot)
-				repoConfig.Key("baseurl").SetValue(newBaseurl)
+		hasUrl := false
+		for _, keyName := range repoUrlKeys {
+			if repoConfig.HasKey(keyName) {
+				hasUrl = true
 			}
 		}
 
+		if isHostConfig && !hasUrl {
+			return fmt.Errorf("invalid repo config (%s): repo (%s) must specify one of: %s", rpmSource,
+				repoConfig.Name(), strings.Join(repoUrlKeys, ", "))
+		}
+
+		if isHostConfig && repoConfig.HasKey("baseurl") {
+			// Check if the repo points to any local directories.
+			baseUrlKey := repoConfig.Key("baseurl")
+			newBaseurl, err := m.mountLocalBaseurls(baseUrlKey.String(), imageChroot)
+			if err != nil {
+				return fmt.Errorf("failed mount repo config local directory (%s):\n%w", rpmSource, err)
+			}
+
+			baseUrlKey.SetValue(newBaseurl)
+		}
+
 		// Copy over the repo details to the all-repos config.
 		err := appendIniSection(allReposConfig, repoConfig)
 		if err != nil {
@@ -216,11 +552,61 @@ func (m *rpmSourcesMounts) createRepoFromRepoConfig(rpmSource string, isHostConf
 	return nil
 }
 
+// mountLocalBaseurls bind mounts each of the local directories (i.e. `file://`) in a baseurl value into the chroot
+// and returns the baseurl value with those URLs changed to point to the bind mount directories.
+// A baseurl value may contain multiple URLs, separated by whitespace or commas.
+func (m *rpmSourcesMounts) mountLocalBaseurls(baseurl string, imageChroot *safechroot.Chroot) (string, error) {
+	urls := splitRepoUrls(baseurl)
+
+	for i, url := range urls {
+		filePath, hasFilePrefix := strings.CutPrefix(url, "file://")
+		if !hasFilePrefix {
+			continue
+		}
+
+		filePath = m.resolveLocalRepoPath(filePath)
+
+		isDir, err := file.IsDir(filePath)
+		if err != nil || !isDir {
+			return "", fmt.Errorf("local repo directory (%s) does not exist or is not a directory", filePath)
+		}
+
+		// Mount the directory in the chroot.
+		rpmSourceName := path.Base(filePath)
+		mountTargetDirectoryInChroot, err := m.mountRpmsDirectory(rpmSourceName, filePath, imageChroot)
+		if err != nil {
+			return "", err
+		}
+
+		// Change the URL to point to the bind mount directory.
+		urls[i] = fmt.Sprintf("file://%s", mountTargetDirectoryInChroot)
+	}
+
+	return strings.Join(urls, " "), nil
+}
+
+// resolveLocalRepoPath resolves a (`file://`) repo path.
+// Relative paths are relative to the config file's directory.
+func (m *rpmSourcesMounts) resolveLocalRepoPath(filePath string) string {
+	if filepath.IsAbs(filePath) {
+		return filePath
+	}
+
+	return filepath.Join(m.baseConfigPath, filePath)
+}
+
+// splitRepoUrls splits a repo config baseurl value into its individual URLs.
+func splitRepoUrls(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+}
+
 func (m *rpmSourcesMounts) mountRpmsDirectory(rpmSourceName string, rpmsDirectory string,
 	imageChroot *safechroot.Chroot,
 ) (string, error) {
 	i := len(m.mounts)
-	targetName := fmt.Sprintf("%02d%s", i, rpmSourceName)
+	targetName := fmt.Sprintf("%02d%s", i, sanitizeMountName(rpmSourceName))
 	mountTargetDirectoryInChroot := path.Join(rpmsMountParentDirInChroot, targetName)
 	mountTargetDirectory := path.Join(imageChroot.RootDir(), mountTargetDirectoryInChroot)
 
@@ -234,6 +620,32 @@ func (m *rpmSourcesMounts) mountRpmsDirectory(rpmSourceName string, rpmsDirector
 	return mountTargetDirectoryInChroot, nil
 }
 
+// mountSignedRepoMetadata signs a copy of a local repo's metadata and mounts the copy over the repo's metadata
+// directory in the chroot.
+// Note: The signature isn't written into the repo's directory, since it may be a shared extracted RPMs cache entry
+// or the user's RPM source directory.
+func (m *rpmSourcesMounts) mountSignedRepoMetadata(buildDir string, rpmsDirectory string,
+	mountTargetDirectoryInChroot string, imageChroot *safechroot.Chroot,
+) error {
+	signedRepodataDir, err := m.repoSigner.signRepoCopy(buildDir, rpmsDirectory)
+	if err != nil {
+		return err
+	}
+
+	m.signedRepoMetadataCopies = append(m.signedRepoMetadataCopies, signedRepodataDir)
+
+	repodataDir := path.Join(imageChroot.RootDir(), mountTargetDirectoryInChroot, "repodata")
+
+	mount, err := safemount.NewMount(signedRepodataDir, repodataDir, "", unix.MS_BIND|unix.MS_RDONLY, "", false)
+	if err != nil {
+		return fmt.Errorf("failed to mount signed repo metadata from (%s) to (%s):\n%w", signedRepodataDir,
+			repodataDir, err)
+	}
+
+	m.signedRepoMetadataMounts = append(m.signedRepoMetadataMounts, mount)
+	return nil
+}
+
 func (m *rpmSourcesMounts) close() error {
 	var err error
 	var errs []error
@@ -244,6 +656,51 @@ func (m *rpmSourcesMounts) close() error {
 		errs = append(errs, err)
 	}
 
+	// Delete the host's copy of the allrepos.repo file (if it exists).
+	if m.hostReposConfigDir != "" {
+		err = os.RemoveAll(m.hostReposConfigDir)
+		if err != nil {
+			errs = append(errs, err)
+		}
+
+		m.hostReposConfigDir = ""
+	}
+
+	// Delete the local RPM files.
+	for _, localRpmFile := range m.localRpmFiles {
+		err = os.RemoveAll(localRpmFile)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	m.localRpmFiles = nil
+
+	// Delete the packages GPG key files.
+	for _, gpgKeyFile := range m.packagesGpgKeyFiles {
+		err = os.RemoveAll(gpgKeyFile)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	m.packagesGpgKeyFiles = nil
+
+	// Delete the repo signing public key file (if it exists).
+	err = os.RemoveAll(m.repoSigningKeyFilePath)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	// Unmount the signed repo metadata directories.
+	// Note: These are mounted on top of the rpm source directories. So, they must be unmounted first.
+	for _, mount := range m.signedRepoMetadataMounts {
+		err = mount.CleanClose()
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
 	// Unmount rpm source directories.
 	for _, mount := range m.mounts {
 		err = mount.CleanClose()
@@ -253,6 +710,16 @@ func (m *rpmSourcesMounts) close() error {
 		}
 	}
 
+	// Release the extracted RPMs cache locks.
+	for _, lock := range m.extractedRpmsLocks {
+		err = lock.Close()
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	m.extractedRpmsLocks = nil
+
 	// Join all the errors together.
 	if len(errs) > 0 {
 		err = errors.Join(errs...)
@@ -260,6 +727,28 @@ func (m *rpmSourcesMounts) close() error {
 		return err
 	}
 
+	// Delete the copies of the RPM source directories.
+	// Note: This is only done once all the mounts have been successfully unmounted.
+	for _, rpmsDirectoryCopy := range m.rpmsDirectoryCopies {
+		err = os.RemoveAll(rpmsDirectoryCopy)
+		if err != nil {
+			return fmt.Errorf("failed to delete RPM source copy directory (%s):\n%w", rpmsDirectoryCopy, err)
+		}
+	}
+
+	m.rpmsDirectoryCopies = nil
+
+	// Delete the signed copies of the repos' metadata.
+	for _, signedRepoMetadataCopy := range m.signedRepoMetadataCopies {
+		err = os.RemoveAll(signedRepoMetadataCopy)
+		if err != nil {
+			return fmt.Errorf("failed to delete signed repo metadata copy directory (%s):\n%w",
+				signedRepoMetadataCopy, err)
+		}
+	}
+
+	m.signedRepoMetadataCopies = nil
+
 	// Delete the temporary directory.
 	if m.rpmsMountParentDirCreated {
 		// Note: Do not use `RemoveAll` here in case there are any leftover mounts that failed to unmount.
@@ -274,8 +763,96 @@ func (m *rpmSourcesMounts) close() error {
 	return nil
 }
 
+// splitRpmSourcePriority splits an RPM source of the form `PATH=PRIORITY` into its path and priority.
+// If the RPM source doesn't end with an integer priority, then the whole string is the path and the priority is 0.
+//
+// URLs never have a priority, since a URL's query string (e.g. `?token=123`) can't be told apart from a priority.
+// Likewise, if the whole string is an existing path (e.g. a directory named `rpms=1`), then it is never split.
+func splitRpmSourcePriority(rpmSource string) (string, int, error) {
+	isUrl, err := isRpmSourceUrl(rpmSource)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if isUrl {
+		return rpmSource, 0, nil
+	}
+
+	index := strings.LastIndex(rpmSource, "=")
+	if index < 0 || !rpmSourcePriorityRegex.MatchString(rpmSource[index+1:]) {
+		return rpmSource, 0, nil
+	}
+
+	exists, err := file.PathExists(rpmSource)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to check if RPM source (%s) exists:\n%w", rpmSource, err)
+	}
+
+	if exists {
+		return rpmSource, 0, nil
+	}
+
+	rpmSourcePath := rpmSource[:index]
+	if rpmSourcePath == "" {
+		return "", 0, fmt.Errorf("RPM source (%s) is missing a path", rpmSource)
+	}
+
+	priority, err := strconv.Atoi(rpmSource[index+1:])
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid RPM source (%s) priority:\n%w", rpmSource, err)
+	}
+
+	return rpmSourcePath, priority, nil
+}
+
+// orderRpmSourcesByPriority removes the priorities from a list of RPM sources and returns the RPM source paths
+// ordered from lowest priority to highest priority, which is the order the rest of the code expects.
+// RPM sources with the same priority keep the order they were specified in.
+func orderRpmSourcesByPriority(rpmsSources []string) ([]string, error) {
+	type rpmSourceWithPriority struct {
+		path     string
+		priority int
+	}
+
+	sources := []rpmSourceWithPriority(nil)
+	for _, rpmSource := range rpmsSources {
+		rpmSourcePath, priority, err := splitRpmSourcePriority(rpmSource)
+		if err != nil {
+			return nil, err
+		}
+
+		sources = append(sources, rpmSourceWithPriority{path: rpmSourcePath, priority: priority})
+	}
+
+	sort.SliceStable(sources, func(i, j int) bool {
+		return sources[i].priority < sources[j].priority
+	})
+
+	orderedRpmsSources := []string(nil)
+	for _, source := range sources {
+		orderedRpmsSources = append(orderedRpmsSources, source.path)
+	}
+
+	return orderedRpmsSources, nil
+}
+
+// redactRepoConfigSecrets masks the secrets within a repo config file's contents, so that they can be logged.
+func redactRepoConfigSecrets(reposConfig string) string {
+	return repoConfigSecretRegex.ReplaceAllString(reposConfig, "${1}"+imagecustomizerapi.RedactedPassword)
+}
+
 func getRpmSourceFileType(rpmSourcePath string) (string, error) {
-	// First, check if path points to a directory.
+	// Remote repos are specified by URL instead of by path.
+	isUrl, err := isRpmSourceUrl(rpmSourcePath)
+	if err != nil {
+		return "", err
+	}
+
+	if isUrl {
+		return "url", nil
+	}
+
+	// Check if path points to a directory.
 	isDir, err := file.IsDir(rpmSourcePath)
 	if err != nil {
 		return "", fmt.Errorf("failed to get type of RPM source (%s):\n%w", rpmSourcePath, err)
@@ -285,24 +862,200 @@ func getRpmSourceFileType(rpmSourcePath string) (string, error) {
 		return "dir", nil
 	}
 
-	filename := filepath.Base(rpmSourcePath)
-	dotIndex := strings.Index(filename, ".")
-	fileExt := ""
-	if dotIndex >= 0 {
-		fileExt = filename[dotIndex:]
-	}
+	_, fileExt := splitRpmSourceFileExt(filepath.Base(rpmSourcePath))
 
 	switch fileExt {
 	case ".repo":
 		return "repo", nil
 
+	case ".tar", ".tar.gz", ".tgz", ".tar.xz", ".tar.bz2":
+		// Note: `tar -xf` autodetects the compression type.
+		return "tar", nil
+
+	case ".rpm":
+		return "rpm", nil
+
 	default:
 		return "", nil
 	}
 }
 
+// isRpmSourceUrl returns true if the RPM source is a URL of a remote repo.
+func isRpmSourceUrl(rpmSource string) (bool, error) {
+	scheme, _, found := strings.Cut(rpmSource, "://")
+	if !found {
+		return false, nil
+	}
+
+	scheme = strings.ToLower(scheme)
+	isRemoteScheme := false
+	for _, remoteScheme := range rpmSourceUrlSchemes {
+		if scheme == remoteScheme {
+			isRemoteScheme = true
+			break
+		}
+	}
+
+	if !isRemoteScheme {
+		return false, nil
+	}
+
+	repoUrl, err := url.Parse(rpmSource)
+	if err != nil {
+		return false, fmt.Errorf("invalid RPM source URL (%s):\n%w", rpmSource, err)
+	}
+
+	if repoUrl.Host == "" {
+		return false, fmt.Errorf("RPM source URL (%s) is missing a host", rpmSource)
+	}
+
+	return true, nil
+}
+
+// loadRepoConfigFile parses a `.repo` file.
+func loadRepoConfigFile(rpmSource string) (*ini.File, error) {
+	reposConfig, err := ini.LoadSources(repoConfigLoadOptions, rpmSource)
+	if err != nil {
+		return nil, fmt.Errorf("failed load repo config file (%s):\n%w", rpmSource, err)
+	}
+
+	return reposConfig, nil
+}
+
+// getRepoVariables returns the values of the repo config variables that the image customizer substitutes itself.
+func getRepoVariables(releaseVersion string) (map[string]string, error) {
+	// The image is always customized using the host's architecture.
+	basearch, err := rpm.GetRpmArch(runtime.GOARCH)
+	if err != nil {
+		return nil, err
+	}
+
+	repoVariables := map[string]string{
+		"basearch": basearch,
+	}
+
+	if releaseVersion != "" {
+		repoVariables["releasever"] = releaseVersion
+	}
+
+	return repoVariables, nil
+}
+
+// substituteRepoVariables replaces the references to known variables in a repo config value.
+// References to unknown variables are left as is, so that tdnf can substitute them.
+func substituteRepoVariables(value string, repoVariables map[string]string) string {
+	return repoVariableRegex.ReplaceAllStringFunc(value, func(variableRef string) string {
+		match := repoVariableRegex.FindStringSubmatch(variableRef)
+		variableName := match[1]
+		if variableName == "" {
+			variableName = match[2]
+		}
+
+		variableValue, ok := repoVariables[variableName]
+		if !ok {
+			return variableRef
+		}
+
+		return variableValue
+	})
+}
+
+// repoConfigUsesVariable checks if any of the repos in a repo config file reference a variable (e.g. `$releasever`)
+// in their URLs.
+func repoConfigUsesVariable(rpmSource string, variableName string) (bool, error) {
+	reposConfig, err := loadRepoConfigFile(rpmSource)
+	if err != nil {
+		return false, err
+	}
+
+	for _, repoConfig := range reposConfig.Sections() {
+		for _, keyName := range repoUrlKeys {
+			value := repoConfig.Key(keyName).String()
+			if valueUsesRepoVariable(value, variableName) {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// valueUsesRepoVariable returns true if a repo config value references the variable.
+func valueUsesRepoVariable(value string, variableName string) bool {
+	variableRefs := []string{"$" + variableName, "${" + variableName + "}"}
+	for _, variableRef := range variableRefs {
+		if strings.Contains(value, variableRef) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sanitizeMountName replaces any characters that aren't alphanumeric, '-', or '_' so that the name can be safely used
+// as a directory name and in a repo config.
+func sanitizeMountName(name string) string {
+	return mountNameUnsafeCharsRegex.ReplaceAllString(name, "_")
+}
+
+// splitRpmSourceFileExt splits an RPM source's file name into its base name and its file extension.
+// Multi-part extensions (e.g. `.tar.gz`) are kept together. But any other dots in the file name are considered part
+// of the base name (e.g. `my.app.tar.gz` becomes `my.app` and `.tar.gz`).
+func splitRpmSourceFileExt(filename string) (string, string) {
+	for _, fileExt := range rpmSourceMultiPartFileExts {
+		baseName, found := strings.CutSuffix(filename, fileExt)
+		if found && baseName != "" {
+			return baseName, fileExt
+		}
+	}
+
+	fileExt := filepath.Ext(filename)
+	return strings.TrimSuffix(filename, fileExt), fileExt
+}
+
+// hasLocalRpmFiles returns true if any of the RPM sources are RPM files.
+func hasLocalRpmFiles(rpmsSources []string) (bool, error) {
+	for _, rpmSource := range rpmsSources {
+		fileType, err := getRpmSourceFileType(rpmSource)
+		if err != nil {
+			return false, fmt.Errorf("failed to get RPM source file type (%s):\n%w", rpmSource, err)
+		}
+
+		if fileType == "rpm" {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// validateRpmFile checks that a file is an RPM package, by checking the file's lead magic number.
+func validateRpmFile(rpmFilePath string) error {
+	rpmFile, err := os.Open(rpmFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to open RPM file (%s):\n%w", rpmFilePath, err)
+	}
+	defer rpmFile.Close()
+
+	magic := make([]byte, len(rpmLeadMagic))
+	_, err = io.ReadFull(rpmFile, magic)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("failed to read RPM file (%s):\n%w", rpmFilePath, err)
+	}
+
+	if !bytes.Equal(magic, rpmLeadMagic) {
+		return fmt.Errorf("file is not a valid RPM package (%s)", rpmFilePath)
+	}
+
+	return nil
+}
+
 // Add a local directory containing RPMs to the allrepos.repo file.
-func appendLocalRepo(iniFile *ini.File, mountTargetDirectoryInChroot string) error {
+// If gpgKeyPathInChroot is set, then tdnf is told to verify the repo's metadata signature using that key.
+// If packagesGpgKeysInChroot is set, then tdnf is told to verify the packages' signatures using those keys.
+func appendLocalRepo(iniFile *ini.File, mountTargetDirectoryInChroot string, gpgKeyPathInChroot string,
+	packagesGpgKeysInChroot []string,
+) error {
 	repoName := filepath.Base(mountTargetDirectoryInChroot)
 	iniSection, err := iniFile.NewSection(repoName)
 	if err != nil {
@@ -326,6 +1079,63 @@ func appendLocalRepo(iniFile *ini.File, mountTargetDirectoryInChroot string) err
 		return err
 	}
 
+	err = setRepoGpgKeys(iniSection, gpgKeyPathInChroot, packagesGpgKeysInChroot)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// setReposMetadataExpire sets how long the metadata of each of the repos is cached for, overriding the repos' own
+// values.
+func setReposMetadataExpire(iniFile *ini.File, metadataExpire string) error {
+	for _, iniSection := range iniFile.Sections() {
+		if iniSection.Name() == ini.DefaultSection {
+			continue
+		}
+
+		_, err := iniSection.NewKey("metadata_expire", metadataExpire)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setRepoGpgKeys enables a repo's GPG checks for the keys that are provided.
+func setRepoGpgKeys(iniSection *ini.Section, gpgKeyPathInChroot string, packagesGpgKeysInChroot []string) error {
+	var err error
+
+	var gpgKeyUrls []string
+	if gpgKeyPathInChroot != "" {
+		_, err = iniSection.NewKey("repo_gpgcheck", "1")
+		if err != nil {
+			return err
+		}
+
+		gpgKeyUrls = append(gpgKeyUrls, fmt.Sprintf("file://%s", gpgKeyPathInChroot))
+	}
+
+	if len(packagesGpgKeysInChroot) > 0 {
+		_, err = iniSection.NewKey("gpgcheck", "1")
+		if err != nil {
+			return err
+		}
+
+		for _, gpgKeyPath := range packagesGpgKeysInChroot {
+			gpgKeyUrls = append(gpgKeyUrls, fmt.Sprintf("file://%s", gpgKeyPath))
+		}
+	}
+
+	if len(gpgKeyUrls) > 0 {
+		_, err = iniSection.NewKey("gpgkey", strings.Join(gpgKeyUrls, " "))
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -337,7 +1147,14 @@ func appendIniSection(iniFile *ini.File, iniSection *ini.Section) error {
 	}
 
 	for _, key := range iniSection.Keys() {
-		_, err := newSection.NewKey(key.Name(), key.Value())
+		value := key.Value()
+		if strings.Contains(value, "\n") {
+			// The INI library writes multi-line values using Python's triple-quote syntax, which tdnf doesn't
+			// support. So, join the lines into a single whitespace separated list instead.
+			value = strings.Join(strings.Fields(value), " ")
+		}
+
+		_, err := newSection.NewKey(key.Name(), value)
 		if err != nil {
 			return err
 		}
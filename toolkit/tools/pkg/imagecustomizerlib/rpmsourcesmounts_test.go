@@ -0,0 +1,497 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/ini.v1"
+)
+
+func TestSubstituteRepoVariables(t *testing.T) {
+	repoVariables := map[string]string{
+		"basearch":   "x86_64",
+		"releasever": "2.0",
+	}
+
+	assert.Equal(t, "file:///repos/2.0/x86_64",
+		substituteRepoVariables("file:///repos/$releasever/$basearch", repoVariables))
+	assert.Equal(t, "file:///repos/2.0-x86_64",
+		substituteRepoVariables("file:///repos/${releasever}-${basearch}", repoVariables))
+	assert.Equal(t, "https://example.com/$unknown/x86_64",
+		substituteRepoVariables("https://example.com/$unknown/$basearch", repoVariables))
+	assert.Equal(t, "file:///repos/$basearchfoo",
+		substituteRepoVariables("file:///repos/$basearchfoo", repoVariables))
+}
+
+func TestSubstituteRepoVariablesNoReleaseVersion(t *testing.T) {
+	repoVariables, err := getRepoVariables("")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.NotEmpty(t, repoVariables["basearch"])
+	assert.Equal(t, "file:///repos/$releasever",
+		substituteRepoVariables("file:///repos/$releasever", repoVariables))
+}
+
+func TestSplitRepoUrls(t *testing.T) {
+	assert.Equal(t, []string{"https://a.example.com/repo", "https://b.example.com/repo", "file:///repo"},
+		splitRepoUrls("https://a.example.com/repo https://b.example.com/repo,\n  file:///repo"))
+	assert.Empty(t, splitRepoUrls(""))
+}
+
+func TestCreateRepoFromRepoConfigMirrorlist(t *testing.T) {
+	repoFilePath := filepath.Join(tmpDir, "TestCreateRepoFromRepoConfigMirrorlist.repo")
+	repoFileContents := `[mariner-official-base]
+name=CBL-Mariner Official Base
+mirrorlist=https://example.com/mirrorlist?repo=base&arch=$basearch
+enabled=1
+gpgcheck=1
+`
+
+	err := os.WriteFile(repoFilePath, []byte(repoFileContents), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	mounts := rpmSourcesMounts{
+		repoVariables: map[string]string{"basearch": "x86_64"},
+	}
+	allReposConfig := ini.Empty()
+
+	err = mounts.createRepoFromRepoConfig(repoFilePath, true, allReposConfig, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Empty(t, mounts.mounts)
+
+	repoConfig, err := allReposConfig.GetSection("mariner-official-base")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, "https://example.com/mirrorlist?repo=base&arch=x86_64", repoConfig.Key("mirrorlist").String())
+	assert.False(t, repoConfig.HasKey("baseurl"))
+	assert.Equal(t, "1", repoConfig.Key("gpgcheck").String())
+}
+
+func TestCreateRepoFromRepoConfigNoUrl(t *testing.T) {
+	repoFilePath := filepath.Join(tmpDir, "TestCreateRepoFromRepoConfigNoUrl.repo")
+	repoFileContents := "[no-url]\nname=No URL\n"
+
+	err := os.WriteFile(repoFilePath, []byte(repoFileContents), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	mounts := rpmSourcesMounts{}
+	err = mounts.createRepoFromRepoConfig(repoFilePath, true, ini.Empty(), nil)
+	assert.ErrorContains(t, err, "must specify one of: baseurl, mirrorlist, metalink")
+}
+
+func TestCreateRepoFromRepoConfigPreservesKeys(t *testing.T) {
+	repoFilePath := filepath.Join(tmpDir, "TestCreateRepoFromRepoConfigPreservesKeys.repo")
+	repoFileContents := `[mariner-official-extended]
+name=CBL-Mariner Official Extended $releasever $basearch
+baseurl=https://packages.example.com/$releasever/prod/extended/$basearch;param=1
+gpgkey=file:///etc/pki/rpm-gpg/MICROSOFT-RPM-GPG-KEY
+  file:///etc/pki/rpm-gpg/MICROSOFT-METADATA-GPG-KEY
+gpgcheck=1
+repo_gpgcheck=1
+enabled=1
+skip_if_unavailable=True
+sslverify=1
+sslcacert=/etc/pki/tls/certs/ca-bundle.crt
+metadata_expire=86400
+module_hotfixes=1
+`
+
+	err := os.WriteFile(repoFilePath, []byte(repoFileContents), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	mounts := rpmSourcesMounts{
+		repoVariables: map[string]string{"basearch": "x86_64"},
+	}
+	allReposConfig := ini.Empty(repoConfigLoadOptions)
+
+	err = mounts.createRepoFromRepoConfig(repoFilePath, false, allReposConfig, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	allReposConfigFilePath := filepath.Join(tmpDir, "TestCreateRepoFromRepoConfigPreservesKeys-allrepos.repo")
+	err = allReposConfig.SaveTo(allReposConfigFilePath)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	allReposConfigContents, err := os.ReadFile(allReposConfigFilePath)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// tdnf doesn't support quoted values.
+	assert.NotContains(t, string(allReposConfigContents), "`")
+	assert.NotContains(t, string(allReposConfigContents), "\"")
+
+	savedReposConfig, err := loadRepoConfigFile(allReposConfigFilePath)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	repoConfig, err := savedReposConfig.GetSection("mariner-official-extended")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	expectedKeys := [][2]string{
+		{"name", "CBL-Mariner Official Extended $releasever $basearch"},
+		{"baseurl", "https://packages.example.com/$releasever/prod/extended/x86_64;param=1"},
+		{"gpgkey", "file:///etc/pki/rpm-gpg/MICROSOFT-RPM-GPG-KEY file:///etc/pki/rpm-gpg/MICROSOFT-METADATA-GPG-KEY"},
+		{"gpgcheck", "1"},
+		{"repo_gpgcheck", "1"},
+		{"enabled", "1"},
+		{"skip_if_unavailable", "True"},
+		{"sslverify", "1"},
+		{"sslcacert", "/etc/pki/tls/certs/ca-bundle.crt"},
+		{"metadata_expire", "86400"},
+		{"module_hotfixes", "1"},
+	}
+
+	var actualKeys [][2]string
+	for _, key := range repoConfig.Keys() {
+		actualKeys = append(actualKeys, [2]string{key.Name(), key.Value()})
+	}
+
+	assert.Equal(t, expectedKeys, actualKeys)
+}
+
+func TestResolveLocalRepoPath(t *testing.T) {
+	mounts := rpmSourcesMounts{
+		baseConfigPath: "/home/user/config",
+	}
+
+	assert.Equal(t, "/srv/repos/base", mounts.resolveLocalRepoPath("/srv/repos/base"))
+	assert.Equal(t, "/home/user/config/repos/base", mounts.resolveLocalRepoPath("repos/base"))
+	assert.Equal(t, "/home/user/repos/base", mounts.resolveLocalRepoPath("../repos/base"))
+}
+
+func TestCreateRepoFromRepoConfigMissingLocalDir(t *testing.T) {
+	repoFilePath := filepath.Join(tmpDir, "TestCreateRepoFromRepoConfigMissingLocalDir.repo")
+	repoFileContents := "[local]\nbaseurl=file://missing-repo\n"
+
+	err := os.WriteFile(repoFilePath, []byte(repoFileContents), 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	mounts := rpmSourcesMounts{
+		baseConfigPath: tmpDir,
+	}
+	err = mounts.createRepoFromRepoConfig(repoFilePath, true, ini.Empty(repoConfigLoadOptions), nil)
+	assert.ErrorContains(t, err, "local repo directory ("+filepath.Join(tmpDir, "missing-repo")+") does not exist")
+}
+
+func TestGetRpmSourceFileTypeTar(t *testing.T) {
+	for _, name := range []string{"rpms.tar", "rpms.tar.gz", "rpms.tgz", "rpms.tar.xz", "rpms.tar.bz2"} {
+		tarballPath := filepath.Join(tmpDir, name)
+
+		err := os.WriteFile(tarballPath, []byte{}, 0o644)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		fileType, err := getRpmSourceFileType(tarballPath)
+		assert.NoError(t, err)
+		assert.Equal(t, "tar", fileType, name)
+	}
+}
+
+func TestGetRpmSourceFileTypeUrl(t *testing.T) {
+	for _, rpmSource := range []string{"https://packages.example.com/mariner/2.0/prod/base/x86_64",
+		"HTTP://packages.example.com/repo"} {
+		fileType, err := getRpmSourceFileType(rpmSource)
+		assert.NoError(t, err)
+		assert.Equal(t, "url", fileType, rpmSource)
+	}
+
+	_, err := getRpmSourceFileType("https:///repo")
+	assert.ErrorContains(t, err, "RPM source URL (https:///repo) is missing a host")
+}
+
+func TestCreateRepoFromUrl(t *testing.T) {
+	mounts := rpmSourcesMounts{
+		repoVariables: map[string]string{"basearch": "x86_64"},
+	}
+	allReposConfig := ini.Empty()
+
+	err := mounts.createRepoFromUrl("https://packages.example.com/base/$basearch", allReposConfig)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = mounts.createRepoFromUrl("https://packages.example.com/extended/$basearch", allReposConfig)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Empty(t, mounts.mounts)
+
+	sections := allReposConfig.Sections()
+	if !assert.Len(t, sections, 3) {
+		return
+	}
+
+	// Ensure the repos are written in the order they were specified.
+	assert.Equal(t, "01packages_example_com_base_basearch", sections[1].Name())
+	assert.Equal(t, "https://packages.example.com/base/x86_64", sections[1].Key("baseurl").String())
+	assert.Equal(t, "1", sections[1].Key("enabled").String())
+
+	assert.Equal(t, "02packages_example_com_extended_basearch", sections[2].Name())
+	assert.Equal(t, "https://packages.example.com/extended/x86_64", sections[2].Key("baseurl").String())
+}
+
+func TestSetRepoGpgKeys(t *testing.T) {
+	allReposConfig := ini.Empty()
+
+	err := appendLocalRepo(allReposConfig, "/_localrpms/00rpms", "/_localrpms/repo-signing-key.asc",
+		[]string{"/_localrpms/packages-gpg-key-00.asc", "/_localrpms/packages-gpg-key-01.asc"})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	repoConfig, err := allReposConfig.GetSection("00rpms")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, "1", repoConfig.Key("repo_gpgcheck").String())
+	assert.Equal(t, "1", repoConfig.Key("gpgcheck").String())
+	assert.Equal(t, "file:///_localrpms/repo-signing-key.asc file:///_localrpms/packages-gpg-key-00.asc "+
+		"file:///_localrpms/packages-gpg-key-01.asc", repoConfig.Key("gpgkey").String())
+}
+
+func TestSetRepoGpgKeysNone(t *testing.T) {
+	allReposConfig := ini.Empty()
+
+	err := appendLocalRepo(allReposConfig, "/_localrpms/00rpms", "", nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	repoConfig, err := allReposConfig.GetSection("00rpms")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.False(t, repoConfig.HasKey("repo_gpgcheck"))
+	assert.False(t, repoConfig.HasKey("gpgcheck"))
+	assert.False(t, repoConfig.HasKey("gpgkey"))
+}
+
+func TestSetReposMetadataExpire(t *testing.T) {
+	allReposConfig := ini.Empty()
+
+	err := appendLocalRepo(allReposConfig, "/_localrpms/00rpms", "", nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	remoteRepo, err := allReposConfig.NewSection("remote")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, err = remoteRepo.NewKey("metadata_expire", "60")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = setReposMetadataExpire(allReposConfig, "never")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, "never", allReposConfig.Section("00rpms").Key("metadata_expire").String())
+	assert.Equal(t, "never", allReposConfig.Section("remote").Key("metadata_expire").String())
+	assert.False(t, allReposConfig.Section(ini.DefaultSection).HasKey("metadata_expire"))
+}
+
+func TestHostReposConfigContents(t *testing.T) {
+	reposConfig := "[00rpms]\nbaseurl=file:///_localrpms/00rpms\ngpgkey=file:///_localrpms/packages-gpg-key-00.asc\n" +
+		"[remote]\nbaseurl=https://packages.example.com/_localrpms/base\n"
+
+	assert.Equal(t, "[00rpms]\nbaseurl=file:///build/imageroot/_localrpms/00rpms\n"+
+		"gpgkey=file:///build/imageroot/_localrpms/packages-gpg-key-00.asc\n"+
+		"[remote]\nbaseurl=https://packages.example.com/_localrpms/base\n",
+		hostReposConfigContents(reposConfig, "/build/imageroot/_localrpms"))
+}
+
+func TestSplitRpmSourcePriority(t *testing.T) {
+	testCases := []struct {
+		rpmSource string
+		path      string
+		priority  int
+	}{
+		{"/rpms", "/rpms", 0},
+		{"/rpms=10", "/rpms", 10},
+		{"/rpms=-5", "/rpms", -5},
+		{"/rpms=+5", "/rpms", 5},
+		{"/a=b/rpms", "/a=b/rpms", 0},
+		{"/rpms=1=0", "/rpms=1", 0},
+		{"https://packages.example.com/repo?token=123", "https://packages.example.com/repo?token=123", 0},
+		{"https://packages.example.com/repo?a=b=2", "https://packages.example.com/repo?a=b=2", 0},
+	}
+
+	for _, testCase := range testCases {
+		path, priority, err := splitRpmSourcePriority(testCase.rpmSource)
+		assert.NoError(t, err, testCase.rpmSource)
+		assert.Equal(t, testCase.path, path, testCase.rpmSource)
+		assert.Equal(t, testCase.priority, priority, testCase.rpmSource)
+	}
+}
+
+func TestSplitRpmSourcePriorityExistingPath(t *testing.T) {
+	testDir := filepath.Join(tmpDir, "TestSplitRpmSourcePriorityExistingPath")
+	rpmsDir := filepath.Join(testDir, "rpms=10")
+	defer os.RemoveAll(testDir)
+
+	err := os.MkdirAll(rpmsDir, os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// An existing path is never split, even if it ends with what looks like a priority.
+	path, priority, err := splitRpmSourcePriority(rpmsDir)
+	assert.NoError(t, err)
+	assert.Equal(t, rpmsDir, path)
+	assert.Equal(t, 0, priority)
+
+	// But an explicit priority can still be added.
+	path, priority, err = splitRpmSourcePriority(rpmsDir + "=5")
+	assert.NoError(t, err)
+	assert.Equal(t, rpmsDir, path)
+	assert.Equal(t, 5, priority)
+}
+
+func TestSplitRpmSourcePriorityInvalid(t *testing.T) {
+	_, _, err := splitRpmSourcePriority("=10")
+	assert.ErrorContains(t, err, "is missing a path")
+
+	_, _, err = splitRpmSourcePriority("/rpms=99999999999999999999")
+	assert.ErrorContains(t, err, "priority")
+}
+
+func TestOrderRpmSourcesByPriority(t *testing.T) {
+	rpmsSources, err := orderRpmSourcesByPriority([]string{"/a", "/b=10", "/c=-1", "/d", "/e=10"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/c", "/a", "/d", "/b", "/e"}, rpmsSources)
+
+	rpmsSources, err = orderRpmSourcesByPriority(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, rpmsSources)
+}
+
+func TestRedactRepoConfigSecrets(t *testing.T) {
+	reposConfig := "[private]\nbaseurl=https://packages.example.com/private\nusername=builder\n" +
+		"password=supersecretpassword\nproxy_password = proxysecret\n[public]\nbaseurl=https://example.com/password=x\n"
+
+	redacted := redactRepoConfigSecrets(reposConfig)
+	assert.NotContains(t, redacted, "supersecretpassword")
+	assert.NotContains(t, redacted, "proxysecret")
+	assert.Equal(t, "[private]\nbaseurl=https://packages.example.com/private\nusername=builder\n"+
+		"password=<redacted>\nproxy_password =<redacted>\n[public]\nbaseurl=https://example.com/password=x\n",
+		redacted)
+}
+
+func TestSplitRpmSourceFileExt(t *testing.T) {
+	testCases := []struct {
+		filename string
+		baseName string
+		fileExt  string
+	}{
+		{"rpms.tar.gz", "rpms", ".tar.gz"},
+		{"my.app.tar.gz", "my.app", ".tar.gz"},
+		{"my.app.tar", "my.app", ".tar"},
+		{"rpms.tar.xz", "rpms", ".tar.xz"},
+		{"rpms.tar.bz2", "rpms", ".tar.bz2"},
+		{"rpms.tgz", "rpms", ".tgz"},
+		{"mariner-2.0.repo", "mariner-2.0", ".repo"},
+		{"rpms", "rpms", ""},
+		{".tar.gz", ".tar", ".gz"},
+	}
+
+	for _, testCase := range testCases {
+		baseName, fileExt := splitRpmSourceFileExt(testCase.filename)
+		assert.Equal(t, testCase.baseName, baseName, testCase.filename)
+		assert.Equal(t, testCase.fileExt, fileExt, testCase.filename)
+	}
+}
+
+func TestGetRpmSourceFileTypeDotsInName(t *testing.T) {
+	tarballPath := filepath.Join(tmpDir, "my.app.tar.gz")
+
+	err := os.WriteFile(tarballPath, []byte{}, 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	fileType, err := getRpmSourceFileType(tarballPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "tar", fileType)
+}
+
+func TestSanitizeMountName(t *testing.T) {
+	assert.Equal(t, "rpms", sanitizeMountName("rpms"))
+	assert.Equal(t, "my-rpms_1", sanitizeMountName("my-rpms_1"))
+	assert.Equal(t, "my_rpms", sanitizeMountName("my rpms"))
+	assert.Equal(t, "paquets_rpm_t_", sanitizeMountName("paquets rpm été"))
+	assert.Equal(t, "_", sanitizeMountName("软件包"))
+	assert.Equal(t, "mariner-2_0", sanitizeMountName("mariner-2.0"))
+}
+
+func TestGetRpmSourceFileTypeRpm(t *testing.T) {
+	rpmPath := filepath.Join(tmpDir, "jq-1.6-1.cm2.x86_64.rpm")
+
+	err := os.WriteFile(rpmPath, []byte{0xed, 0xab, 0xee, 0xdb, 0x03, 0x00}, 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	fileType, err := getRpmSourceFileType(rpmPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "rpm", fileType)
+
+	err = validateRpmFile(rpmPath)
+	assert.NoError(t, err)
+
+	hasRpmFiles, err := hasLocalRpmFiles([]string{tmpDir, rpmPath})
+	assert.NoError(t, err)
+	assert.True(t, hasRpmFiles)
+}
+
+func TestValidateRpmFileInvalid(t *testing.T) {
+	for name, contents := range map[string][]byte{
+		"empty.rpm":     {},
+		"short.rpm":     {0xed, 0xab},
+		"not-a-rpm.rpm": []byte("hello world"),
+	} {
+		rpmPath := filepath.Join(tmpDir, name)
+
+		err := os.WriteFile(rpmPath, contents, 0o644)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		err = validateRpmFile(rpmPath)
+		assert.ErrorContains(t, err, "file is not a valid RPM package", name)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagegen/diskutils"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/logger"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/safeloopback"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/safemount"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/shell"
+)
+
+// trimImageFilesystems discards the unused blocks of each of the image's filesystems.
+// Since the loopback device punches holes in the image file for discarded blocks, this makes the image file sparse.
+// So, the output image produced by `qemu-img convert` is smaller.
+func trimImageFilesystems(buildDir string, buildImageFile string) error {
+	imageLoopback, err := safeloopback.NewLoopback(buildImageFile)
+	if err != nil {
+		return err
+	}
+	defer imageLoopback.Close()
+
+	diskPartitions, err := diskutils.GetDiskPartitions(imageLoopback.DevicePath())
+	if err != nil {
+		return err
+	}
+
+	for _, diskPartition := range diskPartitions {
+		if diskPartition.Type != "part" {
+			continue
+		}
+
+		switch diskPartition.FileSystemType {
+		case "ext2", "ext3", "ext4", "xfs", "vfat":
+
+		default:
+			logger.Log.Debugf("Skipping trim of partition (%s) with filesystem type (%s)", diskPartition.Path,
+				diskPartition.FileSystemType)
+			continue
+		}
+
+		err = trimPartitionFilesystem(buildDir, diskPartition)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = imageLoopback.CleanClose()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func trimPartitionFilesystem(buildDir string, diskPartition diskutils.PartitionInfo) error {
+	tmpDir := filepath.Join(buildDir, tmpParitionDirName)
+
+	logger.Log.Infof("Trimming filesystem of partition (%s)", diskPartition.Path)
+
+	// Temporarily mount the partition.
+	partitionMount, err := safemount.NewMount(diskPartition.Path, tmpDir, diskPartition.FileSystemType, 0, "", true)
+	if err != nil {
+		return fmt.Errorf("failed to mount partition (%s):\n%w", diskPartition.Path, err)
+	}
+	defer partitionMount.Close()
+
+	err = shell.ExecuteLiveWithErr(1, "fstrim", "--verbose", tmpDir)
+	if err != nil {
+		return fmt.Errorf("failed to trim filesystem of partition (%s):\n%w", diskPartition.Path, err)
+	}
+
+	err = partitionMount.CleanClose()
+	if err != nil {
+		return fmt.Errorf("failed to close partition mount (%s):\n%w", diskPartition.Path, err)
+	}
+
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagecustomizerapi"
 	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagegen/configuration"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/sliceutils"
 )
 
 func bootTypeToImager(bootType imagecustomizerapi.BootType) (string, error) {
@@ -19,7 +20,7 @@ func bootTypeToImager(bootType imagecustomizerapi.BootType) (string, error) {
 		return "legacy", nil
 
 	default:
-		return "", fmt.Errorf("invalid BootType value (%s)", bootType)
+		return "", fmt.Errorf("invalid BootType value (%s); must be one of: efi, legacy", bootType)
 	}
 }
 
@@ -29,7 +30,7 @@ func diskConfigToImager(diskConfig imagecustomizerapi.Disk) (configuration.Disk,
 		return configuration.Disk{}, err
 	}
 
-	imagerPartitions, err := partitionsToImager(diskConfig.Partitions)
+	imagerPartitions, err := partitionsToImager(diskConfig)
 	if err != nil {
 		return configuration.Disk{}, err
 	}
@@ -48,15 +49,21 @@ func partitionTableTypeToImager(partitionTableType imagecustomizerapi.PartitionT
 	case imagecustomizerapi.PartitionTableTypeGpt:
 		return configuration.PartitionTableTypeGpt, nil
 
+	case imagecustomizerapi.PartitionTableTypeMbr:
+		return configuration.PartitionTableTypeMbr, nil
+
 	default:
-		return "", fmt.Errorf("unknown partition table type (%s)", partitionTableType)
+		return "", fmt.Errorf("unknown partition table type (%s); must be one of: gpt, mbr", partitionTableType)
 	}
 }
 
-func partitionsToImager(partitions []imagecustomizerapi.Partition) ([]configuration.Partition, error) {
+func partitionsToImager(diskConfig imagecustomizerapi.Disk) ([]configuration.Partition, error) {
 	imagerPartitions := []configuration.Partition(nil)
-	for _, partition := range partitions {
-		imagerPartition, err := partitionToImager(partition)
+	for i, partition := range diskConfig.Partitions {
+		// Note: An End value of 0 means the partition fills the remainder of the disk.
+		imagerEnd, _ := diskConfig.GetPartitionEnd(i)
+
+		imagerPartition, err := partitionToImager(partition, imagerEnd)
 		if err != nil {
 			return nil, err
 		}
@@ -67,17 +74,21 @@ func partitionsToImager(partitions []imagecustomizerapi.Partition) ([]configurat
 	return imagerPartitions, nil
 }
 
-func partitionToImager(partition imagecustomizerapi.Partition) (configuration.Partition, error) {
-	imagerEnd, _ := partition.GetEnd()
-
+func partitionToImager(partition imagecustomizerapi.Partition, imagerEnd uint64) (configuration.Partition, error) {
 	imagerFlags, err := partitionFlagsToImager(partition.Flags)
 	if err != nil {
 		return configuration.Partition{}, err
 	}
 
+	imagerFsType := string(partition.FsType)
+	if partition.FsType == imagecustomizerapi.FileSystemTypeNone {
+		// The imager leaves partitions with an empty filesystem type unformatted.
+		imagerFsType = ""
+	}
+
 	imagerPartition := configuration.Partition{
 		ID:     partition.ID,
-		FsType: string(partition.FsType),
+		FsType: imagerFsType,
 		Name:   partition.Name,
 		Start:  partition.Start,
 		End:    imagerEnd,
@@ -112,7 +123,7 @@ func partitionFlagToImager(flag imagecustomizerapi.PartitionFlag) (configuration
 		return configuration.PartitionFlagBoot, nil
 
 	default:
-		return "", fmt.Errorf("unknown partition flag (%s)", flag)
+		return "", fmt.Errorf("unknown partition flag (%s); must be one of: esp, bios_grub, boot", flag)
 	}
 }
 
@@ -129,6 +140,21 @@ func partitionSettingsToImager(partitionSettings []imagecustomizerapi.PartitionS
 	return imagerPartitionSettings, nil
 }
 
+// partitionSettingsForDisk returns the partition settings that reference a partition on the specified disk.
+func partitionSettingsForDisk(diskConfig imagecustomizerapi.Disk,
+	partitionSettings []imagecustomizerapi.PartitionSetting,
+) []imagecustomizerapi.PartitionSetting {
+	diskPartitionSettings := []imagecustomizerapi.PartitionSetting(nil)
+	for _, partitionSetting := range partitionSettings {
+		if sliceutils.ContainsFunc(diskConfig.Partitions, func(partition imagecustomizerapi.Partition) bool {
+			return partition.ID == partitionSetting.ID
+		}) {
+			diskPartitionSettings = append(diskPartitionSettings, partitionSetting)
+		}
+	}
+	return diskPartitionSettings
+}
+
 func partitionSettingToImager(partitionSettings imagecustomizerapi.PartitionSetting,
 ) (configuration.PartitionSetting, error) {
 	imagerMountIdentifierType, err := mountIdentifierTypeToImager(partitionSettings.MountIdentifier)
@@ -158,14 +184,15 @@ func mountIdentifierTypeToImager(mountIdentifierType imagecustomizerapi.MountIde
 		return configuration.MountIdentifierPartLabel, nil
 
 	default:
-		return "", fmt.Errorf("unknwon MountIdentifierType value (%s)", mountIdentifierType)
+		return "", fmt.Errorf("unknown MountIdentifierType value (%s); must be one of: uuid, partuuid, partlabel",
+			mountIdentifierType)
 	}
 }
 
 func kernelCommandLineToImager(kernelCommandLine imagecustomizerapi.KernelCommandLine,
 ) (configuration.KernelCommandLine, error) {
 	imagerKernelCommandLine := configuration.KernelCommandLine{
-		ExtraCommandLine: kernelCommandLine.ExtraCommandLine,
+		ExtraCommandLine: kernelCommandLine.CommandLine(),
 	}
 	return imagerKernelCommandLine, nil
 }
@@ -0,0 +1,31 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"testing"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/imagecustomizerapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartitionSettingsForDisk(t *testing.T) {
+	disk := imagecustomizerapi.Disk{
+		Partitions: []imagecustomizerapi.Partition{
+			{ID: "esp"},
+			{ID: "rootfs"},
+		},
+	}
+	partitionSettings := []imagecustomizerapi.PartitionSetting{
+		{ID: "esp", MountPoint: "/boot/efi"},
+		{ID: "data", MountPoint: "/data"},
+		{ID: "rootfs", MountPoint: "/"},
+	}
+
+	diskPartitionSettings := partitionSettingsForDisk(disk, partitionSettings)
+	assert.Equal(t, []imagecustomizerapi.PartitionSetting{
+		{ID: "esp", MountPoint: "/boot/efi"},
+		{ID: "rootfs", MountPoint: "/"},
+	}, diskPartitionSettings)
+}
@@ -0,0 +1,165 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/logger"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/safeloopback"
+	"github.com/microsoft/CBL-Mariner/toolkit/tools/internal/safemount"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	verifyRootfsDirName = "verifyroot"
+
+	// The same limit as the Linux kernel.
+	maxSymlinkHops = 40
+)
+
+var (
+	// The OS must have at least one of these.
+	initPaths = []string{"/sbin/init", "/usr/lib/systemd/systemd"}
+
+	kernelPathGlob = "/boot/vmlinuz-*"
+)
+
+// verifyImageRootfs mounts the image's filesystems read-only and checks that the files required to boot the OS exist.
+// This catches customizations that broke the OS (e.g. a script that deleted init).
+func verifyImageRootfs(buildDir string, buildImageFile string) error {
+	logger.Log.Infof("Verifying image's rootfs")
+
+	imageLoopback, err := safeloopback.NewLoopback(buildImageFile)
+	if err != nil {
+		return err
+	}
+	defer imageLoopback.Close()
+
+	_, mountPoints, err := findPartitions(buildDir, imageLoopback.DevicePath())
+	if err != nil {
+		return &PartitionDiscoveryError{Err: fmt.Errorf("failed to find disk partitions:\n%w", err)}
+	}
+
+	// Mount parent directories before their children.
+	sort.SliceStable(mountPoints, func(i, j int) bool {
+		return len(mountPoints[i].Target()) < len(mountPoints[j].Target())
+	})
+
+	rootDir := filepath.Join(buildDir, verifyRootfsDirName)
+
+	var mounts []*safemount.Mount
+	defer func() {
+		for i := len(mounts) - 1; i >= 0; i-- {
+			mounts[i].Close()
+		}
+	}()
+
+	for _, mountPoint := range mountPoints {
+		target := filepath.Join(rootDir, mountPoint.Target())
+
+		// The other mount points' directories must already exist in the (read-only) parent filesystems.
+		isRoot := mountPoint.Target() == "/"
+
+		mount, err := safemount.NewMount(mountPoint.Source(), target, mountPoint.FsType(), unix.MS_RDONLY, "", isRoot)
+		if err != nil {
+			return &MountError{Err: fmt.Errorf("failed to mount partition (%s) read-only at (%s):\n%w",
+				mountPoint.Source(), mountPoint.Target(), err)}
+		}
+
+		mounts = append(mounts, mount)
+	}
+
+	missingPaths, err := findMissingRootfsPaths(rootDir)
+	if err != nil {
+		return err
+	}
+
+	if len(missingPaths) > 0 {
+		return fmt.Errorf("image's rootfs is missing required files: %s", strings.Join(missingPaths, ", "))
+	}
+
+	for i := len(mounts) - 1; i >= 0; i-- {
+		err = mounts[i].CleanClose()
+		if err != nil {
+			return err
+		}
+	}
+	mounts = nil
+
+	err = imageLoopback.CleanClose()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// findMissingRootfsPaths returns a description of each of the required files that don't exist under rootDir.
+func findMissingRootfsPaths(rootDir string) ([]string, error) {
+	var missingPaths []string
+
+	hasInit := false
+	for _, initPath := range initPaths {
+		exists, err := pathExistsInRoot(rootDir, initPath)
+		if err != nil {
+			return nil, err
+		}
+
+		if exists {
+			hasInit = true
+			break
+		}
+	}
+
+	if !hasInit {
+		missingPaths = append(missingPaths, fmt.Sprintf("init (%s)", strings.Join(initPaths, " or ")))
+	}
+
+	kernelPaths, err := filepath.Glob(filepath.Join(rootDir, kernelPathGlob))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(kernelPaths) <= 0 {
+		missingPaths = append(missingPaths, fmt.Sprintf("kernel (%s)", kernelPathGlob))
+	}
+
+	return missingPaths, nil
+}
+
+// pathExistsInRoot checks if a path exists, resolving any symlinks relative to rootDir instead of the host's root.
+func pathExistsInRoot(rootDir string, path string) (bool, error) {
+	for i := 0; i < maxSymlinkHops; i++ {
+		fullPath := filepath.Join(rootDir, path)
+
+		info, err := os.Lstat(fullPath)
+		if os.IsNotExist(err) {
+			return false, nil
+		} else if err != nil {
+			return false, fmt.Errorf("failed to stat (%s):\n%w", path, err)
+		}
+
+		if info.Mode()&os.ModeSymlink == 0 {
+			return true, nil
+		}
+
+		target, err := os.Readlink(fullPath)
+		if err != nil {
+			return false, fmt.Errorf("failed to read symlink (%s):\n%w", path, err)
+		}
+
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(path), target)
+		}
+
+		path = target
+	}
+
+	return false, fmt.Errorf("too many levels of symlinks (%s)", path)
+}
@@ -0,0 +1,80 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+
+package imagecustomizerlib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindMissingRootfsPaths(t *testing.T) {
+	rootDir := filepath.Join(tmpDir, "TestFindMissingRootfsPaths")
+
+	err := os.MkdirAll(filepath.Join(rootDir, "usr/lib/systemd"), os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = os.MkdirAll(filepath.Join(rootDir, "boot"), os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// Nothing exists.
+	missingPaths, err := findMissingRootfsPaths(rootDir)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"init (/sbin/init or /usr/lib/systemd/systemd)", "kernel (/boot/vmlinuz-*)"},
+		missingPaths)
+
+	// Dangling init symlink.
+	err = os.MkdirAll(filepath.Join(rootDir, "sbin"), os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = os.Symlink("/usr/lib/systemd/systemd", filepath.Join(rootDir, "sbin/init"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	missingPaths, err = findMissingRootfsPaths(rootDir)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"init (/sbin/init or /usr/lib/systemd/systemd)", "kernel (/boot/vmlinuz-*)"},
+		missingPaths)
+
+	// Everything exists.
+	err = os.WriteFile(filepath.Join(rootDir, "usr/lib/systemd/systemd"), []byte{}, 0o755)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = os.WriteFile(filepath.Join(rootDir, "boot/vmlinuz-6.6.0"), []byte{}, 0o644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	missingPaths, err = findMissingRootfsPaths(rootDir)
+	assert.NoError(t, err)
+	assert.Empty(t, missingPaths)
+}
+
+func TestPathExistsInRootSymlinkLoop(t *testing.T) {
+	rootDir := filepath.Join(tmpDir, "TestPathExistsInRootSymlinkLoop")
+
+	err := os.MkdirAll(rootDir, os.ModePerm)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = os.Symlink("/loop", filepath.Join(rootDir, "loop"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, err = pathExistsInRoot(rootDir, "/loop")
+	assert.ErrorContains(t, err, "too many levels of symlinks")
+}